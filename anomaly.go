@@ -0,0 +1,164 @@
+package webserver
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type AnomalyOptions struct {
+	// Window is the period error rates are computed over, a window is evaluated with the first request after it ended
+	Window time.Duration
+	// BaselineWindows is the number of windows the baseline error rate of a route is averaged over
+	BaselineWindows int
+	// Multiplier times the baseline is the error rate reported as spike
+	Multiplier float64
+	// MinRequests a window needs to be evaluated and to update the baseline
+	MinRequests int64
+	// MinRate is the lowest error rate reported as spike, so routes with a baseline of 0 do not report single errors
+	MinRate float64
+	// OnAnomaly is called for every spike, without it spikes are logged as warning
+	OnAnomaly func(anomaly Anomaly)
+}
+
+func NewAnomalyOptions() *AnomalyOptions {
+	return &AnomalyOptions{
+		Window:          time.Minute,
+		BaselineWindows: 15,
+		Multiplier:      3,
+		MinRequests:     20,
+		MinRate:         0.05,
+	}
+}
+
+// Anomaly is a window of a route whose 4xx or 5xx rate exceeded its baseline
+type Anomaly struct {
+	Method      string
+	Route       string
+	Class       string
+	WindowStart time.Time
+	Requests    int64
+	Rate        float64
+	Baseline    float64
+}
+
+func (anomaly Anomaly) String() string {
+	return anomaly.Class + " rate " + formatPercent(anomaly.Rate) + " on " + anomaly.Method + " " + anomaly.Route +
+		" (baseline " + formatPercent(anomaly.Baseline) + ", " + strconv.FormatInt(anomaly.Requests, 10) + " requests)"
+}
+
+func formatPercent(rate float64) string {
+	return strconv.FormatFloat(rate*100, 'f', 1, 64) + "%"
+}
+
+type anomalyDetector struct {
+	options AnomalyOptions
+
+	mutex  sync.Mutex
+	routes map[string]*routeErrors
+}
+
+type routeErrors struct {
+	windowStart time.Time
+	requests    int64
+	errors      [2]int64
+	baseline    [2]float64
+	windows     int
+}
+
+var anomalyClasses = [2]string{"4xx", "5xx"}
+
+// EnableAnomalyDetection tracks the 4xx and 5xx rates of every route and reports windows exceeding the baseline of the route
+func (webServer *WebServer) EnableAnomalyDetection(options AnomalyOptions) {
+	if options.Window <= 0 {
+		options.Window = time.Minute
+	}
+	if options.BaselineWindows < 1 {
+		options.BaselineWindows = 1
+	}
+	webServer.anomalies = &anomalyDetector{options: options, routes: map[string]*routeErrors{}}
+}
+
+func (webServer *WebServer) detectAnomaly(req *http.Request, status int, now time.Time) {
+	method, route := webServer.routeLabel(req)
+	anomalies := webServer.anomalies.record(method, route, status, now)
+	for _, anomaly := range anomalies {
+		if webServer.anomalies.options.OnAnomaly != nil {
+			webServer.anomalies.options.OnAnomaly(anomaly)
+		} else {
+			webServer.logger.Println("Anomaly: WARN: " + anomaly.String())
+		}
+	}
+}
+
+func (detector *anomalyDetector) record(method string, route string, status int, now time.Time) []Anomaly {
+	windowStart := now.Truncate(detector.options.Window)
+
+	detector.mutex.Lock()
+	defer detector.mutex.Unlock()
+
+	key := method + " " + route
+	errors, ok := detector.routes[key]
+	if !ok {
+		errors = &routeErrors{windowStart: windowStart}
+		detector.routes[key] = errors
+	}
+
+	var anomalies []Anomaly
+	if !errors.windowStart.Equal(windowStart) {
+		anomalies = detector.evaluate(method, route, errors)
+		*errors = routeErrors{windowStart: windowStart, baseline: errors.baseline, windows: errors.windows}
+	}
+
+	errors.requests++
+	switch {
+	case status >= 500:
+		errors.errors[1]++
+	case status >= 400:
+		errors.errors[0]++
+	}
+	return anomalies
+}
+
+// evaluate must be called with the mutex held, it reports the finished window and folds it into the baseline
+func (detector *anomalyDetector) evaluate(method string, route string, errors *routeErrors) []Anomaly {
+	if errors.requests < detector.options.MinRequests {
+		return nil
+	}
+
+	var anomalies []Anomaly
+	for class := range anomalyClasses {
+		rate := float64(errors.errors[class]) / float64(errors.requests)
+		baseline := errors.baseline[class]
+		if errors.windows > 0 && rate >= detector.options.MinRate && rate > baseline*detector.options.Multiplier {
+			anomalies = append(anomalies, Anomaly{
+				Method:      method,
+				Route:       route,
+				Class:       anomalyClasses[class],
+				WindowStart: errors.windowStart,
+				Requests:    errors.requests,
+				Rate:        rate,
+				Baseline:    baseline,
+			})
+		}
+
+		// moving average over BaselineWindows, the first windows are averaged equally
+		weight := 1 / float64(min(errors.windows+1, detector.options.BaselineWindows))
+		errors.baseline[class] = baseline + (rate-baseline)*weight
+	}
+	errors.windows++
+	return anomalies
+}
+
+// routeLabel returns the method and the pattern of the route matching req, methods without own mux are reported as OTHER
+func (webServer *WebServer) routeLabel(req *http.Request) (string, string) {
+	method := strings.ToUpper(req.Method)
+	_, route := webServer.methodMux(method).Handler(req)
+	if webServer.methodMux(method) == webServer.customMux {
+		// arbitrary methods would create unbounded tag values
+		method = "OTHER"
+	}
+	return method, route
+}
@@ -0,0 +1,55 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAnomalyDetection(t *testing.T) {
+	var anomalies []Anomaly
+	options := NewAnomalyOptions()
+	options.MinRequests = 10
+	options.OnAnomaly = func(anomaly Anomaly) {
+		anomalies = append(anomalies, anomaly)
+	}
+	webServer := NewWebServer(*NewSettings())
+	webServer.NewHandleFunc(HTTPMethodGet, "/orders/{id}", func(rw http.ResponseWriter, req *http.Request) {})
+	webServer.EnableAnomalyDetection(*options)
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	window := func(minute int, requests int, serverErrors int, clientErrors int) {
+		for i := 0; i < requests; i++ {
+			status := http.StatusOK
+			if i < serverErrors {
+				status = http.StatusInternalServerError
+			} else if i < serverErrors+clientErrors {
+				status = http.StatusNotFound
+			}
+			req := httptest.NewRequest(http.MethodGet, "/orders/"+string(rune('a'+i%26)), nil)
+			webServer.detectAnomaly(req, status, start.Add(time.Duration(minute)*time.Minute))
+		}
+	}
+
+	window(0, 20, 0, 1)
+	window(1, 20, 0, 1)
+	window(2, 5, 5, 0)   // too few requests to be evaluated
+	window(3, 20, 10, 1) // 50% 5xx
+	window(4, 20, 0, 8)  // 40% 4xx
+	window(5, 1, 0, 0)
+
+	if len(anomalies) != 2 {
+		t.Fatalf("anomalies %+v", anomalies)
+	}
+	if a := anomalies[0]; a.Class != "5xx" || a.Route != "/orders/{id}" || a.Method != http.MethodGet || a.Rate != 0.5 || a.Baseline != 0 ||
+		!a.WindowStart.Equal(start.Add(3*time.Minute)) {
+		t.Errorf("5xx anomaly %+v", a)
+	}
+	if a := anomalies[1]; a.Class != "4xx" || a.Rate != 0.4 || a.Baseline <= 0 || a.Baseline > 0.1 {
+		t.Errorf("4xx anomaly %+v", a)
+	}
+	if s := anomalies[0].String(); s != "5xx rate 50.0% on GET /orders/{id} (baseline 0.0%, 20 requests)" {
+		t.Errorf("String() = %q", s)
+	}
+}
@@ -0,0 +1,223 @@
+package webserver
+
+import (
+	"html/template"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// RouteDoc documents a route on the page of APIDocsHandler
+type RouteDoc struct {
+	Summary     string
+	Description string
+	// Request is a value of the request type, e.g. CreateUser{}. Typed handlers like NewQueryHandler set it themselves.
+	Request any
+	// RequestEncoding is the encoding of Request: "query", "form", "multipart" or "json", the default
+	RequestEncoding string
+	// Response is a value of the json response type
+	Response any
+}
+
+// routeDoc is the documentation of a route, types are described by their fields
+type routeDoc struct {
+	summary     string
+	description string
+	request     reflect.Type
+	encoding    string
+	response    reflect.Type
+}
+
+// Document attaches doc to the route registered for method and pattern. Fields of the request and response types
+// are listed with the name of their encoding tag and the text of their `doc` tag.
+func (webServer *WebServer) Document(method HTTPMethod, pattern string, doc RouteDoc) {
+	webServer.documentRoute(string(method), pattern, func(current *routeDoc) {
+		current.summary = doc.Summary
+		current.description = doc.Description
+		if doc.Request != nil {
+			current.request = reflect.TypeOf(doc.Request)
+			current.encoding = doc.RequestEncoding
+		}
+		if doc.Response != nil {
+			current.response = reflect.TypeOf(doc.Response)
+		}
+	})
+}
+
+// documentRequest records the request type of a typed handler
+func (webServer *WebServer) documentRequest(method HTTPMethod, pattern string, encoding string, request reflect.Type) {
+	webServer.documentRoute(string(method), pattern, func(current *routeDoc) {
+		current.request = request
+		current.encoding = encoding
+	})
+}
+
+func (webServer *WebServer) documentRoute(method string, pattern string, update func(*routeDoc)) {
+	for i := len(webServer.routes) - 1; i >= 0; i-- {
+		if webServer.routes[i].method == method && webServer.routes[i].pattern == pattern {
+			update(&webServer.routes[i].doc)
+			return
+		}
+	}
+}
+
+type apiDocsField struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+type apiDocsRoute struct {
+	Host        string
+	Method      string
+	Pattern     string
+	Summary     string
+	Description string
+	Encoding    string
+	Request     []apiDocsField
+	Response    []apiDocsField
+}
+
+var apiDocsTemplate = template.Must(template.New("api").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>API Reference</title>
+</head>
+<body>
+<h1>API Reference</h1>
+{{- range .}}
+<section>
+    <h2><code>{{.Method}} {{if .Host}}{{.Host}}{{end}}{{.Pattern}}</code></h2>
+{{- if .Summary}}
+    <p><strong>{{.Summary}}</strong></p>
+{{- end}}
+{{- if .Description}}
+    <p>{{.Description}}</p>
+{{- end}}
+{{- if .Request}}
+    <h3>Request ({{.Encoding}})</h3>
+    <table>
+        <tr><th>Name</th><th>Type</th><th>Description</th></tr>
+{{- range .Request}}
+        <tr><td><code>{{.Name}}</code></td><td>{{.Type}}</td><td>{{.Description}}</td></tr>
+{{- end}}
+    </table>
+{{- end}}
+{{- if .Response}}
+    <h3>Response (json)</h3>
+    <table>
+        <tr><th>Name</th><th>Type</th><th>Description</th></tr>
+{{- range .Response}}
+        <tr><td><code>{{.Name}}</code></td><td>{{.Type}}</td><td>{{.Description}}</td></tr>
+{{- end}}
+    </table>
+{{- end}}
+</section>
+{{- end}}
+</body>
+</html>
+`))
+
+// APIDocsHandler serves an html reference of every route registered with NewHandler and the typed handlers,
+// including those of virtual hosts, with the documentation attached by Document.
+// Routes the server registers itself, like static files and mounts, are left out.
+func (webServer *WebServer) APIDocsHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		routes := webServer.apiDocsRoutes("")
+		for _, host := range webServer.sortedHosts() {
+			routes = append(routes, webServer.virtualHosts[host].apiDocsRoutes(host)...)
+		}
+
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := apiDocsTemplate.Execute(rw, routes)
+		if err != nil {
+			webServer.logger.Println("API Docs: " + err.Error())
+		}
+	})
+}
+
+func (webServer *WebServer) apiDocsRoutes(host string) []apiDocsRoute {
+	var routes []apiDocsRoute
+	for _, route := range webServer.routes {
+		if !route.documentable() {
+			continue
+		}
+		encoding := route.doc.encoding
+		if encoding == "" {
+			encoding = "json"
+		}
+		routes = append(routes, apiDocsRoute{
+			Host:        host,
+			Method:      route.method,
+			Pattern:     route.pattern,
+			Summary:     route.doc.summary,
+			Description: route.doc.description,
+			Encoding:    encoding,
+			Request:     apiDocsFields(route.doc.request, encodingTag(encoding), "", 0),
+			Response:    apiDocsFields(route.doc.response, "json", "", 0),
+		})
+	}
+	return routes
+}
+
+// documentable reports whether the route was registered by the user rather than by the server itself
+func (route route) documentable() bool {
+	if route.handler == "static files" || route.handler == "options" {
+		return false
+	}
+	for _, prefix := range []string{"mount ", "upload ", "sync ", "proxy "} {
+		if strings.HasPrefix(route.handler, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+func encodingTag(encoding string) string {
+	switch encoding {
+	case "multipart":
+		return "form"
+	case "query", "form":
+		return encoding
+	default:
+		return "json"
+	}
+}
+
+// apiDocsFields lists the fields of the struct t, fields of nested structs are prefixed with the name of their parent
+func apiDocsFields(t reflect.Type, tag string, prefix string, depth int) []apiDocsField {
+	if t == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType || t == fileHeaderType.Elem() {
+		return nil
+	}
+
+	var fields []apiDocsField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		if tagValue, ok := field.Tag.Lookup(tag); ok {
+			name, _, _ = strings.Cut(tagValue, ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+		}
+		fields = append(fields, apiDocsField{Name: prefix + name, Type: field.Type.String(), Description: field.Tag.Get("doc")})
+
+		if depth < 3 {
+			fields = append(fields, apiDocsFields(field.Type, tag, prefix+name+".", depth+1)...)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,66 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+type userSearch struct {
+	Name  string `query:"name" doc:"prefix of the user name"`
+	Limit int    `query:"limit"`
+}
+
+type userResponse struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name" doc:"display name"`
+	Address struct {
+		City string `json:"city"`
+	} `json:"address"`
+	Secret string `json:"-"`
+}
+
+func TestAPIDocsHandler(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	NewQueryHandler(webServer, HTTPMethodGet, "/users", func(rw http.ResponseWriter, req *http.Request, search userSearch, err error) {})
+	webServer.Document(HTTPMethodGet, "/users", RouteDoc{
+		Summary:     "Search users",
+		Description: "Returns <at most> limit users.",
+		Response:    []userResponse{},
+	})
+	webServer.NewHandleFunc(HTTPMethodDelete, "/users/{id}", func(rw http.ResponseWriter, req *http.Request) {})
+	webServer.MountFS("/assets", fstest.MapFS{}, *NewMountOptions())
+	webServer.VirtualHost("admin.example.com").NewHandleFunc(HTTPMethodPost, "/reset", func(rw http.ResponseWriter, req *http.Request) {})
+	webServer.NewHandler(HTTPMethodGet, "/docs", webServer.APIDocsHandler())
+
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Fatalf("%d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	for _, want := range []string{
+		"<code>GET /users</code>",
+		"<strong>Search users</strong>",
+		"Returns &lt;at most&gt; limit users.",
+		"Request (query)",
+		"<code>name</code></td><td>string</td><td>prefix of the user name</td>",
+		"<code>limit</code></td><td>int</td>",
+		"<code>name</code></td><td>string</td><td>display name</td>",
+		"<code>address.city</code>",
+		"<code>DELETE /users/{id}</code>",
+		"<code>POST admin.example.com/reset</code>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page does not contain %q:\n%s", want, body)
+		}
+	}
+	for _, unwanted := range []string{"/assets/", "Secret", "<code>GET /</code>"} {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("page contains %q", unwanted)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package webserver
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Principal is the authenticated client of a request
+type Principal struct {
+	Name string
+	// Scheme is the authentication scheme, e.g. Basic or Bearer
+	Scheme     string
+	Attributes map[string]any
+}
+
+// PrincipalFrom returns the principal an auth middleware authenticated the request as
+func PrincipalFrom(req *http.Request) (Principal, bool) {
+	return Get(req, principalKey)
+}
+
+func setPrincipal(req *http.Request, principal Principal) {
+	Set(req, principalKey, principal)
+}
+
+// BasicAuth returns a middleware accepting the users (name to password) with HTTP basic authentication.
+// Other requests are answered with 401 Unauthorized and a Basic challenge.
+// It is meant to be registered with NewPhaseMiddleware(PhaseAuth, ...).
+func BasicAuth(users map[string]string) func(http.ResponseWriter, *http.Request) bool {
+	hashes := map[string][32]byte{}
+	for name, password := range users {
+		hashes[name] = sha256.Sum256([]byte(password))
+	}
+
+	return func(rw http.ResponseWriter, req *http.Request) bool {
+		name, password, ok := req.BasicAuth()
+		if ok {
+			expected, known := hashes[name]
+			hash := sha256.Sum256([]byte(password))
+			if subtle.ConstantTimeCompare(hash[:], expected[:]) == 1 && known {
+				setPrincipal(req, Principal{Name: name, Scheme: "Basic"})
+				return true
+			}
+		}
+
+		rw.Header().Set("WWW-Authenticate", `Basic realm="Restricted", charset="UTF-8"`)
+		writeRequestError(rw, req, http.StatusUnauthorized)
+		return false
+	}
+}
+
+// BearerAuth returns a middleware accepting the bearer tokens validate accepts.
+// Other requests are answered with 401 Unauthorized and a Bearer challenge (RFC 6750).
+// It is meant to be registered with NewPhaseMiddleware(PhaseAuth, ...).
+func BearerAuth(validate func(token string) (Principal, bool)) func(http.ResponseWriter, *http.Request) bool {
+	return func(rw http.ResponseWriter, req *http.Request) bool {
+		token, ok := bearerToken(req)
+		if !ok {
+			rw.Header().Set("WWW-Authenticate", `Bearer realm="Restricted"`)
+			writeRequestError(rw, req, http.StatusUnauthorized)
+			return false
+		}
+
+		principal, ok := validate(token)
+		if !ok {
+			rw.Header().Set("WWW-Authenticate", `Bearer realm="Restricted", error="invalid_token"`)
+			writeRequestError(rw, req, http.StatusUnauthorized)
+			return false
+		}
+		if principal.Scheme == "" {
+			principal.Scheme = "Bearer"
+		}
+		setPrincipal(req, principal)
+		return true
+	}
+}
+
+func bearerToken(req *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -0,0 +1,63 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuth(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	webServer.NewHandleFunc(HTTPMethodGet, "/whoami", func(rw http.ResponseWriter, req *http.Request) {
+		principal, ok := PrincipalFrom(req)
+		if !ok {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = rw.Write([]byte(principal.Scheme + " " + principal.Name))
+	})
+	basic := BasicAuth(map[string]string{"alice": "secret"})
+	bearer := BearerAuth(func(token string) (Principal, bool) {
+		return Principal{Name: "service"}, token == "valid"
+	})
+	webServer.NewMiddleware(func(rw http.ResponseWriter, req *http.Request) bool {
+		if req.Header.Get("X-Auth") == "basic" {
+			return basic(rw, req)
+		}
+		return bearer(rw, req)
+	})
+
+	tests := []struct {
+		auth, user, password, token string
+		status                      int
+		body, challenge             string
+	}{
+		{"basic", "alice", "secret", "", http.StatusOK, "Basic alice", ""},
+		{"basic", "alice", "wrong", "", http.StatusUnauthorized, "", `Basic realm="Restricted", charset="UTF-8"`},
+		{"basic", "bob", "secret", "", http.StatusUnauthorized, "", `Basic realm="Restricted", charset="UTF-8"`},
+		{"basic", "", "", "", http.StatusUnauthorized, "", `Basic realm="Restricted", charset="UTF-8"`},
+		{"bearer", "", "", "valid", http.StatusOK, "Bearer service", ""},
+		{"bearer", "", "", "expired", http.StatusUnauthorized, "", `Bearer realm="Restricted", error="invalid_token"`},
+		{"bearer", "", "", "", http.StatusUnauthorized, "", `Bearer realm="Restricted"`},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+		req.Header.Set("X-Auth", test.auth)
+		if test.user != "" {
+			req.SetBasicAuth(test.user, test.password)
+		}
+		if test.token != "" {
+			req.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+
+		if rec.Code != test.status || rec.Body.String() != test.body {
+			t.Errorf("%s %s%s: %d %q, want %d %q", test.auth, test.user, test.token, rec.Code, rec.Body.String(), test.status, test.body)
+		}
+		if challenge := rec.Header().Get("WWW-Authenticate"); challenge != test.challenge {
+			t.Errorf("%s %s%s: WWW-Authenticate = %q, want %q", test.auth, test.user, test.token, challenge, test.challenge)
+		}
+	}
+}
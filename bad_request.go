@@ -1,11 +1,98 @@
 package webserver
 
-import "net/http"
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
 
+// BadRequest answers with 400 and msg as plain text, see RespondBadRequest for the error handler and API mode
 func (webServer *WebServer) BadRequest(rw http.ResponseWriter, msg string) {
 	rw.WriteHeader(http.StatusBadRequest)
 	_, err := rw.Write([]byte(msg))
 	if err != nil {
-		webServer.settings.Logger.Fatalln(err)
+		webServer.logger.Println("Bad Request: " + err.Error())
 	}
 }
+
+// RespondBadRequest answers with 400 and msg like RespondError, unless the error handler answers it
+func (webServer *WebServer) RespondBadRequest(rw http.ResponseWriter, req *http.Request, msg string, code ...string) {
+	if webServer.handleError(rw, req, errors.New(msg), http.StatusBadRequest) {
+		return
+	}
+	webServer.RespondError(rw, req, http.StatusBadRequest, msg, code...)
+}
+
+// APIError is the body of error responses in API mode, wrapped as {"error": {...}}
+type APIError struct {
+	Status int `json:"status"`
+	// Code is an optional machine readable code like "email_taken"
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+	// Fields are the field errors of a failed validation, see Validate
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// EnableAPIMode answers errors of requests below prefix, of the error responders like NotFound and of the server
+// itself, with a json APIError instead of plain text, unless an error page is set for the status
+func (webServer *WebServer) EnableAPIMode(prefix string) {
+	webServer.apiPrefixes = append(webServer.apiPrefixes, prefix)
+}
+
+func (webServer *WebServer) apiMode(req *http.Request) bool {
+	for _, prefix := range webServer.apiPrefixes {
+		if strings.HasPrefix(req.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RespondError answers with status and message, as json APIError with the optional code in API mode
+// and as plain text otherwise
+func (webServer *WebServer) RespondError(rw http.ResponseWriter, req *http.Request, status int, message string, code ...string) {
+	if !webServer.apiMode(req) {
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		rw.WriteHeader(status)
+		_, _ = rw.Write([]byte(message))
+		return
+	}
+
+	apiError := APIError{Status: status, Message: message}
+	if len(code) > 0 {
+		apiError.Code = code[0]
+	}
+	webServer.writeAPIError(rw, apiError)
+}
+
+func (webServer *WebServer) writeAPIError(rw http.ResponseWriter, apiError APIError) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(apiError.Status)
+	err := json.NewEncoder(rw).Encode(map[string]APIError{"error": apiError})
+	if err != nil {
+		webServer.logger.Println("API Error: " + err.Error())
+	}
+}
+
+func (webServer *WebServer) Unauthorized(rw http.ResponseWriter, req *http.Request, message string, code ...string) {
+	webServer.RespondError(rw, req, http.StatusUnauthorized, message, code...)
+}
+
+func (webServer *WebServer) Forbidden(rw http.ResponseWriter, req *http.Request, message string, code ...string) {
+	webServer.RespondError(rw, req, http.StatusForbidden, message, code...)
+}
+
+func (webServer *WebServer) NotFound(rw http.ResponseWriter, req *http.Request, message string, code ...string) {
+	webServer.RespondError(rw, req, http.StatusNotFound, message, code...)
+}
+
+func (webServer *WebServer) Conflict(rw http.ResponseWriter, req *http.Request, message string, code ...string) {
+	webServer.RespondError(rw, req, http.StatusConflict, message, code...)
+}
+
+// InternalError answers with 500 and logs err, the message keeps details of err from the client
+func (webServer *WebServer) InternalError(rw http.ResponseWriter, req *http.Request, err error, code ...string) {
+	Logger(req).Println("Internal Error: " + err.Error())
+	webServer.RespondError(rw, req, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), code...)
+}
@@ -0,0 +1,92 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/a-h/templ"
+)
+
+func TestAPIMode(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	webServer.EnableAPIMode("/api/")
+	conflict := func(rw http.ResponseWriter, req *http.Request) {
+		webServer.Conflict(rw, req, "email already registered", "email_taken")
+	}
+	webServer.NewHandleFunc(HTTPMethodPost, "/api/users", conflict)
+	webServer.NewHandleFunc(HTTPMethodPost, "/users", conflict)
+	type user struct {
+		Age int
+	}
+	NewURLBodyHandler(webServer, HTTPMethodPut, "/api/users", func(rw http.ResponseWriter, req *http.Request, body user) {})
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		contentType        string
+		response           string
+	}{
+		{http.MethodPost, "/api/users", "", http.StatusConflict, "application/json",
+			`{"error":{"status":409,"code":"email_taken","message":"email already registered"}}` + "\n"},
+		{http.MethodPost, "/users", "", http.StatusConflict, "text/plain; charset=utf-8", "email already registered"},
+		{http.MethodPut, "/api/users", "Age=old", http.StatusBadRequest, "application/json", `{"error":{"status":400,"message":"Age: invalid character`},
+		{http.MethodDelete, "/api/users", "", http.StatusMethodNotAllowed, "application/json",
+			`{"error":{"status":405,"message":"Method Not Allowed"}}` + "\n"},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(test.method, test.path, strings.NewReader(test.body)))
+		if rec.Code != test.status || rec.Header().Get("Content-Type") != test.contentType || !strings.HasPrefix(rec.Body.String(), test.response) {
+			t.Errorf("%s %s = %d %s %q, want %d %s %q", test.method, test.path, rec.Code, rec.Header().Get("Content-Type"), rec.Body.String(),
+				test.status, test.contentType, test.response)
+		}
+	}
+}
+
+func TestErrorRespondersUseErrorHandler(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	webServer.SetErrorHandler(func(rw http.ResponseWriter, req *http.Request, err error, status int) {
+		rw.WriteHeader(status)
+		_, _ = rw.Write([]byte("handled: " + err.Error()))
+	})
+	webServer.NewHandleFunc(HTTPMethodGet, "/bad", func(rw http.ResponseWriter, req *http.Request) {
+		webServer.RespondBadRequest(rw, req, "missing id")
+	})
+	NewFormBodyHandler(webServer, HTTPMethodPost, "/form", func(rw http.ResponseWriter, req *http.Request, values formTestValues) {})
+	type fragment struct {
+		Name string
+	}
+	broken := func(name string) templ.Component {
+		return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+			_, _ = w.Write([]byte("<p>" + name))
+			return errors.New("template failed")
+		})
+	}
+	NewHTMXTemplURLBodyHandler(webServer, broken, HTTPMethodPut, "/fragment", func(rw http.ResponseWriter, req *http.Request, body fragment) string {
+		return body.Name
+	})
+
+	tests := []struct {
+		method, path, contentType string
+		status                    int
+		body                      string
+	}{
+		{http.MethodGet, "/bad", "", http.StatusBadRequest, "handled: missing id"},
+		{http.MethodPost, "/form", "application/json", http.StatusUnsupportedMediaType, "handled: unsupported media type application/json"},
+		{http.MethodPut, "/fragment", "application/x-www-form-urlencoded", http.StatusInternalServerError, "handled: template failed"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader("Name=gopher"))
+		req.Header.Set("Content-Type", test.contentType)
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+		if rec.Code != test.status || rec.Body.String() != test.body {
+			t.Errorf("%s %s = %d %q, want %d %q", test.method, test.path, rec.Code, rec.Body.String(), test.status, test.body)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package webserver
+
+import (
+	"encoding"
+	"errors"
+	"mime/multipart"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BindError describes a value that could not be converted into the type of its struct field
+type BindError struct {
+	Field string
+	Value string
+	Err   error
+}
+
+func (err *BindError) Error() string {
+	return "invalid value " + strconv.Quote(err.Value) + " for " + err.Field + ": " + err.Err.Error()
+}
+
+func (err *BindError) Unwrap() error {
+	return err.Err
+}
+
+// BindErrors returns every BindError contained in err
+func BindErrors(err error) []*BindError {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var bindErrors []*BindError
+		for _, inner := range joined.Unwrap() {
+			bindErrors = append(bindErrors, BindErrors(inner)...)
+		}
+		return bindErrors
+	}
+
+	var bindError *BindError
+	if errors.As(err, &bindError) {
+		return []*BindError{bindError}
+	}
+	return nil
+}
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	fileHeaderType = reflect.TypeFor[*multipart.FileHeader]()
+	unmarshalType  = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+	timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+)
+
+// bindValues sets the exported fields of the struct dst points to from values.
+// The key of a field is the name in its tag, or the field name if the tag is missing; a tag of "-" skips the field.
+// Fields of type *multipart.FileHeader or []*multipart.FileHeader are set from files.
+func bindValues(dst any, tag string, values url.Values, files map[string][]*multipart.FileHeader) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return errors.New("bind target must be a pointer to a struct")
+	}
+	v = v.Elem()
+	t := v.Type()
+
+	var errs []error
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tagValue, ok := field.Tag.Lookup(tag); ok {
+			name, _, _ = strings.Cut(tagValue, ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+		}
+
+		switch field.Type {
+		case fileHeaderType:
+			if headers := files[name]; len(headers) > 0 {
+				v.Field(i).Set(reflect.ValueOf(headers[0]))
+			}
+			continue
+		case reflect.SliceOf(fileHeaderType):
+			if headers := files[name]; len(headers) > 0 {
+				v.Field(i).Set(reflect.ValueOf(headers))
+			}
+			continue
+		}
+
+		fieldValues, ok := values[name]
+		if !ok || len(fieldValues) == 0 {
+			continue
+		}
+
+		err := setField(v.Field(i), fieldValues)
+		if err != nil {
+			var bindError *BindError
+			if errors.As(err, &bindError) {
+				bindError.Field = name
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func setField(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 && !field.Type().Implements(unmarshalType) {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			err := setValue(slice.Index(i), value)
+			if err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+	return setValue(field, values[0])
+}
+
+func setValue(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Pointer {
+		ptr := reflect.New(field.Type().Elem())
+		err := setValue(ptr.Elem(), value)
+		if err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	if field.CanAddr() && field.Addr().Type().Implements(unmarshalType) && field.Type() != timeType {
+		err := field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+		if err != nil {
+			return &BindError{Value: value, Err: err}
+		}
+		return nil
+	}
+
+	var err error
+	switch {
+	case field.Type() == timeType:
+		var parsed time.Time
+		for _, layout := range timeLayouts {
+			parsed, err = time.Parse(layout, value)
+			if err == nil {
+				field.Set(reflect.ValueOf(parsed))
+				break
+			}
+		}
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	case field.Kind() == reflect.Bool:
+		var parsed bool
+		// html checkboxes submit "on"
+		if value == "on" {
+			parsed = true
+		} else {
+			parsed, err = strconv.ParseBool(value)
+		}
+		field.SetBool(parsed)
+	case field.CanInt() && field.Type() == reflect.TypeFor[time.Duration]():
+		var parsed time.Duration
+		parsed, err = time.ParseDuration(value)
+		field.SetInt(int64(parsed))
+	case field.CanInt():
+		var parsed int64
+		parsed, err = strconv.ParseInt(value, 10, field.Type().Bits())
+		field.SetInt(parsed)
+	case field.CanUint():
+		var parsed uint64
+		parsed, err = strconv.ParseUint(value, 10, field.Type().Bits())
+		field.SetUint(parsed)
+	case field.CanFloat():
+		var parsed float64
+		parsed, err = strconv.ParseFloat(value, field.Type().Bits())
+		field.SetFloat(parsed)
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Uint8:
+		field.SetBytes([]byte(value))
+	default:
+		err = errors.New("unsupported type " + field.Type().String())
+	}
+
+	if err != nil {
+		var numError *strconv.NumError
+		if errors.As(err, &numError) {
+			err = numError.Err
+		}
+		return &BindError{Value: value, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package webserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"html"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// Version, Commit and BuildTime describe the build and are meant to be set with
+// -ldflags "-X github.com/Nikkolix/webserver.Version=1.2.3 -X github.com/Nikkolix/webserver.Commit=..."
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+type BuildInfo struct {
+	Version   string
+	Commit    string
+	BuildTime string
+	GoVersion string
+}
+
+// ReadBuildInfo returns the build info set with ldflags, commit and build time fall back to the vcs info embedded by go build
+func ReadBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = setting.Value
+			}
+		}
+	}
+	return info
+}
+
+func (info BuildInfo) String() string {
+	s := info.Version
+	if info.Commit != "" {
+		commit := info.Commit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		s += " " + commit
+	}
+	if info.BuildTime != "" {
+		s += " " + info.BuildTime
+	}
+	return s
+}
+
+// EnableBuildInfo serves the build info as json at pattern, e.g. /version.
+// With injectHTML the file handler adds it as <meta name="build-info"> to the head of served html files.
+func (webServer *WebServer) EnableBuildInfo(pattern string, injectHTML bool) {
+	webServer.injectBuildInfo = injectHTML
+	webServer.NewHandleFunc(HTTPMethodGet, pattern, func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(rw).Encode(ReadBuildInfo())
+		if err != nil {
+			webServer.logger.Println("Build Info: " + err.Error())
+		}
+	})
+}
+
+// injectBuildInfoMeta inserts the meta tag before </head>, documents without head are returned unchanged
+func injectBuildInfoMeta(document []byte) []byte {
+	index := bytes.Index(bytes.ToLower(document), []byte("</head>"))
+	if index < 0 {
+		return document
+	}
+	meta := `<meta name="build-info" content="` + html.EscapeString(ReadBuildInfo().String()) + `">`
+	injected := make([]byte, 0, len(document)+len(meta))
+	injected = append(injected, document[:index]...)
+	injected = append(injected, meta...)
+	return append(injected, document[index:]...)
+}
@@ -0,0 +1,43 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBuildInfo(t *testing.T) {
+	version, commit := Version, Commit
+	defer func() { Version, Commit = version, commit }()
+	Version, Commit = "1.2.3", "0123456789abcdef"
+
+	settings := NewSettings()
+	settings.Root = "root"
+	webServer := NewWebServer(*settings)
+	webServer.EnableBuildInfo("/version", true)
+
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	var info BuildInfo
+	err := json.Unmarshal(rec.Body.Bytes(), &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != "1.2.3" || info.Commit != "0123456789abcdef" || info.GoVersion == "" {
+		t.Errorf("build info = %+v", info)
+	}
+
+	rec = httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/index.html", nil))
+	if !strings.Contains(rec.Body.String(), `<meta name="build-info" content="1.2.3 0123456789ab`) || !strings.Contains(rec.Body.String(), "TEST") {
+		t.Errorf("index.html = %s", rec.Body.String())
+	}
+
+	for _, document := range []string{"<p>no head</p>", ""} {
+		if injected := string(injectBuildInfoMeta([]byte(document))); injected != document {
+			t.Errorf("%q injected to %q", document, injected)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package webserver
+
+import (
+	"path"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// CachePolicy sets Cache-Control of the static files matching Pattern.
+// A Pattern with a "/" matches the url path like DenyPath, e.g. "/assets/**", one without matches the file name, e.g. "*.html".
+type CachePolicy struct {
+	Pattern string
+	Value   CachePreset
+}
+
+// SetCachePolicy serves static files matching pattern with Cache-Control preset, e.g.
+// webServer.SetCachePolicy("/assets/**", CacheImmutable) and webServer.SetCachePolicy("*.html", CacheRevalidate).
+// The first matching policy applies, setting a pattern again replaces its preset. A Cache-Control from _headers is kept.
+func (webServer *WebServer) SetCachePolicy(pattern string, preset CachePreset) {
+	webServer.settingsMutex.Lock()
+	defer webServer.settingsMutex.Unlock()
+
+	// copied so snapshots returned by Settings are not modified
+	policies := slices.Clone(webServer.settings.CachePolicies)
+	i := slices.IndexFunc(policies, func(policy CachePolicy) bool { return policy.Pattern == pattern })
+	if i >= 0 {
+		policies[i].Value = preset
+	} else {
+		policies = append(policies, CachePolicy{Pattern: pattern, Value: preset})
+	}
+	webServer.settings.CachePolicies = policies
+}
+
+// cachePolicy returns the Cache-Control of the first of policies matching urlPath, a directory path is matched as its index.html
+func cachePolicy(policies []CachePolicy, urlPath string) (CachePreset, bool) {
+	if len(policies) == 0 {
+		return "", false
+	}
+	if strings.HasSuffix(urlPath, "/") {
+		urlPath += "index.html"
+	}
+	urlPath = path.Clean("/" + urlPath)
+	for _, policy := range policies {
+		pattern := policy.Pattern
+		name := urlPath
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(urlPath)
+		}
+		if matchGlob(pattern, name) {
+			return policy.Value, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,63 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCachePolicy(t *testing.T) {
+	root, err := os.MkdirTemp(".", "cache-policy-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, name := range []string{"index.html", "about.html", "assets/app.js", "assets/fonts/a.woff2", "headers/x.css", "robots.txt"} {
+		err := os.MkdirAll(filepath.Join(root, filepath.Dir(name)), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(root, name), []byte(name), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = os.WriteFile(filepath.Join(root, "_headers"), []byte("/headers/*\n  Cache-Control: private\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings := NewSettings()
+	settings.Root = root
+	webServer := NewWebServer(*settings)
+	webServer.SetCachePolicy("/assets/**", CacheImmutable)
+	webServer.SetCachePolicy("*.html", CachePublicShort)
+	webServer.SetCachePolicy("*.html", CacheRevalidate)
+	webServer.SetCachePolicy("/headers/**", CacheNoStore)
+	if len(settings.CachePolicies) != 0 || len(webServer.Settings().CachePolicies) != 3 {
+		t.Fatalf("policies = %v", webServer.Settings().CachePolicies)
+	}
+
+	tests := []struct {
+		path, cacheControl string
+	}{
+		{"/", "no-cache"},
+		{"/about.html", "no-cache"},
+		{"/assets/app.js", "public, max-age=31536000, immutable"},
+		{"/assets/fonts/a.woff2", "public, max-age=31536000, immutable"},
+		{"/headers/x.css", "private"},
+		{"/robots.txt", ""},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s = %d", test.path, rec.Code)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != test.cacheControl {
+			t.Errorf("%s Cache-Control = %q, want %q", test.path, got, test.cacheControl)
+		}
+	}
+}
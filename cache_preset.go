@@ -0,0 +1,73 @@
+package webserver
+
+import (
+	"net/http"
+)
+
+// CachePreset is a Cache-Control value, custom presets are created by conversion, e.g. CachePreset("public, max-age=3600")
+type CachePreset string
+
+const (
+	// CacheImmutable is for fingerprinted assets and other responses which never change
+	CacheImmutable CachePreset = "public, max-age=31536000, immutable"
+	// CacheNoStore is for responses with personal or always changing data
+	CacheNoStore CachePreset = "no-store"
+	// CachePrivateShort lets the browser, but no shared cache, reuse the response for a minute
+	CachePrivateShort CachePreset = "private, max-age=60"
+	// CachePublicShort lets every cache reuse the response for five minutes
+	CachePublicShort CachePreset = "public, max-age=300"
+	// CacheRevalidate lets caches store the response but requires revalidation before reuse
+	CacheRevalidate CachePreset = "no-cache"
+)
+
+// WithCache sets Cache-Control to preset on successful and redirect responses of handler, e.g.
+// webServer.NewHandler(HTTPMethodGet, "/api/config", WithCache(CachePrivateShort, handler)).
+// Error responses get no-store so failures are not cached, a Cache-Control set by handler itself is kept.
+func WithCache(preset CachePreset, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		handler.ServeHTTP(&cacheWriter{ResponseWriter: rw, preset: preset}, req)
+	})
+}
+
+// WithCacheFunc is WithCache for handler functions
+func WithCacheFunc(preset CachePreset, handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return WithCache(preset, http.HandlerFunc(handler)).ServeHTTP
+}
+
+type cacheWriter struct {
+	http.ResponseWriter
+	preset      CachePreset
+	wroteHeader bool
+}
+
+func (rw *cacheWriter) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.wroteHeader = true
+		if rw.Header().Get("Cache-Control") == "" {
+			if status >= 400 {
+				rw.Header().Set("Cache-Control", string(CacheNoStore))
+			} else {
+				rw.Header().Set("Cache-Control", string(rw.preset))
+			}
+		}
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *cacheWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+func (rw *cacheWriter) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+func (rw *cacheWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
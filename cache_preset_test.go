@@ -0,0 +1,45 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCachePresets(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	webServer.NewHandleFunc(HTTPMethodGet, "/logo", WithCacheFunc(CacheImmutable, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("logo"))
+	}))
+	webServer.NewHandler(HTTPMethodGet, "/me", WithCache(CachePrivateShort, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Has("fail") {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	})))
+	webServer.NewHandleFunc(HTTPMethodGet, "/own", WithCacheFunc(CacheNoStore, func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Cache-Control", "public, max-age=10")
+	}))
+	webServer.NewHandleFunc(HTTPMethodGet, "/custom", WithCacheFunc(CachePreset("public, max-age=3600"), func(rw http.ResponseWriter, req *http.Request) {
+		http.Redirect(rw, req, "/logo", http.StatusFound)
+	}))
+
+	tests := []struct {
+		path, cacheControl string
+	}{
+		{"/logo", "public, max-age=31536000, immutable"},
+		{"/me", "private, max-age=60"},
+		{"/me?fail", "no-store"},
+		{"/own", "public, max-age=10"},
+		{"/custom", "public, max-age=3600"},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if rec.Header().Get("Cache-Control") != test.cacheControl {
+			t.Errorf("%s: Cache-Control %q, want %q", test.path, rec.Header().Get("Cache-Control"), test.cacheControl)
+		}
+	}
+}
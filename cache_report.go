@@ -0,0 +1,228 @@
+package webserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// cacheReportMaxPages bounds the number of urls CacheReport requests
+const cacheReportMaxPages = 1000
+
+// compressionMinSize is the size from which CacheReport expects compressible responses to be compressed
+const compressionMinSize = 1024
+
+var cssUrlPattern = regexp.MustCompile(`url\(\s*['"]?([^'")]+)['"]?\s*\)`)
+
+// CacheReportEntry is the outcome of one url crawled by CacheReport
+type CacheReportEntry struct {
+	Path        string
+	Status      int
+	ContentType string
+	Size        int
+	// Issues lists what browsers and caches are missing, empty if the response is fine
+	Issues []string
+}
+
+func (entry CacheReportEntry) String() string {
+	if len(entry.Issues) == 0 {
+		return entry.Path + ": ok"
+	}
+	return entry.Path + ": " + strings.Join(entry.Issues, ", ")
+}
+
+// CacheReport serves the server on an ephemeral loopback port and crawls the site starting at "/", following the
+// links, scripts, stylesheets, images and css urls of the same host. Every response is checked for a Cache-Control
+// header and a validator (ETag or Last-Modified), compression of compressible responses from 1 KiB and a
+// Content-Type matching the file extension. Every crawled url is returned, those with issues are logged.
+// It is a diagnostic to run after changing the static settings, e.g. from a command line flag.
+func (webServer *WebServer) CacheReport(ctx context.Context) ([]CacheReportEntry, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	server := &http.Server{Handler: webServer.serverHandler()}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Close()
+
+	client := &http.Client{
+		Timeout: selfTestTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	base := &url.URL{Scheme: "http", Host: listener.Addr().String(), Path: "/"}
+
+	var entries []CacheReportEntry
+	queue := []string{"/"}
+	seen := map[string]bool{"/": true}
+	for len(queue) > 0 && len(entries) < cacheReportMaxPages {
+		if err := ctx.Err(); err != nil {
+			return entries, err
+		}
+		target := queue[0]
+		queue = queue[1:]
+
+		entry, links := crawlCacheReport(ctx, client, base, target)
+		if len(entry.Issues) > 0 {
+			webServer.logger.Println("Cache Report: " + entry.String())
+		}
+		entries = append(entries, entry)
+
+		for _, link := range links {
+			if !seen[link] {
+				seen[link] = true
+				queue = append(queue, link)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// crawlCacheReport requests target and returns its entry and the same host links of html and css responses
+func crawlCacheReport(ctx context.Context, client *http.Client, base *url.URL, target string) (CacheReportEntry, []string) {
+	entry := CacheReportEntry{Path: target}
+	page, err := base.Parse(target)
+	if err != nil {
+		entry.Issues = append(entry.Issues, err.Error())
+		return entry, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, page.String(), nil)
+	if err != nil {
+		entry.Issues = append(entry.Issues, err.Error())
+		return entry, nil
+	}
+	// set explicitly so the transport leaves the body compressed and Content-Encoding can be checked
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	res, err := client.Do(req)
+	if err != nil {
+		entry.Issues = append(entry.Issues, err.Error())
+		return entry, nil
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		entry.Issues = append(entry.Issues, err.Error())
+		return entry, nil
+	}
+	if res.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err == nil {
+			body, err = io.ReadAll(reader)
+		}
+		if err != nil {
+			entry.Issues = append(entry.Issues, "invalid gzip body: "+err.Error())
+			return entry, nil
+		}
+	}
+
+	entry.Status = res.StatusCode
+	entry.ContentType = res.Header.Get("Content-Type")
+	entry.Size = len(body)
+	mediaType, _, _ := mime.ParseMediaType(entry.ContentType)
+
+	switch {
+	case res.StatusCode >= 300 && res.StatusCode < 400:
+		location, err := page.Parse(res.Header.Get("Location"))
+		if err == nil && location.Host == base.Host {
+			return entry, []string{location.RequestURI()}
+		}
+		return entry, nil
+	case res.StatusCode >= 400:
+		entry.Issues = append(entry.Issues, "status "+strconv.Itoa(res.StatusCode))
+		return entry, nil
+	}
+
+	if res.Header.Get("Cache-Control") == "" {
+		entry.Issues = append(entry.Issues, "missing Cache-Control")
+	}
+	if res.Header.Get("ETag") == "" && res.Header.Get("Last-Modified") == "" {
+		entry.Issues = append(entry.Issues, "missing ETag or Last-Modified")
+	}
+	if compressible(mediaType) && entry.Size >= compressionMinSize && res.Header.Get("Content-Encoding") == "" {
+		entry.Issues = append(entry.Issues, "not compressed")
+	}
+	if mediaType == "" {
+		entry.Issues = append(entry.Issues, "missing Content-Type")
+	} else if extension := path.Ext(page.Path); extension != "" {
+		want, _, _ := mime.ParseMediaType(getMimeType(extension[1:]))
+		if want != "application/octet-stream" && want != mediaType {
+			entry.Issues = append(entry.Issues, "Content-Type "+mediaType+", want "+want)
+		}
+	}
+
+	var links []string
+	switch mediaType {
+	case "text/html":
+		links = htmlLinks(body)
+	case "text/css":
+		for _, match := range cssUrlPattern.FindAllSubmatch(body, -1) {
+			links = append(links, string(match[1]))
+		}
+	}
+	var sameHost []string
+	for _, link := range links {
+		resolved, err := page.Parse(strings.TrimSpace(link))
+		if err != nil || resolved.Host != base.Host || (resolved.Scheme != "http" && resolved.Scheme != "https") {
+			continue
+		}
+		sameHost = append(sameHost, resolved.RequestURI())
+	}
+	return entry, sameHost
+}
+
+// htmlLinks returns the href and src attributes of the elements loading or linking resources
+func htmlLinks(body []byte) []string {
+	var links []string
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return links
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			var attribute string
+			switch string(name) {
+			case "a", "link":
+				attribute = "href"
+			case "script", "img", "source", "iframe":
+				attribute = "src"
+			default:
+				continue
+			}
+			for hasAttr {
+				var key, value []byte
+				key, value, hasAttr = tokenizer.TagAttr()
+				if string(key) == attribute && len(value) > 0 {
+					links = append(links, string(value))
+				}
+			}
+		}
+	}
+}
+
+// compressible reports whether responses of mediaType shrink noticeably when compressed
+func compressible(mediaType string) bool {
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	switch mediaType {
+	case "application/javascript", "application/json", "application/xml", "image/svg+xml", "application/wasm":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
@@ -0,0 +1,66 @@
+package webserver
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCacheReport(t *testing.T) {
+	root, err := os.MkdirTemp(".", "cache-report-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for name, content := range map[string]string{
+		"index.html":      `<a href="/docs/">Docs</a><a href="https://example.com/">External</a><link rel="stylesheet" href="style.css"><img src="missing.png">`,
+		"docs/index.html": `<script src="../app.js"></script><a href="#top">Top</a>`,
+		"style.css":       `body { background: url("bg.svg") }` + strings.Repeat(" ", compressionMinSize),
+		"bg.svg":          `<svg xmlns="http://www.w3.org/2000/svg"></svg>`,
+		"app.js":          `console.log("app")`,
+	} {
+		err := os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(root, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	settings := NewSettings()
+	settings.Root = root
+	webServer := NewWebServer(*settings)
+	webServer.NewMiddleware(func(rw http.ResponseWriter, req *http.Request) bool {
+		if strings.HasSuffix(req.URL.Path, ".js") {
+			rw.Header().Set("Cache-Control", string(CacheImmutable))
+		}
+		return true
+	})
+
+	entries, err := webServer.CacheReport(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string][]string{}
+	for _, entry := range entries {
+		got[entry.Path] = entry.Issues
+	}
+	want := map[string][]string{
+		"/":            {"missing Cache-Control"},
+		"/docs/":       {"missing Cache-Control"},
+		"/style.css":   {"missing Cache-Control", "not compressed"},
+		"/missing.png": {"status 404"},
+		"/bg.svg":      {"missing Cache-Control"},
+		"/app.js":      nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CacheReport() = %v, want %v", got, want)
+	}
+}
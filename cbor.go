@@ -0,0 +1,127 @@
+package webserver
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var errCBOR = errors.New("invalid cbor")
+
+// cborMaxDepth limits the nesting of decoded arrays and maps
+const cborMaxDepth = 16
+
+// decodeCBOR decodes the first CBOR (RFC 8949) item of data and returns it with the remaining bytes.
+// It supports the subset used by WebAuthn: integers as int64, byte strings as []byte, text strings as string,
+// arrays as []any, maps as map[any]any with int64 or string keys, booleans and null.
+// Indefinite lengths, tags and floats are rejected.
+func decodeCBOR(data []byte) (any, []byte, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (any, []byte, error) {
+	if depth > cborMaxDepth {
+		return nil, nil, fmt.Errorf("%w: nested too deep", errCBOR)
+	}
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("%w: unexpected end", errCBOR)
+	}
+	major, info := data[0]>>5, data[0]&0x1f
+	data = data[1:]
+
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22:
+			return nil, data, nil
+		}
+		return nil, nil, fmt.Errorf("%w: unsupported simple value %d", errCBOR, info)
+	}
+
+	var argument uint64
+	switch {
+	case info < 24:
+		argument = uint64(info)
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(data) < size {
+			return nil, nil, fmt.Errorf("%w: unexpected end", errCBOR)
+		}
+		switch size {
+		case 1:
+			argument = uint64(data[0])
+		case 2:
+			argument = uint64(binary.BigEndian.Uint16(data))
+		case 4:
+			argument = uint64(binary.BigEndian.Uint32(data))
+		case 8:
+			argument = binary.BigEndian.Uint64(data)
+		}
+		data = data[size:]
+	default:
+		return nil, nil, fmt.Errorf("%w: unsupported length encoding %d", errCBOR, info)
+	}
+
+	switch major {
+	case 0, 1:
+		if argument > 1<<63-1 {
+			return nil, nil, fmt.Errorf("%w: integer overflow", errCBOR)
+		}
+		if major == 1 {
+			return -1 - int64(argument), data, nil
+		}
+		return int64(argument), data, nil
+	case 2, 3:
+		if argument > uint64(len(data)) {
+			return nil, nil, fmt.Errorf("%w: unexpected end", errCBOR)
+		}
+		value := data[:argument]
+		if major == 3 {
+			return string(value), data[argument:], nil
+		}
+		return append([]byte{}, value...), data[argument:], nil
+	case 4:
+		if argument > uint64(len(data)) {
+			return nil, nil, fmt.Errorf("%w: unexpected end", errCBOR)
+		}
+		items := make([]any, 0, argument)
+		for i := uint64(0); i < argument; i++ {
+			var item any
+			var err error
+			item, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, data, nil
+	case 5:
+		if argument > uint64(len(data)) {
+			return nil, nil, fmt.Errorf("%w: unexpected end", errCBOR)
+		}
+		items := make(map[any]any, argument)
+		for i := uint64(0); i < argument; i++ {
+			var key, value any
+			var err error
+			key, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, fmt.Errorf("%w: unsupported map key %T", errCBOR, key)
+			}
+			value, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items[key] = value
+		}
+		return items, data, nil
+	}
+	return nil, nil, fmt.Errorf("%w: unsupported major type %d", errCBOR, major)
+}
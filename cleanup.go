@@ -0,0 +1,51 @@
+package webserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Defer registers fn to run once the response to req is complete, also if the handler panicked or the client
+// disconnected, e.g. to remove temporary files. The functions run in reverse order of registration like deferred calls.
+// For requests which did not pass the main handler fn runs once the request context is done.
+func Defer(req *http.Request, fn func()) {
+	state := stateOf(req)
+	if state == nil {
+		context.AfterFunc(req.Context(), fn)
+		return
+	}
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.cleanups = append(state.cleanups, fn)
+}
+
+// runCleanups runs the functions registered with Defer for req and removes the temporary files of a multipart form
+// a handler parsed, which net/http only does for the request it passed to the server
+func (webServer *WebServer) runCleanups(req *http.Request) {
+	if req.MultipartForm != nil {
+		err := req.MultipartForm.RemoveAll()
+		if err != nil {
+			webServer.logger.Println("Cleanup: " + err.Error())
+		}
+	}
+	state := stateOf(req)
+	state.mutex.Lock()
+	cleanups := state.cleanups
+	state.cleanups = nil
+	state.mutex.Unlock()
+
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		webServer.runCleanup(cleanups[i])
+	}
+}
+
+// runCleanup runs fn, a panic is logged so the remaining functions still run
+func (webServer *WebServer) runCleanup(fn func()) {
+	defer func() {
+		if value := recover(); value != nil {
+			webServer.logger.Println("Cleanup: panic: " + fmt.Sprint(value))
+		}
+	}()
+	fn()
+}
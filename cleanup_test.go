@@ -0,0 +1,106 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefer(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	var order []string
+	webServer.NewHandleFunc(HTTPMethodGet, "/cleanup", func(rw http.ResponseWriter, req *http.Request) {
+		Defer(req, func() { order = append(order, "first") })
+		Defer(req, func() { panic("cleanup failed") })
+		Defer(req, func() { order = append(order, "last") })
+		_, _ = rw.Write([]byte("ok"))
+		order = append(order, "handler")
+	})
+	webServer.NewHandleFunc(HTTPMethodGet, "/panic", func(rw http.ResponseWriter, req *http.Request) {
+		Defer(req, func() { order = append(order, "after panic") })
+		panic("handler failed")
+	})
+
+	webServer.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/cleanup", nil))
+	if strings.Join(order, ",") != "handler,last,first" {
+		t.Errorf("order = %v", order)
+	}
+
+	order = nil
+	func() {
+		defer func() {
+			if value := recover(); value != "handler failed" {
+				t.Errorf("recovered %v", value)
+			}
+		}()
+		webServer.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+	}()
+	if strings.Join(order, ",") != "after panic" {
+		t.Errorf("order after panic = %v", order)
+	}
+
+	// requests which did not pass the main handler run the function once their context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	Defer(httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx), func() { close(done) })
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Defer without main handler did not run")
+	}
+}
+
+type failingReader struct {
+	data string
+}
+
+func (reader *failingReader) Read(p []byte) (int, error) {
+	if reader.data == "" {
+		return 0, errors.New("connection reset")
+	}
+	n := copy(p, reader.data)
+	reader.data = reader.data[n:]
+	return n, nil
+}
+
+func TestUploadCleanup(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "cleanup-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	webServer := NewWebServer(*NewSettings())
+	options := NewMountOptions()
+	options.Uploads = true
+	webServer.Mount("/uploads", dir, *options)
+
+	req := httptest.NewRequest(http.MethodPut, "/uploads/file.txt", io.NopCloser(&failingReader{data: "partial"}))
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("failed upload = %d", rec.Code)
+	}
+	req = httptest.NewRequest(http.MethodPut, "/uploads/file.txt", strings.NewReader("complete"))
+	webServer.mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "file.txt" {
+		t.Errorf("left in upload dir: %v", entries)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "file.txt"))
+	if string(data) != "complete" {
+		t.Errorf("file.txt = %q", data)
+	}
+}
@@ -0,0 +1,93 @@
+package webserver
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+var clientIPKey = NewKey[string]("client ip")
+
+// trustedProxies are the parsed Settings.TrustedProxies
+type trustedProxies struct {
+	sources []string
+	nets    []*net.IPNet
+}
+
+// trustedProxies returns the parsed ranges of Settings.TrustedProxies, invalid ranges are logged and ignored
+func (webServer *WebServer) trustedProxies(settings Settings) []*net.IPNet {
+	trusted := webServer.trusted.Load()
+	if trusted != nil && slices.Equal(trusted.sources, settings.TrustedProxies) {
+		return trusted.nets
+	}
+	trusted = &trustedProxies{sources: slices.Clone(settings.TrustedProxies)}
+	for _, source := range settings.TrustedProxies {
+		nets, err := parseCIDRs([]string{source})
+		if err != nil {
+			webServer.logger.Println("Trusted Proxies: " + err.Error())
+			continue
+		}
+		trusted.nets = append(trusted.nets, nets...)
+	}
+	webServer.trusted.Store(trusted)
+	return trusted.nets
+}
+
+// resolveClientIP returns the address of the client, read from the Forwarded, X-Forwarded-For or X-Real-IP header
+// if the peer is a trusted proxy. The forwarded addresses are read from the right, skipping trusted proxies,
+// as clients can prepend to them.
+func resolveClientIP(req *http.Request, trusted []*net.IPNet) string {
+	ip := peerIP(req)
+	if !containsIP(trusted, net.ParseIP(ip)) {
+		return ip
+	}
+
+	var hops []string
+	if forwarded := req.Header.Values("Forwarded"); len(forwarded) > 0 {
+		hops = forwardedFor(strings.Join(forwarded, ","))
+	} else if forwardedFor := req.Header.Values("X-Forwarded-For"); len(forwardedFor) > 0 {
+		hops = strings.Split(strings.Join(forwardedFor, ","), ",")
+	} else if realIP := req.Header.Get("X-Real-IP"); realIP != "" {
+		hops = []string{realIP}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop.String()
+		if !containsIP(trusted, hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// forwardedFor returns the addresses of the for parameters of a Forwarded header (RFC 7239) without port
+func forwardedFor(header string) []string {
+	var hops []string
+	for _, element := range strings.Split(header, ",") {
+		for _, pair := range strings.Split(element, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+			if !strings.EqualFold(name, "for") {
+				continue
+			}
+			value = strings.Trim(value, `"`)
+			if host, _, err := net.SplitHostPort(value); err == nil {
+				value = host
+			}
+			hops = append(hops, strings.Trim(value, "[]"))
+		}
+	}
+	return hops
+}
+
+func peerIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,56 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	settings := NewSettings()
+	settings.TrustedProxies = []string{"10.0.0.0/8", "2001:db8::/32", "invalid"}
+	webServer := NewWebServer(*settings)
+	webServer.NewHandleFunc(HTTPMethodGet, "/ip", func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(ClientIP(req)))
+	})
+
+	tests := []struct {
+		remote string
+		header string
+		value  string
+		want   string
+	}{
+		{"198.51.100.1:1000", "", "", "198.51.100.1"},
+		{"198.51.100.1:1000", "X-Forwarded-For", "192.0.2.1", "198.51.100.1"},
+		{"10.0.0.1:1000", "", "", "10.0.0.1"},
+		{"10.0.0.1:1000", "X-Forwarded-For", "192.0.2.1", "192.0.2.1"},
+		{"10.0.0.1:1000", "X-Forwarded-For", "192.0.2.9, 192.0.2.1, 10.0.0.2", "192.0.2.1"},
+		{"10.0.0.1:1000", "X-Forwarded-For", "10.0.0.3, 10.0.0.2", "10.0.0.3"},
+		{"10.0.0.1:1000", "X-Forwarded-For", "garbage, 10.0.0.2", "10.0.0.2"},
+		{"10.0.0.1:1000", "X-Real-IP", "192.0.2.1", "192.0.2.1"},
+		{"10.0.0.1:1000", "Forwarded", `for=192.0.2.60;proto=http;by=203.0.113.43`, "192.0.2.60"},
+		{"10.0.0.1:1000", "Forwarded", `for=192.0.2.9, for="[2001:db8:cafe::17]:4711"`, "192.0.2.9"},
+		{"10.0.0.1:1000", "Forwarded", `for="[2001:db9::17]:4711"`, "2001:db9::17"},
+		{"[2001:db8::1]:1000", "X-Forwarded-For", "192.0.2.1", "192.0.2.1"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		req.RemoteAddr = test.remote
+		if test.header != "" {
+			req.Header.Set(test.header, test.value)
+		}
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+		if rec.Body.String() != test.want {
+			t.Errorf("%s %s: %q: ClientIP = %q, want %q", test.remote, test.header, test.value, rec.Body.String(), test.want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+	req.RemoteAddr = "10.0.0.1:1000"
+	req.Header.Set("X-Forwarded-For", "192.0.2.1")
+	if ip := ClientIP(req); ip != "10.0.0.1" {
+		t.Errorf("ClientIP outside of the server = %q, want the peer", ip)
+	}
+}
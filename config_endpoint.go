@@ -0,0 +1,77 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+)
+
+type ConfigEndpointOptions struct {
+	// Settings are the names of the Settings fields exposed, e.g. Hostname
+	Settings []string
+	// Env are the environment variables exposed, unset variables are left out
+	Env []string
+	// Values are exposed as they are
+	Values map[string]any
+	// Global is the window property a javascript endpoint assigns the configuration to
+	Global string
+}
+
+func NewConfigEndpointOptions() *ConfigEndpointOptions {
+	return &ConfigEndpointOptions{
+		Values: map[string]any{},
+		Global: "__CONFIG__",
+	}
+}
+
+// NewConfigEndpoint serves the allow-listed settings, environment variables and values at pattern, generated per request.
+// A pattern ending in .js serves a script assigning the configuration to window[Global], any other pattern serves json.
+// Single page applications served by the file handler can load it to receive runtime configuration without rebuilds.
+func (webServer *WebServer) NewConfigEndpoint(pattern string, options ConfigEndpointOptions) {
+	webServer.NewHandleFunc(HTTPMethodGet, pattern, func(rw http.ResponseWriter, req *http.Request) {
+		config := map[string]any{}
+		settings := reflect.ValueOf(webServer.Settings())
+		for _, name := range options.Settings {
+			field := settings.FieldByName(name)
+			if field.IsValid() {
+				config[name] = field.Interface()
+			}
+		}
+		for _, name := range options.Env {
+			value, ok := os.LookupEnv(name)
+			if ok {
+				config[name] = value
+			}
+		}
+		for name, value := range options.Values {
+			config[name] = value
+		}
+
+		data, err := json.Marshal(config)
+		if err != nil {
+			webServer.writeErrorCause(rw, req, http.StatusInternalServerError, err)
+			webServer.logger.Println("Config Endpoint: 500: " + err.Error())
+			return
+		}
+
+		rw.Header().Set("Cache-Control", "no-store")
+		if strings.HasSuffix(pattern, ".js") {
+			global, err := json.Marshal(options.Global)
+			if err != nil {
+				webServer.writeErrorCause(rw, req, http.StatusInternalServerError, err)
+				webServer.logger.Println("Config Endpoint: 500: " + err.Error())
+				return
+			}
+			rw.Header().Set("Content-Type", "text/javascript")
+			data = []byte("window[" + string(global) + "] = " + string(data) + ";\n")
+		} else {
+			rw.Header().Set("Content-Type", "application/json")
+		}
+		_, err = rw.Write(data)
+		if err != nil {
+			webServer.logger.Println("Config Endpoint: " + err.Error())
+		}
+	})
+}
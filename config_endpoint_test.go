@@ -0,0 +1,41 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConfigEndpoint(t *testing.T) {
+	t.Setenv("API_URL", "https://api.example.com")
+	t.Setenv("SECRET", "hidden")
+
+	settings := NewSettings()
+	settings.Hostname = "example.com"
+	webServer := NewWebServer(*settings)
+	options := NewConfigEndpointOptions()
+	options.Settings = []string{"Hostname", "Missing"}
+	options.Env = []string{"API_URL", "UNSET"}
+	options.Values["feature"] = true
+	webServer.NewConfigEndpoint("/config.json", *options)
+	webServer.NewConfigEndpoint("/config.js", *options)
+
+	tests := []struct {
+		path, contentType, body string
+	}{
+		{"/config.json", "application/json", `{"API_URL":"https://api.example.com","Hostname":"example.com","feature":true}`},
+		{"/config.js", "text/javascript", `window["__CONFIG__"] = {"API_URL":"https://api.example.com","Hostname":"example.com","feature":true};` + "\n"},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if rec.Code != http.StatusOK || rec.Body.String() != test.body {
+			t.Errorf("%s: %d %q, want %q", test.path, rec.Code, rec.Body.String(), test.body)
+		}
+		if contentType := rec.Header().Get("Content-Type"); contentType != test.contentType {
+			t.Errorf("%s: Content-Type = %q, want %q", test.path, contentType, test.contentType)
+		}
+	}
+}
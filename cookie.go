@@ -0,0 +1,104 @@
+package webserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var ErrCookiePrefix = errors.New("cookie breaks the requirements of its name prefix")
+
+const (
+	// cookiePrefixSecure requires the Secure attribute
+	cookiePrefixSecure = "__Secure-"
+	// cookiePrefixHost requires the Secure attribute, Path "/" and no Domain, so the cookie is bound to the host
+	cookiePrefixHost = "__Host-"
+)
+
+// CheckCookiePrefix returns an error wrapping ErrCookiePrefix if cookie breaks the requirements of a __Secure- or
+// __Host- name prefix, browsers reject such cookies
+func CheckCookiePrefix(cookie *http.Cookie) error {
+	var broken []string
+	if strings.HasPrefix(cookie.Name, cookiePrefixSecure) || strings.HasPrefix(cookie.Name, cookiePrefixHost) {
+		if !cookie.Secure {
+			broken = append(broken, "Secure is not set")
+		}
+	}
+	if strings.HasPrefix(cookie.Name, cookiePrefixHost) {
+		if cookie.Path != "/" {
+			broken = append(broken, "Path is not /")
+		}
+		if cookie.Domain != "" {
+			broken = append(broken, "Domain is set")
+		}
+	}
+	if len(broken) > 0 {
+		return fmt.Errorf("%w: %s: %s", ErrCookiePrefix, cookie.Name, strings.Join(broken, ", "))
+	}
+	return nil
+}
+
+// enforceCookiePrefix changes cookie to meet the requirements of its name prefix and returns the changes
+func enforceCookiePrefix(cookie *http.Cookie) []string {
+	var changes []string
+	if strings.HasPrefix(cookie.Name, cookiePrefixSecure) || strings.HasPrefix(cookie.Name, cookiePrefixHost) {
+		if !cookie.Secure {
+			cookie.Secure = true
+			changes = append(changes, "set Secure")
+		}
+	}
+	if strings.HasPrefix(cookie.Name, cookiePrefixHost) {
+		if cookie.Path != "/" {
+			cookie.Path = "/"
+			changes = append(changes, "set Path /")
+		}
+		if cookie.Domain != "" {
+			cookie.Domain = ""
+			changes = append(changes, "removed Domain")
+		}
+	}
+	return changes
+}
+
+// SetCookie sets cookie like http.SetCookie and enforces the requirements of a __Secure- or __Host- name prefix,
+// setting Secure and for __Host- Path "/" and removing Domain. Changed cookies and prefixed cookies set over
+// plain http, which browsers reject, are logged once per cookie name.
+func (webServer *WebServer) SetCookie(rw http.ResponseWriter, req *http.Request, cookie *http.Cookie) {
+	webServer.checkCookie(req, cookie, false)
+	http.SetCookie(rw, cookie)
+}
+
+// SetPartitionedCookie sets cookie like SetCookie with the Partitioned attribute (CHIPS), which stores the cookie
+// separately for every top level site embedding this one, e.g. for embedded widgets once third party cookies are
+// blocked. Partitioned requires Secure, it is set if missing.
+func (webServer *WebServer) SetPartitionedCookie(rw http.ResponseWriter, req *http.Request, cookie *http.Cookie) {
+	webServer.checkCookie(req, cookie, true)
+	value := cookie.String()
+	if value == "" {
+		webServer.logger.Println("Cookie: invalid cookie " + cookie.Name)
+		return
+	}
+	rw.Header().Add("Set-Cookie", value+"; Partitioned")
+}
+
+func (webServer *WebServer) checkCookie(req *http.Request, cookie *http.Cookie, partitioned bool) {
+	changes := enforceCookiePrefix(cookie)
+	if partitioned && !cookie.Secure {
+		cookie.Secure = true
+		changes = append(changes, "set Secure for Partitioned")
+	}
+	if len(changes) > 0 {
+		webServer.warnCookie(cookie.Name, "Cookie: "+cookie.Name+": "+strings.Join(changes, ", "))
+	}
+	if cookie.Secure && req.TLS == nil && !webServer.Settings().UseHttps && len(webServer.Settings().TrustedProxies) == 0 {
+		webServer.warnCookie(cookie.Name, "Cookie: "+cookie.Name+" is Secure but served over http, browsers reject it")
+	}
+}
+
+// warnCookie logs message once per cookie name
+func (webServer *WebServer) warnCookie(name string, message string) {
+	if _, warned := webServer.cookieWarnings.LoadOrStore(name+"\x00"+message, true); !warned {
+		webServer.logger.Println(message)
+	}
+}
@@ -0,0 +1,59 @@
+package webserver
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckCookiePrefix(t *testing.T) {
+	cookies := []struct {
+		cookie http.Cookie
+		valid  bool
+	}{
+		{http.Cookie{Name: "plain"}, true},
+		{http.Cookie{Name: "__Secure-id", Secure: true, Domain: "example.com"}, true},
+		{http.Cookie{Name: "__Secure-id"}, false},
+		{http.Cookie{Name: "__Host-id", Secure: true, Path: "/"}, true},
+		{http.Cookie{Name: "__Host-id", Secure: true, Path: "/app"}, false},
+		{http.Cookie{Name: "__Host-id", Secure: true, Path: "/", Domain: "example.com"}, false},
+	}
+	for _, test := range cookies {
+		err := CheckCookiePrefix(&test.cookie)
+		if (err == nil) != test.valid || err != nil && !errors.Is(err, ErrCookiePrefix) {
+			t.Errorf("CheckCookiePrefix(%s) = %v, valid %v", test.cookie.String(), err, test.valid)
+		}
+	}
+}
+
+func TestSetCookie(t *testing.T) {
+	var logs bytes.Buffer
+	settings := NewSettings()
+	settings.Logger = log.New(&logs, "", 0)
+	webServer := NewWebServer(*settings)
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		webServer.SetCookie(rec, httptest.NewRequest(http.MethodGet, "/", nil), &http.Cookie{Name: "__Host-id", Value: "1", Path: "/app", Domain: "example.com"})
+		header := rec.Header().Get("Set-Cookie")
+		if header != "__Host-id=1; Path=/; Secure" {
+			t.Errorf("Set-Cookie = %q", header)
+		}
+	}
+	if count := strings.Count(logs.String(), "Cookie: __Host-id: set Secure, set Path /, removed Domain"); count != 1 {
+		t.Errorf("changes logged %d times, want once:\n%s", count, logs.String())
+	}
+	if !strings.Contains(logs.String(), "Cookie: __Host-id is Secure but served over http") {
+		t.Errorf("missing plain http warning:\n%s", logs.String())
+	}
+
+	rec := httptest.NewRecorder()
+	webServer.SetPartitionedCookie(rec, httptest.NewRequest(http.MethodGet, "https://example.com/", nil), &http.Cookie{Name: "widget", Value: "2", SameSite: http.SameSiteNoneMode})
+	if header := rec.Header().Get("Set-Cookie"); header != "widget=2; Secure; SameSite=None; Partitioned" {
+		t.Errorf("partitioned Set-Cookie = %q", header)
+	}
+}
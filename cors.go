@@ -0,0 +1,177 @@
+package webserver
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+type CORSOptions struct {
+	// AllowedOrigins are exact origins like "https://app.example.com", patterns like "https://*.example.com" or "*" for every origin
+	AllowedOrigins []string
+	AllowedMethods []string
+	// AllowedHeaders are the request headers a client may send, empty allows every header a preflight asks for
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers scripts may read
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is sent as Access-Control-Max-Age so browsers reuse a preflight, browsers cap it (Chromium at 2 hours,
+	// Firefox at 24 hours). 0 omits the header which means 5 seconds, negative values disable the preflight cache.
+	MaxAge time.Duration
+	// PreflightCacheControl is sent with preflight responses, e.g. "public, max-age=600" so a CDN answers repeated preflights
+	PreflightCacheControl string
+}
+
+func NewCORSOptions(origins ...string) *CORSOptions {
+	return &CORSOptions{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{http.MethodGet, http.MethodHead, http.MethodPost},
+		AllowedHeaders: []string{},
+		ExposedHeaders: []string{},
+		MaxAge:         2 * time.Hour,
+	}
+}
+
+// CORSStats counts the preflight requests answered by the CORS middleware
+type CORSStats struct {
+	Preflights int64
+	// Rejected preflights came from origins or asked for methods or headers which are not allowed
+	Rejected int64
+}
+
+type cors struct {
+	options    CORSOptions
+	preflights atomic.Int64
+	rejected   atomic.Int64
+}
+
+// EnableCORS answers preflight requests and adds the CORS headers to responses for allowed origins.
+// It runs in PhaseSecurity since preflights carry no credentials and must not reach auth middleware.
+// Preflights are counted in CORSStats and, if a metrics exporter is set, as cors_preflights.
+func (webServer *WebServer) EnableCORS(options CORSOptions) {
+	c := &cors{options: options}
+	webServer.cors = c
+	webServer.NewPhaseMiddleware(PhaseSecurity, func(rw http.ResponseWriter, req *http.Request) bool {
+		return webServer.handleCORS(c, rw, req)
+	})
+}
+
+// CORSStats returns the preflight counters since EnableCORS
+func (webServer *WebServer) CORSStats() CORSStats {
+	if webServer.cors == nil {
+		return CORSStats{}
+	}
+	return CORSStats{Preflights: webServer.cors.preflights.Load(), Rejected: webServer.cors.rejected.Load()}
+}
+
+func (webServer *WebServer) handleCORS(c *cors, rw http.ResponseWriter, req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	requestMethod := req.Header.Get("Access-Control-Request-Method")
+	preflight := req.Method == http.MethodOptions && origin != "" && requestMethod != ""
+
+	header := rw.Header()
+	header.Add("Vary", "Origin")
+	if !preflight {
+		if origin != "" && c.allowsOrigin(origin) {
+			c.setOriginHeaders(header, origin)
+			if len(c.options.ExposedHeaders) > 0 {
+				header.Set("Access-Control-Expose-Headers", strings.Join(c.options.ExposedHeaders, ", "))
+			}
+		}
+		return true
+	}
+
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	c.preflights.Add(1)
+	requestHeaders := parseHeaderList(req.Header.Get("Access-Control-Request-Headers"))
+	allowed := c.allowsOrigin(origin) && c.allowsMethod(requestMethod) && c.allowsHeaders(requestHeaders)
+	if webServer.metrics != nil {
+		webServer.metrics.Count("cors_preflights", 1, map[string]string{"allowed": strconv.FormatBool(allowed)})
+	}
+	if !allowed {
+		c.rejected.Add(1)
+		rw.WriteHeader(http.StatusNoContent)
+		webServer.logger.Println("CORS: preflight rejected: " + origin + " " + requestMethod + " " + req.URL.Path)
+		return false
+	}
+
+	c.setOriginHeaders(header, origin)
+	header.Set("Access-Control-Allow-Methods", strings.Join(c.options.AllowedMethods, ", "))
+	if len(requestHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(requestHeaders, ", "))
+	}
+	switch {
+	case c.options.MaxAge > 0:
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(c.options.MaxAge/time.Second)))
+	case c.options.MaxAge < 0:
+		header.Set("Access-Control-Max-Age", "0")
+	}
+	if c.options.PreflightCacheControl != "" {
+		header.Set("Cache-Control", c.options.PreflightCacheControl)
+	}
+	rw.WriteHeader(http.StatusNoContent)
+	return false
+}
+
+func (c *cors) setOriginHeaders(header http.Header, origin string) {
+	if slices.Contains(c.options.AllowedOrigins, "*") && !c.options.AllowCredentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.options.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *cors) allowsOrigin(origin string) bool {
+	for _, allowed := range c.options.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		if strings.Contains(allowed, "*") {
+			matched, err := path.Match(strings.ToLower(allowed), strings.ToLower(origin))
+			if err == nil && matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *cors) allowsMethod(method string) bool {
+	return slices.ContainsFunc(c.options.AllowedMethods, func(allowed string) bool {
+		return strings.EqualFold(allowed, method)
+	})
+}
+
+func (c *cors) allowsHeaders(headers []string) bool {
+	if len(c.options.AllowedHeaders) == 0 {
+		return true
+	}
+	for _, header := range headers {
+		if !slices.ContainsFunc(c.options.AllowedHeaders, func(allowed string) bool {
+			return strings.EqualFold(allowed, header)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseHeaderList(value string) []string {
+	headers := []string{}
+	for _, header := range strings.Split(value, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" {
+			headers = append(headers, header)
+		}
+	}
+	return headers
+}
@@ -0,0 +1,69 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	options := NewCORSOptions("https://app.example.com", "https://*.preview.example.com")
+	options.AllowedMethods = append(options.AllowedMethods, http.MethodDelete)
+	options.AllowedHeaders = []string{"Content-Type", "Authorization"}
+	options.ExposedHeaders = []string{"X-Total"}
+	options.AllowCredentials = true
+	options.MaxAge = 10 * time.Minute
+	options.PreflightCacheControl = "public, max-age=600"
+	webServer.EnableCORS(*options)
+	webServer.NewPhaseMiddleware(PhaseAuth, BearerAuth(func(token string) (Principal, bool) {
+		return Principal{Name: "user"}, token == "valid"
+	}))
+	webServer.NewHandleFunc(HTTPMethodDelete, "/items/{id}", func(rw http.ResponseWriter, req *http.Request) {})
+
+	tests := []struct {
+		method, origin, requestMethod, requestHeaders string
+		status                                        int
+		allowOrigin, maxAge, cacheControl             string
+	}{
+		{http.MethodOptions, "https://app.example.com", "DELETE", "authorization", http.StatusNoContent, "https://app.example.com", "600", "public, max-age=600"},
+		{http.MethodOptions, "https://pr-7.preview.example.com", "DELETE", "", http.StatusNoContent, "https://pr-7.preview.example.com", "600", "public, max-age=600"},
+		{http.MethodOptions, "https://evil.example.com", "DELETE", "", http.StatusNoContent, "", "", ""},
+		{http.MethodOptions, "https://app.example.com", "PUT", "", http.StatusNoContent, "", "", ""},
+		{http.MethodOptions, "https://app.example.com", "DELETE", "X-Debug", http.StatusNoContent, "", "", ""},
+		{http.MethodDelete, "https://app.example.com", "", "", http.StatusOK, "https://app.example.com", "", ""},
+		{http.MethodDelete, "https://evil.example.com", "", "", http.StatusOK, "", "", ""},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "/items/1", nil)
+		req.Header.Set("Origin", test.origin)
+		req.Header.Set("Authorization", "Bearer valid")
+		if test.requestMethod != "" {
+			req.Header.Del("Authorization")
+			req.Header.Set("Access-Control-Request-Method", test.requestMethod)
+		}
+		if test.requestHeaders != "" {
+			req.Header.Set("Access-Control-Request-Headers", test.requestHeaders)
+		}
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+
+		header := rec.Header()
+		if rec.Code != test.status || header.Get("Access-Control-Allow-Origin") != test.allowOrigin ||
+			header.Get("Access-Control-Max-Age") != test.maxAge || header.Get("Cache-Control") != test.cacheControl {
+			t.Errorf("%s %s %s: %d %v", test.method, test.origin, test.requestMethod, rec.Code, header)
+		}
+		if test.allowOrigin != "" && header.Get("Access-Control-Allow-Credentials") != "true" {
+			t.Errorf("%s %s: missing credentials header", test.method, test.origin)
+		}
+		if test.method == http.MethodDelete && test.allowOrigin != "" && header.Get("Access-Control-Expose-Headers") != "X-Total" {
+			t.Errorf("%s %s: expose headers %q", test.method, test.origin, header.Get("Access-Control-Expose-Headers"))
+		}
+	}
+
+	if stats := webServer.CORSStats(); stats.Preflights != 5 || stats.Rejected != 3 {
+		t.Errorf("stats %+v", stats)
+	}
+}
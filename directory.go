@@ -0,0 +1,99 @@
+package webserver
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// DirectoryEntry is one file or directory of a directory listing
+type DirectoryEntry struct {
+	Name    string    `json:"name"`
+	Dir     bool      `json:"dir"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+var directoryTemplate = template.Must(template.New("directory").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Index of {{.Path}}</title>
+</head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<table>
+    <tr><th>Name</th><th>Size</th><th>Modified</th></tr>
+{{- if ne .Path "/"}}
+    <tr><td><a href="../">../</a></td><td></td><td></td></tr>
+{{- end}}
+{{- range .Entries}}
+    <tr><td><a href="{{.Name}}{{if .Dir}}/{{end}}">{{.Name}}{{if .Dir}}/{{end}}</a></td><td>{{if not .Dir}}{{.Size}}{{end}}</td><td>{{.ModTime.UTC.Format "2006-01-02 15:04:05"}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// serveDirectoryListing renders the entries of dir as html or, if the client accepts json but not html, as json.
+// Dotfiles, files of the FileExtensionFilter or not of the AllowedExtensions and DenyPaths are left out.
+func (webServer *WebServer) serveDirectoryListing(rw http.ResponseWriter, req *http.Request, settings Settings, dir string) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		webServer.writeErrorCause(rw, req, http.StatusInternalServerError, err)
+		webServer.logger.Println("Directory Listing: 500: " + err.Error())
+		return
+	}
+
+	entries := []DirectoryEntry{}
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		parts := strings.Split(name, ".")
+		if strings.HasPrefix(name, ".") || (!dirEntry.IsDir() && slices.Contains(settings.FileExtensionFilter, parts[len(parts)-1])) {
+			continue
+		}
+		if !dirEntry.IsDir() && !allowedExtension(settings.AllowedExtensions, parts[len(parts)-1]) {
+			continue
+		}
+		if _, denied := deniedPath(settings.DenyPaths, path.Join(req.URL.Path, name)); denied {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		entry := DirectoryEntry{Name: name, Dir: info.IsDir(), ModTime: info.ModTime()}
+		if !entry.Dir {
+			entry.Size = info.Size()
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Dir != entries[j].Dir {
+			return entries[i].Dir
+		}
+		return entries[i].Name < entries[j].Name
+	})
+
+	accept := req.Header.Get("Accept")
+	if strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html") {
+		rw.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(rw).Encode(entries)
+	} else {
+		rw.Header().Set("Content-Type", "text/html")
+		err = directoryTemplate.Execute(rw, struct {
+			Path    string
+			Entries []DirectoryEntry
+		}{path.Clean(req.URL.Path), entries})
+	}
+	if err != nil {
+		webServer.logger.Println("Directory Listing: " + err.Error())
+	}
+}
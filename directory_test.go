@@ -0,0 +1,85 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDirectoryListing(t *testing.T) {
+	root, err := os.MkdirTemp(".", "directory-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(root) }()
+	for _, dir := range []string{"docs/guide", "site"} {
+		err = os.MkdirAll(filepath.Join(root, dir), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range map[string]string{
+		"docs/readme.txt": "hello",
+		"docs/.secret":    "hidden",
+		"docs/key.pem":    "filtered",
+		"site/index.html": "<p>index</p>",
+	} {
+		err = os.WriteFile(filepath.Join(root, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	settings := NewSettings()
+	settings.Root = root
+	settings.DirectoryListing = true
+	settings.FileExtensionFilter = []string{"pem"}
+	webServer := NewWebServer(*settings)
+
+	tests := []struct {
+		path, accept string
+		status       int
+		contains     []string
+		excludes     []string
+	}{
+		{"/docs", "", http.StatusMovedPermanently, nil, nil},
+		{"/docs/", "text/html", http.StatusOK, []string{"Index of /docs", `href="guide/"`, `href="readme.txt"`, "<td>5</td>"}, []string{".secret", "key.pem"}},
+		{"/docs/", "application/json", http.StatusOK, []string{`"name":"guide","dir":true`, `"name":"readme.txt","dir":false,"size":5`}, []string{".secret"}},
+		{"/site/", "", http.StatusOK, []string{"<p>index</p>"}, []string{"Index of"}},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		req.Header.Set("Accept", test.accept)
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("%s %s: status %d, want %d", test.path, test.accept, rec.Code, test.status)
+		}
+		for _, s := range test.contains {
+			if !strings.Contains(rec.Body.String(), s) {
+				t.Errorf("%s %s: body misses %q: %s", test.path, test.accept, s, rec.Body.String())
+			}
+		}
+		for _, s := range test.excludes {
+			if strings.Contains(rec.Body.String(), s) {
+				t.Errorf("%s %s: body contains %q", test.path, test.accept, s)
+			}
+		}
+	}
+
+	var entries []DirectoryEntry
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/docs/", nil)
+	req.Header.Set("Accept", "application/json")
+	webServer.mux.ServeHTTP(rec, req)
+	err = json.Unmarshal(rec.Body.Bytes(), &entries)
+	if err != nil || len(entries) != 2 || entries[0].Name != "guide" {
+		t.Errorf("entries = %v, %v", entries, err)
+	}
+}
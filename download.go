@@ -0,0 +1,75 @@
+package webserver
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// Attachment makes the browser download the response as filename instead of displaying it.
+// Non-ASCII filenames are sent as RFC 5987 filename* with an ASCII fallback for old clients.
+func Attachment(rw http.ResponseWriter, filename string) {
+	rw.Header().Set("Content-Disposition", contentDisposition("attachment", filename))
+}
+
+// Inline lets the browser display the response, filename is used if the user saves it
+func Inline(rw http.ResponseWriter, filename string) {
+	rw.Header().Set("Content-Disposition", contentDisposition("inline", filename))
+}
+
+func contentDisposition(disposition string, filename string) string {
+	filename = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, filename)
+	if filename == "" {
+		return disposition
+	}
+
+	fallback := strings.Map(func(r rune) rune {
+		if r > 0x7e || r == '"' || r == '\\' || r == '%' {
+			return '_'
+		}
+		return r
+	}, filename)
+	value := disposition + `; filename="` + fallback + `"`
+	if fallback != filename {
+		value += "; filename*=UTF-8''" + encodeExtValue(filename)
+	}
+	return value
+}
+
+// encodeExtValue percent-encodes every byte of value which is no attr-char of RFC 5987
+func encodeExtValue(value string) string {
+	const hex = "0123456789ABCDEF"
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			builder.WriteByte(c)
+		} else {
+			builder.WriteByte('%')
+			builder.WriteByte(hex[c>>4])
+			builder.WriteByte(hex[c&0xf])
+		}
+	}
+	return builder.String()
+}
+
+// isDownload reports whether the static file at urlPath is served as attachment by Settings.DownloadExtensions or Settings.DownloadPrefixes
+func isDownload(settings Settings, urlPath string, fileExtension string) bool {
+	if slices.Contains(settings.DownloadExtensions, strings.ToLower(fileExtension)) {
+		return true
+	}
+	return slices.ContainsFunc(settings.DownloadPrefixes, func(prefix string) bool {
+		return strings.HasPrefix(urlPath, prefix)
+	})
+}
+
+func downloadName(urlPath string) string {
+	return path.Base(path.Clean("/" + urlPath))
+}
@@ -0,0 +1,45 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		filename, want string
+	}{
+		{"report.pdf", `attachment; filename="report.pdf"`},
+		{"Übersicht 2024.pdf", `attachment; filename="_bersicht 2024.pdf"; filename*=UTF-8''%C3%9Cbersicht%202024.pdf`},
+		{`say "hi".txt`, `attachment; filename="say _hi_.txt"; filename*=UTF-8''say%20%22hi%22.txt`},
+		{"it's\r\n.txt", `attachment; filename="it's.txt"`},
+		{"", "attachment"},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		Attachment(rec, test.filename)
+		if got := rec.Header().Get("Content-Disposition"); got != test.want {
+			t.Errorf("%q: %q, want %q", test.filename, got, test.want)
+		}
+	}
+
+	settings := NewSettings()
+	settings.Root = "root"
+	settings.DownloadExtensions = []string{"json"}
+	settings.DownloadPrefixes = []string{"/script"}
+	webServer := NewWebServer(*settings)
+
+	for path, want := range map[string]string{
+		"/test.json":  `attachment; filename="test.json"`,
+		"/script.js":  `attachment; filename="script.js"`,
+		"/style.css":  "",
+		"/index.html": "",
+	} {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if got := rec.Header().Get("Content-Disposition"); rec.Code != http.StatusOK || got != want {
+			t.Errorf("%s: %d %q, want %q", path, rec.Code, got, want)
+		}
+	}
+}
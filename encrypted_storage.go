@@ -0,0 +1,102 @@
+package webserver
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// encryptedFileMagic starts every file written by EncryptFile
+const encryptedFileMagic = "WSE1"
+
+var ErrKeyNotFound = errors.New("keyring: key not found")
+
+// Keyring holds the AES-256 keys of encrypted files by id, new files are encrypted with Current.
+// Old keys stay in the keyring so files encrypted before a rotation can still be read.
+type Keyring struct {
+	Current string
+	Keys    map[string][]byte
+}
+
+func (keyring Keyring) aead(id string) (cipher.AEAD, error) {
+	key, ok := keyring.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrKeyNotFound, id)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptFile encrypts file with the current key using AES-GCM, name is authenticated so a file cannot be moved to another name.
+// The result records the key id and can be decrypted with DecryptFile or served through NewEncryptedStorage.
+func EncryptFile(keyring Keyring, name string, file []byte) ([]byte, error) {
+	if len(keyring.Current) > 255 {
+		return nil, errors.New("keyring: key id longer than 255 bytes")
+	}
+	aead, err := keyring.aead(keyring.Current)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append([]byte(encryptedFileMagic), byte(len(keyring.Current)))
+	header = append(header, keyring.Current...)
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(append(header, nonce...), nonce, file, []byte(name)), nil
+}
+
+func DecryptFile(keyring Keyring, name string, encrypted []byte) ([]byte, error) {
+	if len(encrypted) < len(encryptedFileMagic)+1 || string(encrypted[:len(encryptedFileMagic)]) != encryptedFileMagic {
+		return nil, errors.New("keyring: " + name + " is not encrypted")
+	}
+	rest := encrypted[len(encryptedFileMagic):]
+	idLength := int(rest[0])
+	if len(rest) < 1+idLength {
+		return nil, errors.New("keyring: " + name + " is truncated")
+	}
+	aead, err := keyring.aead(string(rest[1 : 1+idLength]))
+	if err != nil {
+		return nil, err
+	}
+	rest = rest[1+idLength:]
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("keyring: " + name + " is truncated")
+	}
+	return aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(name))
+}
+
+type encryptedStorage struct {
+	storage StaticStorage
+	keyring Keyring
+}
+
+// NewEncryptedStorage decrypts the files of storage, which have to be written with EncryptFile, when they are served
+func NewEncryptedStorage(storage StaticStorage, keyring Keyring) StaticStorage {
+	return &encryptedStorage{storage: storage, keyring: keyring}
+}
+
+func (storage *encryptedStorage) ModTime(ctx context.Context, name string) (time.Time, error) {
+	modTimes, ok := storage.storage.(modTimeStorage)
+	if !ok {
+		return time.Time{}, errors.ErrUnsupported
+	}
+	return modTimes.ModTime(ctx, name)
+}
+
+func (storage *encryptedStorage) ReadFile(ctx context.Context, name string) ([]byte, error) {
+	encrypted, err := storage.storage.ReadFile(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return DecryptFile(storage.keyring, name, encrypted)
+}
@@ -0,0 +1,62 @@
+package webserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+type memoryStorage map[string][]byte
+
+func (storage memoryStorage) ReadFile(ctx context.Context, name string) ([]byte, error) {
+	return storage[name], nil
+}
+
+func TestEncryptedStorage(t *testing.T) {
+	keyring := Keyring{Current: "2024-01", Keys: map[string][]byte{
+		"2023-06": bytes.Repeat([]byte{1}, 32),
+		"2024-01": bytes.Repeat([]byte{2}, 32),
+	}}
+	old := Keyring{Current: "2023-06", Keys: keyring.Keys}
+
+	current, err := EncryptFile(keyring, "uploads/a.txt", []byte("current"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := EncryptFile(old, "uploads/b.txt", []byte("rotated"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(current, []byte("current")) {
+		t.Error("file is stored in plain text")
+	}
+
+	storage := NewEncryptedStorage(memoryStorage{
+		"uploads/a.txt": current,
+		"uploads/b.txt": rotated,
+		"uploads/c.txt": current,
+		"uploads/d.txt": []byte("plain"),
+	}, keyring)
+
+	tests := []struct {
+		name, file string
+		err        bool
+	}{
+		{"uploads/a.txt", "current", false},
+		{"uploads/b.txt", "rotated", false},
+		{"uploads/c.txt", "", true},
+		{"uploads/d.txt", "", true},
+	}
+	for _, test := range tests {
+		file, err := storage.ReadFile(context.Background(), test.name)
+		if (err != nil) != test.err || string(file) != test.file {
+			t.Errorf("%s: %q, %v", test.name, file, err)
+		}
+	}
+
+	_, err = DecryptFile(Keyring{Keys: map[string][]byte{}}, "uploads/a.txt", current)
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("missing key: %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package webserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// SetErrorPage renders page instead of an empty body when the file handler or the server itself answers with
+// status, e.g. 403, 404 or 500. page is the url path of a file below Root like "/errors/404.html", an http.Handler
+// or a func(http.ResponseWriter, *http.Request). The response keeps status whatever the handler writes.
+// A 404 page also replaces the fallback redirect for missing pages.
+func (webServer *WebServer) SetErrorPage(status int, page any) {
+	var handler http.Handler
+	switch page := page.(type) {
+	case string:
+		handler = webServer.errorPageFile(page)
+	case http.Handler:
+		handler = page
+	case func(http.ResponseWriter, *http.Request):
+		handler = http.HandlerFunc(page)
+	default:
+		panic(fmt.Sprintf("error page must be a file path or a handler, not %T", page))
+	}
+	if webServer.errorPages == nil {
+		webServer.errorPages = map[int]http.Handler{}
+	}
+	webServer.errorPages[status] = handler
+}
+
+// SetErrorHandler registers handler for the errors the server answers itself: unreadable, too large and undecodable
+// bodies, failed file reads, panics and the statuses error pages are rendered for. err is the cause, for statuses
+// without one like 404 it holds the status text. If handler writes no response, the error page or the default response
+// follows, so a handler may only report errors. With a handler panics are answered with 500 instead of aborting the connection.
+func (webServer *WebServer) SetErrorHandler(handler func(rw http.ResponseWriter, req *http.Request, err error, status int)) {
+	webServer.errorHandler = handler
+}
+
+// handleError passes err to the error handler and reports whether it wrote a response
+func (webServer *WebServer) handleError(rw http.ResponseWriter, req *http.Request, err error, status int) bool {
+	if webServer.errorHandler == nil {
+		return false
+	}
+	writer := &errorHandlerWriter{ResponseWriter: rw}
+	webServer.errorHandler(writer, req, err, status)
+	return writer.wrote
+}
+
+// writeError answers with status and the error page of status if one is set
+func (webServer *WebServer) writeError(rw http.ResponseWriter, req *http.Request, status int) {
+	webServer.writeErrorCause(rw, req, status, errors.New(http.StatusText(status)))
+}
+
+// writeRequestError is writeError for middleware created without a server, like Passwords.BasicAuth.
+// Requests which did not pass the main handler are answered with status only.
+func writeRequestError(rw http.ResponseWriter, req *http.Request, status int) {
+	webServer, ok := Get(req, serverKey)
+	if !ok {
+		rw.WriteHeader(status)
+		return
+	}
+	webServer.writeError(rw, req, status)
+}
+
+// writeErrorCause is writeError for an error with known cause, passed to the error handler
+func (webServer *WebServer) writeErrorCause(rw http.ResponseWriter, req *http.Request, status int, err error) {
+	if webServer.handleError(rw, req, err, status) {
+		return
+	}
+	page, ok := webServer.errorPages[status]
+	if !ok && webServer.apiMode(req) {
+		webServer.RespondError(rw, req, status, http.StatusText(status))
+		return
+	}
+	if !ok {
+		rw.WriteHeader(status)
+		return
+	}
+	writer := &errorPageWriter{ResponseWriter: rw, status: status}
+	page.ServeHTTP(writer, req)
+	writer.WriteHeader(status)
+}
+
+func (webServer *WebServer) errorPageFile(target string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		settings := webServer.Settings()
+		filePath, err := resolvePath(settings.Root, target, settings.BlockSymlinkEscape)
+		var file []byte
+		if err == nil {
+			file, err = os.ReadFile(filePath)
+		}
+		if err != nil {
+			webServer.logger.Println("Error Page: " + err.Error())
+			return
+		}
+
+		parts := strings.Split(target, ".")
+		rw.Header().Set("Content-Type", getMimeType(parts[len(parts)-1]))
+		_, err = rw.Write(file)
+		if err != nil {
+			webServer.logger.Println("Error Page: Write Error: " + err.Error())
+		}
+	})
+}
+
+// errorPageWriter writes the status of the error page once, regardless of the status the page handler writes
+type errorPageWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (writer *errorPageWriter) WriteHeader(int) {
+	if writer.wroteHeader {
+		return
+	}
+	writer.wroteHeader = true
+	writer.ResponseWriter.WriteHeader(writer.status)
+}
+
+func (writer *errorPageWriter) Write(b []byte) (int, error) {
+	writer.WriteHeader(writer.status)
+	return writer.ResponseWriter.Write(b)
+}
+
+// errorHandlerWriter records whether the error handler wrote a response
+type errorHandlerWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (writer *errorHandlerWriter) WriteHeader(status int) {
+	writer.wrote = true
+	writer.ResponseWriter.WriteHeader(status)
+}
+
+func (writer *errorHandlerWriter) Write(b []byte) (int, error) {
+	writer.wrote = true
+	return writer.ResponseWriter.Write(b)
+}
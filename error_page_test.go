@@ -0,0 +1,178 @@
+package webserver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestErrorPages(t *testing.T) {
+	root, err := os.MkdirTemp(".", "error-page-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for name, content := range map[string]string{
+		"index.html":      "index",
+		"secret.txt":      "secret",
+		"errors/404.html": "<h1>Not Found</h1>",
+	} {
+		err := os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(root, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	settings := NewSettings()
+	settings.Root = root
+	webServer := NewWebServer(*settings)
+	webServer.SetFileExtensionsFilter("txt")
+	webServer.NewHandleFunc(HTTPMethodPost, "/api", func(rw http.ResponseWriter, req *http.Request) {})
+	webServer.SetErrorPage(http.StatusNotFound, "/errors/404.html")
+	webServer.SetErrorPage(http.StatusForbidden, func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(rw, "forbidden: "+req.URL.Path)
+	})
+
+	tests := []struct {
+		method      string
+		path        string
+		status      int
+		contentType string
+		body        string
+	}{
+		{http.MethodGet, "/index.html", http.StatusOK, "text/html", "index"},
+		{http.MethodGet, "/missing.png", http.StatusNotFound, "text/html", "<h1>Not Found</h1>"},
+		{http.MethodGet, "/missing", http.StatusNotFound, "text/html", "<h1>Not Found</h1>"},
+		{http.MethodGet, "/secret.txt", http.StatusForbidden, "text/plain", "forbidden: /secret.txt"},
+		{http.MethodPost, "/missing", http.StatusNotFound, "text/html", "<h1>Not Found</h1>"},
+		{http.MethodPut, "/api", http.StatusMethodNotAllowed, "", ""},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(test.method, test.path, nil))
+		if rec.Code != test.status {
+			t.Errorf("%s %s: status %d, want %d", test.method, test.path, rec.Code, test.status)
+		}
+		if !strings.HasPrefix(rec.Header().Get("Content-Type"), test.contentType) {
+			t.Errorf("%s %s: Content-Type %q, want %q", test.method, test.path, rec.Header().Get("Content-Type"), test.contentType)
+		}
+		if rec.Body.String() != test.body {
+			t.Errorf("%s %s: body %q, want %q", test.method, test.path, rec.Body.String(), test.body)
+		}
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	settings := NewSettings()
+	settings.Root = "root"
+	webServer := NewWebServer(*settings)
+	webServer.NewHandleFunc(HTTPMethodGet, "/panic", func(rw http.ResponseWriter, req *http.Request) {
+		panic("boom")
+	})
+	type person struct {
+		Age int
+	}
+	NewURLBodyHandler(webServer, HTTPMethodPost, "/person", func(rw http.ResponseWriter, req *http.Request, body person) {})
+	webServer.SetErrorPage(http.StatusForbidden, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("forbidden page"))
+	})
+	webServer.SetFileExtensionsFilter("txt")
+
+	var reported []string
+	webServer.SetErrorHandler(func(rw http.ResponseWriter, req *http.Request, err error, status int) {
+		reported = append(reported, req.URL.Path)
+		// 403 is only reported, the error page renders it
+		if status == http.StatusForbidden {
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(status)
+		_, _ = rw.Write([]byte(`{"status":` + strconv.Itoa(status) + `,"error":` + strconv.Quote(err.Error()) + `}`))
+	})
+
+	requests := []struct {
+		method, path, body string
+		status             int
+		response           string
+	}{
+		{http.MethodGet, "/panic", "", http.StatusInternalServerError, `{"status":500,"error":"panic: boom"}`},
+		{http.MethodPost, "/person", "Age=abc", http.StatusBadRequest, `"error":"Age: invalid character`},
+		{http.MethodGet, "/missing.css", "", http.StatusNotFound, `{"status":404,"error":"Not Found"}`},
+		{http.MethodGet, "/secret.txt", "", http.StatusForbidden, "forbidden page"},
+	}
+	for _, request := range requests {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(request.method, request.path, strings.NewReader(request.body)))
+		if rec.Code != request.status || !strings.Contains(rec.Body.String(), request.response) {
+			t.Errorf("%s %s = %d %q, want %d %q", request.method, request.path, rec.Code, rec.Body.String(), request.status, request.response)
+		}
+	}
+	if len(reported) != len(requests) {
+		t.Errorf("reported %v, want every request", reported)
+	}
+}
+
+func TestFrameworkErrorsUseErrorHandler(t *testing.T) {
+	handled := func(rw http.ResponseWriter, req *http.Request, err error, status int) {
+		rw.WriteHeader(status)
+		_, _ = rw.Write([]byte("handled: " + err.Error()))
+	}
+	hello := func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("hello"))
+	}
+
+	webServer := NewWebServer(*NewSettings())
+	webServer.SetErrorHandler(handled)
+	webServer.SetRateLimit("/limited", *NewRateLimitOptions(0.001, 1))
+	jwtOptions := NewJWTOptions()
+	jwtOptions.HMACKey = []byte("secret")
+	webServer.RequireJWT("/api/", *jwtOptions)
+	webServer.NewHandleFunc(HTTPMethodGet, "/limited", hello)
+	webServer.NewHandleFunc(HTTPMethodGet, "/api/status", hello)
+
+	passwords := NewPasswords(NewBcryptHasher())
+	basicAuth := NewWebServer(*NewSettings())
+	basicAuth.SetErrorHandler(handled)
+	basicAuth.NewPhaseMiddleware(PhaseAuth, passwords.BasicAuth(NewMemoryCredentialStore()))
+	basicAuth.NewHandleFunc(HTTPMethodGet, "/private", hello)
+
+	tests := []struct {
+		webServer     *WebServer
+		path, bearer  string
+		status        int
+		body          string
+		header, value string
+	}{
+		{webServer, "/limited", "", http.StatusOK, "hello", "", ""},
+		{webServer, "/limited", "", http.StatusTooManyRequests, "handled: Too Many Requests", "Retry-After", "1000"},
+		{webServer, "/api/status", "", http.StatusUnauthorized, "handled: jwt: missing token", "WWW-Authenticate", `Bearer realm="Restricted"`},
+		{webServer, "/api/status", "a.b.c", http.StatusUnauthorized, "handled: jwt: ", "WWW-Authenticate", `Bearer realm="Restricted", error="invalid_token"`},
+		{basicAuth, "/private", "", http.StatusUnauthorized, "handled: Unauthorized", "WWW-Authenticate", `Basic realm="Restricted", charset="UTF-8"`},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if test.bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+test.bearer)
+		}
+		rec := httptest.NewRecorder()
+		test.webServer.mux.ServeHTTP(rec, req)
+		if rec.Code != test.status || !strings.HasPrefix(rec.Body.String(), test.body) {
+			t.Errorf("GET %s = %d %q, want %d %q", test.path, rec.Code, rec.Body.String(), test.status, test.body)
+		}
+		if test.header != "" && rec.Header().Get(test.header) != test.value {
+			t.Errorf("GET %s: %s = %q, want %q", test.path, test.header, rec.Header().Get(test.header), test.value)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package webserver
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event is emitted by the server on its event bus, see Subscribe
+type Event interface {
+	EventName() string
+}
+
+// ServerStarted is emitted once a listener serves, also after a reload bound a new one
+type ServerStarted struct {
+	Addr  string
+	Build BuildInfo
+}
+
+// RouteNotFound is emitted for requests neither a route nor a static file matched
+type RouteNotFound struct {
+	Method string
+	Path   string
+}
+
+// HandlerPanic is emitted before the panic of a handler or middleware reaches net/http
+type HandlerPanic struct {
+	Method string
+	Path   string
+	Value  any
+}
+
+// UpstreamDown is emitted when a proxy target could not be reached or its circuit breaker opened
+type UpstreamDown struct {
+	Target string
+	Err    error
+}
+
+// CertExpiring is emitted when a certificate expiring within 30 days is loaded
+type CertExpiring struct {
+	Subject  string
+	NotAfter time.Time
+}
+
+// AccountLocked is emitted when an identity is locked after repeated failed logins
+type AccountLocked struct {
+	Identity string
+	IP       string
+	Failures int
+	Until    time.Time
+}
+
+// LoginFailed is emitted for every failed login
+type LoginFailed struct {
+	Identity string
+	IP       string
+	Failures int
+}
+
+// SuspiciousLogin is emitted for a successful login from an ip address or device the identity did not log in from before
+type SuspiciousLogin struct {
+	Identity  string
+	IP        string
+	UserAgent string
+	NewIP     bool
+	NewDevice bool
+}
+
+// TwoFactorFailed is emitted when a TOTP or recovery code was wrong or reused
+type TwoFactorFailed struct {
+	Identity string
+	IP       string
+}
+
+// RecoveryCodeUsed is emitted when a recovery code was used instead of a TOTP code
+type RecoveryCodeUsed struct {
+	Identity  string
+	IP        string
+	Remaining int
+}
+
+// RateLimited is emitted when a request exceeded a rate limit
+type RateLimited struct {
+	IP   string
+	Key  string
+	Path string
+}
+
+// IPBlocked is emitted when an ip filter rejected a request
+type IPBlocked struct {
+	IP   string
+	Path string
+}
+
+// AuthFailed is emitted when a request was rejected for a missing or invalid token
+type AuthFailed struct {
+	Scheme string
+	IP     string
+	Path   string
+	Reason string
+}
+
+// HoneypotHit is emitted for requests to a honeypot path, see SetHoneypot
+type HoneypotHit struct {
+	IP        string
+	Method    string
+	Path      string
+	UserAgent string
+}
+
+// ResponseTooLarge is emitted when a response body exceeded Settings.MaxResponseSize
+type ResponseTooLarge struct {
+	Method  string
+	Path    string
+	Limit   int64
+	Written int64
+	Aborted bool
+}
+
+func (ServerStarted) EventName() string    { return "server_started" }
+func (RouteNotFound) EventName() string    { return "route_not_found" }
+func (HandlerPanic) EventName() string     { return "handler_panic" }
+func (UpstreamDown) EventName() string     { return "upstream_down" }
+func (CertExpiring) EventName() string     { return "cert_expiring" }
+func (AccountLocked) EventName() string    { return "account_locked" }
+func (LoginFailed) EventName() string      { return "login_failed" }
+func (SuspiciousLogin) EventName() string  { return "suspicious_login" }
+func (TwoFactorFailed) EventName() string  { return "two_factor_failed" }
+func (RecoveryCodeUsed) EventName() string { return "recovery_code_used" }
+func (RateLimited) EventName() string      { return "rate_limited" }
+func (IPBlocked) EventName() string        { return "ip_blocked" }
+func (AuthFailed) EventName() string       { return "auth_failed" }
+func (HoneypotHit) EventName() string      { return "honeypot_hit" }
+func (ResponseTooLarge) EventName() string { return "response_too_large" }
+
+const eventQueueSize = 256
+
+// eventBus delivers events on its own goroutine so subscribers never slow down request handling.
+// Events emitted while the queue is full are dropped.
+type eventBus struct {
+	mutex       sync.Mutex
+	subscribers map[int]func(Event)
+	next        int
+	queue       chan Event
+	dropped     atomic.Int64
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subscribers: map[int]func(Event){}}
+}
+
+// Subscribe calls handler for every event of the server and its virtual hosts until unsubscribe is called.
+// Handlers run one after another on a single goroutine in the order the events were emitted.
+func (webServer *WebServer) Subscribe(handler func(Event)) (unsubscribe func()) {
+	bus := webServer.events
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	if bus.queue == nil {
+		bus.queue = make(chan Event, eventQueueSize)
+		go bus.deliver()
+	}
+	id := bus.next
+	bus.next++
+	bus.subscribers[id] = handler
+	return func() {
+		bus.mutex.Lock()
+		defer bus.mutex.Unlock()
+		delete(bus.subscribers, id)
+	}
+}
+
+// On subscribes handler to the events of type T, e.g. On(webServer, func(event UpstreamDown) {...})
+func On[T Event](webServer *WebServer, handler func(T)) (unsubscribe func()) {
+	return webServer.Subscribe(func(event Event) {
+		if typed, ok := event.(T); ok {
+			handler(typed)
+		}
+	})
+}
+
+// DroppedEvents returns the number of events dropped because subscribers could not keep up
+func (webServer *WebServer) DroppedEvents() int64 {
+	return webServer.events.dropped.Load()
+}
+
+func (webServer *WebServer) emit(event Event) {
+	bus := webServer.events
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	if len(bus.subscribers) == 0 {
+		return
+	}
+	select {
+	case bus.queue <- event:
+	default:
+		bus.dropped.Add(1)
+	}
+}
+
+func (bus *eventBus) deliver() {
+	for event := range bus.queue {
+		bus.mutex.Lock()
+		handlers := make([]func(Event), 0, len(bus.subscribers))
+		for id := 0; id < bus.next; id++ {
+			if handler, ok := bus.subscribers[id]; ok {
+				handlers = append(handlers, handler)
+			}
+		}
+		bus.mutex.Unlock()
+
+		for _, handler := range handlers {
+			handler(event)
+		}
+	}
+}
+
+// recoverPanics emits HandlerPanic for a panicking request. With an error handler set, see SetErrorHandler, the panic
+// is answered with 500, otherwise it continues to net/http, which aborts the connection.
+func (webServer *WebServer) recoverPanics(rw http.ResponseWriter, req *http.Request) {
+	value := recover()
+	if value == nil {
+		return
+	}
+	if value == http.ErrAbortHandler {
+		panic(value)
+	}
+	webServer.emit(HandlerPanic{Method: req.Method, Path: req.URL.Path, Value: value})
+	if webServer.errorHandler == nil {
+		panic(value)
+	}
+
+	webServer.logger.Println("Panic: " + fmt.Sprint(value) + "\n" + string(debug.Stack()))
+	err, ok := value.(error)
+	if ok {
+		err = fmt.Errorf("panic: %w", err)
+	} else {
+		err = fmt.Errorf("panic: %v", value)
+	}
+	webServer.writeErrorCause(rw, req, http.StatusInternalServerError, err)
+}
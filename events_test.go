@@ -0,0 +1,85 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	settings := NewSettings()
+	settings.Root = "root"
+	webServer := NewWebServer(*settings)
+	webServer.NewHandleFunc(HTTPMethodGet, "/panic", func(rw http.ResponseWriter, req *http.Request) {
+		panic("boom")
+	})
+	target, _ := url.Parse("http://127.0.0.1:1")
+	webServer.NewProxyHandler("/api/", target, *NewProxyOptions())
+
+	events := make(chan Event, 16)
+	unsubscribe := webServer.Subscribe(func(event Event) {
+		events <- event
+	})
+	notFound := make(chan RouteNotFound, 16)
+	On(webServer, func(event RouteNotFound) {
+		notFound <- event
+	})
+
+	requests := []struct {
+		method, path string
+	}{
+		{http.MethodGet, "/missing.css"},
+		{http.MethodPost, "/nothing"},
+		{http.MethodGet, "/api/users"},
+		{http.MethodGet, "/panic"},
+		{http.MethodGet, "/index.html"},
+	}
+	for _, request := range requests {
+		func() {
+			defer func() { _ = recover() }()
+			webServer.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(request.method, request.path, nil))
+		}()
+	}
+
+	want := []Event{
+		RouteNotFound{Method: http.MethodGet, Path: "/missing.css"},
+		RouteNotFound{Method: http.MethodPost, Path: "/nothing"},
+		UpstreamDown{Target: "http://127.0.0.1:1"},
+		HandlerPanic{Method: http.MethodGet, Path: "/panic", Value: "boom"},
+	}
+	for _, expected := range want {
+		select {
+		case event := <-events:
+			if upstream, ok := event.(UpstreamDown); ok && upstream.Err != nil {
+				upstream.Err = nil
+				event = upstream
+			}
+			if event != expected {
+				t.Errorf("event %#v, want %#v", event, expected)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("missing event %#v", expected)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-notFound:
+			if event.EventName() != "route_not_found" {
+				t.Errorf("typed event %#v", event)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("missing typed RouteNotFound")
+		}
+	}
+
+	unsubscribe()
+	webServer.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing.js", nil))
+	<-notFound
+	select {
+	case event := <-events:
+		t.Errorf("event after unsubscribe %#v", event)
+	default:
+	}
+}
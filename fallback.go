@@ -0,0 +1,100 @@
+package webserver
+
+import (
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+type FallbackMode string
+
+const (
+	// FallbackModeRedirect redirects to the target url path with 307
+	FallbackModeRedirect FallbackMode = "redirect"
+	// FallbackModeSPA serves the target file (default index.html) with 200 so client side routing can take over
+	FallbackModeSPA FallbackMode = "spa"
+	// FallbackModeNotFound serves the target file (default 404.html) with 404
+	FallbackModeNotFound FallbackMode = "404"
+)
+
+type fallbackRule struct {
+	prefix string
+	mode   FallbackMode
+	target string
+}
+
+// SetFallback overrides the fallback for missing pages below prefix, the longest matching prefix wins.
+// An empty target uses the default of the mode, Settings.FallbackRedirect for redirects.
+func (webServer *WebServer) SetFallback(prefix string, mode FallbackMode, target string) {
+	rule := fallbackRule{prefix: prefix, mode: mode, target: target}
+	for i, existing := range webServer.fallbackRules {
+		if existing.prefix == prefix {
+			webServer.fallbackRules[i] = rule
+			return
+		}
+	}
+	webServer.fallbackRules = append(webServer.fallbackRules, rule)
+	sort.SliceStable(webServer.fallbackRules, func(i, j int) bool {
+		return len(webServer.fallbackRules[i].prefix) > len(webServer.fallbackRules[j].prefix)
+	})
+}
+
+func (webServer *WebServer) fallback(rw http.ResponseWriter, req *http.Request) {
+	settings := webServer.Settings()
+	mode, target := settings.FallbackMode, settings.FallbackFile
+	if mode != FallbackModeSPA && mode != FallbackModeNotFound {
+		target = settings.FallbackRedirect
+	}
+	for _, rule := range webServer.fallbackRules {
+		if strings.HasPrefix(req.URL.Path, rule.prefix) {
+			mode, target = rule.mode, rule.target
+			break
+		}
+	}
+
+	switch mode {
+	case FallbackModeSPA:
+		if target == "" {
+			target = "/index.html"
+		}
+		webServer.serveFallbackFile(rw, req, settings, target, http.StatusOK)
+	case FallbackModeNotFound:
+		if target == "" {
+			target = "/404.html"
+		}
+		webServer.serveFallbackFile(rw, req, settings, target, http.StatusNotFound)
+	default:
+		if _, ok := webServer.errorPages[http.StatusNotFound]; ok {
+			webServer.writeError(rw, req, http.StatusNotFound)
+			return
+		}
+		if target == "" {
+			target = settings.FallbackRedirect
+		}
+		webServer.fallbackRedirect(rw, req, target)
+	}
+}
+
+func (webServer *WebServer) serveFallbackFile(rw http.ResponseWriter, req *http.Request, settings Settings, target string, status int) {
+	filePath, err := resolvePath(settings.Root, target, settings.BlockSymlinkEscape)
+	var file []byte
+	if err == nil {
+		file, err = os.ReadFile(filePath)
+	}
+	if err != nil {
+		webServer.writeError(rw, req, http.StatusNotFound)
+		webServer.logger.Println("Fallback: 404: " + err.Error())
+		return
+	}
+
+	parts := strings.Split(target, ".")
+	rw.Header().Set("Content-Type", getMimeType(parts[len(parts)-1]))
+	rw.WriteHeader(status)
+	_, err = rw.Write(file)
+	if err != nil {
+		webServer.logger.Println("Fallback: Write Error: " + err.Error())
+		return
+	}
+	webServer.logger.Println("Fallback: " + target)
+}
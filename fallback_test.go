@@ -0,0 +1,45 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFallback(t *testing.T) {
+	settings := NewSettings()
+	settings.Root = "root"
+	settings.FallbackRedirect = "/index"
+	webServer := NewWebServer(*settings)
+	webServer.SetFallback("/app", FallbackModeSPA, "")
+	webServer.SetFallback("/app/admin", FallbackModeNotFound, "/index.html")
+	webServer.SetFallback("/docs", FallbackModeRedirect, "/docs/start")
+
+	tests := []struct {
+		path     string
+		status   int
+		location string
+		body     string
+	}{
+		{"/missing", http.StatusTemporaryRedirect, "http://localhost:80/index", ""},
+		{"/missing.png", http.StatusNotFound, "", ""},
+		{"/app/settings/profile", http.StatusOK, "", "TEST"},
+		{"/app/admin/users", http.StatusNotFound, "", "TEST"},
+		{"/docs/missing.html", http.StatusTemporaryRedirect, "http://localhost:80/docs/start", ""},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if rec.Code != test.status {
+			t.Errorf("%s: status = %d, want %d", test.path, rec.Code, test.status)
+		}
+		if location := rec.Header().Get("Location"); location != test.location {
+			t.Errorf("%s: location = %q, want %q", test.path, location, test.location)
+		}
+		if !strings.Contains(rec.Body.String(), test.body) {
+			t.Errorf("%s: body does not contain %q", test.path, test.body)
+		}
+	}
+}
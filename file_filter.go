@@ -0,0 +1,89 @@
+package webserver
+
+import (
+	"path"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// ServeOnlyExtensions turns the file extension filter into an allow list: only files with these extensions,
+// e.g. "html", "css" and "js", are served, others are answered with 403 Forbidden. Directory requests are checked
+// with the extension of their index file. SetFileExtensionsFilter still denies extensions of the allow list.
+func (webServer *WebServer) ServeOnlyExtensions(fileExtensions ...string) {
+	webServer.settingsMutex.Lock()
+	defer webServer.settingsMutex.Unlock()
+	webServer.settings.AllowedExtensions = slices.Clone(fileExtensions)
+}
+
+// DenyPath answers requests for paths matching one of the patterns with 403 Forbidden.
+// A "*" matches within one path segment and "**" any number of segments, e.g. "/**/.git/**" or "/drafts/*.md".
+func (webServer *WebServer) DenyPath(patterns ...string) {
+	webServer.settingsMutex.Lock()
+	defer webServer.settingsMutex.Unlock()
+
+	// copied so snapshots returned by Settings are not modified
+	denyPaths := slices.Clone(webServer.settings.DenyPaths)
+	for _, pattern := range patterns {
+		if !slices.Contains(denyPaths, pattern) {
+			denyPaths = append(denyPaths, pattern)
+		}
+	}
+	webServer.settings.DenyPaths = denyPaths
+}
+
+// allowedExtension reports whether a file with extension may be served, an empty allow list allows every extension
+func allowedExtension(allowed []string, extension string) bool {
+	return len(allowed) == 0 || slices.Contains(allowed, extension)
+}
+
+// deniedPath returns the first pattern of denyPaths matching urlPath
+func deniedPath(denyPaths []string, urlPath string) (string, bool) {
+	if len(denyPaths) == 0 {
+		return "", false
+	}
+	urlPath = path.Clean("/" + urlPath)
+	for _, pattern := range denyPaths {
+		if matchGlob(pattern, urlPath) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// matchGlob reports whether urlPath matches pattern, "*" matches within a segment and "**" any number of segments
+func matchGlob(pattern string, urlPath string) bool {
+	return matchSegments(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(strings.Trim(urlPath, "/"), "/"))
+}
+
+func matchSegments(pattern []string, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		matched, err := path.Match(pattern[0], segments[0])
+		if err != nil || !matched {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// hiddenPath returns the first segment of urlPath starting with a dot, like .env or .git, which is not one of exceptions
+func hiddenPath(urlPath string, exceptions []string) (string, bool) {
+	for _, segment := range strings.Split(path.Clean("/"+urlPath), "/") {
+		if strings.HasPrefix(segment, ".") && !slices.Contains(exceptions, segment) {
+			return segment, true
+		}
+	}
+	return "", false
+}
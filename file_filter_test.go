@@ -0,0 +1,128 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"/**/.git/**", "/.git/config", true},
+		{"/**/.git/**", "/site/.git/objects/ab/cd", true},
+		{"/**/.git/**", "/.git", true},
+		{"/**/.git/**", "/.gitignore", false},
+		{"/drafts/*.md", "/drafts/post.md", true},
+		{"/drafts/*.md", "/drafts/2024/post.md", false},
+		{"/**/*.bak", "/a/b/c.bak", true},
+		{"/**/*.bak", "/c.bak", true},
+		{"/private", "/private/file", false},
+		{"/private/**", "/private/file", true},
+	}
+
+	for _, test := range tests {
+		if got := matchGlob(test.pattern, test.path); got != test.match {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", test.pattern, test.path, got, test.match)
+		}
+	}
+}
+
+func TestFileFilters(t *testing.T) {
+	settings := NewSettings()
+	settings.Root = "root"
+	webServer := NewWebServer(*settings)
+	webServer.ServeOnlyExtensions("html", "css", "js")
+	webServer.SetFileExtensionsFilter("css")
+	webServer.DenyPath("/**/*.min.js", "/**/.git/**")
+	assets := NewMountOptions()
+	assets.AllowedExtensions = []string{"txt"}
+	assets.DenyPaths = []string{"/private/**"}
+	webServer.MountFS("/assets", fstest.MapFS{
+		"notes.txt":         {Data: []byte("notes")},
+		"data.json":         {Data: []byte("{}")},
+		"private/notes.txt": {Data: []byte("secret")},
+	}, *assets)
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/", http.StatusOK},
+		{"/index.html", http.StatusOK},
+		{"/script.js", http.StatusOK},
+		{"/style.css", http.StatusForbidden},
+		{"/test.json", http.StatusForbidden},
+		{"/app.min.js", http.StatusForbidden},
+		{"/.git/config", http.StatusForbidden},
+		{"/assets/notes.txt", http.StatusOK},
+		{"/assets/data.json", http.StatusForbidden},
+		{"/assets/private/notes.txt", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if rec.Code != test.status {
+			t.Errorf("%s: %d, want %d", test.path, rec.Code, test.status)
+		}
+	}
+}
+
+func TestBlockDotfiles(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "dotfiles-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		".env":                     "SECRET=1",
+		".git/config":              "[core]",
+		".well-known/security.txt": "Contact: security@example.com",
+		"public/.htpasswd":         "admin:hash",
+		"public/index.html":        "public",
+	} {
+		filePath := filepath.Join(dir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(filePath), 0755)
+		if err == nil {
+			err = os.WriteFile(filePath, []byte(content), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	settings := NewSettings()
+	settings.Root = dir
+	settings.BlockDotfiles = true
+	webServer := NewWebServer(*settings)
+
+	tests := map[string]int{
+		"/.env":                     http.StatusNotFound,
+		"/.git/config":              http.StatusNotFound,
+		"/public/.htpasswd":         http.StatusNotFound,
+		"/.well-known/security.txt": http.StatusOK,
+		"/public/":                  http.StatusOK,
+	}
+	for path, status := range tests {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != status {
+			t.Errorf("%s: %d, want %d", path, rec.Code, status)
+		}
+	}
+
+	settings.BlockDotfiles = false
+	webServer = NewWebServer(*settings)
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.env", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("/.env without BlockDotfiles: %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,139 @@
+package webserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// AssetManifest maps the slash separated names of static files below Settings.Root to their fingerprinted names,
+// e.g. "js/app.js" to "js/app.3f2a1b9c.js". It is stored as a json object like the manifests of bundlers.
+type AssetManifest map[string]string
+
+type FingerprintOptions struct {
+	// Extensions are the extensions of the files which are fingerprinted
+	Extensions []string
+	// HashLength is the number of hex digits of the sha256 of the content in the fingerprinted names
+	HashLength int
+}
+
+func NewFingerprintOptions() *FingerprintOptions {
+	return &FingerprintOptions{
+		Extensions: []string{"js", "mjs", "css", "map", "svg", "png", "jpg", "jpeg", "gif", "webp", "avif", "ico", "woff", "woff2"},
+		HashLength: 8,
+	}
+}
+
+// BuildAssetManifest fingerprints the files of fsys with one of options.Extensions, it is meant to run as build step
+// with the result written next to the binary and loaded with LoadAssetManifest and SetAssetManifest at startup.
+// Sidecars like app.js.gz are not fingerprinted, they are served for the fingerprinted name of app.js.
+func BuildAssetManifest(fsys fs.FS, options FingerprintOptions) (AssetManifest, error) {
+	if options.HashLength < 1 || options.HashLength > sha256.Size*2 {
+		return nil, errors.New("fingerprint hash length must be between 1 and 64")
+	}
+	manifest := AssetManifest{}
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		extension := strings.TrimPrefix(path.Ext(name), ".")
+		if !slices.Contains(options.Extensions, extension) {
+			return nil
+		}
+		file, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(file)
+		base := strings.TrimSuffix(name, "."+extension)
+		manifest[name] = base + "." + hex.EncodeToString(sum[:])[:options.HashLength] + "." + extension
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// LoadAssetManifest reads a manifest written by AssetManifest.Write
+func LoadAssetManifest(r io.Reader) (AssetManifest, error) {
+	manifest := AssetManifest{}
+	err := json.NewDecoder(r).Decode(&manifest)
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func (manifest AssetManifest) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(manifest)
+}
+
+// assetIndex is a manifest together with the reverse lookup the file handler uses
+type assetIndex struct {
+	manifest  AssetManifest
+	originals map[string]string
+}
+
+// FingerprintAssets builds the manifest of the files below Settings.Root with BuildAssetManifest and serves them
+// under their fingerprinted names, it has to be called again after the files changed.
+func (webServer *WebServer) FingerprintAssets(options FingerprintOptions) error {
+	manifest, err := BuildAssetManifest(os.DirFS(rootDir(webServer.Settings().Root)), options)
+	if err != nil {
+		return err
+	}
+	webServer.SetAssetManifest(manifest)
+	return nil
+}
+
+// SetAssetManifest serves the static files of manifest under their fingerprinted names with Cache-Control
+// CacheImmutable, the original names are still served as before. nil stops serving fingerprinted names.
+func (webServer *WebServer) SetAssetManifest(manifest AssetManifest) {
+	if manifest == nil {
+		webServer.assets.Store(nil)
+		return
+	}
+	index := &assetIndex{manifest: make(AssetManifest, len(manifest)), originals: make(map[string]string, len(manifest))}
+	for name, fingerprinted := range manifest {
+		name, fingerprinted = strings.TrimPrefix(name, "/"), strings.TrimPrefix(fingerprinted, "/")
+		index.manifest[name] = fingerprinted
+		index.originals["/"+fingerprinted] = "/" + name
+	}
+	webServer.assets.Store(index)
+}
+
+// AssetPath returns the url path of the fingerprinted name of the static file name, e.g. "/app.3f2a1b9c.js" for
+// "app.js", or the url path of name itself if it is not in the manifest. In templates it can be used as function,
+// e.g. template.FuncMap{"asset": webServer.AssetPath} and {{asset "app.js"}}.
+func (webServer *WebServer) AssetPath(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if index := webServer.assets.Load(); index != nil {
+		if fingerprinted, ok := index.manifest[name]; ok {
+			return "/" + fingerprinted
+		}
+	}
+	return "/" + name
+}
+
+// fingerprintedAsset returns the url path of the original file of the fingerprinted urlPath
+func (webServer *WebServer) fingerprintedAsset(urlPath string) (string, bool) {
+	index := webServer.assets.Load()
+	if index == nil {
+		return "", false
+	}
+	original, ok := index.originals[urlPath]
+	return original, ok
+}
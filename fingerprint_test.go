@@ -0,0 +1,120 @@
+package webserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestBuildAssetManifest(t *testing.T) {
+	sum := sha256.Sum256([]byte("app"))
+	hash := hex.EncodeToString(sum[:])
+	fsys := fstest.MapFS{
+		"js/app.min.js":    {Data: []byte("app")},
+		"js/app.min.js.gz": {Data: []byte("gzip")},
+		"index.html":       {Data: []byte("<html>")},
+	}
+	manifest, err := BuildAssetManifest(fsys, *NewFingerprintOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AssetManifest{"js/app.min.js": "js/app.min." + hash[:8] + ".js"}
+	if !reflect.DeepEqual(manifest, want) {
+		t.Errorf("manifest = %v, want %v", manifest, want)
+	}
+
+	var buffer bytes.Buffer
+	err = manifest.Write(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadAssetManifest(&buffer)
+	if err != nil || !reflect.DeepEqual(loaded, want) {
+		t.Errorf("loaded = %v, %v", loaded, err)
+	}
+
+	options := NewFingerprintOptions()
+	options.HashLength = 0
+	_, err = BuildAssetManifest(fsys, *options)
+	if err == nil {
+		t.Error("hash length 0 accepted")
+	}
+}
+
+func TestFingerprintAssets(t *testing.T) {
+	root, err := os.MkdirTemp(".", "fingerprint-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	err = os.MkdirAll(filepath.Join(root, "css"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"app.js": "console.log(1)", "css/site.css": "a{}", "robots.txt": "robots"} {
+		err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	settings := NewSettings()
+	settings.Root = root
+	webServer := NewWebServer(*settings)
+	if webServer.AssetPath("app.js") != "/app.js" {
+		t.Errorf("AssetPath without manifest = %q", webServer.AssetPath("app.js"))
+	}
+	err = webServer.FingerprintAssets(*NewFingerprintOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256([]byte("a{}"))
+	css := "/css/site." + hex.EncodeToString(sum[:])[:8] + ".css"
+	if webServer.AssetPath("/css/site.css") != css || webServer.AssetPath("robots.txt") != "/robots.txt" {
+		t.Errorf("AssetPath = %q, %q", webServer.AssetPath("/css/site.css"), webServer.AssetPath("robots.txt"))
+	}
+
+	tests := []struct {
+		path         string
+		status       int
+		body         string
+		cacheControl string
+	}{
+		{css, http.StatusOK, "a{}", string(CacheImmutable)},
+		{webServer.AssetPath("app.js"), http.StatusOK, "console.log(1)", string(CacheImmutable)},
+		{"/app.js", http.StatusOK, "console.log(1)", ""},
+		{"/css/site.00000000.css", http.StatusNotFound, "", ""},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if rec.Code != test.status {
+			t.Errorf("%s = %d, want %d", test.path, rec.Code, test.status)
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		if rec.Body.String() != test.body || rec.Header().Get("Cache-Control") != test.cacheControl {
+			t.Errorf("%s: body %q, Cache-Control %q", test.path, rec.Body.String(), rec.Header().Get("Cache-Control"))
+		}
+		if rec.Header().Get("Content-Type") == "application/octet-stream" {
+			t.Errorf("%s: Content-Type %q", test.path, rec.Header().Get("Content-Type"))
+		}
+	}
+
+	webServer.SetAssetManifest(nil)
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, css, nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("without manifest %s = %d", css, rec.Code)
+	}
+}
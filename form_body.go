@@ -0,0 +1,150 @@
+package webserver
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"reflect"
+)
+
+// NewFormBodyHandler decodes application/x-www-form-urlencoded bodies into T using `form` field tags
+func NewFormBodyHandler[T any](
+	webServer *WebServer,
+	method HTTPMethod,
+	pattern string,
+	handler func(http.ResponseWriter, *http.Request, T),
+) {
+	if reflect.TypeFor[T]().Kind() != reflect.Struct {
+		panic("T must be a struct")
+	}
+
+	webServer.NewHandleFunc(method, pattern, func(rw http.ResponseWriter, req *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if mediaType != "application/x-www-form-urlencoded" {
+			webServer.unsupportedMediaType(rw, req, mediaType)
+			return
+		}
+
+		webServer.limitBody(rw, req)
+		err := req.ParseForm()
+		if err != nil {
+			webServer.bodyError(rw, req, err)
+			return
+		}
+
+		var values T
+		err = bindValues(&values, "form", req.PostForm, nil)
+		if err != nil {
+			webServer.bodyError(rw, req, err)
+			return
+		}
+
+		handler(rw, req, values)
+	})
+	webServer.documentRequest(method, pattern, "form", reflect.TypeFor[T]())
+}
+
+// NewMultipartHandler decodes multipart/form-data bodies into T using `form` field tags.
+// File parts are bound to fields of type *multipart.FileHeader or []*multipart.FileHeader and read with Open,
+// parts exceeding Settings.MaxMultipartMemory are buffered in temporary files which are removed once the response is complete,
+// also if the handler panics.
+// Files are checked by the upload scanner before binding if one is set. With Settings.StripImageMetadata
+// jpeg and png files are replaced by copies without exif, gps and text metadata.
+func NewMultipartHandler[T any](
+	webServer *WebServer,
+	method HTTPMethod,
+	pattern string,
+	handler func(http.ResponseWriter, *http.Request, T),
+) {
+	if reflect.TypeFor[T]().Kind() != reflect.Struct {
+		panic("T must be a struct")
+	}
+
+	webServer.NewHandleFunc(method, pattern, func(rw http.ResponseWriter, req *http.Request) {
+		settings := webServer.Settings()
+		webServer.limitBody(rw, req)
+		err := req.ParseMultipartForm(settings.MaxMultipartMemory)
+		if errors.Is(err, http.ErrNotMultipart) {
+			mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+			webServer.unsupportedMediaType(rw, req, mediaType)
+			return
+		}
+		if err != nil {
+			webServer.bodyError(rw, req, err)
+			return
+		}
+		form := req.MultipartForm
+		Defer(req, func() {
+			err := form.RemoveAll()
+			if err != nil {
+				webServer.logger.Println("Multipart Handler: " + err.Error())
+			}
+		})
+
+		err = webServer.scanUploads(req.Context(), req.MultipartForm.File)
+		var infected *infectedError
+		if errors.As(err, &infected) {
+			webServer.writeErrorCause(rw, req, http.StatusUnprocessableEntity, err)
+			webServer.logger.Println("Upload Scanner: 422: " + err.Error())
+			return
+		}
+		if err != nil {
+			webServer.writeErrorCause(rw, req, http.StatusServiceUnavailable, err)
+			webServer.logger.Println("Upload Scanner: 503: " + err.Error())
+			return
+		}
+
+		if settings.StripImageMetadata {
+			forms, err := sanitizeUploads(req.MultipartForm.File, settings.MaxMultipartMemory)
+			Defer(req, func() {
+				for _, form := range forms {
+					_ = form.RemoveAll()
+				}
+			})
+			if err != nil {
+				webServer.writeErrorCause(rw, req, http.StatusInternalServerError, err)
+				webServer.logger.Println("Multipart Handler: 500: " + err.Error())
+				return
+			}
+		}
+
+		var values T
+		err = bindValues(&values, "form", req.MultipartForm.Value, req.MultipartForm.File)
+		if err != nil {
+			webServer.bodyError(rw, req, err)
+			return
+		}
+
+		handler(rw, req, values)
+	})
+	webServer.documentRequest(method, pattern, "multipart", reflect.TypeFor[T]())
+}
+
+func (webServer *WebServer) limitBody(rw http.ResponseWriter, req *http.Request) {
+	maxBodySize := webServer.Settings().MaxBodySize
+	if maxBodySize > 0 {
+		req.Body = http.MaxBytesReader(rw, req.Body, maxBodySize)
+	}
+}
+
+// bodyError answers unreadable, too large and undecodable bodies with 413 or 400 and the error as body
+func (webServer *WebServer) bodyError(rw http.ResponseWriter, req *http.Request, err error) {
+	status := http.StatusBadRequest
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		status = http.StatusRequestEntityTooLarge
+		webServer.logger.Println("Body: 413: " + err.Error())
+	}
+	if !webServer.handleError(rw, req, err, status) {
+		message := err.Error()
+		if webServer.minimalErrors() {
+			message = http.StatusText(status)
+		}
+		webServer.RespondError(rw, req, status, message)
+	}
+}
+
+func (webServer *WebServer) unsupportedMediaType(rw http.ResponseWriter, req *http.Request, mediaType string) {
+	webServer.logger.Println("Body: 415: " + mediaType)
+	webServer.writeErrorCause(rw, req, http.StatusUnsupportedMediaType, errors.New("unsupported media type "+mediaType))
+}
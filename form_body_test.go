@@ -0,0 +1,158 @@
+package webserver
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type formTestValues struct {
+	Name     string    `form:"name"`
+	Age      int       `form:"age"`
+	Admin    bool      `form:"admin"`
+	Tags     []string  `form:"tag"`
+	Scores   []float64 `form:"score"`
+	Birthday time.Time `form:"birthday"`
+	Nickname *string   `form:"nickname"`
+	Ignored  string    `form:"-"`
+	Plain    uint8
+}
+
+func TestBindValues(t *testing.T) {
+	values := url.Values{
+		"name":     {"gopher"},
+		"age":      {"13"},
+		"admin":    {"on"},
+		"tag":      {"a", "b"},
+		"score":    {"1.5", "2"},
+		"birthday": {"2009-11-10"},
+		"nickname": {"go"},
+		"Ignored":  {"x"},
+		"-":        {"x"},
+		"Plain":    {"255"},
+	}
+
+	var got formTestValues
+	err := bindValues(&got, "form", values, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Name != "gopher" || got.Age != 13 || !got.Admin || got.Plain != 255 {
+		t.Errorf("scalars = %+v", got)
+	}
+	if strings.Join(got.Tags, ",") != "a,b" || len(got.Scores) != 2 || got.Scores[0] != 1.5 {
+		t.Errorf("slices = %v %v", got.Tags, got.Scores)
+	}
+	if !got.Birthday.Equal(time.Date(2009, 11, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("birthday = %v", got.Birthday)
+	}
+	if got.Nickname == nil || *got.Nickname != "go" {
+		t.Errorf("nickname = %v", got.Nickname)
+	}
+	if got.Ignored != "" {
+		t.Errorf("ignored field was set to %q", got.Ignored)
+	}
+}
+
+func TestBindValuesErrors(t *testing.T) {
+	var got formTestValues
+	err := bindValues(&got, "form", url.Values{"age": {"old"}, "Plain": {"256"}}, nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "age") || !strings.Contains(err.Error(), "Plain") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestNewFormBodyHandler(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	NewFormBodyHandler(webServer, HTTPMethodPost, "/form", func(rw http.ResponseWriter, req *http.Request, values formTestValues) {
+		_, _ = rw.Write([]byte(values.Name))
+	})
+
+	tests := []struct {
+		body        string
+		contentType string
+		status      int
+	}{
+		{"name=gopher&age=3", "application/x-www-form-urlencoded", http.StatusOK},
+		{"name=gopher&age=x", "application/x-www-form-urlencoded", http.StatusBadRequest},
+		{`{"name":"gopher"}`, "application/json", http.StatusUnsupportedMediaType},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/form", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", test.contentType)
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("%s %q: status = %d, want %d", test.contentType, test.body, rec.Code, test.status)
+		}
+	}
+}
+
+func TestNewMultipartHandler(t *testing.T) {
+	type upload struct {
+		Title string                  `form:"title"`
+		File  *multipart.FileHeader   `form:"file"`
+		Extra []*multipart.FileHeader `form:"extra"`
+	}
+
+	settings := NewSettings()
+	settings.MaxBodySize = 1024
+	webServer := NewWebServer(*settings)
+	NewMultipartHandler(webServer, HTTPMethodPost, "/upload", func(rw http.ResponseWriter, req *http.Request, values upload) {
+		if values.File == nil || len(values.Extra) != 2 {
+			t.Errorf("files not bound: %+v", values)
+			return
+		}
+		file, err := values.File.Open()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		content, _ := io.ReadAll(file)
+		_ = file.Close()
+		_, _ = rw.Write([]byte(values.Title + ":" + string(content)))
+	})
+
+	newBody := func(size int) (*bytes.Buffer, string) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		_ = writer.WriteField("title", "report")
+		part, _ := writer.CreateFormFile("file", "report.txt")
+		_, _ = part.Write(bytes.Repeat([]byte("x"), size))
+		for _, name := range []string{"a.txt", "b.txt"} {
+			part, _ = writer.CreateFormFile("extra", name)
+			_, _ = part.Write([]byte(name))
+		}
+		_ = writer.Close()
+		return body, writer.FormDataContentType()
+	}
+
+	body, contentType := newBody(3)
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "report:xxx" {
+		t.Errorf("status = %d, body = %q", rec.Code, rec.Body.String())
+	}
+
+	body, contentType = newBody(2048)
+	req = httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", contentType)
+	rec = httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized upload: status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
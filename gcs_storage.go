@@ -0,0 +1,44 @@
+package webserver
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// GCSStorage reads static files from a Google Cloud Storage bucket through the json api with OAuth2 access tokens
+type GCSStorage struct {
+	Bucket string
+	// Prefix is prepended to the object names
+	Prefix string
+	// Token returns the access token of a request, e.g. from the metadata server or an oauth2.TokenSource
+	Token func(ctx context.Context) (string, error)
+	// Endpoint defaults to https://storage.googleapis.com
+	Endpoint string
+	Client   *http.Client
+}
+
+func NewGCSStorage(bucket string, token func(ctx context.Context) (string, error)) *GCSStorage {
+	return &GCSStorage{
+		Bucket:   bucket,
+		Token:    token,
+		Endpoint: "https://storage.googleapis.com",
+		Client:   http.DefaultClient,
+	}
+}
+
+func (storage *GCSStorage) ReadFile(ctx context.Context, name string) ([]byte, error) {
+	target := storage.Endpoint + "/storage/v1/b/" + url.PathEscape(storage.Bucket) + "/o/" + url.PathEscape(storage.Prefix+name) + "?alt=media"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if storage.Token != nil {
+		token, err := storage.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return readObject(storage.Client, req, name)
+}
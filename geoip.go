@@ -0,0 +1,180 @@
+package webserver
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+var geoKey = NewKey[GeoInfo]("geo")
+
+// GeoInfo is the location and network of an ip address
+type GeoInfo struct {
+	// Country is the ISO 3166-1 alpha-2 code, e.g. "DE"
+	Country string
+	// ASN is the autonomous system number of the network, e.g. 16509 for Amazon
+	ASN          uint32
+	Organization string
+}
+
+// GeoIPLookup resolves ip addresses, implementations must be safe for concurrent use
+type GeoIPLookup interface {
+	Lookup(ip net.IP) (GeoInfo, bool)
+}
+
+// SetGeoIP looks up every ClientIP with lookup, so rate limits and ip filters can match countries and ASNs,
+// see RateLimitOptions.Countries and IPFilterOptions.DenyCountries, and handlers can read Geo
+func (webServer *WebServer) SetGeoIP(lookup GeoIPLookup) {
+	webServer.geoIP = lookup
+}
+
+// Geo returns the GeoInfo of the client of req, false without SetGeoIP or if the address is unknown
+func Geo(req *http.Request) (GeoInfo, bool) {
+	return Get(req, geoKey)
+}
+
+func (webServer *WebServer) lookupGeo(req *http.Request, clientIP string) {
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return
+	}
+	info, ok := webServer.geoIP.Lookup(ip)
+	if ok {
+		Set(req, geoKey, info)
+	}
+}
+
+// GeoMatch matches clients by country or ASN, a client matches if it is in one of Countries or ASNs
+type GeoMatch struct {
+	Countries []string
+	ASNs      []uint32
+}
+
+func (match GeoMatch) empty() bool {
+	return len(match.Countries) == 0 && len(match.ASNs) == 0
+}
+
+// Matches reports whether the client of req is in one of the countries or ASNs, unknown clients match nothing
+func (match GeoMatch) Matches(req *http.Request) bool {
+	info, ok := Geo(req)
+	if !ok {
+		return false
+	}
+	return slices.ContainsFunc(match.Countries, func(country string) bool { return strings.EqualFold(country, info.Country) }) ||
+		slices.Contains(match.ASNs, info.ASN)
+}
+
+func (match GeoMatch) String() string {
+	asns := make([]string, 0, len(match.ASNs))
+	for _, asn := range match.ASNs {
+		asns = append(asns, "AS"+strconv.FormatUint(uint64(asn), 10))
+	}
+	return strings.Join(append(append([]string{}, match.Countries...), asns...), ",")
+}
+
+// GeoIPTable is a GeoIPLookup of ip ranges held in memory, the ranges must not overlap
+type GeoIPTable struct {
+	// ranges are sorted by their first address
+	ranges []geoRange
+}
+
+type geoRange struct {
+	first, last net.IP
+	info        GeoInfo
+}
+
+func NewGeoIPTable() *GeoIPTable {
+	return &GeoIPTable{}
+}
+
+// Add adds the addresses from first to last, it must not be called while the table is in use.
+// Adding ranges in ascending order, like they are listed in geoip databases, is fastest.
+func (table *GeoIPTable) Add(first net.IP, last net.IP, info GeoInfo) error {
+	first, last = first.To16(), last.To16()
+	if first == nil || last == nil || bytes.Compare(first, last) > 0 {
+		return errors.New("invalid geoip range")
+	}
+	i := len(table.ranges)
+	if i > 0 && bytes.Compare(table.ranges[i-1].first, first) > 0 {
+		i = sort.Search(len(table.ranges), func(i int) bool {
+			return bytes.Compare(table.ranges[i].first, first) > 0
+		})
+	}
+	table.ranges = slices.Insert(table.ranges, i, geoRange{first: first, last: last, info: info})
+	return nil
+}
+
+// AddCIDR adds the addresses of cidr like "203.0.113.0/24"
+func (table *GeoIPTable) AddCIDR(cidr string, info GeoInfo) error {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return err
+	}
+	last := make(net.IP, len(network.IP))
+	for i := range network.IP {
+		last[i] = network.IP[i] | ^network.Mask[i]
+	}
+	return table.Add(network.IP, last, info)
+}
+
+// Lookup returns the info of the range containing ip
+func (table *GeoIPTable) Lookup(ip net.IP) (GeoInfo, bool) {
+	ip = ip.To16()
+	if ip == nil {
+		return GeoInfo{}, false
+	}
+	i := sort.Search(len(table.ranges), func(i int) bool {
+		return bytes.Compare(table.ranges[i].first, ip) > 0
+	})
+	if i == 0 || bytes.Compare(ip, table.ranges[i-1].last) > 0 {
+		return GeoInfo{}, false
+	}
+	return table.ranges[i-1].info, true
+}
+
+// LoadGeoIPTable reads the tab separated format of iptoasn.com,
+// "range_start range_end AS_number country_code AS_description" per line. Ranges of AS 0 are skipped.
+func LoadGeoIPTable(r io.Reader) (*GeoIPTable, error) {
+	table := NewGeoIPTable()
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.SplitN(text, "\t", 5)
+		if len(fields) < 4 {
+			return nil, errors.New("geoip table line " + strconv.Itoa(line) + ": expected at least 4 fields")
+		}
+		asn, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return nil, errors.New("geoip table line " + strconv.Itoa(line) + ": " + err.Error())
+		}
+		if asn == 0 {
+			continue
+		}
+		info := GeoInfo{Country: fields[3], ASN: uint32(asn)}
+		if len(fields) == 5 {
+			info.Organization = fields[4]
+		}
+		err = table.Add(net.ParseIP(fields[0]), net.ParseIP(fields[1]), info)
+		if err != nil {
+			return nil, errors.New("geoip table line " + strconv.Itoa(line) + ": " + err.Error())
+		}
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+	return table, nil
+}
@@ -0,0 +1,101 @@
+package webserver
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const geoIPTable = `# range_start	range_end	AS_number	country_code	AS_description
+1.0.0.0	1.0.0.255	13335	US	CLOUDFLARENET
+1.0.1.0	1.0.3.255	0	None	Not routed
+2.0.0.0	2.0.0.255	3320	DE	DTAG
+2001:db8::	2001:db8::ffff	16509	US	AMAZON-02
+`
+
+func TestGeoIPTable(t *testing.T) {
+	table, err := LoadGeoIPTable(strings.NewReader(geoIPTable))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = table.AddCIDR("0.9.0.0/16", GeoInfo{Country: "FR", ASN: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip   string
+		info GeoInfo
+		ok   bool
+	}{
+		{"1.0.0.0", GeoInfo{"US", 13335, "CLOUDFLARENET"}, true},
+		{"1.0.0.255", GeoInfo{"US", 13335, "CLOUDFLARENET"}, true},
+		{"1.0.2.1", GeoInfo{}, false},
+		{"2.0.0.7", GeoInfo{"DE", 3320, "DTAG"}, true},
+		{"0.9.255.255", GeoInfo{"FR", 1, ""}, true},
+		{"0.8.0.0", GeoInfo{}, false},
+		{"2001:db8::1", GeoInfo{"US", 16509, "AMAZON-02"}, true},
+		{"2001:db9::", GeoInfo{}, false},
+	}
+	for _, test := range tests {
+		info, ok := table.Lookup(net.ParseIP(test.ip))
+		if ok != test.ok || info != test.info {
+			t.Errorf("Lookup(%s) = %v %t, want %v %t", test.ip, info, ok, test.info, test.ok)
+		}
+	}
+
+	_, err = LoadGeoIPTable(strings.NewReader("1.0.0.0\t1.0.0.255\tAS1\tUS\n"))
+	if err == nil {
+		t.Error("invalid ASN loaded")
+	}
+}
+
+func TestGeoRules(t *testing.T) {
+	table, err := LoadGeoIPTable(strings.NewReader(geoIPTable))
+	if err != nil {
+		t.Fatal(err)
+	}
+	webServer := NewWebServer(*NewSettings())
+	webServer.SetGeoIP(table)
+	webServer.EnableRateLimit(*NewRateLimitOptions(0.001, 3))
+	datacenters := NewRateLimitOptions(0.001, 1)
+	datacenters.Geo = GeoMatch{ASNs: []uint32{13335, 16509}}
+	webServer.EnableRateLimit(*datacenters)
+	filter := NewIPFilterOptions()
+	filter.DenyCountries = []string{"de"}
+	err = webServer.SetIPFilter("/private/", *filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	country := func(rw http.ResponseWriter, req *http.Request) {
+		info, _ := Geo(req)
+		_, _ = rw.Write([]byte(info.Country))
+	}
+	webServer.NewHandleFunc(HTTPMethodGet, "/geo", country)
+	webServer.NewHandleFunc(HTTPMethodGet, "/private/x", country)
+
+	tests := []struct {
+		remote, path string
+		status       int
+	}{
+		{"1.0.0.1:1000", "/geo", http.StatusOK},
+		{"1.0.0.1:1001", "/geo", http.StatusTooManyRequests},
+		{"2.0.0.1:1000", "/geo", http.StatusOK},
+		{"2.0.0.1:1001", "/geo", http.StatusOK},
+		{"2.0.0.1:1002", "/private/x", http.StatusForbidden},
+		{"[2001:db8::1]:1000", "/private/x", http.StatusOK},
+		{"[2001:db8::1]:1001", "/geo", http.StatusTooManyRequests},
+		{"9.9.9.9:1000", "/private/x", http.StatusOK},
+	}
+	for i, test := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		req.RemoteAddr = test.remote
+		webServer.mux.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("%d: %s %s = %d, want %d", i, test.remote, test.path, rec.Code, test.status)
+		}
+	}
+}
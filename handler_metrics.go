@@ -0,0 +1,104 @@
+package webserver
+
+import (
+	"net/http"
+	"time"
+)
+
+var metricsServerKey = NewKey[*WebServer]("metrics server")
+
+// HandlerMetrics records business metrics of a handler with the exporter of the server, see Metrics
+type HandlerMetrics struct {
+	exporter MetricsExporter
+	tags     map[string]string
+}
+
+// Metrics returns the metrics of the server handling req, e.g. Metrics(req).Counter("orders_created").Inc().
+// They go to the same exporter as the request metrics, so they share its prefix, tags and MetricsHandler, and
+// carry the method and route labels selected by Settings.MetricsLabels. Without a metrics exporter nothing is recorded.
+func Metrics(req *http.Request) *HandlerMetrics {
+	webServer, ok := Get(req, metricsServerKey)
+	if !ok || webServer.metrics == nil {
+		return &HandlerMetrics{}
+	}
+	settings := webServer.Settings()
+	method, route := webServer.routeLabel(req)
+
+	tags := map[string]string{}
+	for _, label := range settings.MetricsLabels {
+		switch label {
+		case MetricsLabelMethod:
+			tags[label] = method
+		case MetricsLabelRoute:
+			tags[label] = webServer.metricRoutes.limit(route, settings.MetricsMaxRoutes)
+		}
+	}
+	return &HandlerMetrics{exporter: webServer.metrics, tags: tags}
+}
+
+// With returns metrics carrying the additional label name, keep its values bounded like an enum
+func (metrics *HandlerMetrics) With(name string, value string) *HandlerMetrics {
+	tags := make(map[string]string, len(metrics.tags)+1)
+	for tag, tagValue := range metrics.tags {
+		tags[tag] = tagValue
+	}
+	tags[name] = value
+	return &HandlerMetrics{exporter: metrics.exporter, tags: tags}
+}
+
+func (metrics *HandlerMetrics) Counter(name string) Counter {
+	return Counter{metrics: metrics, name: name}
+}
+
+func (metrics *HandlerMetrics) Gauge(name string) Gauge {
+	return Gauge{metrics: metrics, name: name}
+}
+
+func (metrics *HandlerMetrics) Timer(name string) Timer {
+	return Timer{metrics: metrics, name: name}
+}
+
+// Counter is a metric which only increases, like the number of created orders
+type Counter struct {
+	metrics *HandlerMetrics
+	name    string
+}
+
+func (counter Counter) Inc() {
+	counter.Add(1)
+}
+
+func (counter Counter) Add(value int64) {
+	if counter.metrics.exporter != nil {
+		counter.metrics.exporter.Count(counter.name, value, counter.metrics.tags)
+	}
+}
+
+// Gauge is a metric which is set to the current value, like the size of a queue
+type Gauge struct {
+	metrics *HandlerMetrics
+	name    string
+}
+
+func (gauge Gauge) Set(value float64) {
+	if gauge.metrics.exporter != nil {
+		gauge.metrics.exporter.Gauge(gauge.name, value, gauge.metrics.tags)
+	}
+}
+
+// Timer records durations, a histogram with the Prometheus exporter
+type Timer struct {
+	metrics *HandlerMetrics
+	name    string
+}
+
+func (timer Timer) Observe(value time.Duration) {
+	if timer.metrics.exporter != nil {
+		timer.metrics.exporter.Timing(timer.name, value, timer.metrics.tags)
+	}
+}
+
+// Since observes the time elapsed since start, e.g. defer Metrics(req).Timer("checkout").Since(time.Now())
+func (timer Timer) Since(start time.Time) {
+	timer.Observe(time.Since(start))
+}
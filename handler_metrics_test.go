@@ -0,0 +1,55 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlerMetrics(t *testing.T) {
+	settings := NewSettings()
+	settings.Root = "root"
+	settings.MetricsBackend = MetricsBackendPrometheus
+	settings.MetricsLabels = []string{MetricsLabelMethod, MetricsLabelRoute}
+	webServer := NewWebServer(*settings)
+	webServer.NewHandleFunc(HTTPMethodPost, "/orders", func(rw http.ResponseWriter, req *http.Request) {
+		metrics := Metrics(req)
+		metrics.Counter("orders_created").Inc()
+		metrics.With("plan", "pro").Counter("orders_created").Add(2)
+		metrics.Gauge("open_orders").Set(5)
+		metrics.Timer("checkout").Observe(20 * time.Millisecond)
+	})
+	webServer.NewHandler(HTTPMethodGet, "/metrics", webServer.MetricsHandler())
+
+	webServer.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/orders", nil))
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		`webserver_orders_created_total{method="POST",route="/orders"} 1`,
+		`webserver_orders_created_total{method="POST",plan="pro",route="/orders"} 2`,
+		`webserver_open_orders{method="POST",route="/orders"} 5`,
+		`webserver_checkout_seconds_count{method="POST",route="/orders"} 1`,
+		`webserver_requests_total{method="POST",route="/orders"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestHandlerMetricsWithoutExporter(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	webServer.NewHandleFunc(HTTPMethodPost, "/orders", func(rw http.ResponseWriter, req *http.Request) {
+		Metrics(req).Counter("orders_created").Inc()
+	})
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status %d, want 200", rec.Code)
+	}
+	Metrics(httptest.NewRequest(http.MethodGet, "/", nil)).Timer("outside").Since(time.Now())
+}
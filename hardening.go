@@ -0,0 +1,144 @@
+package webserver
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type HardeningOptions struct {
+	// HSTSMaxAge is the max-age of the Strict-Transport-Security header sent over TLS, 0 leaves it out
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	// Headers are set on every response that does not set them itself
+	Headers map[string]string
+	// RemoveHeaders are removed from every response, e.g. the Server header of proxied targets
+	RemoveHeaders []string
+	// DisableTrace answers TRACE requests with 405 Method Not Allowed, also if a route exists
+	DisableTrace bool
+	// RequireContentType answers requests with a body but without Content-Type with 415 Unsupported Media Type
+	RequireContentType bool
+	// MinimalErrors answers body errors with the status text instead of details like decode errors
+	MinimalErrors bool
+	// LimitBodies applies Settings.MaxBodySize to every request body, not only to the body handlers
+	LimitBodies bool
+}
+
+func NewHardeningOptions() *HardeningOptions {
+	return &HardeningOptions{
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		HSTSIncludeSubdomains: true,
+		Headers: map[string]string{
+			"X-Content-Type-Options": "nosniff",
+			"X-Frame-Options":        "DENY",
+			"Referrer-Policy":        "strict-origin-when-cross-origin",
+		},
+		RemoveHeaders:      []string{"Server", "X-Powered-By", "X-AspNet-Version"},
+		DisableTrace:       true,
+		RequireContentType: true,
+		MinimalErrors:      true,
+		LimitBodies:        true,
+	}
+}
+
+// EnableHardening applies the hardened profile, NewHardeningOptions enables every measure security scanners
+// commonly check for. It runs before every middleware.
+func (webServer *WebServer) EnableHardening(options HardeningOptions) {
+	webServer.hardening = &options
+}
+
+// harden applies the hardening to the request and wraps rw to adjust the response headers,
+// it returns false if the request was answered
+func (webServer *WebServer) harden(rw http.ResponseWriter, req *http.Request) (http.ResponseWriter, bool) {
+	options := webServer.hardening
+	settings := webServer.Settings()
+
+	headers := &hardenedWriter{ResponseWriter: rw, options: options}
+	if options.HSTSMaxAge > 0 && (req.TLS != nil || settings.UseHttps) {
+		headers.hsts = "max-age=" + strconv.FormatInt(int64(options.HSTSMaxAge.Seconds()), 10)
+		if options.HSTSIncludeSubdomains {
+			headers.hsts += "; includeSubDomains"
+		}
+	}
+
+	if options.DisableTrace && req.Method == http.MethodTrace {
+		webServer.writeError(headers, req, http.StatusMethodNotAllowed)
+		webServer.logger.Println("Hardening: 405: TRACE " + req.URL.Path)
+		return headers, false
+	}
+	hasBody := req.ContentLength > 0 || len(req.TransferEncoding) > 0
+	if options.RequireContentType && hasBody && req.Header.Get("Content-Type") == "" {
+		webServer.writeError(headers, req, http.StatusUnsupportedMediaType)
+		webServer.logger.Println("Hardening: 415: body without Content-Type " + req.URL.Path)
+		return headers, false
+	}
+	if options.LimitBodies && settings.MaxBodySize > 0 {
+		if req.ContentLength > settings.MaxBodySize {
+			webServer.writeError(headers, req, http.StatusRequestEntityTooLarge)
+			webServer.logger.Println("Hardening: 413: " + strconv.FormatInt(req.ContentLength, 10) + " bytes " + req.URL.Path)
+			return headers, false
+		}
+		req.Body = http.MaxBytesReader(headers, req.Body, settings.MaxBodySize)
+	}
+	return headers, true
+}
+
+// minimalErrors reports whether error responses leave out details
+func (webServer *WebServer) minimalErrors() bool {
+	return webServer.hardening != nil && webServer.hardening.MinimalErrors
+}
+
+// hardenedWriter adjusts the response headers before they are written
+type hardenedWriter struct {
+	http.ResponseWriter
+	options     *HardeningOptions
+	hsts        string
+	wroteHeader bool
+}
+
+func (rw *hardenedWriter) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.wroteHeader = true
+		header := rw.Header()
+		for _, name := range rw.options.RemoveHeaders {
+			header.Del(name)
+		}
+		for name, value := range rw.options.Headers {
+			if header.Get(name) == "" {
+				header.Set(name, value)
+			}
+		}
+		if rw.hsts != "" && header.Get("Strict-Transport-Security") == "" {
+			header.Set("Strict-Transport-Security", rw.hsts)
+		}
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *hardenedWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+func (rw *hardenedWriter) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack keeps websocket upgrades working through the wrapper
+func (rw *hardenedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(rw.ResponseWriter).Hijack()
+}
+
+// Unwrap is used by http.ResponseController to reach the underlying writer
+func (rw *hardenedWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,85 @@
+package webserver
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHardening(t *testing.T) {
+	settings := NewSettings()
+	settings.MaxBodySize = 8
+	webServer := NewWebServer(*settings)
+	webServer.EnableHardening(*NewHardeningOptions())
+	webServer.NewHandleFunc(HTTPMethodGet, "/upstream", func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Server", "Apache/2.4.1")
+		rw.Header().Set("X-Frame-Options", "SAMEORIGIN")
+		_, _ = rw.Write([]byte("ok"))
+	})
+	webServer.NewHandleFunc(HTTPMethodTrace, "/upstream", func(rw http.ResponseWriter, req *http.Request) {})
+	webServer.NewHandleFunc(HTTPMethodPost, "/echo", func(rw http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			webServer.bodyError(rw, req, err)
+			return
+		}
+		_, _ = rw.Write(body)
+	})
+	type user struct {
+		Age int
+	}
+	NewURLBodyHandler(webServer, HTTPMethodPut, "/users", func(rw http.ResponseWriter, req *http.Request, body user) {})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/upstream", nil)
+	req.TLS = &tls.ConnectionState{}
+	webServer.mux.ServeHTTP(rec, req)
+	header := rec.Result().Header
+	if header.Get("Server") != "" {
+		t.Errorf("Server = %q, want removed", header.Get("Server"))
+	}
+	if header.Get("X-Frame-Options") != "SAMEORIGIN" || header.Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("X-Frame-Options = %q, X-Content-Type-Options = %q", header.Get("X-Frame-Options"), header.Get("X-Content-Type-Options"))
+	}
+	if hsts := header.Get("Strict-Transport-Security"); hsts != "max-age=31536000; includeSubDomains" {
+		t.Errorf("Strict-Transport-Security = %q", hsts)
+	}
+
+	rec = httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/upstream", nil))
+	if hsts := rec.Result().Header.Get("Strict-Transport-Security"); hsts != "" {
+		t.Errorf("Strict-Transport-Security over http = %q, want none", hsts)
+	}
+
+	tests := []struct {
+		method, path, contentType, body string
+		status                          int
+		response                        string
+	}{
+		{http.MethodTrace, "/upstream", "", "", http.StatusMethodNotAllowed, ""},
+		{http.MethodPost, "/echo", "", "hello", http.StatusUnsupportedMediaType, ""},
+		{http.MethodPost, "/echo", "text/plain", "hello", http.StatusOK, "hello"},
+		{http.MethodPost, "/echo", "text/plain", "hello world", http.StatusRequestEntityTooLarge, ""},
+		{http.MethodPut, "/users?Age=x", "text/plain", "", http.StatusBadRequest, "Bad Request"},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		if test.contentType != "" {
+			req.Header.Set("Content-Type", test.contentType)
+		}
+		webServer.mux.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("%s %s = %d, want %d", test.method, test.path, rec.Code, test.status)
+		}
+		if test.response != "" && rec.Body.String() != test.response {
+			t.Errorf("%s %s body = %q, want %q", test.method, test.path, rec.Body.String(), test.response)
+		}
+		if rec.Result().Header.Get("X-Content-Type-Options") != "nosniff" {
+			t.Errorf("%s %s misses X-Content-Type-Options", test.method, test.path)
+		}
+	}
+}
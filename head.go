@@ -0,0 +1,49 @@
+package webserver
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// headWriter runs a GET handler for a HEAD request, it discards the body and holds the header back
+// until the handler returned to set Content-Length from the discarded bytes
+type headWriter struct {
+	http.ResponseWriter
+
+	status  int
+	written int64
+}
+
+func newHeadWriter(rw http.ResponseWriter) *headWriter {
+	return &headWriter{ResponseWriter: rw}
+}
+
+func (rw *headWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+}
+
+func (rw *headWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.written += int64(len(b))
+	return len(b), nil
+}
+
+func (rw *headWriter) finish() {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	header := rw.Header()
+	if header.Get("Content-Length") == "" && header.Get("Transfer-Encoding") == "" &&
+		rw.status != http.StatusNoContent && rw.status != http.StatusNotModified && rw.status >= 200 {
+		header.Set("Content-Length", strconv.FormatInt(rw.written, 10))
+	}
+	rw.ResponseWriter.WriteHeader(rw.status)
+}
+
+func (rw *headWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
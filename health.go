@@ -0,0 +1,108 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// readinessTimeout is how long the readiness endpoint waits for its checks
+const readinessTimeout = 5 * time.Second
+
+// HealthStatus is the json body of the health check endpoints
+type HealthStatus struct {
+	Status string `json:"status"`
+	// Checks maps the name of every readiness check to "ok" or its error
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type readinessCheck struct {
+	name  string
+	check func() error
+}
+
+// EnableHealthChecks serves liveness at livePath, answering 200 as long as the server handles requests,
+// and readiness at readyPath, answering 200 if every readiness check passed and 503 otherwise.
+// An empty path disables the endpoint.
+func (webServer *WebServer) EnableHealthChecks(livePath string, readyPath string) {
+	if livePath != "" {
+		webServer.NewHandleFunc(HTTPMethodGet, livePath, func(rw http.ResponseWriter, req *http.Request) {
+			webServer.writeHealth(rw, http.StatusOK, HealthStatus{Status: "ok"})
+		})
+	}
+	if readyPath != "" {
+		webServer.NewHandleFunc(HTTPMethodGet, readyPath, func(rw http.ResponseWriter, req *http.Request) {
+			status := webServer.readiness()
+			if status.Status == "ok" {
+				webServer.writeHealth(rw, http.StatusOK, status)
+			} else {
+				webServer.writeHealth(rw, http.StatusServiceUnavailable, status)
+			}
+		})
+	}
+}
+
+// AddReadinessCheck adds a probe, e.g. a database ping, to the readiness endpoint. Checks run concurrently on every
+// readiness request, a check not returning within 5 seconds fails.
+func (webServer *WebServer) AddReadinessCheck(name string, check func() error) {
+	webServer.readinessMutex.Lock()
+	defer webServer.readinessMutex.Unlock()
+	webServer.readinessChecks = append(webServer.readinessChecks, readinessCheck{name: name, check: check})
+}
+
+func (webServer *WebServer) readiness() HealthStatus {
+	webServer.readinessMutex.Lock()
+	checks := append([]readinessCheck{}, webServer.readinessChecks...)
+	webServer.readinessMutex.Unlock()
+
+	results := map[string]string{}
+	var mutex sync.Mutex
+	var wait sync.WaitGroup
+	for _, check := range checks {
+		results[check.name] = "timeout"
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			result := "ok"
+			err := check.check()
+			if err != nil {
+				result = err.Error()
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			results[check.name] = result
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wait.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(readinessTimeout):
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	result := HealthStatus{Status: "ok", Checks: map[string]string{}}
+	for name, checkResult := range results {
+		result.Checks[name] = checkResult
+		if checkResult != "ok" {
+			result.Status = "unavailable"
+		}
+	}
+	return result
+}
+
+func (webServer *WebServer) writeHealth(rw http.ResponseWriter, code int, status HealthStatus) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.WriteHeader(code)
+	err := json.NewEncoder(rw).Encode(status)
+	if err != nil {
+		webServer.logger.Println("Health Check: " + err.Error())
+	}
+}
@@ -0,0 +1,39 @@
+package webserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthChecks(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	webServer.EnableHealthChecks("/livez", "/readyz")
+	var dbErr error
+	webServer.AddReadinessCheck("database", func() error { return dbErr })
+	webServer.AddReadinessCheck("cache", func() error { return nil })
+
+	get := func(path string) (int, HealthStatus) {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var status HealthStatus
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+			t.Fatalf("%s: %v %q", path, err, rec.Body.String())
+		}
+		return rec.Code, status
+	}
+
+	if code, status := get("/livez"); code != http.StatusOK || status.Status != "ok" || status.Checks != nil {
+		t.Errorf("live: %d %+v", code, status)
+	}
+	if code, status := get("/readyz"); code != http.StatusOK || status.Checks["database"] != "ok" || status.Checks["cache"] != "ok" {
+		t.Errorf("ready: %d %+v", code, status)
+	}
+	dbErr = errors.New("connection refused")
+	if code, status := get("/readyz"); code != http.StatusServiceUnavailable || status.Status != "unavailable" ||
+		status.Checks["database"] != "connection refused" || status.Checks["cache"] != "ok" {
+		t.Errorf("not ready: %d %+v", code, status)
+	}
+}
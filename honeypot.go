@@ -0,0 +1,28 @@
+package webserver
+
+import (
+	"net/http"
+)
+
+// SetHoneypot answers requests to paths, which no legitimate client requests, e.g. "/wp-login.php" or "/.env",
+// with 404 Not Found and emits HoneypotHit for them
+func (webServer *WebServer) SetHoneypot(paths ...string) {
+	if webServer.honeypots == nil {
+		webServer.honeypots = map[string]bool{}
+		webServer.NewPhaseMiddleware(PhaseSecurity, webServer.checkHoneypot)
+	}
+	for _, path := range paths {
+		webServer.honeypots[path] = true
+	}
+}
+
+func (webServer *WebServer) checkHoneypot(rw http.ResponseWriter, req *http.Request) bool {
+	if !webServer.honeypots[req.URL.Path] {
+		return true
+	}
+	ip := ClientIP(req)
+	webServer.emit(HoneypotHit{IP: ip, Method: req.Method, Path: req.URL.Path, UserAgent: req.UserAgent()})
+	webServer.writeError(rw, req, http.StatusNotFound)
+	webServer.logger.Println("Honeypot: 404: " + ip + " " + req.URL.Path)
+	return false
+}
@@ -0,0 +1,29 @@
+package webserver
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func configureHttp2(server *http.Server, settings Settings) error {
+	h2Server := &http2.Server{
+		MaxConcurrentStreams: settings.Http2MaxConcurrentStreams,
+		MaxReadFrameSize:     settings.Http2MaxReadFrameSize,
+		IdleTimeout:          settings.Http2IdleTimeout,
+	}
+
+	if settings.UseH2C {
+		server.Handler = h2c.NewHandler(server.Handler, h2Server)
+	}
+
+	if !settings.UseHttp2 {
+		// a non nil, empty map disables the automatic HTTP/2 upgrade of net/http
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		return nil
+	}
+
+	return http2.ConfigureServer(server, h2Server)
+}
@@ -0,0 +1,85 @@
+package webserver
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"testing"
+
+	"golang.org/x/net/http2"
+)
+
+// startHttp2Test starts a server with settings on a free port answering /proto with the protocol of the request
+func startHttp2Test(t *testing.T, settings *Settings) *WebServer {
+	settings.Bind = "127.0.0.1"
+	settings.HttpPort = "0"
+	settings.HttpsPort = "0"
+	settings.Root = "root"
+	webServer := NewWebServer(*settings)
+	webServer.NewHandleFunc(HTTPMethodGet, "/proto", func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(req.Proto))
+	})
+	err := webServer.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = webServer.Shutdown(context.Background()) })
+	return webServer
+}
+
+func TestUseHttp2(t *testing.T) {
+	for _, useHttp2 := range []bool{true, false} {
+		settings := NewSettings()
+		settings.UseHttps = true
+		settings.UseSelfSignedTLS = true
+		settings.UseHttp2 = useHttp2
+		webServer := startHttp2Test(t, settings)
+
+		transport := &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: webServer.SelfSignedCertPool()},
+			ForceAttemptHTTP2: true,
+		}
+		resp, err := (&http.Client{Transport: transport}).Get("https://" + webServer.ListenAddr() + "/proto")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		transport.CloseIdleConnections()
+		if want := map[bool]int{true: 2, false: 1}[useHttp2]; resp.ProtoMajor != want {
+			t.Errorf("UseHttp2 %t: negotiated %s, want HTTP/%d", useHttp2, resp.Proto, want)
+		}
+	}
+}
+
+func TestUseH2C(t *testing.T) {
+	// prior knowledge, the client speaks HTTP/2 on the plain connection without upgrade
+	transport := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network string, addr string, config *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	settings := NewSettings()
+	settings.UseH2C = true
+	webServer := startHttp2Test(t, settings)
+	resp, err := client.Get("http://" + webServer.ListenAddr() + "/proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("h2c prior knowledge: %s, want HTTP/2", resp.Proto)
+	}
+
+	settings = NewSettings()
+	settings.UseH2C = false
+	webServer = startHttp2Test(t, settings)
+	_, err = client.Get("http://" + webServer.ListenAddr() + "/proto")
+	if err == nil {
+		t.Error("h2c prior knowledge was accepted without UseH2C")
+	}
+}
@@ -0,0 +1,55 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// startHttpRedirect binds Settings.HttpPort and redirects its requests to the https server, keeping path and query
+func (webServer *WebServer) startHttpRedirect(settings Settings) error {
+	redirectSettings := settings
+	redirectSettings.UseHttps = false
+	listener, err := listen(redirectSettings, redirectSettings.BindAddr())
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			current := webServer.Settings()
+			url := current.UrlHttps() + req.URL.RequestURI()
+			http.Redirect(rw, req, url, http.StatusMovedPermanently)
+			webServer.logger.Println("Redirect: http to https 301 to " + url)
+		}),
+		ReadTimeout:       settings.ReadTimeout,
+		ReadHeaderTimeout: settings.ReadHeaderTimeout,
+		WriteTimeout:      settings.WriteTimeout,
+		IdleTimeout:       settings.IdleTimeout,
+		MaxHeaderBytes:    settings.MaxHeaderBytes,
+	}
+	redirect := &serving{server: server, listener: listener}
+	webServer.servingMutex.Lock()
+	webServer.httpRedirect = redirect
+	webServer.servingMutex.Unlock()
+
+	go func() {
+		err := server.Serve(listener)
+		if !errors.Is(err, http.ErrServerClosed) {
+			webServer.logger.Println("Redirect: " + err.Error())
+		}
+	}()
+	return nil
+}
+
+// stopHttpRedirect shuts the http redirect down, waiting for its requests until ctx is done
+func (webServer *WebServer) stopHttpRedirect(ctx context.Context) error {
+	webServer.servingMutex.Lock()
+	redirect := webServer.httpRedirect
+	webServer.httpRedirect = nil
+	webServer.servingMutex.Unlock()
+	if redirect == nil {
+		return nil
+	}
+	return redirect.server.Shutdown(ctx)
+}
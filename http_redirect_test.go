@@ -0,0 +1,67 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestHttpRedirect(t *testing.T) {
+	settings := NewSettings()
+	settings.Bind = "127.0.0.1"
+	settings.HttpPort = "0"
+	settings.HttpsPort = "8443"
+	settings.UseHttps = true
+	settings.UseSelfSignedTLS = true
+	settings.UseHttpRedirect = true
+	settings.FallbackPorts = []string{"0"}
+	settings.Root = "root"
+	webServer := NewWebServer(*settings)
+	err := webServer.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer webServer.Shutdown(context.Background())
+
+	webServer.servingMutex.Lock()
+	redirectAddr := webServer.httpRedirect.listener.Addr().String()
+	webServer.servingMutex.Unlock()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	response, err := client.Get("http://" + redirectAddr + "/docs/page?q=a+b&x=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = response.Body.Close()
+	current := webServer.Settings()
+	want := current.UrlHttps() + "/docs/page?q=a+b&x=1"
+	if response.StatusCode != http.StatusMovedPermanently || response.Header.Get("Location") != want {
+		t.Errorf("redirect = %d %q, want %q", response.StatusCode, response.Header.Get("Location"), want)
+	}
+
+	// a redirect port in use fails Start instead of panicking, the main listener is released again
+	blocker, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blocker.Close()
+	_, port, _ := net.SplitHostPort(blocker.Addr().String())
+	blocked := *settings
+	blocked.HttpPort = port
+	blocked.HttpsPort = "0"
+	blocked.FallbackPorts = nil
+	err = NewWebServer(blocked).Start()
+	if !errors.Is(err, ErrPortInUse) {
+		t.Errorf("Start with the http port in use = %v, want ErrPortInUse", err)
+	}
+
+	err = webServer.Shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = net.Dial("tcp", redirectAddr)
+	if err == nil {
+		t.Error("redirect still accepts connections after Shutdown")
+	}
+}
@@ -0,0 +1,249 @@
+package webserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"mime/multipart"
+	"net/http"
+)
+
+var errInvalidImage = errors.New("invalid image")
+
+// sanitizeUploads replaces jpeg and png file parts by copies without metadata.
+// The returned forms hold the copies and have to be removed after the request.
+func sanitizeUploads(files map[string][]*multipart.FileHeader, maxMemory int64) ([]*multipart.Form, error) {
+	var forms []*multipart.Form
+	for _, headers := range files {
+		for i, header := range headers {
+			file, err := header.Open()
+			if err != nil {
+				return forms, err
+			}
+			content, err := io.ReadAll(file)
+			_ = file.Close()
+			if err != nil {
+				return forms, err
+			}
+
+			sanitized, changed, err := stripImageMetadata(content)
+			if err != nil || !changed {
+				continue
+			}
+
+			replacement, form, err := newFileHeader(header, sanitized, maxMemory)
+			if err != nil {
+				return forms, err
+			}
+			forms = append(forms, form)
+			headers[i] = replacement
+		}
+	}
+	return forms, nil
+}
+
+// newFileHeader creates a file header like header with another content by parsing it from a multipart body,
+// as file headers cannot be built otherwise
+func newFileHeader(header *multipart.FileHeader, content []byte, maxMemory int64) (*multipart.FileHeader, *multipart.Form, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreatePart(header.Header)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = part.Write(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	form, err := multipart.NewReader(body, writer.Boundary()).ReadForm(maxMemory)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, headers := range form.File {
+		return headers[0], form, nil
+	}
+	_ = form.RemoveAll()
+	return nil, nil, errors.New("multipart: file part lost")
+}
+
+// stripImageMetadata removes exif, xmp, iptc and comments from jpeg and text and exif chunks from png images.
+// Jpeg images with an exif orientation are rotated so they display the same without it.
+// Other content is returned unchanged.
+func stripImageMetadata(content []byte) ([]byte, bool, error) {
+	switch http.DetectContentType(content) {
+	case "image/jpeg":
+		return stripJPEGMetadata(content)
+	case "image/png":
+		return stripPNGMetadata(content)
+	}
+	return content, false, nil
+}
+
+func stripJPEGMetadata(content []byte) ([]byte, bool, error) {
+	stripped := []byte{0xFF, 0xD8}
+	orientation := 1
+	changed := false
+
+	rest := content[2:]
+	for {
+		if len(rest) < 4 || rest[0] != 0xFF {
+			return nil, false, errInvalidImage
+		}
+		marker := rest[1]
+		if marker == 0xDA {
+			// the entropy coded data follows the start of scan, nothing after it is metadata
+			stripped = append(stripped, rest...)
+			break
+		}
+		length := int(binary.BigEndian.Uint16(rest[2:4]))
+		if length < 2 || len(rest) < 2+length {
+			return nil, false, errInvalidImage
+		}
+		segment := rest[:2+length]
+		rest = rest[2+length:]
+
+		switch marker {
+		case 0xE1:
+			// APP1 holds exif or xmp
+			if bytes.HasPrefix(segment[4:], []byte("Exif\x00\x00")) {
+				orientation = exifOrientation(segment[10:])
+			}
+			changed = true
+		case 0xED, 0xFE:
+			// APP13 holds iptc, COM comments
+			changed = true
+		default:
+			stripped = append(stripped, segment...)
+		}
+	}
+
+	if orientation < 2 || orientation > 8 {
+		return stripped, changed, nil
+	}
+	img, err := jpeg.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		return nil, false, err
+	}
+	rotated := &bytes.Buffer{}
+	err = jpeg.Encode(rotated, orient(img, orientation), &jpeg.Options{Quality: 95})
+	if err != nil {
+		return nil, false, err
+	}
+	return rotated.Bytes(), true, nil
+}
+
+// exifOrientation reads the orientation tag of the first image file directory of a tiff header, 1 if it has none
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < entries; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 1
+}
+
+// orient transforms img as the exif orientation describes so it displays upright without it
+func orient(img image.Image, orientation int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if orientation >= 5 {
+		w, h = h, w
+	}
+	src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	W, H := bounds.Dx(), bounds.Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = W-1-x, y
+			case 3:
+				sx, sy = W-1-x, H-1-y
+			case 4:
+				sx, sy = x, H-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, H-1-x
+			case 7:
+				sx, sy = W-1-y, H-1-x
+			case 8:
+				sx, sy = W-1-y, x
+			}
+			dst.SetRGBA(x, y, src.RGBAAt(sx, sy))
+		}
+	}
+	return dst
+}
+
+// pngMetadataChunks are the ancillary chunks holding text, exif and timestamps
+var pngMetadataChunks = map[string]bool{
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"eXIf": true,
+	"tIME": true,
+}
+
+func stripPNGMetadata(content []byte) ([]byte, bool, error) {
+	stripped := append([]byte{}, content[:8]...)
+	changed := false
+
+	rest := content[8:]
+	for len(rest) > 0 {
+		if len(rest) < 12 {
+			return nil, false, errInvalidImage
+		}
+		length := int(binary.BigEndian.Uint32(rest[:4]))
+		if length < 0 || len(rest) < 12+length {
+			return nil, false, errInvalidImage
+		}
+		chunk := rest[:12+length]
+		rest = rest[12+length:]
+
+		if binary.BigEndian.Uint32(chunk[8+length:]) != crc32.ChecksumIEEE(chunk[4:8+length]) {
+			return nil, false, errInvalidImage
+		}
+		if pngMetadataChunks[string(chunk[4:8])] {
+			changed = true
+			continue
+		}
+		stripped = append(stripped, chunk...)
+	}
+	return stripped, changed, nil
+}
@@ -0,0 +1,143 @@
+package webserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			if x < 8 {
+				img.Set(x, y, color.RGBA{R: 255, A: 255})
+			} else {
+				img.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+	return img
+}
+
+func jpegWithExif(t *testing.T, orientation uint16) []byte {
+	encoded := &bytes.Buffer{}
+	err := jpeg.Encode(encoded, testImage(), &jpeg.Options{Quality: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	exif = binary.BigEndian.AppendUint16(exif, 0x0112)
+	exif = binary.BigEndian.AppendUint16(exif, 3)
+	exif = binary.BigEndian.AppendUint32(exif, 1)
+	exif = binary.BigEndian.AppendUint16(exif, orientation)
+	exif = append(exif, 0, 0, 0, 0, 0, 0)
+	app1 := binary.BigEndian.AppendUint16([]byte{0xFF, 0xE1}, uint16(len(exif)+2))
+	comment := []byte{0xFF, 0xFE, 0x00, 0x06, 'g', 'p', 's', '!'}
+
+	jpg := append([]byte{0xFF, 0xD8}, app1...)
+	jpg = append(jpg, exif...)
+	jpg = append(jpg, comment...)
+	return append(jpg, encoded.Bytes()[2:]...)
+}
+
+func pngWithText(t *testing.T) []byte {
+	encoded := &bytes.Buffer{}
+	err := png.Encode(encoded, testImage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("tEXtAuthor\x00alice")
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)-4))
+	chunk = append(chunk, data...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(data))
+
+	// the text chunk goes after the 8 byte signature and the 25 byte IHDR chunk
+	result := append([]byte{}, encoded.Bytes()[:33]...)
+	result = append(result, chunk...)
+	return append(result, encoded.Bytes()[33:]...)
+}
+
+func TestStripImageMetadata(t *testing.T) {
+	type upload struct {
+		File *multipart.FileHeader `form:"file"`
+	}
+	uploaded := func(strip bool, content []byte) []byte {
+		settings := NewSettings()
+		settings.StripImageMetadata = strip
+		webServer := NewWebServer(*settings)
+		var result []byte
+		NewMultipartHandler(webServer, HTTPMethodPost, "/upload", func(rw http.ResponseWriter, req *http.Request, values upload) {
+			file, err := values.File.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, _ = io.ReadAll(file)
+			_ = file.Close()
+			if values.File.Filename != "photo" || values.File.Size != int64(len(result)) {
+				t.Errorf("file header = %+v", values.File)
+			}
+		})
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "photo")
+		_, _ = part.Write(content)
+		_ = writer.Close()
+		req := httptest.NewRequest(http.MethodPost, "/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d", rec.Code)
+		}
+		return result
+	}
+
+	jpg := jpegWithExif(t, 6)
+	if !bytes.Equal(uploaded(false, jpg), jpg) {
+		t.Error("disabled stripping changed the upload")
+	}
+
+	stripped := uploaded(true, jpg)
+	if bytes.Contains(stripped, []byte("Exif")) || bytes.Contains(stripped, []byte("gps!")) {
+		t.Error("jpeg metadata not stripped")
+	}
+	img, err := jpeg.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 8 || img.Bounds().Dy() != 16 {
+		t.Fatalf("rotated size = %v", img.Bounds())
+	}
+	if r, _, b, _ := img.At(4, 2).RGBA(); r < b {
+		t.Errorf("top of rotated image is not red")
+	}
+	if r, _, b, _ := img.At(4, 13).RGBA(); b < r {
+		t.Errorf("bottom of rotated image is not blue")
+	}
+
+	pngFile := pngWithText(t)
+	stripped = uploaded(true, pngFile)
+	if bytes.Contains(stripped, []byte("alice")) || len(stripped) >= len(pngFile) {
+		t.Error("png metadata not stripped")
+	}
+	_, err = png.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		t.Error(err)
+	}
+
+	if text := uploaded(true, []byte("plain text")); string(text) != "plain text" {
+		t.Errorf("text upload changed to %q", text)
+	}
+}
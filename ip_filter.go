@@ -0,0 +1,97 @@
+package webserver
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type IPFilterOptions struct {
+	// Allow accepts only clients in these ranges, like "10.0.0.0/8" or "2001:db8::1", empty accepts every client
+	Allow []string
+	// Deny rejects clients in these ranges, even if Allow accepts them
+	Deny []string
+	// AllowCountries accepts only clients of these countries, DenyCountries and DenyASNs reject clients of these
+	// countries and autonomous systems, see SetGeoIP. Clients without GeoInfo pass unless AllowCountries is set.
+	AllowCountries []string
+	DenyCountries  []string
+	DenyASNs       []uint32
+}
+
+func NewIPFilterOptions() *IPFilterOptions {
+	return &IPFilterOptions{
+		Allow:          []string{},
+		Deny:           []string{},
+		AllowCountries: []string{},
+		DenyCountries:  []string{},
+		DenyASNs:       []uint32{},
+	}
+}
+
+type ipFilterRule struct {
+	prefix   string
+	allow    []*net.IPNet
+	deny     []*net.IPNet
+	allowGeo GeoMatch
+	denyGeo  GeoMatch
+}
+
+// EnableIPFilter filters every request by client address, route filters set with SetIPFilter apply in addition
+func (webServer *WebServer) EnableIPFilter(options IPFilterOptions) error {
+	return webServer.SetIPFilter("", options)
+}
+
+// SetIPFilter filters requests below prefix by their ClientIP, behind a proxy it has to be in Settings.TrustedProxies.
+// A request has to pass every matching filter, otherwise it is answered with 403 Forbidden.
+// An invalid range returns an error and leaves the filters unchanged.
+func (webServer *WebServer) SetIPFilter(prefix string, options IPFilterOptions) error {
+	allow, err := parseCIDRs(options.Allow)
+	if err != nil {
+		return err
+	}
+	deny, err := parseCIDRs(options.Deny)
+	if err != nil {
+		return err
+	}
+
+	if webServer.ipFilters == nil {
+		webServer.NewPhaseMiddleware(PhaseSecurity, webServer.checkIPFilters)
+	}
+
+	rule := ipFilterRule{
+		prefix:   prefix,
+		allow:    allow,
+		deny:     deny,
+		allowGeo: GeoMatch{Countries: options.AllowCountries},
+		denyGeo:  GeoMatch{Countries: options.DenyCountries, ASNs: options.DenyASNs},
+	}
+	for i, existing := range webServer.ipFilters {
+		if existing.prefix == prefix {
+			webServer.ipFilters[i] = rule
+			return nil
+		}
+	}
+	webServer.ipFilters = append(webServer.ipFilters, rule)
+	sort.SliceStable(webServer.ipFilters, func(i, j int) bool {
+		return len(webServer.ipFilters[i].prefix) > len(webServer.ipFilters[j].prefix)
+	})
+	return nil
+}
+
+func (webServer *WebServer) checkIPFilters(rw http.ResponseWriter, req *http.Request) bool {
+	for _, rule := range webServer.ipFilters {
+		if !strings.HasPrefix(req.URL.Path, rule.prefix) {
+			continue
+		}
+		ip := ClientIP(req)
+		if containsIP(rule.deny, net.ParseIP(ip)) || (len(rule.allow) > 0 && !containsIP(rule.allow, net.ParseIP(ip))) ||
+			rule.denyGeo.Matches(req) || (!rule.allowGeo.empty() && !rule.allowGeo.Matches(req)) {
+			webServer.writeError(rw, req, http.StatusForbidden)
+			webServer.logger.Println("IP Filter: 403: " + ip + " " + req.URL.Path)
+			webServer.emit(IPBlocked{IP: ip, Path: req.URL.Path})
+			return false
+		}
+	}
+	return true
+}
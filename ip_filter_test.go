@@ -0,0 +1,67 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPFilter(t *testing.T) {
+	settings := NewSettings()
+	settings.Root = "root"
+	settings.TrustedProxies = []string{"10.0.0.1", "10.1.0.0/16"}
+	webServer := NewWebServer(*settings)
+	global := NewIPFilterOptions()
+	global.Deny = []string{"203.0.113.0/24"}
+	if err := webServer.EnableIPFilter(*global); err != nil {
+		t.Fatal(err)
+	}
+	admin := NewIPFilterOptions()
+	admin.Allow = []string{"192.168.0.0/16", "2001:db8::/32"}
+	if err := webServer.SetIPFilter("/admin", *admin); err != nil {
+		t.Fatal(err)
+	}
+	webServer.NewHandleFunc(HTTPMethodGet, "/admin", func(rw http.ResponseWriter, req *http.Request) {})
+
+	tests := []struct {
+		remote       string
+		forwardedFor string
+		realIP       string
+		path         string
+		status       int
+	}{
+		{"198.51.100.1:1000", "", "", "/", http.StatusOK},
+		{"203.0.113.5:1000", "", "", "/", http.StatusForbidden},
+		{"203.0.113.5:1000", "198.51.100.1", "", "/", http.StatusForbidden},
+		{"10.0.0.1:1000", "203.0.113.5", "", "/", http.StatusForbidden},
+		{"10.0.0.1:1000", "203.0.113.5, 198.51.100.1", "", "/", http.StatusOK},
+		{"10.0.0.1:1000", "198.51.100.1, 203.0.113.5, 10.1.2.3", "", "/", http.StatusForbidden},
+		{"10.0.0.1:1000", "", "203.0.113.5", "/", http.StatusForbidden},
+		{"192.168.1.1:1000", "", "", "/admin", http.StatusOK},
+		{"[2001:db8::1]:1000", "", "", "/admin", http.StatusOK},
+		{"198.51.100.1:1000", "", "", "/admin", http.StatusForbidden},
+		{"198.51.100.1:1000", "192.168.1.1", "", "/admin", http.StatusForbidden},
+		{"10.0.0.1:1000", "192.168.1.1", "", "/admin", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		req.RemoteAddr = test.remote
+		if test.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", test.forwardedFor)
+		}
+		if test.realIP != "" {
+			req.Header.Set("X-Real-IP", test.realIP)
+		}
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("%s (X-Forwarded-For %q, X-Real-IP %q) %s: status %d, want %d",
+				test.remote, test.forwardedFor, test.realIP, test.path, rec.Code, test.status)
+		}
+	}
+
+	if err := webServer.SetIPFilter("/", IPFilterOptions{Allow: []string{"10.0.0.0/33"}}); err == nil {
+		t.Error("SetIPFilter with invalid range: no error")
+	}
+}
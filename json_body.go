@@ -0,0 +1,64 @@
+package webserver
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// NewJsonBodyHandler decodes application/json bodies into T and validates them with Validate, bodies breaking a
+// `validate` tag or failing Validatable are answered with 422 Unprocessable Entity and a json APIError listing
+// the field errors
+func NewJsonBodyHandler[T any](
+	webServer *WebServer,
+	method HTTPMethod,
+	pattern string,
+	handler func(http.ResponseWriter, *http.Request, T),
+) {
+	checkValidationTags(reflect.TypeFor[T](), map[reflect.Type]bool{})
+
+	webServer.NewHandleFunc(method, pattern, func(rw http.ResponseWriter, req *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+			webServer.unsupportedMediaType(rw, req, mediaType)
+			return
+		}
+
+		webServer.limitBody(rw, req)
+		var values T
+		err := json.NewDecoder(req.Body).Decode(&values)
+		if err != nil {
+			webServer.bodyError(rw, req, err)
+			return
+		}
+
+		err = Validate(values)
+		if err != nil {
+			webServer.validationError(rw, req, err)
+			return
+		}
+
+		handler(rw, req, values)
+	})
+	webServer.documentRequest(method, pattern, "json", reflect.TypeFor[T]())
+}
+
+// validationError answers a failed validation with 422 and the field errors
+func (webServer *WebServer) validationError(rw http.ResponseWriter, req *http.Request, err error) {
+	if webServer.handleError(rw, req, err, http.StatusUnprocessableEntity) {
+		return
+	}
+
+	apiError := APIError{Status: http.StatusUnprocessableEntity, Code: "validation_failed", Message: "validation failed"}
+	var fields ValidationErrors
+	if errors.As(err, &fields) {
+		apiError.Fields = fields
+	} else {
+		apiError.Fields = []FieldError{{Message: err.Error()}}
+	}
+	webServer.writeAPIError(rw, apiError)
+	webServer.logger.Println("Validation: 422: " + req.URL.Path + ": " + err.Error())
+}
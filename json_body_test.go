@@ -0,0 +1,86 @@
+package webserver
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type signup struct {
+	Name    string   `json:"name" validate:"required,min=2,max=20"`
+	Age     int      `json:"age" validate:"min=18"`
+	Color   string   `json:"color" validate:"enum=red|green"`
+	Code    string   `json:"code" validate:"regexp=^[a-z]{2,3}$"`
+	Tags    []string `json:"tags" validate:"max=2"`
+	Address *struct {
+		City string `json:"city" validate:"required"`
+	} `json:"address"`
+	Password string `json:"password"`
+}
+
+func (body signup) Validate() error {
+	if body.Password == body.Name {
+		return ValidationErrors{{Field: "password", Message: "must differ from name"}}
+	}
+	return nil
+}
+
+func TestJsonBodyHandler(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	NewJsonBodyHandler(webServer, HTTPMethodPost, "/signup", func(rw http.ResponseWriter, req *http.Request, body signup) {
+		_, _ = rw.Write([]byte(body.Name))
+	})
+
+	tests := []struct {
+		contentType, body string
+		status            int
+		response          string
+	}{
+		{"application/json", `{"name":"alice","age":20,"color":"red","code":"ab","password":"x"}`, http.StatusOK, "alice"},
+		{"text/plain", `{}`, http.StatusUnsupportedMediaType, ""},
+		{"application/json", `{"name":`, http.StatusBadRequest, ""},
+		{"application/json", `{"name":"a","age":3,"color":"blue","code":"a,b","tags":["a","b","c"],"address":{}}`, http.StatusUnprocessableEntity,
+			`{"error":{"status":422,"code":"validation_failed","message":"validation failed","fields":[` +
+				`{"field":"name","message":"must have at least 2 characters"},` +
+				`{"field":"age","message":"must be at least 18"},` +
+				`{"field":"color","message":"must be one of red, green"},` +
+				`{"field":"code","message":"must match ^[a-z]{2,3}$"},` +
+				`{"field":"tags","message":"must have at most 2 items"},` +
+				`{"field":"address.city","message":"is required"}]}}` + "\n"},
+		{"application/json", `{"name":"bob","age":30,"password":"bob"}`, http.StatusUnprocessableEntity,
+			`{"error":{"status":422,"code":"validation_failed","message":"validation failed","fields":[` +
+				`{"field":"password","message":"must differ from name"}]}}` + "\n"},
+	}
+	for i, test := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", test.contentType)
+		webServer.mux.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("%d: status = %d, want %d", i, rec.Code, test.status)
+		}
+		if test.response != "" && rec.Body.String() != test.response {
+			t.Errorf("%d: body = %s, want %s", i, rec.Body.String(), test.response)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var errs ValidationErrors
+	err := Validate(signup{Age: 18, Password: "x"})
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "name" {
+		t.Errorf("Validate = %v, want name is required", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("invalid validate tag did not panic on registration")
+		}
+	}()
+	NewJsonBodyHandler(NewWebServer(*NewSettings()), HTTPMethodPost, "/", func(rw http.ResponseWriter, req *http.Request, body struct {
+		Name string `validate:"min=x"`
+	}) {
+	})
+}
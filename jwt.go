@@ -0,0 +1,199 @@
+package webserver
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+var (
+	ErrJWTMalformed = errors.New("jwt: malformed token")
+	ErrJWTAlgorithm = errors.New("jwt: unsupported algorithm")
+	ErrJWTSignature = errors.New("jwt: invalid signature")
+	ErrJWTExpired   = errors.New("jwt: token expired")
+	ErrJWTNotYet    = errors.New("jwt: token not valid yet")
+	ErrJWTIssuer    = errors.New("jwt: unexpected issuer")
+	ErrJWTAudience  = errors.New("jwt: unexpected audience")
+
+	errJWTMissing = errors.New("jwt: missing token")
+)
+
+type JWTOptions struct {
+	// HMACKey verifies HS256 tokens, RSAKey verifies RS256 tokens, a nil key rejects the algorithm
+	HMACKey []byte
+	RSAKey  *rsa.PublicKey
+	// Issuer and Audience are checked against iss and aud if set
+	Issuer   string
+	Audience string
+	// Cookie is read if the request has no bearer token
+	Cookie string
+	// Leeway is tolerated when checking exp and nbf
+	Leeway time.Duration
+}
+
+func NewJWTOptions() *JWTOptions {
+	return &JWTOptions{
+		Leeway: 30 * time.Second,
+	}
+}
+
+type jwtRule struct {
+	prefix  string
+	options JWTOptions
+}
+
+// Claims returns the claims of the verified token of the request
+func Claims(req *http.Request) (map[string]any, bool) {
+	principal, ok := PrincipalFrom(req)
+	if !ok || principal.Scheme != "JWT" {
+		return nil, false
+	}
+	return principal.Attributes, true
+}
+
+// RequireJWT protects the requests below prefix with JWT verification, the longest matching prefix wins.
+// Routes outside every prefix stay public. Requests without a valid token are answered with 401 Unauthorized.
+func (webServer *WebServer) RequireJWT(prefix string, options JWTOptions) {
+	if webServer.jwtRules == nil {
+		webServer.NewPhaseMiddleware(PhaseAuth, webServer.verifyJWTRequest)
+	}
+
+	rule := jwtRule{prefix: prefix, options: options}
+	for i, existing := range webServer.jwtRules {
+		if existing.prefix == prefix {
+			webServer.jwtRules[i] = rule
+			return
+		}
+	}
+	webServer.jwtRules = append(webServer.jwtRules, rule)
+	sort.SliceStable(webServer.jwtRules, func(i, j int) bool {
+		return len(webServer.jwtRules[i].prefix) > len(webServer.jwtRules[j].prefix)
+	})
+}
+
+func (webServer *WebServer) verifyJWTRequest(rw http.ResponseWriter, req *http.Request) bool {
+	for _, rule := range webServer.jwtRules {
+		if !strings.HasPrefix(req.URL.Path, rule.prefix) {
+			continue
+		}
+
+		token, ok := bearerToken(req)
+		if !ok && rule.options.Cookie != "" {
+			cookie, err := req.Cookie(rule.options.Cookie)
+			ok = err == nil && cookie.Value != ""
+			if ok {
+				token = cookie.Value
+			}
+		}
+		if !ok {
+			rw.Header().Set("WWW-Authenticate", `Bearer realm="Restricted"`)
+			webServer.writeErrorCause(rw, req, http.StatusUnauthorized, errJWTMissing)
+			webServer.emit(AuthFailed{Scheme: "JWT", IP: ClientIP(req), Path: req.URL.Path, Reason: "missing token"})
+			return false
+		}
+
+		claims, err := VerifyJWT(token, rule.options, time.Now())
+		if err != nil {
+			rw.Header().Set("WWW-Authenticate", `Bearer realm="Restricted", error="invalid_token"`)
+			webServer.writeErrorCause(rw, req, http.StatusUnauthorized, err)
+			webServer.logger.Println("JWT: 401: " + err.Error() + " (" + req.URL.Path + ")")
+			webServer.emit(AuthFailed{Scheme: "JWT", IP: ClientIP(req), Path: req.URL.Path, Reason: err.Error()})
+			return false
+		}
+		subject, _ := claims["sub"].(string)
+		setPrincipal(req, Principal{Name: subject, Scheme: "JWT", Attributes: claims})
+		return true
+	}
+	return true
+}
+
+// VerifyJWT checks the signature and the exp, nbf, iss and aud claims of a compact HS256 or RS256 token and returns its claims
+func VerifyJWT(token string, options JWTOptions, now time.Time) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrJWTMalformed
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	err := decodeJWTPart(parts[0], &header)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrJWTMalformed
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	switch {
+	case header.Alg == "HS256" && options.HMACKey != nil:
+		mac := hmac.New(sha256.New, options.HMACKey)
+		mac.Write(signed)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return nil, ErrJWTSignature
+		}
+	case header.Alg == "RS256" && options.RSAKey != nil:
+		digest := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(options.RSAKey, crypto.SHA256, digest[:], signature) != nil {
+			return nil, ErrJWTSignature
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrJWTAlgorithm, header.Alg)
+	}
+
+	var claims map[string]any
+	err = decodeJWTPart(parts[1], &claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(options.Leeway)) {
+		return nil, ErrJWTExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(nbf), 0).Add(-options.Leeway)) {
+		return nil, ErrJWTNotYet
+	}
+	if options.Issuer != "" && claims["iss"] != options.Issuer {
+		return nil, ErrJWTIssuer
+	}
+	if options.Audience != "" && !jwtAudience(claims["aud"], options.Audience) {
+		return nil, ErrJWTAudience
+	}
+	return claims, nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return ErrJWTMalformed
+	}
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		return ErrJWTMalformed
+	}
+	return nil
+}
+
+// jwtAudience reports whether aud, a string or a list of strings, contains audience
+func jwtAudience(aud any, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []any:
+		return slices.Contains(aud, any(audience))
+	}
+	return false
+}
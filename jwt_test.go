@@ -0,0 +1,135 @@
+package webserver
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func signJWT(t *testing.T, alg string, claims map[string]any, hmacKey []byte, rsaKey *rsa.PrivateKey) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var signature []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, hmacKey)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case "RS256":
+		digest := sha256.Sum256([]byte(signed))
+		signature, err = rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerifyJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmacKey := []byte("secret")
+	now := time.Unix(1700000000, 0)
+	options := JWTOptions{HMACKey: hmacKey, RSAKey: &rsaKey.PublicKey, Issuer: "auth", Audience: "api"}
+	valid := map[string]any{"sub": "alice", "iss": "auth", "aud": []string{"web", "api"}, "exp": now.Unix() + 60, "nbf": now.Unix() - 60}
+	with := func(key string, value any) map[string]any {
+		claims := map[string]any{}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		claims[key] = value
+		return claims
+	}
+
+	tests := []struct {
+		token string
+		err   error
+	}{
+		{signJWT(t, "HS256", valid, hmacKey, nil), nil},
+		{signJWT(t, "RS256", valid, nil, rsaKey), nil},
+		{signJWT(t, "HS256", valid, []byte("other"), nil), ErrJWTSignature},
+		{signJWT(t, "none", valid, nil, nil), ErrJWTAlgorithm},
+		{signJWT(t, "HS256", with("exp", now.Unix()-60), hmacKey, nil), ErrJWTExpired},
+		{signJWT(t, "HS256", with("exp", now.Unix()-10), hmacKey, nil), nil},
+		{signJWT(t, "HS256", with("nbf", now.Unix()+60), hmacKey, nil), ErrJWTNotYet},
+		{signJWT(t, "HS256", with("iss", "other"), hmacKey, nil), ErrJWTIssuer},
+		{signJWT(t, "HS256", with("aud", "web"), hmacKey, nil), ErrJWTAudience},
+		{signJWT(t, "HS256", with("aud", "api"), hmacKey, nil), nil},
+		{"a.b", ErrJWTMalformed},
+	}
+
+	options.Leeway = 30 * time.Second
+	for i, test := range tests {
+		claims, err := VerifyJWT(test.token, options, now)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%d: err = %v, want %v", i, err, test.err)
+		}
+		if err == nil && claims["sub"] != "alice" {
+			t.Errorf("%d: claims = %v", i, claims)
+		}
+	}
+}
+
+func TestRequireJWT(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	whoami := func(rw http.ResponseWriter, req *http.Request) {
+		claims, ok := Claims(req)
+		if ok {
+			_, _ = rw.Write([]byte(claims["sub"].(string)))
+		}
+	}
+	webServer.NewHandleFunc(HTTPMethodGet, "/public", whoami)
+	webServer.NewHandleFunc(HTTPMethodGet, "/api/", whoami)
+	options := NewJWTOptions()
+	options.HMACKey = []byte("secret")
+	options.Cookie = "token"
+	webServer.RequireJWT("/api/", *options)
+
+	token := signJWT(t, "HS256", map[string]any{"sub": "alice", "exp": time.Now().Unix() + 60}, options.HMACKey, nil)
+	tests := []struct {
+		path, header, cookie string
+		status               int
+		body                 string
+	}{
+		{"/public", "", "", http.StatusOK, ""},
+		{"/api/users", "", "", http.StatusUnauthorized, ""},
+		{"/api/users", "Bearer " + token, "", http.StatusOK, "alice"},
+		{"/api/users", "", token, http.StatusOK, "alice"},
+		{"/api/users", "Bearer " + token + "x", "", http.StatusUnauthorized, ""},
+	}
+
+	for i, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if test.header != "" {
+			req.Header.Set("Authorization", test.header)
+		}
+		if test.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "token", Value: test.cookie})
+		}
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+
+		if rec.Code != test.status || rec.Body.String() != test.body {
+			t.Errorf("%d: %d %q, want %d %q", i, rec.Code, rec.Body.String(), test.status, test.body)
+		}
+	}
+}
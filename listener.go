@@ -0,0 +1,165 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// listenMain binds the listener for the main server, the unix socket if set or Bind:Port
+func listenMain(settings Settings) (net.Listener, error) {
+	if settings.UnixSocket != "" {
+		return listenUnix(settings, settings.UnixSocket)
+	}
+	return listen(settings, settings.BindAddr())
+}
+
+// SetPortFallbackHook registers a hook called by Run with the port of Settings.FallbackPorts it bound
+// because the configured port was in use, e.g. to open the browser of a desktop app on the right url
+func (webServer *WebServer) SetPortFallbackHook(hook func(port string)) {
+	webServer.portFallbackHook = hook
+}
+
+// listenMainFallback binds the main listener like listenMain and tries Settings.FallbackPorts if the port is in use.
+// The bound port is stored in the settings, so Url and Settings report it.
+func (webServer *WebServer) listenMainFallback(settings Settings) (net.Listener, error) {
+	listener, err := listenMain(settings)
+	if !errors.Is(err, ErrPortInUse) || settings.UnixSocket != "" || len(settings.FallbackPorts) == 0 {
+		return listener, err
+	}
+
+	for _, port := range settings.FallbackPorts {
+		candidate := settings
+		if candidate.UseHttps {
+			candidate.HttpsPort = port
+		} else {
+			candidate.HttpPort = port
+		}
+		listener, err = listen(candidate, candidate.BindAddr())
+		if errors.Is(err, ErrPortInUse) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// the bound port differs from port "0"
+		_, port, _ = net.SplitHostPort(listener.Addr().String())
+		webServer.settingsMutex.Lock()
+		if settings.UseHttps {
+			webServer.settings.HttpsPort = port
+		} else {
+			webServer.settings.HttpPort = port
+		}
+		webServer.settingsMutex.Unlock()
+		webServer.logger.Println("WebServer: port " + settings.Port() + " in use, using " + port)
+		if webServer.portFallbackHook != nil {
+			webServer.portFallbackHook(port)
+		}
+		return listener, nil
+	}
+	return nil, fmt.Errorf("%w: %s and fallback ports %s", ErrPortInUse, settings.Port(), strings.Join(settings.FallbackPorts, ", "))
+}
+
+func listen(settings Settings, addr string) (net.Listener, error) {
+	listenConfig := net.ListenConfig{}
+	if settings.ReusePort {
+		listenConfig.Control = func(network, address string, conn syscall.RawConn) error {
+			var sockErr error
+			err := conn.Control(func(fd uintptr) {
+				sockErr = setReusePort(fd)
+			})
+			if err != nil {
+				return err
+			}
+			return sockErr
+		}
+	}
+
+	listener, err := listenConfig.Listen(context.Background(), settings.Network(), addr)
+	if err != nil {
+		return nil, listenError(addr, err)
+	}
+	return wrapListener(settings, listener)
+}
+
+func listenUnix(settings Settings, socket string) (net.Listener, error) {
+	// a socket file left over by a previous run would make the bind fail, it is removed unless a process accepts on it
+	info, err := os.Stat(socket)
+	if err == nil && info.Mode()&fs.ModeSocket != 0 {
+		conn, dialErr := net.DialTimeout("unix", socket, time.Second)
+		if dialErr == nil {
+			_ = conn.Close()
+		}
+		if !errors.Is(dialErr, syscall.ECONNREFUSED) {
+			return nil, fmt.Errorf("%w: %s, a running process owns the socket, stop it or choose another socket", ErrPortInUse, socket)
+		}
+		err = os.Remove(socket)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	return wrapListener(settings, listener)
+}
+
+func wrapListener(settings Settings, listener net.Listener) (net.Listener, error) {
+	if settings.UseProxyProtocol {
+		proxyListener, err := newProxyProtocolListener(listener, settings.ProxyProtocolTrustedSources)
+		if err != nil {
+			_ = listener.Close()
+			return nil, err
+		}
+		return proxyListener, nil
+	}
+	return listener, nil
+}
+
+// reuseListener returns a new listener on the socket of listener, wrapped for settings, so a reload can start a new
+// server on the bound address while the current one still holds it. The socket stays bound until both are closed.
+func reuseListener(listener net.Listener, settings Settings) (net.Listener, error) {
+	raw := rawListener(listener)
+	filer, ok := raw.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, errors.New("listener on " + raw.Addr().String() + " cannot be reused")
+	}
+	file, err := filer.File()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	duplicate, err := net.FileListener(file)
+	if err != nil {
+		return nil, err
+	}
+	if unix, ok := raw.(*net.UnixListener); ok {
+		// closing the previous listener must not remove the socket file still in use, a stale file left after
+		// shutdown is removed by the next listenUnix
+		unix.SetUnlinkOnClose(false)
+	}
+	return wrapListener(settings, duplicate)
+}
+
+// rawListener returns the socket listener below the proxy protocol and fingerprint wrappers
+func rawListener(listener net.Listener) net.Listener {
+	for {
+		switch wrapped := listener.(type) {
+		case *proxyProtocolListener:
+			listener = wrapped.Listener
+		case *fingerprintListener:
+			listener = wrapped.Listener
+		default:
+			return listener
+		}
+	}
+}
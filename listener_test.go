@@ -0,0 +1,131 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// unixClient returns a client sending every request to socket
+func unixClient(socket string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+}
+
+func getBody(t *testing.T, client *http.Client, url string) string {
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestUnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets")
+	}
+	socket := filepath.Join(t.TempDir(), "web.sock")
+
+	// the socket file of a previous run that did not shut down
+	stale, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	settings := NewSettings()
+	settings.UnixSocket = socket
+	settings.Root = "root"
+	webServer := NewWebServer(*settings)
+	webServer.NewHandleFunc(HTTPMethodGet, "/hello", func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("hello"))
+	})
+	err = webServer.Start()
+	if err != nil {
+		t.Fatalf("Start with a stale socket file: %v", err)
+	}
+	if body := getBody(t, unixClient(socket), "http://unix/hello"); body != "hello" {
+		t.Errorf("GET over unix socket = %q", body)
+	}
+
+	// a second instance does not take the socket of a running one
+	err = NewWebServer(*settings).Start()
+	if !errors.Is(err, ErrPortInUse) {
+		t.Errorf("Start on the socket of a running server = %v, want ErrPortInUse", err)
+	}
+	if body := getBody(t, unixClient(socket), "http://unix/hello"); body != "hello" {
+		t.Errorf("GET over unix socket after a second Start = %q", body)
+	}
+	err = webServer.Shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(socket); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("socket file after Shutdown: %v", err)
+	}
+
+	// other files are not removed
+	err = os.WriteFile(socket, []byte("data"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = NewWebServer(*settings).Start()
+	if err == nil {
+		t.Error("Start replaced a regular file with the socket")
+	}
+}
+
+func TestRunListener(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets")
+	}
+	socket := filepath.Join(t.TempDir(), "activated.sock")
+	// a listener passed in like one of systemd socket activation
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings := NewSettings()
+	settings.Root = "root"
+	webServer := NewWebServer(*settings)
+	webServer.NewHandleFunc(HTTPMethodGet, "/hello", func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("hello"))
+	})
+	ready := make(chan net.Addr, 1)
+	webServer.SetReadyHook(func(addr net.Addr) { ready <- addr })
+	runErr := make(chan error, 1)
+	go func() { runErr <- webServer.RunListener(listener) }()
+	select {
+	case addr := <-ready:
+		if addr.String() != socket {
+			t.Errorf("ready on %s, want %s", addr, socket)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("server did not start")
+	}
+
+	if body := getBody(t, unixClient(socket), "http://unix/hello"); body != "hello" {
+		t.Errorf("GET over the passed listener = %q", body)
+	}
+	err = webServer.Shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-runErr; !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("RunListener returned %v, want http.ErrServerClosed", err)
+	}
+}
@@ -0,0 +1,246 @@
+package webserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type LogShipperKind string
+
+const (
+	LogShipperNone          LogShipperKind = ""
+	LogShipperLoki          LogShipperKind = "loki"
+	LogShipperElasticsearch LogShipperKind = "elasticsearch"
+)
+
+type LogShipperOptions struct {
+	Kind LogShipperKind
+	// Url is the base url of Loki or Elasticsearch, the push and bulk paths are appended
+	Url           string
+	Labels        map[string]string
+	Index         string
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxQueue      int
+	MaxRetries    int
+}
+
+func NewLogShipperOptions(kind LogShipperKind, url string) *LogShipperOptions {
+	return &LogShipperOptions{
+		Kind:          kind,
+		Url:           url,
+		Labels:        map[string]string{"job": "webserver"},
+		Index:         "webserver-logs",
+		BatchSize:     500,
+		FlushInterval: 2 * time.Second,
+		MaxQueue:      10000,
+		MaxRetries:    3,
+	}
+}
+
+// LogShipper is an io.Writer sending every written log line in batches to Loki or Elasticsearch.
+// Lines are dropped instead of blocking the writer when MaxQueue lines are waiting.
+type LogShipper struct {
+	options LogShipperOptions
+	client  *http.Client
+
+	queue   chan logEntry
+	dropped atomic.Int64
+
+	closeOnce sync.Once
+	done      chan struct{}
+	stopped   chan struct{}
+}
+
+type logEntry struct {
+	time time.Time
+	line string
+}
+
+func NewLogShipper(options LogShipperOptions) (*LogShipper, error) {
+	if options.Kind != LogShipperLoki && options.Kind != LogShipperElasticsearch {
+		return nil, errors.New("unknown log shipper: " + string(options.Kind))
+	}
+	if options.Url == "" {
+		return nil, errors.New("log shipper url is empty")
+	}
+
+	shipper := &LogShipper{
+		options: options,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		queue:   make(chan logEntry, max(options.MaxQueue, 1)),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go shipper.run()
+	return shipper, nil
+}
+
+func (shipper *LogShipper) Write(p []byte) (int, error) {
+	entry := logEntry{time: time.Now(), line: strings.TrimRight(string(p), "\n")}
+	select {
+	case shipper.queue <- entry:
+	default:
+		shipper.dropped.Add(1)
+	}
+	return len(p), nil
+}
+
+// Dropped returns the number of lines lost to a full queue or failed deliveries
+func (shipper *LogShipper) Dropped() int64 {
+	return shipper.dropped.Load()
+}
+
+// Close sends the queued lines and stops the shipper
+func (shipper *LogShipper) Close() error {
+	shipper.closeOnce.Do(func() {
+		close(shipper.done)
+	})
+	<-shipper.stopped
+	return nil
+}
+
+func (shipper *LogShipper) run() {
+	defer close(shipper.stopped)
+
+	interval := shipper.options.FlushInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	batchSize := max(shipper.options.BatchSize, 1)
+	batch := make([]logEntry, 0, batchSize)
+	for {
+		select {
+		case entry := <-shipper.queue:
+			batch = append(batch, entry)
+			if len(batch) >= batchSize {
+				shipper.ship(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			shipper.ship(batch)
+			batch = batch[:0]
+		case <-shipper.done:
+			for {
+				select {
+				case entry := <-shipper.queue:
+					batch = append(batch, entry)
+				default:
+					shipper.ship(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (shipper *LogShipper) ship(batch []logEntry) {
+	if len(batch) == 0 {
+		return
+	}
+
+	var err error
+	backoff := 500 * time.Millisecond
+	for attempt := 0; attempt <= shipper.options.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-shipper.done:
+			}
+			backoff *= 2
+		}
+		err = shipper.send(batch)
+		if err == nil {
+			return
+		}
+	}
+
+	shipper.dropped.Add(int64(len(batch)))
+	// the logger itself may write into this shipper, so failures go to stderr directly
+	_, _ = fmt.Fprintln(os.Stderr, "Log Shipper: dropped "+strconv.Itoa(len(batch))+" lines: "+err.Error())
+}
+
+func (shipper *LogShipper) send(batch []logEntry) error {
+	var url, contentType string
+	var body []byte
+	var err error
+
+	switch shipper.options.Kind {
+	case LogShipperLoki:
+		url = strings.TrimRight(shipper.options.Url, "/") + "/loki/api/v1/push"
+		contentType = "application/json"
+		body, err = lokiPayload(batch, shipper.options.Labels)
+	default:
+		url = strings.TrimRight(shipper.options.Url, "/") + "/_bulk"
+		contentType = "application/x-ndjson"
+		body, err = elasticsearchPayload(batch, shipper.options.Index, shipper.options.Labels)
+	}
+	if err != nil {
+		return err
+	}
+
+	resp, err := shipper.client.Post(url, contentType, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.New("unexpected status " + resp.Status + " from " + url)
+	}
+	return nil
+}
+
+func lokiPayload(batch []logEntry, labels map[string]string) ([]byte, error) {
+	values := make([][2]string, 0, len(batch))
+	for _, entry := range batch {
+		values = append(values, [2]string{strconv.FormatInt(entry.time.UnixNano(), 10), entry.line})
+	}
+	return json.Marshal(map[string]any{
+		"streams": []map[string]any{{
+			"stream": labels,
+			"values": values,
+		}},
+	})
+}
+
+func elasticsearchPayload(batch []logEntry, index string, labels map[string]string) ([]byte, error) {
+	action, err := json.Marshal(map[string]any{"index": map[string]string{"_index": index}})
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	for _, entry := range batch {
+		document := map[string]any{
+			"@timestamp": entry.time.UTC().Format(time.RFC3339Nano),
+			"message":    entry.line,
+		}
+		for key, value := range labels {
+			document[key] = value
+		}
+		line, err := json.Marshal(document)
+		if err != nil {
+			return nil, err
+		}
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(line)
+		body.WriteByte('\n')
+	}
+	return body.Bytes(), nil
+}
@@ -0,0 +1,153 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLogShipper(t *testing.T) {
+	var mutex sync.Mutex
+	bodies := map[string]string{}
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if failures > 0 {
+			failures--
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		bodies[req.URL.Path] += string(body)
+	}))
+	defer server.Close()
+
+	for _, kind := range []LogShipperKind{LogShipperLoki, LogShipperElasticsearch} {
+		options := NewLogShipperOptions(kind, server.URL)
+		options.FlushInterval = time.Hour
+		shipper, err := NewLogShipper(*options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger := log.New(shipper, "", 0)
+		logger.Println("first line")
+		logger.Println("second line")
+		_ = shipper.Close()
+	}
+
+	var push struct {
+		Streams []struct {
+			Stream map[string]string
+			Values [][2]string
+		}
+	}
+	err := json.Unmarshal([]byte(bodies["/loki/api/v1/push"]), &push)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(push.Streams) != 1 || push.Streams[0].Stream["job"] != "webserver" || len(push.Streams[0].Values) != 2 ||
+		push.Streams[0].Values[1][1] != "second line" {
+		t.Errorf("loki push = %+v", push)
+	}
+
+	bulk := strings.Split(strings.TrimSpace(bodies["/_bulk"]), "\n")
+	if len(bulk) != 4 || !strings.Contains(bulk[0], `"_index":"webserver-logs"`) || !strings.Contains(bulk[3], `"message":"second line"`) {
+		t.Errorf("elasticsearch bulk = %q", bulk)
+	}
+}
+
+func TestLogShipperMaxQueue(t *testing.T) {
+	options := NewLogShipperOptions(LogShipperLoki, "http://127.0.0.1:1")
+	options.MaxQueue = 1
+	options.MaxRetries = 0
+	shipper := &LogShipper{options: *options, queue: make(chan logEntry, options.MaxQueue)}
+
+	for i := 0; i < 3; i++ {
+		_, _ = shipper.Write([]byte("line\n"))
+	}
+	if shipper.Dropped() != 2 {
+		t.Errorf("dropped = %d, want 2", shipper.Dropped())
+	}
+}
+
+// closingSink records whether it was closed
+type closingSink struct {
+	closed atomic.Bool
+}
+
+func (sink *closingSink) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (sink *closingSink) Close() error {
+	sink.closed.Store(true)
+	return nil
+}
+
+func TestShutdownFlushesExporters(t *testing.T) {
+	shipped := make(chan string, 1)
+	loki := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		shipped <- string(body)
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer loki.Close()
+	statsd, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer statsd.Close()
+
+	settings := NewSettings()
+	settings.Logger = log.New(io.Discard, "", 0)
+	settings.LogShipping = *NewLogShipperOptions(LogShipperLoki, loki.URL)
+	// nothing is shipped or sent before Shutdown flushes
+	settings.LogShipping.FlushInterval = time.Hour
+	settings.MetricsBackend = MetricsBackendStatsd
+	settings.MetricsAddr = statsd.LocalAddr().String()
+	webServer := NewWebServer(*settings)
+	sink := &closingSink{}
+	_, err = webServer.StreamSecurityEvents(*NewSIEMOptions(SIEMFormatJSON, sink))
+	if err != nil {
+		t.Fatal(err)
+	}
+	webServer.logger.Println("before shutdown")
+	webServer.metrics.Count("requests", 1, nil)
+
+	err = webServer.Shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case body := <-shipped:
+		if !strings.Contains(body, "before shutdown") {
+			t.Errorf("shipped %s", body)
+		}
+	case <-time.After(time.Second):
+		t.Error("log lines were not shipped on Shutdown")
+	}
+	_ = statsd.SetReadDeadline(time.Now().Add(time.Second))
+	packet := make([]byte, maxStatsdPacketSize)
+	n, _, err := statsd.ReadFrom(packet)
+	if err != nil || !strings.Contains(string(packet[:n]), "requests:1|c") {
+		t.Errorf("statsd packet %q, %v", packet[:n], err)
+	}
+	if !sink.closed.Load() {
+		t.Error("siem sink was not closed")
+	}
+	// a second Shutdown does not close them again
+	err = webServer.Shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
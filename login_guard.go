@@ -0,0 +1,173 @@
+package webserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+var ErrAccountLocked = errors.New("account locked")
+
+// loginGuardPruneSize is the number of tracked identities from which expired failures are pruned
+const loginGuardPruneSize = 10000
+
+type LoginGuardOptions struct {
+	// MaxFailures within Window lock the identity for LockoutDuration
+	MaxFailures     int
+	Window          time.Duration
+	LockoutDuration time.Duration
+	// KnownClients is the number of ip addresses and devices remembered per identity
+	KnownClients int
+}
+
+func NewLoginGuardOptions() *LoginGuardOptions {
+	return &LoginGuardOptions{
+		MaxFailures:     5,
+		Window:          15 * time.Minute,
+		LockoutDuration: 15 * time.Minute,
+		KnownClients:    10,
+	}
+}
+
+// LoginGuard locks identities after repeated failed logins and recognizes logins from new ip addresses and devices,
+// identified by their User-Agent. It emits AccountLocked, LoginFailed and SuspiciousLogin on the event bus of the
+// server, subscribe with On to notify the user, e.g. by mail. Its state is kept in memory.
+type LoginGuard struct {
+	webServer *WebServer
+	options   LoginGuardOptions
+
+	mutex    sync.Mutex
+	failures map[string]*loginFailures
+	known    map[string]*knownClients
+}
+
+type loginFailures struct {
+	count  int
+	first  time.Time
+	locked time.Time
+}
+
+type knownClients struct {
+	ips     []string
+	devices []string
+}
+
+func (webServer *WebServer) NewLoginGuard(options LoginGuardOptions) *LoginGuard {
+	return &LoginGuard{
+		webServer: webServer,
+		options:   options,
+		failures:  map[string]*loginFailures{},
+		known:     map[string]*knownClients{},
+	}
+}
+
+// Authenticate runs check for a login of identity unless it is locked, which returns an error wrapping
+// ErrAccountLocked without calling check, and records the result of check with Failed or Succeeded
+func (guard *LoginGuard) Authenticate(req *http.Request, identity string, check func() error) error {
+	err := guard.Check(identity)
+	if err != nil {
+		return err
+	}
+	err = check()
+	if err != nil {
+		guard.Failed(req, identity)
+		return err
+	}
+	guard.Succeeded(req, identity)
+	return nil
+}
+
+// Check returns an error wrapping ErrAccountLocked if identity is locked
+func (guard *LoginGuard) Check(identity string) error {
+	guard.mutex.Lock()
+	defer guard.mutex.Unlock()
+	failures := guard.failures[identity]
+	if failures != nil && time.Now().Before(failures.locked) {
+		return fmt.Errorf("%w: %s until %s", ErrAccountLocked, identity, failures.locked.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Failed records a failed login of identity and locks it once MaxFailures were reached within Window
+func (guard *LoginGuard) Failed(req *http.Request, identity string) {
+	now := time.Now()
+	ip := ClientIP(req)
+
+	guard.mutex.Lock()
+	if len(guard.failures) >= loginGuardPruneSize {
+		guard.prune(now)
+	}
+	failures := guard.failures[identity]
+	if failures == nil || now.Sub(failures.first) > guard.options.Window {
+		failures = &loginFailures{first: now}
+		guard.failures[identity] = failures
+	}
+	failures.count++
+	count := failures.count
+	// every failure from MaxFailures on locks again once a lockout shorter than Window expired
+	locked := guard.options.MaxFailures > 0 && count >= guard.options.MaxFailures && !now.Before(failures.locked)
+	if locked {
+		failures.locked = now.Add(guard.options.LockoutDuration)
+	}
+	guard.mutex.Unlock()
+
+	guard.webServer.emit(LoginFailed{Identity: identity, IP: ip, Failures: count})
+	if locked {
+		guard.webServer.logger.Println("Login Guard: " + identity + " locked after " + fmt.Sprint(count) + " failed logins, last from " + ip)
+		guard.webServer.emit(AccountLocked{Identity: identity, IP: ip, Failures: count, Until: now.Add(guard.options.LockoutDuration)})
+	}
+}
+
+// Succeeded resets the failures of identity and emits SuspiciousLogin if the ip address or device is new.
+// The first login of an identity is not suspicious.
+func (guard *LoginGuard) Succeeded(req *http.Request, identity string) {
+	ip, device := ClientIP(req), req.UserAgent()
+
+	guard.mutex.Lock()
+	delete(guard.failures, identity)
+	known := guard.known[identity]
+	first := known == nil
+	if first {
+		known = &knownClients{}
+		guard.known[identity] = known
+	}
+	newIP, newDevice := !slices.Contains(known.ips, ip), !slices.Contains(known.devices, device)
+	known.ips = remember(known.ips, ip, guard.options.KnownClients)
+	known.devices = remember(known.devices, device, guard.options.KnownClients)
+	guard.mutex.Unlock()
+
+	if !first && (newIP || newDevice) {
+		guard.webServer.emit(SuspiciousLogin{Identity: identity, IP: ip, UserAgent: device, NewIP: newIP, NewDevice: newDevice})
+	}
+}
+
+// Unlock resets the failures of identity, e.g. after the user reset the password
+func (guard *LoginGuard) Unlock(identity string) {
+	guard.mutex.Lock()
+	defer guard.mutex.Unlock()
+	delete(guard.failures, identity)
+}
+
+func (guard *LoginGuard) prune(now time.Time) {
+	for identity, failures := range guard.failures {
+		if now.Sub(failures.first) > guard.options.Window && now.After(failures.locked) {
+			delete(guard.failures, identity)
+		}
+	}
+}
+
+// remember moves value to the end of values, dropping the oldest values beyond max
+func remember(values []string, value string, max int) []string {
+	values = slices.DeleteFunc(values, func(existing string) bool {
+		return existing == value
+	})
+	values = append(values, value)
+	if max > 0 && len(values) > max {
+		values = values[len(values)-max:]
+	}
+	return values
+}
@@ -0,0 +1,110 @@
+package webserver
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoginGuard(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	options := NewLoginGuardOptions()
+	options.MaxFailures = 3
+	guard := webServer.NewLoginGuard(*options)
+
+	locked := make(chan AccountLocked, 4)
+	On(webServer, func(event AccountLocked) {
+		locked <- event
+	})
+	suspicious := make(chan SuspiciousLogin, 4)
+	On(webServer, func(event SuspiciousLogin) {
+		suspicious <- event
+	})
+
+	request := func(ip string, userAgent string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("User-Agent", userAgent)
+		return req
+	}
+	wrong := func() error { return ErrPasswordMismatch }
+	right := func() error { return nil }
+
+	// first login is not suspicious, logins from known clients neither
+	for i := 0; i < 2; i++ {
+		err := guard.Authenticate(request("10.0.0.1", "laptop"), "alice", right)
+		if err != nil {
+			t.Fatalf("login %d: %v", i, err)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		err := guard.Authenticate(request("192.0.2.7", "bot"), "alice", wrong)
+		if !errors.Is(err, ErrPasswordMismatch) {
+			t.Fatalf("failure %d = %v, want ErrPasswordMismatch", i, err)
+		}
+	}
+	err := guard.Authenticate(request("10.0.0.1", "laptop"), "alice", right)
+	if !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("login while locked = %v, want ErrAccountLocked", err)
+	}
+	select {
+	case event := <-locked:
+		if event.Identity != "alice" || event.IP != "192.0.2.7" || event.Failures != 3 || !event.Until.After(time.Now()) {
+			t.Errorf("AccountLocked = %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("AccountLocked was not emitted")
+	}
+
+	guard.Unlock("alice")
+	err = guard.Authenticate(request("10.0.0.1", "phone"), "alice", right)
+	if err != nil {
+		t.Fatalf("login after unlock: %v", err)
+	}
+	select {
+	case event := <-suspicious:
+		want := SuspiciousLogin{Identity: "alice", IP: "10.0.0.1", UserAgent: "phone", NewIP: false, NewDevice: true}
+		if event != want {
+			t.Errorf("SuspiciousLogin = %+v, want %+v", event, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SuspiciousLogin was not emitted")
+	}
+	select {
+	case event := <-suspicious:
+		t.Errorf("unexpected SuspiciousLogin %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestLoginGuardLocksAgain(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	options := NewLoginGuardOptions()
+	options.MaxFailures = 2
+	options.LockoutDuration = 20 * time.Millisecond
+	guard := webServer.NewLoginGuard(*options)
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	wrong := func() error { return ErrPasswordMismatch }
+
+	for lockout := 0; lockout < 3; lockout++ {
+		failures := 2
+		if lockout > 0 {
+			// the count within Window is past MaxFailures already, one more failure locks again
+			failures = 1
+		}
+		for i := 0; i < failures; i++ {
+			err := guard.Authenticate(req, "alice", wrong)
+			if !errors.Is(err, ErrPasswordMismatch) {
+				t.Fatalf("lockout %d failure %d = %v, want ErrPasswordMismatch", lockout, i, err)
+			}
+		}
+		err := guard.Authenticate(req, "alice", wrong)
+		if !errors.Is(err, ErrAccountLocked) {
+			t.Fatalf("lockout %d: login = %v, want ErrAccountLocked", lockout, err)
+		}
+		time.Sleep(options.LockoutDuration + 5*time.Millisecond)
+	}
+}
@@ -0,0 +1,139 @@
+package webserver
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type MetricsBackend string
+
+const (
+	MetricsBackendNone      MetricsBackend = ""
+	MetricsBackendStatsd    MetricsBackend = "statsd"
+	MetricsBackendDogStatsd MetricsBackend = "dogstatsd"
+	// MetricsBackendPrometheus keeps metrics in memory for scraping, see MetricsHandler
+	MetricsBackendPrometheus MetricsBackend = "prometheus"
+)
+
+// Labels of the request metrics, Settings.MetricsLabels selects which of them are recorded
+const (
+	MetricsLabelMethod      = "method"
+	MetricsLabelRoute       = "route"
+	MetricsLabelStatusClass = "status_class"
+)
+
+// otherRoute replaces routes beyond Settings.MetricsMaxRoutes in the route label
+const otherRoute = "other"
+
+// MetricsExporter receives the metrics recorded by the server
+type MetricsExporter interface {
+	Count(name string, value int64, tags map[string]string)
+	Gauge(name string, value float64, tags map[string]string)
+	Timing(name string, value time.Duration, tags map[string]string)
+}
+
+// SetMetricsExporter replaces the exporter selected by Settings.MetricsBackend, nil disables metrics
+func (webServer *WebServer) SetMetricsExporter(exporter MetricsExporter) {
+	webServer.metrics = exporter
+}
+
+func newMetricsExporter(settings Settings) (MetricsExporter, error) {
+	switch settings.MetricsBackend {
+	case MetricsBackendStatsd, MetricsBackendDogStatsd:
+		return NewStatsdExporter(
+			settings.MetricsAddr,
+			settings.MetricsPrefix,
+			settings.MetricsBackend == MetricsBackendDogStatsd,
+			metricsTags(settings),
+		)
+	case MetricsBackendPrometheus:
+		return NewPrometheusExporter(settings.MetricsPrefix, settings.MetricsBuckets, metricsTags(settings)), nil
+	default:
+		return nil, nil
+	}
+}
+
+// metricsTags returns the MetricsTags with the instance tag of MetricsInstance
+func metricsTags(settings Settings) map[string]string {
+	instance := settings.MetricsInstance
+	if instance == "" && settings.ReusePort {
+		instance = strconv.Itoa(os.Getpid())
+	}
+	if instance == "" {
+		return settings.MetricsTags
+	}
+	tags := map[string]string{}
+	for key, value := range settings.MetricsTags {
+		tags[key] = value
+	}
+	tags["instance"] = instance
+	return tags
+}
+
+func (webServer *WebServer) recordRequest(rw *responseWriter, req *http.Request, start time.Time) {
+	settings := webServer.Settings()
+	method, route := webServer.routeLabel(req)
+
+	tags := map[string]string{}
+	for _, label := range settings.MetricsLabels {
+		switch label {
+		case MetricsLabelMethod:
+			tags[label] = method
+		case MetricsLabelRoute:
+			tags[label] = webServer.metricRoutes.limit(route, settings.MetricsMaxRoutes)
+		case MetricsLabelStatusClass:
+			tags[label] = statusClass(rw.Status())
+		}
+	}
+	webServer.metrics.Count("requests", 1, tags)
+	webServer.metrics.Timing("request_duration", time.Since(start), tags)
+	webServer.metrics.Count("response_bytes", rw.written, tags)
+	if rw.exceeded {
+		webServer.metrics.Count("responses_too_large", 1, tags)
+	}
+}
+
+func statusClass(status int) string {
+	switch {
+	case status >= 500:
+		return "5xx"
+	case status >= 400:
+		return "4xx"
+	case status >= 300:
+		return "3xx"
+	case status >= 200:
+		return "2xx"
+	default:
+		return "1xx"
+	}
+}
+
+// labelLimiter bounds the number of distinct values of a label, values seen after the limit was reached are
+// replaced by otherRoute so unexpected patterns cannot flood the metrics backend
+type labelLimiter struct {
+	mutex sync.Mutex
+	seen  map[string]bool
+}
+
+// limit returns value if it was seen before or fewer than max values were seen, max 0 disables the limit
+func (limiter *labelLimiter) limit(value string, max int) string {
+	if max <= 0 {
+		return value
+	}
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	if limiter.seen[value] {
+		return value
+	}
+	if len(limiter.seen) >= max {
+		return otherRoute
+	}
+	if limiter.seen == nil {
+		limiter.seen = map[string]bool{}
+	}
+	limiter.seen[value] = true
+	return value
+}
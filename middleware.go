@@ -0,0 +1,89 @@
+package webserver
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// MiddlewarePhase orders middleware, lower phases run first and middleware of one phase runs in registration order
+type MiddlewarePhase int
+
+const (
+	// PhaseSecurity rejects requests before anything else looks at them, e.g. rate limits
+	PhaseSecurity MiddlewarePhase = iota * 100
+	// PhaseAuth authenticates the client, e.g. BasicAuth, BearerAuth and RequireJWT
+	PhaseAuth
+	// PhaseTransform rewrites requests and wraps responses
+	PhaseTransform
+	// PhaseBusiness is the phase of NewMiddleware
+	PhaseBusiness
+)
+
+type middleware struct {
+	phase  MiddlewarePhase
+	handle func(http.ResponseWriter, *http.Request) bool
+}
+
+// MiddlewareCondition decides whether a middleware runs for a request, skipped middleware lets the request pass
+type MiddlewareCondition func(req *http.Request) bool
+
+// Except skips the middleware for the paths, a path ending with "/" also skips every path below it
+func Except(paths ...string) MiddlewareCondition {
+	return func(req *http.Request) bool {
+		for _, path := range paths {
+			if req.URL.Path == path || strings.HasSuffix(path, "/") && strings.HasPrefix(req.URL.Path, path) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// OnlyMethods runs the middleware only for requests with one of the methods
+func OnlyMethods(methods ...HTTPMethod) MiddlewareCondition {
+	return func(req *http.Request) bool {
+		for _, method := range methods {
+			if strings.EqualFold(req.Method, string(method)) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// When runs the middleware only for requests predicate returns true for
+func When(predicate func(req *http.Request) bool) MiddlewareCondition {
+	return predicate
+}
+
+// NewPhaseMiddleware registers m to run in phase, custom phases in between the named ones like PhaseAuth+10 are allowed.
+// The return value of m is for deciding to run next middleware/handler.
+// m only runs for requests all conditions hold for.
+func (webServer *WebServer) NewPhaseMiddleware(phase MiddlewarePhase, m func(http.ResponseWriter, *http.Request) bool, conditions ...MiddlewareCondition) {
+	if len(conditions) > 0 {
+		handle := m
+		m = func(rw http.ResponseWriter, req *http.Request) bool {
+			for _, condition := range conditions {
+				if !condition(req) {
+					return true
+				}
+			}
+			return handle(rw, req)
+		}
+	}
+	webServer.middleware = append(webServer.middleware, middleware{phase: phase, handle: m})
+	sort.SliceStable(webServer.middleware, func(i, j int) bool {
+		return webServer.middleware[i].phase < webServer.middleware[j].phase
+	})
+}
+
+// runMiddleware returns false if a middleware answered the request
+func (webServer *WebServer) runMiddleware(rw http.ResponseWriter, req *http.Request) bool {
+	for _, m := range webServer.middleware {
+		if !m.handle(rw, req) {
+			return false
+		}
+	}
+	return true
+}
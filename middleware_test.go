@@ -0,0 +1,91 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddlewarePhases(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	var order []string
+	add := func(phase MiddlewarePhase, name string) {
+		webServer.NewPhaseMiddleware(phase, func(rw http.ResponseWriter, req *http.Request) bool {
+			order = append(order, name)
+			return name != "auth" || req.Header.Get("Authorization") != ""
+		})
+	}
+	webServer.NewMiddleware(func(rw http.ResponseWriter, req *http.Request) bool {
+		order = append(order, "business")
+		return true
+	})
+	add(PhaseTransform, "transform")
+	add(PhaseAuth, "auth")
+	add(PhaseSecurity, "security")
+	add(PhaseAuth+10, "auth+10")
+	add(PhaseSecurity, "security2")
+	webServer.NewHandleFunc(HTTPMethodGet, "/ok", func(rw http.ResponseWriter, req *http.Request) {})
+
+	tests := []struct {
+		authorization string
+		order         string
+	}{
+		{"Bearer x", "security security2 auth auth+10 transform business"},
+		{"", "security security2 auth"},
+	}
+
+	for _, test := range tests {
+		order = nil
+		req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+		if test.authorization != "" {
+			req.Header.Set("Authorization", test.authorization)
+		}
+		webServer.mux.ServeHTTP(httptest.NewRecorder(), req)
+		if strings.Join(order, " ") != test.order {
+			t.Errorf("%q: order %v, want %s", test.authorization, order, test.order)
+		}
+	}
+}
+
+func TestMiddlewareConditions(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	deny := func(rw http.ResponseWriter, req *http.Request) bool {
+		rw.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+	webServer.NewPhaseMiddleware(PhaseAuth, deny, Except("/health", "/webhooks/"), OnlyMethods(HTTPMethodPost, HTTPMethodGet))
+	webServer.NewMiddleware(deny, When(func(req *http.Request) bool {
+		return req.Header.Get("X-Block") != ""
+	}))
+	for _, method := range []HTTPMethod{HTTPMethodGet, HTTPMethodPost, HTTPMethodPut} {
+		for _, pattern := range []string{"/health", "/healthz", "/webhooks/github", "/api"} {
+			webServer.NewHandleFunc(method, pattern, func(rw http.ResponseWriter, req *http.Request) {})
+		}
+	}
+
+	tests := []struct {
+		method, path string
+		block        bool
+		status       int
+	}{
+		{http.MethodGet, "/health", false, http.StatusOK},
+		{http.MethodGet, "/healthz", false, http.StatusUnauthorized},
+		{http.MethodPost, "/webhooks/github", false, http.StatusOK},
+		{http.MethodPost, "/api", false, http.StatusUnauthorized},
+		{http.MethodPut, "/api", false, http.StatusOK},
+		{http.MethodPut, "/api", true, http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		if test.block {
+			req.Header.Set("X-Block", "1")
+		}
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("%s %s: %d, want %d", test.method, test.path, rec.Code, test.status)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package webserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type MirrorOptions struct {
+	Dir           string
+	SampleRate    float64
+	MaxBodySize   int64
+	MaxFileSize   int64
+	MaxFiles      int
+	RedactHeaders []string
+}
+
+func NewMirrorOptions(dir string) *MirrorOptions {
+	return &MirrorOptions{
+		Dir:         dir,
+		SampleRate:  1,
+		MaxBodySize: 64 * 1024,
+		MaxFileSize: 16 * 1024 * 1024,
+		MaxFiles:    8,
+		RedactHeaders: []string{
+			"Authorization",
+			"Proxy-Authorization",
+			"Cookie",
+			"Set-Cookie",
+		},
+	}
+}
+
+// MirrorRecord is one line of a mirror file, Request rebuilds the recorded request for replaying.
+type MirrorRecord struct {
+	Time                  time.Time
+	Duration              time.Duration
+	RemoteAddr            string
+	Method                string
+	URL                   string
+	Host                  string
+	RequestHeader         http.Header
+	RequestBody           []byte
+	RequestBodyTruncated  bool
+	Status                int
+	ResponseHeader        http.Header
+	ResponseBody          []byte
+	ResponseBodyTruncated bool
+}
+
+func (record MirrorRecord) Request() (*http.Request, error) {
+	req, err := http.NewRequest(record.Method, record.URL, bytes.NewReader(record.RequestBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = record.RequestHeader.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.Host = record.Host
+	req.RemoteAddr = record.RemoteAddr
+	return req, nil
+}
+
+// ReadMirrorFile reads all records of a file written by a mirror.
+func ReadMirrorFile(fileName string) ([]MirrorRecord, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	var records []MirrorRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		var record MirrorRecord
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// EnableMirror writes a sample of the requests and their responses as json lines into rotating files below options.Dir.
+func (webServer *WebServer) EnableMirror(options MirrorOptions) error {
+	err := os.MkdirAll(options.Dir, 0755)
+	if err != nil {
+		return err
+	}
+	webServer.mirror = &mirror{options: options}
+	return nil
+}
+
+type mirror struct {
+	options MirrorOptions
+
+	mutex    sync.Mutex
+	file     *os.File
+	fileSize int64
+}
+
+func (m *mirror) sample() bool {
+	return m.options.SampleRate >= 1 || rand.Float64() < m.options.SampleRate
+}
+
+// capture returns the writer and request to pass on and a function to call after the request has been served.
+func (m *mirror) capture(rw http.ResponseWriter, req *http.Request) (http.ResponseWriter, *http.Request, func() error) {
+	start := time.Now()
+
+	record := MirrorRecord{
+		Time:          start,
+		RemoteAddr:    req.RemoteAddr,
+		Method:        req.Method,
+		URL:           req.URL.String(),
+		Host:          req.Host,
+		RequestHeader: m.redact(req.Header),
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(io.LimitReader(req.Body, m.options.MaxBodySize+1))
+		if err == nil || len(body) > 0 {
+			record.RequestBodyTruncated = int64(len(body)) > m.options.MaxBodySize
+			record.RequestBody = body[:min(int64(len(body)), m.options.MaxBodySize)]
+			req.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), req.Body), Closer: req.Body}
+		}
+	}
+
+	recorder := newResponseWriter(rw)
+	recorder.capture = &bytes.Buffer{}
+	recorder.maxCapture = m.options.MaxBodySize
+
+	return recorder, req, func() error {
+		record.Duration = time.Since(start)
+		record.Status = recorder.Status()
+		record.ResponseHeader = m.redact(recorder.Header())
+		record.ResponseBody = recorder.capture.Bytes()
+		record.ResponseBodyTruncated = recorder.truncated
+		return m.write(record)
+	}
+}
+
+func (m *mirror) redact(header http.Header) http.Header {
+	header = header.Clone()
+	for _, name := range m.options.RedactHeaders {
+		if values, ok := header[http.CanonicalHeaderKey(name)]; ok {
+			for i := range values {
+				values[i] = "REDACTED"
+			}
+		}
+	}
+	return header
+}
+
+func (m *mirror) write(record MirrorRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.file == nil || (m.options.MaxFileSize > 0 && m.fileSize+int64(len(line)) > m.options.MaxFileSize) {
+		err := m.rotate()
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := m.file.Write(line)
+	m.fileSize += int64(n)
+	return err
+}
+
+func (m *mirror) rotate() error {
+	if m.file != nil {
+		err := m.file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	fileName := filepath.Join(m.options.Dir, "mirror-"+time.Now().UTC().Format("20060102T150405.000000000")+".jsonl")
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		m.file = nil
+		return err
+	}
+	m.file = file
+	m.fileSize = 0
+
+	if m.options.MaxFiles <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(m.options.Dir)
+	if err != nil {
+		return err
+	}
+	var files []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "mirror-") && strings.HasSuffix(entry.Name(), ".jsonl") {
+			files = append(files, entry.Name())
+		}
+	}
+	sort.Strings(files)
+	for len(files) > m.options.MaxFiles {
+		err := os.Remove(filepath.Join(m.options.Dir, files[0]))
+		if err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
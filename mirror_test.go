@@ -0,0 +1,93 @@
+package webserver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMirror(t *testing.T) {
+	dir := t.TempDir()
+
+	webServer := NewWebServer(*NewSettings())
+	options := NewMirrorOptions(dir)
+	options.MaxBodySize = 8
+	err := webServer.EnableMirror(*options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webServer.NewHandlerBody(HTTPMethodPost, "/echo", func(rw http.ResponseWriter, req *http.Request, body []byte) {
+		rw.Header().Set("Set-Cookie", "session=secret")
+		rw.WriteHeader(http.StatusCreated)
+		_, _ = rw.Write(body)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/echo?x=1", strings.NewReader("hello mirror"))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, req)
+
+	if rec.Body.String() != "hello mirror" {
+		t.Fatalf("handler saw body %q", rec.Body.String())
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "mirror-*.jsonl"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("mirror files = %v, %v", files, err)
+	}
+	records, err := ReadMirrorFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("got %d records", len(records))
+	}
+
+	record := records[0]
+	if record.Method != http.MethodPost || record.URL != "/echo?x=1" || record.Status != http.StatusCreated {
+		t.Errorf("record = %+v", record)
+	}
+	if string(record.RequestBody) != "hello mi" || !record.RequestBodyTruncated {
+		t.Errorf("request body = %q truncated %v", record.RequestBody, record.RequestBodyTruncated)
+	}
+	if string(record.ResponseBody) != "hello mi" || !record.ResponseBodyTruncated {
+		t.Errorf("response body = %q truncated %v", record.ResponseBody, record.ResponseBodyTruncated)
+	}
+	if record.RequestHeader.Get("Authorization") != "REDACTED" || record.ResponseHeader.Get("Set-Cookie") != "REDACTED" {
+		t.Errorf("headers not redacted: %v %v", record.RequestHeader, record.ResponseHeader)
+	}
+
+	replay, err := record.Request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(replay.Body)
+	if replay.Method != http.MethodPost || replay.URL.Path != "/echo" || string(body) != "hello mi" {
+		t.Errorf("replayed request = %s %s %q", replay.Method, replay.URL, body)
+	}
+}
+
+func TestMirrorRotation(t *testing.T) {
+	dir := t.TempDir()
+	m := &mirror{options: MirrorOptions{Dir: dir, MaxFileSize: 1, MaxFiles: 2}}
+
+	for i := 0; i < 5; i++ {
+		err := m.write(MirrorRecord{Method: http.MethodGet, URL: "/"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("got %d files after rotation, want 2", len(entries))
+	}
+}
@@ -0,0 +1,169 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+type MountOptions struct {
+	// FileExtensionFilter answers files with these extensions with 403 Forbidden
+	FileExtensionFilter []string
+	// AllowedExtensions answers files with other extensions with 403 Forbidden if set
+	AllowedExtensions []string
+	// DenyPaths answers paths below the mount matching one of these globs with 403 Forbidden, see WebServer.DenyPath
+	DenyPaths []string
+	// CacheControl is sent with every file of the mount if set, e.g. "public, max-age=31536000, immutable"
+	CacheControl string
+	// Uploads accepts PUT requests writing files into the directory of Mount, also in parts with Content-Range.
+	// Files being uploaded are not served until the upload is complete. It has no effect on MountFS.
+	Uploads bool
+	// Keyring encrypts uploaded files with EncryptFile and decrypts the served files like NewEncryptedStorage,
+	// so every file of the mount has to be encrypted. nil stores and serves the files as they are.
+	Keyring *Keyring
+}
+
+func NewMountOptions() *MountOptions {
+	return &MountOptions{
+		FileExtensionFilter: []string{},
+		AllowedExtensions:   []string{},
+		DenyPaths:           []string{},
+		CacheControl:        "",
+		Uploads:             false,
+		Keyring:             nil,
+	}
+}
+
+// dirStorage reads the files of a mounted directory, honouring Settings.BlockSymlinkEscape
+type dirStorage struct {
+	webServer *WebServer
+	root      string
+}
+
+func (storage dirStorage) ReadFile(ctx context.Context, name string) ([]byte, error) {
+	filePath, _, err := storage.stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filePath)
+}
+
+func (storage dirStorage) ModTime(ctx context.Context, name string) (time.Time, error) {
+	_, info, err := storage.stat(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// stat resolves name below the mounted directory, directories are reported as not existing
+func (storage dirStorage) stat(name string) (string, os.FileInfo, error) {
+	filePath, err := resolvePath(storage.root, "/"+name, storage.webServer.Settings().BlockSymlinkEscape)
+	if err != nil {
+		return "", nil, err
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.IsDir() {
+		return "", nil, &fs.PathError{Op: "open", Path: filePath, Err: fs.ErrNotExist}
+	}
+	return filePath, info, nil
+}
+
+// Mount serves the files below root at prefix, e.g. Mount("/downloads", "files/downloads", options), independent of Settings.Root
+func (webServer *WebServer) Mount(prefix string, root string, options MountOptions) {
+	var storage StaticStorage = dirStorage{webServer: webServer, root: root}
+	if options.Keyring != nil {
+		storage = NewEncryptedStorage(storage, *options.Keyring)
+	}
+	webServer.mount(prefix, "mount "+root, storage, options)
+	if options.Uploads {
+		prefix = "/" + strings.Trim(prefix, "/") + "/"
+		webServer.handle(http.MethodPut, prefix, "upload "+root, &uploadHandler{webServer: webServer, prefix: prefix, root: root, options: options})
+	}
+}
+
+// MountFS serves the files of fsys at prefix
+func (webServer *WebServer) MountFS(prefix string, fsys fs.FS, options MountOptions) {
+	webServer.mount(prefix, "mount fs", fsStorage{fsys: fsys}, options)
+}
+
+func (webServer *WebServer) mount(prefix string, name string, storage StaticStorage, options MountOptions) {
+	prefix = "/" + strings.Trim(prefix, "/") + "/"
+	webServer.handle(http.MethodGet, prefix, name, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		path := "/" + strings.TrimPrefix(req.URL.Path, prefix)
+		parts := strings.Split(path, ".")
+		fileExtension := parts[len(parts)-1]
+
+		if slices.Contains(options.FileExtensionFilter, fileExtension) {
+			webServer.writeError(rw, req, http.StatusForbidden)
+			webServer.logger.Println("Mount: 403: " + fileExtension + " (" + req.URL.Path + ")")
+			return
+		}
+		err := checkTraversal(path)
+		if err != nil {
+			webServer.writeError(rw, req, http.StatusForbidden)
+			webServer.logger.Println("Mount: 403: " + err.Error() + " (" + req.URL.Path + ")")
+			return
+		}
+		if pattern, denied := deniedPath(options.DenyPaths, path); denied {
+			webServer.writeError(rw, req, http.StatusForbidden)
+			webServer.logger.Println("Mount: 403: " + pattern + " (" + req.URL.Path + ")")
+			return
+		}
+
+		settings := webServer.Settings()
+		if hidden, ok := hiddenPath(path, settings.DotfileExceptions); settings.BlockDotfiles && ok {
+			webServer.writeError(rw, req, http.StatusNotFound)
+			webServer.logger.Println("Mount: 404: hidden " + hidden + " (" + req.URL.Path + ")")
+			return
+		}
+		if options.Uploads && isPartFile(path) {
+			webServer.writeError(rw, req, http.StatusNotFound)
+			webServer.logger.Println("Mount: 404: " + req.URL.Path)
+			return
+		}
+
+		name, index := storageName(path)
+		if index {
+			fileExtension = "html"
+		}
+		if !allowedExtension(options.AllowedExtensions, fileExtension) {
+			webServer.writeError(rw, req, http.StatusForbidden)
+			webServer.logger.Println("Mount: 403: " + fileExtension + " not allowed (" + req.URL.Path + ")")
+			return
+		}
+		file, err := storage.ReadFile(req.Context(), name)
+		if errors.Is(err, fs.ErrNotExist) {
+			webServer.writeError(rw, req, http.StatusNotFound)
+			webServer.logger.Println("Mount: 404: " + req.URL.Path)
+			webServer.emit(RouteNotFound{Method: req.Method, Path: req.URL.Path})
+			return
+		}
+		if err != nil {
+			webServer.writeErrorCause(rw, req, http.StatusInternalServerError, err)
+			webServer.logger.Println("Mount: 500: " + err.Error())
+			return
+		}
+
+		rw.Header().Set("Content-Type", getMimeType(fileExtension))
+		if options.CacheControl != "" {
+			rw.Header().Set("Cache-Control", options.CacheControl)
+		}
+		sidecar := func(suffix string) ([]byte, error) {
+			return storage.ReadFile(req.Context(), name+suffix)
+		}
+		modTime := storageModTime(req.Context(), storage, name)
+		status := webServer.serveContent(rw, req, settings, path, modTime, file, sidecar)
+		webServer.logger.Println("Mount: " + strconv.Itoa(status) + ": " + req.URL.Path)
+	}))
+}
@@ -0,0 +1,101 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestMount(t *testing.T) {
+	settings := NewSettings()
+	settings.Root = "root"
+	webServer := NewWebServer(*settings)
+	assets := NewMountOptions()
+	assets.CacheControl = "public, max-age=31536000, immutable"
+	webServer.Mount("/assets", "root", *assets)
+	downloads := NewMountOptions()
+	downloads.FileExtensionFilter = []string{"json"}
+	webServer.MountFS("/downloads/", fstest.MapFS{
+		"manual.txt": {Data: []byte("manual")},
+		"data.json":  {Data: []byte("{}")},
+	}, *downloads)
+
+	tests := []struct {
+		path         string
+		status       int
+		body         string
+		cacheControl string
+	}{
+		{"/assets/script.js", http.StatusOK, "", "public, max-age=31536000, immutable"},
+		{"/assets/missing.js", http.StatusNotFound, "", ""},
+		{"/assets/..%2fwebserver.go", http.StatusForbidden, "", ""},
+		{"/downloads/manual.txt", http.StatusOK, "manual", ""},
+		{"/downloads/data.json", http.StatusForbidden, "", ""},
+		{"/test.json", http.StatusOK, "", ""},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if rec.Code != test.status || (test.body != "" && rec.Body.String() != test.body) {
+			t.Errorf("%s: %d %q, want %d %q", test.path, rec.Code, rec.Body.String(), test.status, test.body)
+		}
+		if cacheControl := rec.Header().Get("Cache-Control"); cacheControl != test.cacheControl {
+			t.Errorf("%s: Cache-Control = %q, want %q", test.path, cacheControl, test.cacheControl)
+		}
+	}
+}
+
+func TestMountAbsoluteDir(t *testing.T) {
+	dir := t.TempDir()
+	content := strings.Repeat("a", 100)
+	for name, data := range map[string]string{"a.txt": content, "a.txt.gz": "gzip", ".env": "secret"} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	err := os.Chtimes(filepath.Join(dir, "a.txt"), modTime, modTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings := NewSettings()
+	settings.BlockDotfiles = true
+	webServer := NewWebServer(*settings)
+	webServer.Mount("/m", dir, *NewMountOptions())
+
+	tests := []struct {
+		path    string
+		headers map[string]string
+		status  int
+		body    string
+	}{
+		{"/m/a.txt", nil, http.StatusOK, content},
+		{"/m/a.txt", map[string]string{"Range": "bytes=0-9"}, http.StatusPartialContent, content[:10]},
+		{"/m/a.txt", map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)}, http.StatusNotModified, ""},
+		{"/m/a.txt", map[string]string{"Accept-Encoding": "gzip"}, http.StatusOK, "gzip"},
+		{"/m/.env", nil, http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		for name, value := range test.headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+		if rec.Code != test.status || (test.status != http.StatusNotFound && rec.Body.String() != test.body) {
+			t.Errorf("%s %v = %d %q, want %d %q", test.path, test.headers, rec.Code, rec.Body.String(), test.status, test.body)
+		}
+		if test.status == http.StatusOK && rec.Header().Get("Last-Modified") != modTime.Format(http.TimeFormat) {
+			t.Errorf("%s Last-Modified = %q", test.path, rec.Header().Get("Last-Modified"))
+		}
+	}
+}
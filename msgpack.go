@@ -0,0 +1,255 @@
+package webserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var errMsgPack = errors.New("invalid msgpack")
+
+// msgPackMaxDepth limits the nesting of decoded arrays and maps
+const msgPackMaxDepth = 32
+
+// marshalMsgPack encodes v as MessagePack. v is converted with encoding/json first, so json field tags and
+// Marshaler implementations apply and maps are encoded with sorted keys.
+func marshalMsgPack(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	err = decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	err = encodeMsgPack(&buffer, value)
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// unmarshalMsgPack decodes MessagePack data into v through encoding/json, binary data is decoded like a base64 string
+func unmarshalMsgPack(data []byte, v any) error {
+	value, rest, err := decodeMsgPack(data, 0)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("%w: %d trailing bytes", errMsgPack, len(rest))
+	}
+	converted, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(converted, v)
+}
+
+func encodeMsgPack(buffer *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case nil:
+		buffer.WriteByte(0xc0)
+	case bool:
+		if v {
+			buffer.WriteByte(0xc3)
+		} else {
+			buffer.WriteByte(0xc2)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			buffer.WriteByte(0xd3)
+			buffer.Write(binary.BigEndian.AppendUint64(nil, uint64(i)))
+			return nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		buffer.WriteByte(0xcb)
+		buffer.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+	case string:
+		writeMsgPackLength(buffer, len(v), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		buffer.WriteString(v)
+	case []any:
+		writeMsgPackLength(buffer, len(v), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, item := range v {
+			err := encodeMsgPack(buffer, item)
+			if err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		writeMsgPackLength(buffer, len(v), 0x80, 15, 0, 0xde, 0xdf)
+		for _, key := range keys {
+			_ = encodeMsgPack(buffer, key)
+			err := encodeMsgPack(buffer, v[key])
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%w: unsupported type %T", errMsgPack, value)
+	}
+	return nil
+}
+
+// writeMsgPackLength writes the header of a string, array or map, fix is the format of lengths up to fixMax,
+// format8 is 0 for types without an 8 bit length
+func writeMsgPackLength(buffer *bytes.Buffer, length int, fix byte, fixMax int, format8, format16, format32 byte) {
+	switch {
+	case length <= fixMax:
+		buffer.WriteByte(fix | byte(length))
+	case format8 != 0 && length <= math.MaxUint8:
+		buffer.WriteByte(format8)
+		buffer.WriteByte(byte(length))
+	case length <= math.MaxUint16:
+		buffer.WriteByte(format16)
+		buffer.Write(binary.BigEndian.AppendUint16(nil, uint16(length)))
+	default:
+		buffer.WriteByte(format32)
+		buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(length)))
+	}
+}
+
+// decodeMsgPack decodes the first item of data into nil, bool, int64, uint64, float64, string, []byte, []any or
+// map[string]any, keys of other types are formatted with fmt. Extension types are rejected.
+func decodeMsgPack(data []byte, depth int) (any, []byte, error) {
+	if depth > msgPackMaxDepth {
+		return nil, nil, fmt.Errorf("%w: nested too deep", errMsgPack)
+	}
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("%w: unexpected end", errMsgPack)
+	}
+	format, data := data[0], data[1:]
+
+	switch {
+	case format <= 0x7f:
+		return int64(format), data, nil
+	case format >= 0xe0:
+		return int64(int8(format)), data, nil
+	case format&0xe0 == 0xa0:
+		return decodeMsgPackString(data, int(format&0x1f))
+	case format&0xf0 == 0x90:
+		return decodeMsgPackArray(data, int(format&0x0f), depth)
+	case format&0xf0 == 0x80:
+		return decodeMsgPackMap(data, int(format&0x0f), depth)
+	}
+
+	switch format {
+	case 0xc0:
+		return nil, data, nil
+	case 0xc2:
+		return false, data, nil
+	case 0xc3:
+		return true, data, nil
+	case 0xc4, 0xc5, 0xc6, 0xd9, 0xda, 0xdb, 0xdc, 0xdd, 0xde, 0xdf:
+		size := map[byte]int{0xc4: 1, 0xc5: 2, 0xc6: 4, 0xd9: 1, 0xda: 2, 0xdb: 4, 0xdc: 2, 0xdd: 4, 0xde: 2, 0xdf: 4}[format]
+		n, data, err := readMsgPackUint(data, size)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch format {
+		case 0xc4, 0xc5, 0xc6:
+			if uint64(len(data)) < n {
+				return nil, nil, fmt.Errorf("%w: unexpected end", errMsgPack)
+			}
+			return bytes.Clone(data[:n]), data[n:], nil
+		case 0xd9, 0xda, 0xdb:
+			return decodeMsgPackString(data, int(n))
+		case 0xdc, 0xdd:
+			return decodeMsgPackArray(data, int(n), depth)
+		default:
+			return decodeMsgPackMap(data, int(n), depth)
+		}
+	case 0xca:
+		n, data, err := readMsgPackUint(data, 4)
+		return float64(math.Float32frombits(uint32(n))), data, err
+	case 0xcb:
+		n, data, err := readMsgPackUint(data, 8)
+		return math.Float64frombits(n), data, err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, data, err := readMsgPackUint(data, 1<<(format-0xcc))
+		return n, data, err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (format - 0xd0)
+		n, data, err := readMsgPackUint(data, size)
+		shift := 64 - 8*size
+		return int64(n<<shift) >> shift, data, err
+	}
+	return nil, nil, fmt.Errorf("%w: unsupported format 0x%02x", errMsgPack, format)
+}
+
+func readMsgPackUint(data []byte, size int) (uint64, []byte, error) {
+	if len(data) < size {
+		return 0, nil, fmt.Errorf("%w: unexpected end", errMsgPack)
+	}
+	var n uint64
+	for _, b := range data[:size] {
+		n = n<<8 | uint64(b)
+	}
+	return n, data[size:], nil
+}
+
+func decodeMsgPackString(data []byte, length int) (any, []byte, error) {
+	if length < 0 || len(data) < length {
+		return nil, nil, fmt.Errorf("%w: unexpected end", errMsgPack)
+	}
+	return string(data[:length]), data[length:], nil
+}
+
+func decodeMsgPackArray(data []byte, length int, depth int) (any, []byte, error) {
+	// every item takes at least one byte, so larger lengths are invalid and must not be allocated
+	if length < 0 || len(data) < length {
+		return nil, nil, fmt.Errorf("%w: unexpected end", errMsgPack)
+	}
+	items := make([]any, 0, length)
+	for i := 0; i < length; i++ {
+		var item any
+		var err error
+		item, data, err = decodeMsgPack(data, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		items = append(items, item)
+	}
+	return items, data, nil
+}
+
+func decodeMsgPackMap(data []byte, length int, depth int) (any, []byte, error) {
+	if length < 0 || len(data) < 2*length {
+		return nil, nil, fmt.Errorf("%w: unexpected end", errMsgPack)
+	}
+	items := make(map[string]any, length)
+	for i := 0; i < length; i++ {
+		var key, value any
+		var err error
+		key, data, err = decodeMsgPack(data, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		value, data, err = decodeMsgPack(data, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			name = fmt.Sprint(key)
+		}
+		items[name] = value
+	}
+	return items, data, nil
+}
@@ -0,0 +1,286 @@
+package webserver
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Codec encodes responses and decodes requests of a media type, a nil Encode or Decode leaves the direction out
+type Codec struct {
+	MediaType string
+	Encode    func(w io.Writer, v any) error
+	Decode    func(r io.Reader, v any) error
+}
+
+var (
+	JSONCodec = Codec{
+		MediaType: "application/json",
+		Encode:    func(w io.Writer, v any) error { return json.NewEncoder(w).Encode(v) },
+		Decode:    func(r io.Reader, v any) error { return json.NewDecoder(r).Decode(v) },
+	}
+	XMLCodec = Codec{
+		MediaType: "application/xml",
+		Encode:    func(w io.Writer, v any) error { return xml.NewEncoder(w).Encode(v) },
+		Decode:    func(r io.Reader, v any) error { return xml.NewDecoder(r).Decode(v) },
+	}
+	// MsgPackCodec converts values with encoding/json, so json field tags apply
+	MsgPackCodec = Codec{
+		MediaType: "application/msgpack",
+		Encode: func(w io.Writer, v any) error {
+			data, err := marshalMsgPack(v)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(data)
+			return err
+		},
+		Decode: func(r io.Reader, v any) error {
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			return unmarshalMsgPack(data, v)
+		},
+	}
+	// FormCodec binds structs using `form` field tags like NewFormBodyHandler and encodes their scalar fields
+	FormCodec = Codec{
+		MediaType: "application/x-www-form-urlencoded",
+		Encode: func(w io.Writer, v any) error {
+			values, err := formValues(v)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(w, values.Encode())
+			return err
+		},
+		Decode: func(r io.Reader, v any) error {
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			values, err := url.ParseQuery(string(data))
+			if err != nil {
+				return err
+			}
+			return bindValues(v, "form", values, nil)
+		},
+	}
+)
+
+// NewNegotiatedHandler registers handler for method and pattern and serves one route in several representations.
+// The request body is decoded with the codec of its Content-Type, requests without body are passed the zero Req.
+// The result is encoded with the codec the Accept header prefers, the first codec for requests without Accept.
+// Requests are answered with 415 Unsupported Media Type for unknown body types and with 406 Not Acceptable if no
+// codec matches Accept. Without codecs JSONCodec, XMLCodec, MsgPackCodec and FormCodec are used.
+// Errors of handler are answered like validation errors for ValidationErrors and with 500 otherwise.
+func NewNegotiatedHandler[Req, Resp any](
+	webServer *WebServer,
+	method HTTPMethod,
+	pattern string,
+	handler func(http.ResponseWriter, *http.Request, Req) (Resp, error),
+	codecs ...Codec,
+) {
+	if len(codecs) == 0 {
+		codecs = []Codec{JSONCodec, XMLCodec, MsgPackCodec, FormCodec}
+	}
+	var offers []string
+	for _, codec := range codecs {
+		if codec.Encode != nil {
+			offers = append(offers, codec.MediaType)
+		}
+	}
+
+	webServer.NewHandleFunc(method, pattern, func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Add("Vary", "Accept")
+		offer, ok := negotiateMediaType(req.Header.Get("Accept"), offers)
+		if !ok {
+			webServer.logger.Println("Negotiation: 406: " + req.Header.Get("Accept"))
+			available := "available: " + strings.Join(offers, ", ")
+			if webServer.handleError(rw, req, errors.New(available), http.StatusNotAcceptable) {
+				return
+			}
+			rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			rw.WriteHeader(http.StatusNotAcceptable)
+			_, _ = rw.Write([]byte(available))
+			return
+		}
+
+		var body Req
+		if req.ContentLength != 0 && req.Body != nil && req.Body != http.NoBody {
+			mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+			decode := codecFor(codecs, mediaType)
+			if decode == nil || decode.Decode == nil {
+				webServer.unsupportedMediaType(rw, req, mediaType)
+				return
+			}
+			webServer.limitBody(rw, req)
+			err := decode.Decode(req.Body, &body)
+			if errors.Is(err, io.EOF) && req.ContentLength < 0 {
+				// a chunked request without content
+				err = nil
+			}
+			if err != nil {
+				webServer.bodyError(rw, req, err)
+				return
+			}
+		}
+
+		result, err := handler(rw, req, body)
+		var validationErrors ValidationErrors
+		if errors.As(err, &validationErrors) {
+			webServer.validationError(rw, req, err)
+			return
+		}
+		if err != nil {
+			webServer.writeErrorCause(rw, req, http.StatusInternalServerError, err)
+			webServer.logger.Println("Negotiation: 500: " + err.Error())
+			return
+		}
+
+		rw.Header().Set("Content-Type", offer)
+		err = codecFor(codecs, offer).Encode(rw, result)
+		if err != nil {
+			webServer.logger.Println("Negotiation: " + offer + ": " + err.Error())
+		}
+	})
+	webServer.documentRequest(method, pattern, "json", reflect.TypeFor[Req]())
+}
+
+func codecFor(codecs []Codec, mediaType string) *Codec {
+	for i := range codecs {
+		if strings.EqualFold(codecs[i].MediaType, mediaType) {
+			return &codecs[i]
+		}
+	}
+	return nil
+}
+
+// negotiateMediaType returns the offer the Accept header prefers by quality, then specificity, then order of offers.
+// An empty header accepts the first offer.
+func negotiateMediaType(accept string, offers []string) (string, bool) {
+	if len(offers) == 0 {
+		return "", false
+	}
+	if strings.TrimSpace(accept) == "" {
+		return offers[0], true
+	}
+
+	type acceptRange struct {
+		mediaType   string
+		quality     float64
+		specificity int
+	}
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+		}
+		specificity := 2
+		if mediaType == "*/*" {
+			specificity = 0
+		} else if strings.HasSuffix(mediaType, "/*") {
+			specificity = 1
+		}
+		ranges = append(ranges, acceptRange{mediaType, quality, specificity})
+	}
+	// the most specific range matching an offer decides its quality
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].specificity > ranges[j].specificity
+	})
+
+	best, bestQuality := "", 0.0
+	for _, offer := range offers {
+		for _, r := range ranges {
+			prefix, _, _ := strings.Cut(r.mediaType, "/")
+			if r.mediaType == "*/*" || strings.EqualFold(r.mediaType, offer) ||
+				(r.specificity == 1 && strings.HasPrefix(strings.ToLower(offer), strings.ToLower(prefix)+"/")) {
+				if r.quality > bestQuality {
+					best, bestQuality = offer, r.quality
+				}
+				break
+			}
+		}
+	}
+	return best, bestQuality > 0
+}
+
+// formValues returns the exported scalar fields of the struct v as form values, named like bindValues binds them
+func formValues(v any) (url.Values, error) {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return url.Values{}, nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, errors.New("form encoding needs a struct, got " + value.Type().String())
+	}
+
+	values := url.Values{}
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		if tagValue, ok := field.Tag.Lookup("form"); ok {
+			name, _, _ = strings.Cut(tagValue, ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+		}
+
+		fieldValue := value.Field(i)
+		if fieldValue.Kind() == reflect.Slice && fieldValue.Type().Elem().Kind() != reflect.Uint8 {
+			for j := 0; j < fieldValue.Len(); j++ {
+				values.Add(name, formValue(fieldValue.Index(j)))
+			}
+			continue
+		}
+		values.Set(name, formValue(fieldValue))
+	}
+	return values, nil
+}
+
+func formValue(value reflect.Value) string {
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+	if marshaler, ok := value.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		if err == nil {
+			return string(text)
+		}
+	}
+	if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Uint8 {
+		return string(value.Bytes())
+	}
+	return fmt.Sprint(value.Interface())
+}
@@ -0,0 +1,101 @@
+package webserver
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type negotiatedItem struct {
+	Name  string   `json:"name" xml:"name" form:"name"`
+	Count int      `json:"count" xml:"count" form:"count"`
+	Tags  []string `json:"tags" xml:"tag" form:"tag"`
+}
+
+func TestNegotiatedHandler(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	NewNegotiatedHandler(webServer, HTTPMethodPost, "/items", func(rw http.ResponseWriter, req *http.Request, item negotiatedItem) (negotiatedItem, error) {
+		if item.Name == "" {
+			return item, ValidationErrors{{Field: "name", Message: "is required"}}
+		}
+		item.Count++
+		return item, nil
+	})
+
+	msgpack, err := marshalMsgPack(negotiatedItem{Name: "a", Count: 1, Tags: []string{"x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		contentType, accept, body string
+		status                    int
+		responseType, response    string
+	}{
+		{"application/json", "", `{"name":"a","count":1}`, http.StatusOK, "application/json", `{"name":"a","count":2,"tags":null}` + "\n"},
+		{"application/json", "text/html, application/xml;q=0.9, */*;q=0.1", `{"name":"a","count":1,"tags":["x"]}`, http.StatusOK,
+			"application/xml", `<negotiatedItem><name>a</name><count>2</count><tag>x</tag></negotiatedItem>`},
+		{"application/x-www-form-urlencoded", "application/*;q=0.5, application/x-www-form-urlencoded", "name=a&count=1&tag=x&tag=y", http.StatusOK,
+			"application/x-www-form-urlencoded", "count=2&name=a&tag=x&tag=y"},
+		{"application/msgpack", "application/json", string(msgpack), http.StatusOK, "application/json", `{"name":"a","count":2,"tags":["x"]}` + "\n"},
+		{"text/csv", "", "a,1", http.StatusUnsupportedMediaType, "", ""},
+		{"application/json", "text/html, application/json;q=0", `{"name":"a"}`, http.StatusNotAcceptable, "", ""},
+		{"application/json", "", `{"count":1}`, http.StatusUnprocessableEntity, "application/json", ""},
+		{"application/json", "", `{"name":`, http.StatusBadRequest, "", ""},
+	}
+	for i, test := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", test.contentType)
+		req.Header.Set("Accept", test.accept)
+		webServer.mux.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("%d: status = %d, want %d", i, rec.Code, test.status)
+		}
+		if test.responseType != "" && rec.Header().Get("Content-Type") != test.responseType {
+			t.Errorf("%d: Content-Type = %q, want %q", i, rec.Header().Get("Content-Type"), test.responseType)
+		}
+		if test.response != "" && rec.Body.String() != test.response {
+			t.Errorf("%d: body = %q, want %q", i, rec.Body.String(), test.response)
+		}
+		if rec.Header().Get("Vary") != "Accept" {
+			t.Errorf("%d: Vary = %q", i, rec.Header().Get("Vary"))
+		}
+	}
+}
+
+func TestMsgPack(t *testing.T) {
+	value := map[string]any{
+		"small": int64(5), "negative": int64(-300), "large": int64(1) << 40, "float": 1.5,
+		"string": strings.Repeat("s", 40), "list": []any{true, false, nil}, "nested": map[string]any{"a": "b"},
+	}
+	data, err := marshalMsgPack(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, rest, err := decodeMsgPack(data, 0)
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("decode: %v, %d bytes left", err, len(rest))
+	}
+	if !reflect.DeepEqual(decoded, value) {
+		t.Errorf("decoded = %#v, want %#v", decoded, value)
+	}
+
+	// fixint, int8, uint16, float32 and a map with an int key as written by other encoders
+	data = []byte{0x84, 0x01, 0x7f, 0xa1, 'a', 0xd0, 0x80, 0xa1, 'b', 0xcd, 0x01, 0x00, 0xa1, 'c', 0xca, 0x3f, 0xc0, 0x00, 0x00}
+	decoded, _, err = decodeMsgPack(data, 0)
+	want := map[string]any{"1": int64(127), "a": int64(-128), "b": uint64(256), "c": 1.5}
+	if err != nil || !reflect.DeepEqual(decoded, want) {
+		t.Errorf("decoded = %#v, %v, want %#v", decoded, err, want)
+	}
+	_, _, err = decodeMsgPack(append([]byte{0xc4, 0x03}, 'a'), 0)
+	if err == nil {
+		t.Error("truncated bin decoded")
+	}
+	_, _, err = decodeMsgPack(bytes.Repeat([]byte{0x91}, 100), 0)
+	if err == nil {
+		t.Error("deep nesting decoded")
+	}
+}
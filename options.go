@@ -0,0 +1,119 @@
+package webserver
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+var standardMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+}
+
+// optionsHandler answers OPTIONS requests without an OPTIONS route with 204 No Content and
+// the methods registered for the path in the Allow header
+func (webServer *WebServer) optionsHandler(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Allow", strings.Join(webServer.allowedMethods(req), ", "))
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// allowedMethods returns the methods with a route for the path of req. Paths without route are served by the
+// file handler and allow GET, HEAD and OPTIONS.
+func (webServer *WebServer) allowedMethods(req *http.Request) []string {
+	methods := webServer.routedMethods(req)
+	if len(methods) == 0 {
+		return []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+	}
+	if !slices.Contains(methods, http.MethodOptions) {
+		methods = append(methods, http.MethodOptions)
+	}
+	return methods
+}
+
+func (webServer *WebServer) routedMethods(req *http.Request) []string {
+	methods := []string{}
+	for _, method := range webServer.methods() {
+		if webServer.hasRoute(method, req) {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// hasRoute reports whether a handler other than the file handler or the OPTIONS auto-responder matches req with method,
+// HEAD requests also have a route if a GET handler matches
+func (webServer *WebServer) hasRoute(method string, req *http.Request) bool {
+	if method == http.MethodHead && webServer.headFromGet(req) {
+		return true
+	}
+	return webServer.muxRoute(method, req)
+}
+
+// headFromGet reports whether the HEAD request req is answered by the GET handler of its path
+func (webServer *WebServer) headFromGet(req *http.Request) bool {
+	return !webServer.muxRoute(http.MethodHead, req) && webServer.muxRoute(http.MethodGet, req)
+}
+
+func (webServer *WebServer) muxRoute(method string, req *http.Request) bool {
+	probe := *req
+	probe.Method = method
+	_, pattern := webServer.methodMux(method).Handler(&probe)
+	builtin := pattern == "/" && (method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions)
+	return pattern != "" && !builtin
+}
+
+// methodNotAllowed returns the allowed methods if the path of req has routes, but none for its method
+func (webServer *WebServer) methodNotAllowed(req *http.Request) ([]string, bool) {
+	method := strings.ToUpper(req.Method)
+	if method == http.MethodOptions || webServer.hasRoute(method, req) {
+		return nil, false
+	}
+	methods := webServer.allowedMethods(req)
+	if !webServer.hasRoute(methods[0], req) {
+		return nil, false
+	}
+	return methods, true
+}
+
+// methods returns the standard methods and the custom methods with a route
+func (webServer *WebServer) methods() []string {
+	methods := append([]string{}, standardMethods...)
+	for _, route := range webServer.routes {
+		if webServer.methodMux(route.method) == webServer.customMux && !slices.Contains(methods, route.method) {
+			methods = append(methods, route.method)
+		}
+	}
+	return methods
+}
+
+// globalOptions answers "OPTIONS *" with every method the server has a route for
+func (webServer *WebServer) globalOptions(rw http.ResponseWriter) {
+	methods := []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+	for _, route := range webServer.routes {
+		if !slices.Contains(methods, route.method) {
+			methods = append(methods, route.method)
+		}
+	}
+	rw.Header().Set("Allow", strings.Join(methods, ", "))
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// serverHandler is the handler of the http.Server, "OPTIONS *" never reaches a ServeMux
+func (webServer *WebServer) serverHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodOptions && req.RequestURI == "*" {
+			webServer.globalOptions(rw)
+			return
+		}
+		webServer.mux.ServeHTTP(rw, req)
+	})
+}
@@ -0,0 +1,112 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOptions(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	handler := func(rw http.ResponseWriter, req *http.Request) {}
+	webServer.NewHandleFunc(HTTPMethodPost, "/api", handler)
+	webServer.NewHandleFunc(HTTPMethodDelete, "/api/{id}", handler)
+	webServer.NewHandleFunc("PURGE", "/api", handler)
+	webServer.NewHandleFunc(HTTPMethodOptions, "/custom", func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Allow", "custom")
+	})
+
+	tests := []struct {
+		target string
+		status int
+		allow  string
+	}{
+		{"/api", http.StatusNoContent, "POST, PURGE, OPTIONS"},
+		{"/api/7", http.StatusNoContent, "DELETE, OPTIONS"},
+		{"/index.html", http.StatusNoContent, "GET, HEAD, OPTIONS"},
+		{"/custom", http.StatusOK, "custom"},
+		{"*", http.StatusNoContent, "GET, HEAD, OPTIONS, POST, DELETE, PURGE"},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		webServer.serverHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, test.target, nil))
+		if rec.Code != test.status || rec.Header().Get("Allow") != test.allow {
+			t.Errorf("%s: %d %q, want %d %q", test.target, rec.Code, rec.Header().Get("Allow"), test.status, test.allow)
+		}
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	settings := NewSettings()
+	settings.Root = "root"
+	webServer := NewWebServer(*settings)
+	handler := func(rw http.ResponseWriter, req *http.Request) {}
+	webServer.NewHandleFunc(HTTPMethodPost, "/api", handler)
+	webServer.NewHandleFunc(HTTPMethodPut, "/api", handler)
+	webServer.NewHandleFunc(HTTPMethodGet, "/items/{id}", handler)
+
+	tests := []struct {
+		method, path string
+		status       int
+		allow        string
+	}{
+		{http.MethodPost, "/api", http.StatusOK, ""},
+		{http.MethodGet, "/api", http.StatusMethodNotAllowed, "POST, PUT, OPTIONS"},
+		{http.MethodDelete, "/api", http.StatusMethodNotAllowed, "POST, PUT, OPTIONS"},
+		{"PURGE", "/api", http.StatusMethodNotAllowed, "POST, PUT, OPTIONS"},
+		{http.MethodPost, "/items/1", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{http.MethodGet, "/index.html", http.StatusOK, ""},
+		{http.MethodPost, "/index.html", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(test.method, test.path, nil))
+		if rec.Code != test.status || rec.Header().Get("Allow") != test.allow {
+			t.Errorf("%s %s: %d %q, want %d %q", test.method, test.path, rec.Code, rec.Header().Get("Allow"), test.status, test.allow)
+		}
+	}
+}
+
+func TestHeadFromGet(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	webServer.NewHandleFunc(HTTPMethodGet, "/report", func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/csv")
+		_, _ = rw.Write([]byte("a,b\n"))
+		_, _ = rw.Write([]byte("1,2\n"))
+	})
+	webServer.NewHandleFunc(HTTPMethodGet, "/status", func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusAccepted)
+	})
+	webServer.NewHandleFunc(HTTPMethodGet, "/own", func(rw http.ResponseWriter, req *http.Request) {})
+	webServer.NewHandleFunc(HTTPMethodHead, "/own", func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Head", "own")
+	})
+
+	tests := []struct {
+		path          string
+		status        int
+		contentLength string
+		header        string
+	}{
+		{"/report", http.StatusOK, "8", "text/csv"},
+		{"/status", http.StatusAccepted, "0", ""},
+		{"/own", http.StatusOK, "", "own"},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, test.path, nil))
+		header := rec.Header().Get("Content-Type") + rec.Header().Get("X-Head")
+		if rec.Code != test.status || rec.Header().Get("Content-Length") != test.contentLength || header != test.header || rec.Body.Len() != 0 {
+			t.Errorf("%s: %d %q %q %q, want %d %q %q", test.path, rec.Code, rec.Header().Get("Content-Length"), header, rec.Body.String(), test.status, test.contentLength, test.header)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	webServer.serverHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/report", nil))
+	if rec.Header().Get("Allow") != "GET, HEAD, OPTIONS" {
+		t.Errorf("Allow %q", rec.Header().Get("Allow"))
+	}
+}
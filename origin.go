@@ -0,0 +1,158 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var errOrigin = errors.New("origin")
+
+type originCall struct {
+	done chan struct{}
+	file []byte
+	err  error
+}
+
+type originResult struct {
+	file []byte
+	err  error
+}
+
+// pullFromOrigin fetches a file missing below Root from Settings.OriginUrl, stores it in Root and returns it.
+// Concurrent requests for the same path share one fetch. A missing file at the origin is reported as *fs.PathError.
+func (webServer *WebServer) pullFromOrigin(settings Settings, urlPath string) ([]byte, error) {
+	webServer.originMutex.Lock()
+	if call, ok := webServer.originCalls[urlPath]; ok {
+		webServer.originMutex.Unlock()
+		<-call.done
+		return call.file, call.err
+	}
+	call := &originCall{done: make(chan struct{})}
+	if webServer.originCalls == nil {
+		webServer.originCalls = map[string]*originCall{}
+	}
+	webServer.originCalls[urlPath] = call
+	webServer.originMutex.Unlock()
+
+	call.file, call.err = webServer.fetchOrigin(settings, urlPath)
+	if call.err == nil {
+		err := storeOriginFile(settings, urlPath, call.file)
+		if err != nil {
+			webServer.logger.Println("Origin: storing " + urlPath + ": " + err.Error())
+		}
+	}
+
+	webServer.originMutex.Lock()
+	delete(webServer.originCalls, urlPath)
+	webServer.originMutex.Unlock()
+	close(call.done)
+	return call.file, call.err
+}
+
+// fetchOrigin requests the file from the origin. With Settings.OriginHedgeDelay a second request is sent if the first
+// has not answered within the delay, the first successful answer is used.
+func (webServer *WebServer) fetchOrigin(settings Settings, urlPath string) ([]byte, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if settings.OriginTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, settings.OriginTimeout)
+	}
+	defer cancel()
+
+	target := strings.TrimSuffix(settings.OriginUrl, "/") + (&url.URL{Path: urlPath}).EscapedPath()
+	results := make(chan originResult, 2)
+	fetch := func() {
+		file, err := getOrigin(ctx, target)
+		results <- originResult{file: file, err: err}
+	}
+
+	go fetch()
+	if settings.OriginHedgeDelay <= 0 {
+		result := <-results
+		return result.file, result.err
+	}
+
+	timer := time.NewTimer(settings.OriginHedgeDelay)
+	defer timer.Stop()
+	select {
+	case result := <-results:
+		return result.file, result.err
+	case <-timer.C:
+		go fetch()
+	}
+
+	result := <-results
+	if result.err != nil {
+		result = <-results
+	}
+	return result.file, result.err
+}
+
+func getOrigin(ctx context.Context, target string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errOrigin, err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errOrigin, err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return nil, &fs.PathError{Op: "get", Path: target, Err: fs.ErrNotExist}
+	default:
+		return nil, fmt.Errorf("%w: %s: %s", errOrigin, target, res.Status)
+	}
+
+	file, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errOrigin, err)
+	}
+	return file, nil
+}
+
+func storeOriginFile(settings Settings, urlPath string, file []byte) error {
+	filePath, err := resolvePath(settings.Root, urlPath, false)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filePath, file)
+}
+
+// writeFileAtomic writes the file to a temporary file first so concurrent readers never see a partial file
+func writeFileAtomic(filePath string, file []byte) error {
+	err := os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(filePath), ".partial-*")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(file)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), filePath)
+	}
+	if err != nil {
+		_ = os.Remove(temp.Name())
+	}
+	return err
+}
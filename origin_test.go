@@ -0,0 +1,78 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOriginPullThrough(t *testing.T) {
+	var hits atomic.Int32
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		hit := hits.Add(1)
+		switch req.URL.Path {
+		case "/assets/app.js":
+			_, _ = rw.Write([]byte("console.log(1)"))
+		case "/slow.css":
+			if hit == 1 {
+				time.Sleep(300 * time.Millisecond)
+			}
+			_, _ = rw.Write([]byte("body{}"))
+		case "/broken.js":
+			rw.WriteHeader(http.StatusInternalServerError)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer origin.Close()
+
+	settings := NewSettings()
+	root, err := os.MkdirTemp(".", "origin-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(root) }()
+	settings.Root = root
+	settings.OriginUrl = origin.URL + "/"
+	settings.OriginHedgeDelay = 50 * time.Millisecond
+	webServer := NewWebServer(*settings)
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+		hits   int32
+	}{
+		{"/assets/app.js", http.StatusOK, "console.log(1)", 1},
+		{"/assets/app.js", http.StatusOK, "console.log(1)", 0},
+		{"/missing.js", http.StatusNotFound, "", 1},
+		{"/broken.js", http.StatusBadGateway, "", 1},
+		{"/slow.css", http.StatusOK, "body{}", 2},
+	}
+
+	for _, test := range tests {
+		hits.Store(0)
+		start := time.Now()
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if rec.Code != test.status || rec.Body.String() != test.body {
+			t.Errorf("%s: %d %q, want %d %q", test.path, rec.Code, rec.Body.String(), test.status, test.body)
+		}
+		if hits.Load() != test.hits {
+			t.Errorf("%s: %d origin requests, want %d", test.path, hits.Load(), test.hits)
+		}
+		if time.Since(start) > 250*time.Millisecond {
+			t.Errorf("%s: took %s", test.path, time.Since(start))
+		}
+	}
+
+	stored, err := os.ReadFile(filepath.Join(settings.Root, "assets", "app.js"))
+	if err != nil || string(stored) != "console.log(1)" {
+		t.Errorf("stored file = %q, %v", stored, err)
+	}
+}
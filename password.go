@@ -0,0 +1,300 @@
+package webserver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrPasswordMismatch    = errors.New("password does not match")
+	ErrUnknownPasswordHash = errors.New("unknown password hash")
+	ErrCredentialNotFound  = errors.New("credential not found")
+)
+
+// PasswordHasher creates and verifies password hashes in a self describing string format.
+// bcrypt hashes, e.g. of an existing user table, are verified by BcryptHasher passed to NewPasswords as legacy hasher.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify returns ErrPasswordMismatch if password does not match hash
+	Verify(password string, hash string) error
+	// Handles reports whether hash has the format of the hasher
+	Handles(hash string) bool
+	// NeedsRehash reports whether hash was created with other parameters than the hasher uses
+	NeedsRehash(hash string) bool
+}
+
+// Argon2idHasher hashes passwords with Argon2id to the PHC string format "$argon2id$v=19$m=19456,t=2,p=1$salt$key"
+type Argon2idHasher struct {
+	// Memory is in KiB
+	Memory     uint32
+	Time       uint32
+	Threads    uint8
+	SaltLength uint32
+	KeyLength  uint32
+}
+
+// NewArgon2idHasher returns a hasher with the parameters recommended by OWASP, 19 MiB memory, 2 passes and 1 thread
+func NewArgon2idHasher() *Argon2idHasher {
+	return &Argon2idHasher{
+		Memory:     19 * 1024,
+		Time:       2,
+		Threads:    1,
+		SaltLength: 16,
+		KeyLength:  32,
+	}
+}
+
+func (hasher *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, hasher.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, hasher.Time, hasher.Memory, hasher.Threads, hasher.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, hasher.Memory, hasher.Time, hasher.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (hasher *Argon2idHasher) Verify(password string, hash string) error {
+	params, salt, key, err := parseArgon2idHash(hash)
+	if err != nil {
+		return err
+	}
+	derived := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(derived, key) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+func (hasher *Argon2idHasher) Handles(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func (hasher *Argon2idHasher) NeedsRehash(hash string) bool {
+	params, salt, key, err := parseArgon2idHash(hash)
+	return err != nil || params.Memory != hasher.Memory || params.Time != hasher.Time || params.Threads != hasher.Threads ||
+		uint32(len(salt)) != hasher.SaltLength || uint32(len(key)) != hasher.KeyLength
+}
+
+// Parameters of stored argon2id hashes above these limits are rejected, a tampered hash could otherwise make Verify
+// allocate and compute without bounds
+const (
+	argon2idMaxMemory    = 4 * 1024 * 1024
+	argon2idMaxTime      = 64
+	argon2idMaxThreads   = 64
+	argon2idMaxKeyLength = 1024
+)
+
+func parseArgon2idHash(hash string) (params Argon2idHasher, salt []byte, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, fmt.Errorf("%w: not an argon2id hash", ErrUnknownPasswordHash)
+	}
+	var version int
+	_, err = fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("%w: argon2id version %s", ErrUnknownPasswordHash, parts[2])
+	}
+	var memory, time, threads uint64
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads)
+	if err != nil || memory > argon2idMaxMemory || time == 0 || time > argon2idMaxTime || threads == 0 || threads > argon2idMaxThreads {
+		return params, nil, nil, fmt.Errorf("%w: argon2id parameters %s", ErrUnknownPasswordHash, parts[3])
+	}
+	params.Memory, params.Time, params.Threads = uint32(memory), uint32(time), uint8(threads)
+	salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("%w: argon2id salt: %w", ErrUnknownPasswordHash, err)
+	}
+	key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 || len(key) > argon2idMaxKeyLength {
+		return params, nil, nil, fmt.Errorf("%w: argon2id key", ErrUnknownPasswordHash)
+	}
+	return params, salt, key, nil
+}
+
+// BcryptHasher hashes passwords with bcrypt, mainly to verify and upgrade hashes of existing user tables as legacy
+// hasher of NewPasswords. bcrypt only uses the first 72 bytes of a password.
+type BcryptHasher struct {
+	Cost int
+}
+
+func NewBcryptHasher() *BcryptHasher {
+	return &BcryptHasher{Cost: bcrypt.DefaultCost}
+}
+
+func (hasher *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), hasher.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (hasher *BcryptHasher) Verify(password string, hash string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrPasswordMismatch
+	}
+	if err != nil {
+		return fmt.Errorf("%w: bcrypt: %w", ErrUnknownPasswordHash, err)
+	}
+	return nil
+}
+
+func (hasher *BcryptHasher) Handles(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (hasher *BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != hasher.Cost
+}
+
+// Passwords hashes new passwords with the preferred hasher and verifies hashes of the preferred and legacy hashers
+type Passwords struct {
+	preferred PasswordHasher
+	legacy    []PasswordHasher
+	// dummy is verified for unknown identities, so they take as long as wrong passwords
+	dummy     string
+	dummyOnce sync.Once
+}
+
+func NewPasswords(preferred PasswordHasher, legacy ...PasswordHasher) *Passwords {
+	return &Passwords{preferred: preferred, legacy: legacy}
+}
+
+func (passwords *Passwords) Hash(password string) (string, error) {
+	return passwords.preferred.Hash(password)
+}
+
+// Verify checks password against hash. If it matches but hash is of a legacy hasher or outdated parameters,
+// rehash is the hash of the preferred hasher to store instead, otherwise it is empty.
+func (passwords *Passwords) Verify(password string, hash string) (rehash string, err error) {
+	hasher := passwords.hasher(hash)
+	if hasher == nil {
+		return "", ErrUnknownPasswordHash
+	}
+	err = hasher.Verify(password, hash)
+	if err != nil {
+		return "", err
+	}
+	if hasher == passwords.preferred && !hasher.NeedsRehash(hash) {
+		return "", nil
+	}
+	return passwords.preferred.Hash(password)
+}
+
+func (passwords *Passwords) hasher(hash string) PasswordHasher {
+	if passwords.preferred.Handles(hash) {
+		return passwords.preferred
+	}
+	for _, hasher := range passwords.legacy {
+		if hasher.Handles(hash) {
+			return hasher
+		}
+	}
+	return nil
+}
+
+// Credential is the stored password of an identity
+type Credential struct {
+	Identity     string
+	PasswordHash string
+}
+
+// CredentialStore keeps credentials, implementations must be safe for concurrent use
+type CredentialStore interface {
+	// FindCredential returns ErrCredentialNotFound for unknown identities
+	FindCredential(ctx context.Context, identity string) (Credential, error)
+	SaveCredential(ctx context.Context, credential Credential) error
+}
+
+// Authenticate checks password of identity against store and stores the rehashed password if Verify returns one.
+// A failed save of the rehash is logged to logger, e.g. Logger(req), the login succeeds anyway.
+// Unknown identities return ErrPasswordMismatch after verifying a dummy hash, so they cannot be told apart by timing.
+func (passwords *Passwords) Authenticate(ctx context.Context, store CredentialStore, identity string, password string, logger *log.Logger) error {
+	credential, err := store.FindCredential(ctx, identity)
+	if errors.Is(err, ErrCredentialNotFound) {
+		passwords.dummyOnce.Do(func() {
+			passwords.dummy, _ = passwords.preferred.Hash("dummy")
+		})
+		_, _ = passwords.Verify(password, passwords.dummy)
+		return ErrPasswordMismatch
+	}
+	if err != nil {
+		return err
+	}
+
+	rehash, err := passwords.Verify(password, credential.PasswordHash)
+	if err != nil {
+		return err
+	}
+	if rehash != "" {
+		credential.PasswordHash = rehash
+		err = store.SaveCredential(ctx, credential)
+		if err != nil {
+			logger.Println("Passwords: rehash of " + identity + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// BasicAuth returns a middleware like BasicAuth which authenticates the users of store
+func (passwords *Passwords) BasicAuth(store CredentialStore) func(http.ResponseWriter, *http.Request) bool {
+	return func(rw http.ResponseWriter, req *http.Request) bool {
+		name, password, ok := req.BasicAuth()
+		if ok {
+			err := passwords.Authenticate(req.Context(), store, name, password, Logger(req))
+			if err == nil {
+				setPrincipal(req, Principal{Name: name, Scheme: "Basic"})
+				return true
+			}
+			if !errors.Is(err, ErrPasswordMismatch) {
+				Logger(req).Println("Basic Auth: " + err.Error())
+			}
+		}
+
+		rw.Header().Set("WWW-Authenticate", `Basic realm="Restricted", charset="UTF-8"`)
+		writeRequestError(rw, req, http.StatusUnauthorized)
+		return false
+	}
+}
+
+// MemoryCredentialStore keeps the credentials in process memory
+type MemoryCredentialStore struct {
+	mutex       sync.Mutex
+	credentials map[string]Credential
+}
+
+func NewMemoryCredentialStore() *MemoryCredentialStore {
+	return &MemoryCredentialStore{credentials: map[string]Credential{}}
+}
+
+func (store *MemoryCredentialStore) FindCredential(ctx context.Context, identity string) (Credential, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	credential, ok := store.credentials[identity]
+	if !ok {
+		return Credential{}, ErrCredentialNotFound
+	}
+	return credential, nil
+}
+
+func (store *MemoryCredentialStore) SaveCredential(ctx context.Context, credential Credential) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.credentials[credential.Identity] = credential
+	return nil
+}
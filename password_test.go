@@ -0,0 +1,185 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// plainHasher stands in for a legacy hasher like bcrypt
+type plainHasher struct{}
+
+func (plainHasher) Hash(password string) (string, error) {
+	return "plain:" + password, nil
+}
+
+func (plainHasher) Verify(password string, hash string) error {
+	if hash != "plain:"+password {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+func (plainHasher) Handles(hash string) bool {
+	return strings.HasPrefix(hash, "plain:")
+}
+
+func (plainHasher) NeedsRehash(hash string) bool {
+	return false
+}
+
+func TestArgon2idHasher(t *testing.T) {
+	hasher := NewArgon2idHasher()
+	hasher.Memory = 64
+	hash, err := hasher.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=2,p=1$") {
+		t.Errorf("hash = %s", hash)
+	}
+	if err := hasher.Verify("secret", hash); err != nil {
+		t.Errorf("Verify(secret) = %v", err)
+	}
+	if err := hasher.Verify("wrong", hash); !errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("Verify(wrong) = %v, want ErrPasswordMismatch", err)
+	}
+	if hasher.NeedsRehash(hash) {
+		t.Error("NeedsRehash with the same parameters")
+	}
+	stronger := *hasher
+	stronger.Time = 3
+	if !stronger.NeedsRehash(hash) {
+		t.Error("NeedsRehash with more passes = false")
+	}
+	if err := hasher.Verify("secret", "$argon2id$v=19$m=64$salt$key"); !errors.Is(err, ErrUnknownPasswordHash) {
+		t.Errorf("Verify(malformed) = %v, want ErrUnknownPasswordHash", err)
+	}
+
+	// hashes stored by earlier versions still verify
+	stored := "$argon2id$v=19$m=64,t=2,p=2$c29tZXNhbHRzb21lc2FsdA$vGWrn6I/PGlluEBRcxBfMjJk/9ti0CC0ig+MNGAsI8Q"
+	if err := hasher.Verify("secret", stored); err != nil {
+		t.Errorf("Verify(stored) = %v", err)
+	}
+
+	for _, parameters := range []string{"m=4294967295,t=2,p=1", "m=64,t=100000,p=1", "m=64,t=2,p=255", "m=64,t=2,p=256"} {
+		hash := "$argon2id$v=19$" + parameters + "$c29tZXNhbHRzb21lc2FsdA$vGWrn6I/PGlluEBRcxBfMjJk/9ti0CC0ig+MNGAsI8Q"
+		if err := hasher.Verify("secret", hash); !errors.Is(err, ErrUnknownPasswordHash) {
+			t.Errorf("Verify(%s) = %v, want ErrUnknownPasswordHash", parameters, err)
+		}
+	}
+}
+
+func TestBcryptHasher(t *testing.T) {
+	hasher := NewBcryptHasher()
+	hasher.Cost = bcrypt.MinCost
+	hash, err := hasher.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasher.Handles(hash) || hasher.NeedsRehash(hash) {
+		t.Errorf("hash = %s", hash)
+	}
+	if err := hasher.Verify("secret", hash); err != nil {
+		t.Errorf("Verify(secret) = %v", err)
+	}
+	if err := hasher.Verify("wrong", hash); !errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("Verify(wrong) = %v, want ErrPasswordMismatch", err)
+	}
+	if err := hasher.Verify("secret", "$2b$10$short"); !errors.Is(err, ErrUnknownPasswordHash) {
+		t.Errorf("Verify(malformed) = %v, want ErrUnknownPasswordHash", err)
+	}
+
+	// a bcrypt user table is upgraded to argon2id on sign in
+	argon2id := NewArgon2idHasher()
+	argon2id.Memory = 64
+	rehash, err := NewPasswords(argon2id, hasher).Verify("secret", hash)
+	if err != nil || !argon2id.Handles(rehash) {
+		t.Errorf("Verify of a legacy bcrypt hash = %q, %v", rehash, err)
+	}
+}
+
+func TestPasswordsAuthenticate(t *testing.T) {
+	hasher := NewArgon2idHasher()
+	hasher.Memory = 64
+	passwords := NewPasswords(hasher, plainHasher{})
+	store := NewMemoryCredentialStore()
+	ctx := context.Background()
+	_ = store.SaveCredential(ctx, Credential{Identity: "alice", PasswordHash: "plain:secret"})
+	logger := log.New(io.Discard, "", 0)
+
+	if err := passwords.Authenticate(ctx, store, "alice", "wrong", logger); !errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("wrong password: %v", err)
+	}
+	if err := passwords.Authenticate(ctx, store, "bob", "secret", logger); !errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("unknown identity: %v", err)
+	}
+	if err := passwords.Authenticate(ctx, store, "alice", "secret", logger); err != nil {
+		t.Fatalf("legacy hash: %v", err)
+	}
+	credential, _ := store.FindCredential(ctx, "alice")
+	if !hasher.Handles(credential.PasswordHash) {
+		t.Errorf("legacy hash was not upgraded: %s", credential.PasswordHash)
+	}
+	if err := passwords.Authenticate(ctx, store, "alice", "secret", logger); err != nil {
+		t.Errorf("upgraded hash: %v", err)
+	}
+	if _, err := passwords.Verify("secret", "$2b$10$unknown"); !errors.Is(err, ErrUnknownPasswordHash) {
+		t.Errorf("hash of unknown hasher: %v", err)
+	}
+
+	webServer := NewWebServer(*NewSettings())
+	webServer.NewPhaseMiddleware(PhaseAuth, passwords.BasicAuth(store))
+	webServer.NewHandleFunc(HTTPMethodGet, "/whoami", func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(Identity(req)))
+	})
+	for _, test := range []struct {
+		password string
+		status   int
+	}{
+		{"secret", http.StatusOK},
+		{"wrong", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+		req.SetBasicAuth("alice", test.password)
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("basic auth with %q: status %d, want %d", test.password, rec.Code, test.status)
+		}
+	}
+}
+
+// readOnlyStore fails to save credentials
+type readOnlyStore struct {
+	*MemoryCredentialStore
+}
+
+func (readOnlyStore) SaveCredential(ctx context.Context, credential Credential) error {
+	return errors.New("read-only")
+}
+
+func TestPasswordsAuthenticateRehashFails(t *testing.T) {
+	hasher := NewArgon2idHasher()
+	hasher.Memory = 64
+	passwords := NewPasswords(hasher, plainHasher{})
+	store := NewMemoryCredentialStore()
+	ctx := context.Background()
+	_ = store.SaveCredential(ctx, Credential{Identity: "alice", PasswordHash: "plain:secret"})
+
+	var logs strings.Builder
+	err := passwords.Authenticate(ctx, readOnlyStore{store}, "alice", "secret", log.New(&logs, "", 0))
+	if err != nil {
+		t.Errorf("login with a failed rehash = %v", err)
+	}
+	if !strings.Contains(logs.String(), "Passwords: rehash of alice: read-only") {
+		t.Errorf("log = %q", logs.String())
+	}
+}
@@ -0,0 +1,87 @@
+package webserver
+
+import (
+	"errors"
+	"io/fs"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var errPathTraversal = errors.New("path traversal")
+
+// rootDir returns the directory of a root like Settings.Root, relative roots are relative to the working directory.
+// The root "/" is the working directory too, as it was the default Root of earlier versions.
+func rootDir(root string) string {
+	if root == "" || path.Clean(root) == "/" {
+		return "."
+	}
+	return filepath.Clean(filepath.FromSlash(root))
+}
+
+// resolvePath maps the url path of a request onto a file below root.
+// Paths containing ".." segments (also encoded or with backslash separators) are rejected with errPathTraversal.
+// With blockSymlinkEscape set, files whose symlinks resolve outside of root are reported as not existing.
+func resolvePath(root string, urlPath string, blockSymlinkEscape bool) (string, error) {
+	err := checkTraversal(urlPath)
+	if err != nil {
+		return "", err
+	}
+
+	rootDir := rootDir(root)
+	filePath := filepath.Join(rootDir, filepath.FromSlash(path.Clean("/"+urlPath)))
+	relPath, err := filepath.Rel(rootDir, filePath)
+	if err != nil || isOutside(relPath) {
+		return "", errPathTraversal
+	}
+
+	if blockSymlinkEscape {
+		realRoot, err := filepath.EvalSymlinks(rootDir)
+		if err != nil {
+			return "", err
+		}
+		realPath, err := filepath.EvalSymlinks(filePath)
+		if err != nil {
+			return "", err
+		}
+		relPath, err := filepath.Rel(realRoot, realPath)
+		if err != nil || isOutside(relPath) {
+			return "", &fs.PathError{Op: "open", Path: filePath, Err: fs.ErrNotExist}
+		}
+	}
+
+	return filePath, nil
+}
+
+// isOutside reports whether a path relative to a directory leaves it
+func isOutside(relPath string) bool {
+	return relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator))
+}
+
+// checkTraversal returns errPathTraversal for url paths containing ".." segments, also encoded or with backslash separators
+func checkTraversal(urlPath string) error {
+	if strings.ContainsRune(urlPath, 0) {
+		return errPathTraversal
+	}
+
+	decoded := urlPath
+	for strings.Contains(decoded, "%") {
+		next, err := url.PathUnescape(decoded)
+		if err != nil || next == decoded {
+			break
+		}
+		decoded = next
+	}
+
+	for _, segment := range strings.FieldsFunc(decoded, isPathSeparator) {
+		if segment == ".." {
+			return errPathTraversal
+		}
+	}
+	return nil
+}
+
+func isPathSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
@@ -0,0 +1,139 @@
+package webserver
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolvePath(t *testing.T) {
+	tests := []struct {
+		urlPath string
+		want    string
+		err     error
+	}{
+		{"/index.html", filepath.Join("root", "index.html"), nil},
+		{"/sub/dir/../file.txt", "", errPathTraversal},
+		{"/../webserver.go", "", errPathTraversal},
+		{"/..", "", errPathTraversal},
+		{"/%2e%2e/webserver.go", "", errPathTraversal},
+		{"/%252e%252e/webserver.go", "", errPathTraversal},
+		{"/..%2fwebserver.go", "", errPathTraversal},
+		{"/..\\webserver.go", "", errPathTraversal},
+		{"/sub\\..\\..\\webserver.go", "", errPathTraversal},
+		{"/..%5cwebserver.go", "", errPathTraversal},
+		{"/index.html\x00.png", "", errPathTraversal},
+		{"/...", filepath.Join("root", "..."), nil},
+		{"/a..b/c", filepath.Join("root", "a..b", "c"), nil},
+		{"//style.css", filepath.Join("root", "style.css"), nil},
+	}
+
+	for _, test := range tests {
+		got, err := resolvePath("root", test.urlPath, false)
+		if !errors.Is(err, test.err) {
+			t.Errorf("resolvePath(%q) error = %v, want %v", test.urlPath, err, test.err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("resolvePath(%q) = %q, want %q", test.urlPath, got, test.want)
+		}
+	}
+}
+
+func TestResolvePathAbsoluteRoot(t *testing.T) {
+	root := t.TempDir()
+	err := os.MkdirAll(filepath.Join(root, "a"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(root, "a", "b.html"), []byte("b"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := resolvePath(root, "/a/b.html", false)
+	if err != nil || got != filepath.Join(root, "a", "b.html") {
+		t.Errorf("resolvePath(%q, /a/b.html) = %q, %v", root, got, err)
+	}
+	got, err = resolvePath(root+string(filepath.Separator), "/", false)
+	if err != nil || got != root {
+		t.Errorf("resolvePath(%q, /) = %q, %v", root, got, err)
+	}
+
+	settings := NewSettings()
+	settings.Root = root
+	webServer := NewWebServer(*settings)
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a/b.html", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "b" {
+		t.Errorf("GET /a/b.html below %s = %d %q", root, rec.Code, rec.Body.String())
+	}
+}
+
+func TestResolvePathSlashRoot(t *testing.T) {
+	// "/" was the default Root and means the working directory, not the root of the filesystem
+	for _, root := range []string{"/", "//"} {
+		got, err := resolvePath(root, "/root/index.html", false)
+		if err != nil || got != filepath.Join("root", "index.html") {
+			t.Errorf("resolvePath(%q, /root/index.html) = %q, %v", root, got, err)
+		}
+	}
+
+	settings := NewSettings()
+	settings.Root = "/"
+	webServer := NewWebServer(*settings)
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/path_test.go", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /path_test.go with Root / = %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/etc/passwd", nil))
+	if rec.Code == http.StatusOK {
+		t.Errorf("GET /etc/passwd with Root / served %q", rec.Body.String())
+	}
+}
+
+func TestResolvePathSymlinkEscape(t *testing.T) {
+	root, err := os.MkdirTemp(".", "symlink-root-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(root) })
+
+	outside, err := filepath.Abs("go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink(outside, filepath.Join(root, "escape"))
+	if err != nil {
+		t.Skip("symlinks not supported: ", err)
+	}
+	err = os.WriteFile(filepath.Join(root, "inside.txt"), []byte("inside"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink("inside.txt", filepath.Join(root, "link.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resolvePath(root, "/escape", false)
+	if err != nil {
+		t.Errorf("escape without blocking: unexpected error %v", err)
+	}
+
+	_, err = resolvePath(root, "/escape", true)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("escape with blocking: error = %v, want %v", err, fs.ErrNotExist)
+	}
+
+	_, err = resolvePath(root, "/link.txt", true)
+	if err != nil {
+		t.Errorf("link inside root: unexpected error %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package webserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// fileEncodings are the sidecar encodings in order of preference
+var fileEncodings = []struct {
+	name   string
+	suffix string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// encodeFile returns the body of a static file for the Accept-Encoding of req and sets Content-Encoding for it:
+// a .br or .gz sidecar read with sidecar, file gzipped on the fly with Settings.CompressFiles, or file itself.
+// sidecar is nil for files that have none, e.g. files pulled from the origin.
+func (webServer *WebServer) encodeFile(rw http.ResponseWriter, req *http.Request, settings Settings, file []byte, sidecar func(suffix string) ([]byte, error)) []byte {
+	mediaType, _, _ := mime.ParseMediaType(rw.Header().Get("Content-Type"))
+	precompressed := settings.ServePrecompressed && sidecar != nil
+	compress := settings.CompressFiles && compressible(mediaType) && len(file) >= compressionMinSize
+	if !precompressed && !compress {
+		return file
+	}
+	rw.Header().Add("Vary", "Accept-Encoding")
+	if rw.Header().Get("Content-Encoding") != "" {
+		return file
+	}
+
+	acceptEncoding := req.Header.Get("Accept-Encoding")
+	if precompressed {
+		for _, encoding := range fileEncodings {
+			if !acceptsEncoding(acceptEncoding, encoding.name) {
+				continue
+			}
+			encoded, err := sidecar(encoding.suffix)
+			if err == nil {
+				rw.Header().Set("Content-Encoding", encoding.name)
+				return encoded
+			}
+		}
+	}
+
+	if compress && acceptsEncoding(acceptEncoding, "gzip") {
+		var buffer bytes.Buffer
+		writer := gzip.NewWriter(&buffer)
+		_, err := writer.Write(file)
+		if err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			webServer.logger.Println("File Handler: gzip: " + err.Error())
+			return file
+		}
+		rw.Header().Set("Content-Encoding", "gzip")
+		return buffer.Bytes()
+	}
+	return file
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header accepts encoding with a quality above 0
+func acceptsEncoding(acceptEncoding string, encoding string) bool {
+	accepted := false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.TrimSpace(name)
+		if !strings.EqualFold(name, encoding) && name != "*" {
+			continue
+		}
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err == nil {
+				quality = parsed
+			}
+		}
+		// an explicit entry overrides the wildcard
+		if strings.EqualFold(name, encoding) {
+			return quality > 0
+		}
+		accepted = quality > 0
+	}
+	return accepted
+}
@@ -0,0 +1,97 @@
+package webserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrecompressed(t *testing.T) {
+	root, err := os.MkdirTemp(".", "precompressed-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	script := strings.Repeat("console.log(1);", 100)
+	for name, content := range map[string]string{
+		"app.js":     script,
+		"app.js.br":  "brotli",
+		"app.js.gz":  "gzip",
+		"style.css":  strings.Repeat("a{}", 500),
+		"small.css":  "a{}",
+		"image.png":  strings.Repeat("x", 2000),
+		"only.js":    "only",
+		"only.js.gz": "gzip only",
+	} {
+		err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	settings := NewSettings()
+	settings.Root = root
+	settings.CompressFiles = true
+	webServer := NewWebServer(*settings)
+
+	tests := []struct {
+		path, acceptEncoding string
+		encoding, body       string
+	}{
+		{"/app.js", "gzip, deflate, br", "br", "brotli"},
+		{"/app.js", "gzip, br;q=0", "gzip", "gzip"},
+		{"/app.js", "", "", script},
+		{"/app.js", "identity", "", script},
+		{"/app.js", "*", "br", "brotli"},
+		{"/only.js", "br, gzip", "gzip", "gzip only"},
+		{"/style.css", "br, gzip", "gzip", strings.Repeat("a{}", 500)},
+		{"/small.css", "gzip", "", "a{}"},
+		{"/image.png", "gzip", "", strings.Repeat("x", 2000)},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		req.Header.Set("Accept-Encoding", test.acceptEncoding)
+		webServer.mux.ServeHTTP(rec, req)
+
+		encoding := rec.Header().Get("Content-Encoding")
+		if rec.Code != http.StatusOK || encoding != test.encoding {
+			t.Errorf("%s (%s) = %d, Content-Encoding %q, want %q", test.path, test.acceptEncoding, rec.Code, encoding, test.encoding)
+			continue
+		}
+		body := rec.Body.Bytes()
+		if test.path == "/style.css" {
+			reader, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ = io.ReadAll(reader)
+		}
+		if string(body) != test.body {
+			t.Errorf("%s (%s) body = %.20q, want %.20q", test.path, test.acceptEncoding, body, test.body)
+		}
+		if rec.Header().Get("Content-Type") == "application/gzip" {
+			t.Errorf("%s Content-Type of the sidecar", test.path)
+		}
+	}
+
+	settings.ServePrecompressed = false
+	settings.CompressFiles = false
+	err = webServer.Reload(*settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	webServer.mux.ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Encoding") != "" || rec.Header().Get("Vary") != "" {
+		t.Errorf("disabled: Content-Encoding %q, Vary %q", rec.Header().Get("Content-Encoding"), rec.Header().Get("Vary"))
+	}
+}
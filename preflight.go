@@ -0,0 +1,63 @@
+package webserver
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+var (
+	// ErrPortInUse is returned by Run if another process listens on the address and on every Settings.FallbackPorts
+	ErrPortInUse = errors.New("port already in use")
+	// ErrPrivilegedPort is returned by Run if the process may not bind a port below 1024
+	ErrPrivilegedPort = errors.New("permission denied for privileged port")
+	// ErrCertNotFound is returned by Run if Settings.CertFile or Settings.KeyFile do not exist
+	ErrCertNotFound = errors.New("certificate not found")
+)
+
+// preflight checks the settings before anything is bound
+func preflight(settings Settings) error {
+	if !settings.UseHttps || settings.UseSelfSignedTLS || settings.TLS.Config != nil && len(settings.TLS.Config.Certificates) > 0 {
+		return nil
+	}
+	for _, file := range []struct{ setting, path string }{{"CertFile", settings.CertFile}, {"KeyFile", settings.KeyFile}} {
+		if file.path == "" {
+			return fmt.Errorf("%w: Settings.%s is empty but UseHttps is set", ErrCertNotFound, file.setting)
+		}
+		_, err := os.Stat(file.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: Settings.%s %s does not exist, working directory is %s", ErrCertNotFound, file.setting, file.path, workingDir())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listenError explains the common bind failures
+func listenError(addr string, err error) error {
+	if errors.Is(err, syscall.EADDRINUSE) {
+		return fmt.Errorf("%w: %s, stop the other process or choose another port: %w", ErrPortInUse, addr, err)
+	}
+	if errors.Is(err, syscall.EACCES) || errors.Is(err, syscall.EPERM) {
+		_, portString, _ := net.SplitHostPort(addr)
+		port, parseErr := strconv.Atoi(portString)
+		if parseErr == nil && port > 0 && port < 1024 {
+			return fmt.Errorf("%w: %s, run as root, grant CAP_NET_BIND_SERVICE or choose a port above 1023: %w", ErrPrivilegedPort, addr, err)
+		}
+	}
+	return err
+}
+
+func workingDir() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "unknown"
+	}
+	return dir
+}
@@ -0,0 +1,173 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPreflight(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	_, port, _ := net.SplitHostPort(busy.Addr().String())
+
+	settings := NewSettings()
+	settings.Bind = "127.0.0.1"
+	settings.HttpPort = port
+	err = NewWebServer(*settings).Run()
+	if !errors.Is(err, ErrPortInUse) || !errors.Is(err, syscall.EADDRINUSE) {
+		t.Errorf("busy port: %v", err)
+	}
+
+	settings = NewSettings()
+	settings.UseHttps = true
+	settings.CertFile = "missing.crt"
+	settings.KeyFile = "missing.key"
+	err = NewWebServer(*settings).Run()
+	if !errors.Is(err, ErrCertNotFound) {
+		t.Errorf("missing certificate: %v", err)
+	}
+
+	settings.CertFile = ""
+	err = NewWebServer(*settings).Run()
+	if !errors.Is(err, ErrCertNotFound) {
+		t.Errorf("empty CertFile: %v", err)
+	}
+
+	denied := &net.OpError{Op: "listen", Net: "tcp", Err: os.NewSyscallError("bind", syscall.EACCES)}
+	if err := listenError(":80", denied); !errors.Is(err, ErrPrivilegedPort) {
+		t.Errorf("port 80: %v", err)
+	}
+	if err := listenError(":8080", denied); err != denied {
+		t.Errorf("port 8080: %v", err)
+	}
+}
+
+func TestPortFallback(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	_, port, _ := net.SplitHostPort(busy.Addr().String())
+
+	settings := NewSettings()
+	settings.Bind = "127.0.0.1"
+	settings.HttpPort = port
+	settings.FallbackPorts = []string{port, "0"}
+	webServer := NewWebServer(*settings)
+	chosen := make(chan string, 1)
+	webServer.SetPortFallbackHook(func(port string) {
+		chosen <- port
+	})
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- webServer.Run() }()
+	serving := waitServing(t, webServer, nil)
+	fallback := <-chosen
+	if _, bound, _ := net.SplitHostPort(serving.listener.Addr().String()); fallback != bound || fallback == port {
+		t.Errorf("hook port = %s, bound %s, busy %s", fallback, bound, port)
+	}
+	if current := webServer.Settings(); current.HttpPort != fallback {
+		t.Errorf("Settings().HttpPort = %s, want %s", current.HttpPort, fallback)
+	}
+	_ = serving.server.Close()
+	if err := <-runErr; err != http.ErrServerClosed {
+		t.Errorf("Run returned %v, want %v", err, http.ErrServerClosed)
+	}
+
+	settings.FallbackPorts = []string{port}
+	err = NewWebServer(*settings).Run()
+	if !errors.Is(err, ErrPortInUse) {
+		t.Errorf("every fallback port busy: %v", err)
+	}
+}
+
+func TestStartAndRunContext(t *testing.T) {
+	settings := NewSettings()
+	settings.Bind = "127.0.0.1"
+	settings.HttpPort = "0"
+	webServer := NewWebServer(*settings)
+	webServer.NewHandleFunc(HTTPMethodGet, "/ping", func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("pong"))
+	})
+
+	if webServer.Addr() != nil {
+		t.Errorf("Addr before Start = %v, want nil", webServer.Addr())
+	}
+	err := webServer.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get("http://" + webServer.Addr().String() + "/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(body) != "pong" {
+		t.Errorf("body = %q, want %q", body, "pong")
+	}
+	err = webServer.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+
+	webServer = NewWebServer(*settings)
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- webServer.RunContext(ctx) }()
+	waitServing(t, webServer, nil)
+	cancel()
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Errorf("RunContext returned %v after cancel, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunContext did not return after cancel")
+	}
+}
+
+func TestEphemeralPort(t *testing.T) {
+	settings := NewSettings()
+	settings.Bind = "127.0.0.1"
+	settings.HttpPort = "0"
+	webServer := NewWebServer(*settings)
+	ready := make(chan net.Addr, 1)
+	webServer.SetReadyHook(func(addr net.Addr) {
+		ready <- addr
+	})
+
+	if webServer.ListenAddr() != "" {
+		t.Errorf("ListenAddr before Start = %q, want empty", webServer.ListenAddr())
+	}
+	err := webServer.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = webServer.Shutdown(context.Background()) }()
+
+	addr := <-ready
+	if addr.String() != webServer.ListenAddr() {
+		t.Errorf("ready hook address %s, ListenAddr %s", addr, webServer.ListenAddr())
+	}
+	_, port, _ := net.SplitHostPort(webServer.ListenAddr())
+	if port == "" || port == "0" {
+		t.Errorf("bound port = %q", port)
+	}
+	conn, err := net.Dial("tcp", webServer.ListenAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+}
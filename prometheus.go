@@ -0,0 +1,201 @@
+package webserver
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMetricsBuckets are the histogram bucket boundaries in seconds used if Settings.MetricsBuckets is empty
+var DefaultMetricsBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// PrometheusExporter keeps metrics in memory and serves them in the Prometheus text format.
+// Counts become counters with a _total suffix, gauges gauges and timings histograms in seconds.
+type PrometheusExporter struct {
+	prefix  string
+	buckets []float64
+	tags    map[string]string
+
+	mutex      sync.Mutex
+	counters   map[string]map[string]float64
+	gauges     map[string]map[string]float64
+	histograms map[string]map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewPrometheusExporter creates an exporter with the metric name prefix, the histogram buckets in seconds
+// and tags added to every metric. Register it with SetMetricsExporter and serve it with MetricsHandler.
+func NewPrometheusExporter(prefix string, buckets []float64, tags map[string]string) *PrometheusExporter {
+	if len(buckets) == 0 {
+		buckets = DefaultMetricsBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return &PrometheusExporter{
+		prefix:     prefix,
+		buckets:    buckets,
+		tags:       tags,
+		counters:   map[string]map[string]float64{},
+		gauges:     map[string]map[string]float64{},
+		histograms: map[string]map[string]*histogram{},
+	}
+}
+
+func (exporter *PrometheusExporter) Count(name string, value int64, tags map[string]string) {
+	name, labels := exporter.metricName(name)+"_total", exporter.labels(tags)
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	if exporter.counters[name] == nil {
+		exporter.counters[name] = map[string]float64{}
+	}
+	exporter.counters[name][labels] += float64(value)
+}
+
+func (exporter *PrometheusExporter) Gauge(name string, value float64, tags map[string]string) {
+	name, labels := exporter.metricName(name), exporter.labels(tags)
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	if exporter.gauges[name] == nil {
+		exporter.gauges[name] = map[string]float64{}
+	}
+	exporter.gauges[name][labels] = value
+}
+
+func (exporter *PrometheusExporter) Timing(name string, value time.Duration, tags map[string]string) {
+	name, labels := exporter.metricName(name)+"_seconds", exporter.labels(tags)
+	seconds := value.Seconds()
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	if exporter.histograms[name] == nil {
+		exporter.histograms[name] = map[string]*histogram{}
+	}
+	series := exporter.histograms[name][labels]
+	if series == nil {
+		series = &histogram{counts: make([]uint64, len(exporter.buckets))}
+		exporter.histograms[name][labels] = series
+	}
+	for i, bound := range exporter.buckets {
+		if seconds <= bound {
+			series.counts[i]++
+		}
+	}
+	series.sum += seconds
+	series.count++
+}
+
+// ServeHTTP writes every metric in the Prometheus text exposition format
+func (exporter *PrometheusExporter) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	var builder strings.Builder
+	exporter.mutex.Lock()
+	for _, name := range sortedKeys(exporter.counters) {
+		builder.WriteString("# TYPE " + name + " counter\n")
+		for _, labels := range sortedKeys(exporter.counters[name]) {
+			builder.WriteString(name + labelSet(labels, "") + " " + formatFloat(exporter.counters[name][labels]) + "\n")
+		}
+	}
+	for _, name := range sortedKeys(exporter.gauges) {
+		builder.WriteString("# TYPE " + name + " gauge\n")
+		for _, labels := range sortedKeys(exporter.gauges[name]) {
+			builder.WriteString(name + labelSet(labels, "") + " " + formatFloat(exporter.gauges[name][labels]) + "\n")
+		}
+	}
+	for _, name := range sortedKeys(exporter.histograms) {
+		builder.WriteString("# TYPE " + name + " histogram\n")
+		for _, labels := range sortedKeys(exporter.histograms[name]) {
+			series := exporter.histograms[name][labels]
+			for i, bound := range exporter.buckets {
+				le := `le="` + formatFloat(bound) + `"`
+				builder.WriteString(name + "_bucket" + labelSet(labels, le) + " " + strconv.FormatUint(series.counts[i], 10) + "\n")
+			}
+			builder.WriteString(name + "_bucket" + labelSet(labels, `le="+Inf"`) + " " + strconv.FormatUint(series.count, 10) + "\n")
+			builder.WriteString(name + "_sum" + labelSet(labels, "") + " " + formatFloat(series.sum) + "\n")
+			builder.WriteString(name + "_count" + labelSet(labels, "") + " " + strconv.FormatUint(series.count, 10) + "\n")
+		}
+	}
+	exporter.mutex.Unlock()
+
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = rw.Write([]byte(builder.String()))
+}
+
+// MetricsHandler serves the metrics of the exporter if it can be scraped, like the PrometheusExporter,
+// and answers with 404 Not Found otherwise
+func (webServer *WebServer) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		handler, ok := webServer.metrics.(http.Handler)
+		if !ok {
+			webServer.writeError(rw, req, http.StatusNotFound)
+			webServer.logger.Println("Metrics: 404: exporter cannot be scraped")
+			return
+		}
+		handler.ServeHTTP(rw, req)
+	})
+}
+
+func (exporter *PrometheusExporter) metricName(name string) string {
+	if exporter.prefix != "" {
+		name = exporter.prefix + "_" + name
+	}
+	return sanitizePrometheus(name)
+}
+
+// labels returns the label pairs of the exporter and tags, sorted by name and comma separated
+func (exporter *PrometheusExporter) labels(tags map[string]string) string {
+	merged := map[string]string{}
+	for name, value := range exporter.tags {
+		merged[name] = value
+	}
+	for name, value := range tags {
+		merged[name] = value
+	}
+	pairs := make([]string, 0, len(merged))
+	for _, name := range sortedKeys(merged) {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(merged[name])
+		pairs = append(pairs, sanitizePrometheus(name)+`="`+value+`"`)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func labelSet(labels string, extra string) string {
+	if extra != "" {
+		if labels != "" {
+			labels += ","
+		}
+		labels += extra
+	}
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+// sanitizePrometheus replaces characters not allowed in metric and label names with underscores
+func sanitizePrometheus(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,106 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusExporter(t *testing.T) {
+	exporter := NewPrometheusExporter("webserver", []float64{0.5, 0.1}, map[string]string{"env": "prod"})
+	exporter.Count("requests", 2, map[string]string{"route": "/a"})
+	exporter.Count("requests", 1, map[string]string{"route": "/a"})
+	exporter.Gauge("connections", 3, nil)
+	exporter.Timing("request_duration", 200*time.Millisecond, map[string]string{"route": `/"b"`})
+
+	rec := httptest.NewRecorder()
+	exporter.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	want := `# TYPE webserver_requests_total counter
+webserver_requests_total{env="prod",route="/a"} 3
+# TYPE webserver_connections gauge
+webserver_connections{env="prod"} 3
+# TYPE webserver_request_duration_seconds histogram
+webserver_request_duration_seconds_bucket{env="prod",route="/\"b\"",le="0.1"} 0
+webserver_request_duration_seconds_bucket{env="prod",route="/\"b\"",le="0.5"} 1
+webserver_request_duration_seconds_bucket{env="prod",route="/\"b\"",le="+Inf"} 1
+webserver_request_duration_seconds_sum{env="prod",route="/\"b\""} 0.2
+webserver_request_duration_seconds_count{env="prod",route="/\"b\""} 1
+`
+	if rec.Body.String() != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", rec.Body.String(), want)
+	}
+}
+
+func TestMetricsLabels(t *testing.T) {
+	settings := NewSettings()
+	settings.Root = "root"
+	settings.MetricsBackend = MetricsBackendPrometheus
+	settings.MetricsLabels = []string{MetricsLabelRoute, MetricsLabelStatusClass}
+	settings.MetricsMaxRoutes = 2
+	webServer := NewWebServer(*settings)
+	for _, pattern := range []string{"/a", "/b", "/c"} {
+		webServer.NewHandleFunc(HTTPMethodGet, pattern, func(rw http.ResponseWriter, req *http.Request) {})
+	}
+	webServer.NewHandler(HTTPMethodGet, "/metrics", webServer.MetricsHandler())
+
+	for _, path := range []string{"/a", "/b", "/c", "/a"} {
+		webServer.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		`webserver_requests_total{route="/a",status_class="2xx"} 2`,
+		`webserver_requests_total{route="/b",status_class="2xx"} 1`,
+		`webserver_requests_total{route="other",status_class="2xx"} 1`,
+		`webserver_request_duration_seconds_bucket{route="/a",status_class="2xx",le="0.005"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "method=") {
+		t.Errorf("metrics contain the disabled method label:\n%s", body)
+	}
+
+	webServer = NewWebServer(*NewSettings())
+	webServer.NewHandler(HTTPMethodGet, "/metrics", webServer.MetricsHandler())
+	rec = httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("MetricsHandler without scrapable exporter: %d, want 404", rec.Code)
+	}
+}
+
+func TestMetricsInstance(t *testing.T) {
+	settings := NewSettings()
+	settings.Root = "root"
+	settings.MetricsBackend = MetricsBackendPrometheus
+	settings.MetricsLabels = []string{}
+	settings.ReusePort = true
+	webServer := NewWebServer(*settings)
+	webServer.NewHandler(HTTPMethodGet, "/metrics", webServer.MetricsHandler())
+	webServer.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	want := `webserver_requests_total{instance="` + strconv.Itoa(os.Getpid()) + `"} 1`
+	if !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("metrics do not contain %q:\n%s", want, rec.Body.String())
+	}
+
+	settings.MetricsInstance = "worker-2"
+	settings.MetricsTags = map[string]string{"env": "prod"}
+	if tags := metricsTags(*settings); len(tags) != 2 || tags["instance"] != "worker-2" || tags["env"] != "prod" {
+		t.Errorf("tags with MetricsInstance = %v", tags)
+	}
+	settings.MetricsInstance = ""
+	settings.ReusePort = false
+	if tags := metricsTags(*settings); len(tags) != 1 {
+		t.Errorf("tags without ReusePort = %v", tags)
+	}
+}
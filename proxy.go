@@ -0,0 +1,116 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type ProxyOptions struct {
+	// StripPrefix is removed from the request path before it is joined to the target path
+	StripPrefix string
+	// PreserveHost forwards the Host header of the client instead of the target host
+	PreserveHost bool
+	// TrustForwardedHeaders appends to X-Forwarded-For of the client instead of replacing it
+	TrustForwardedHeaders bool
+	// RequestHeaders are set on every request sent to the target
+	RequestHeaders http.Header
+	// ResponseHeaders are set on every response of the target
+	ResponseHeaders http.Header
+	// FlushInterval is passed to httputil.ReverseProxy, negative values flush after every write
+	FlushInterval time.Duration
+	Transport     http.RoundTripper
+	// ModifyResponse may change or reject the response of the target, an error is handled by ErrorHandler
+	ModifyResponse func(res *http.Response) error
+	// ErrorHandler answers requests the target could not serve, the default answers 502 Bad Gateway
+	ErrorHandler func(rw http.ResponseWriter, req *http.Request, err error)
+
+	// BreakerThreshold consecutive failures, errors or 5xx responses, open the circuit breaker for BreakerCooldown.
+	// While it is open requests are not forwarded. 0 disables the breaker.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// CacheLastGood keeps the last 200 response of GET requests up to CacheMaxBodySize and serves it while the target is down
+	CacheLastGood    bool
+	CacheMaxBodySize int64
+	// FallbackFile below Settings.Root is served with 503 Service Unavailable while the target is down and no cached response exists
+	FallbackFile string
+}
+
+func NewProxyOptions() *ProxyOptions {
+	return &ProxyOptions{
+		RequestHeaders:   http.Header{},
+		ResponseHeaders:  http.Header{},
+		BreakerCooldown:  30 * time.Second,
+		CacheMaxBodySize: 1024 * 1024,
+	}
+}
+
+// NewProxyHandler forwards every request matching pattern to target with X-Forwarded-For, X-Forwarded-Host and X-Forwarded-Proto set.
+// Websocket and other upgrade requests are passed through.
+// The options can degrade to a cached response or a static fallback page while the target is down.
+func (webServer *WebServer) NewProxyHandler(pattern string, target *url.URL, opts ProxyOptions) {
+	var proxy http.Handler
+	if opts.BreakerThreshold > 0 || opts.CacheLastGood || opts.FallbackFile != "" {
+		proxy = webServer.newDegradingProxy(target, opts)
+	} else {
+		proxy = webServer.newReverseProxy(target, opts)
+	}
+	for _, method := range standardMethods {
+		webServer.handle(method, pattern, "proxy "+target.String(), proxy)
+	}
+	webServer.customMux.Handle(pattern, proxy)
+}
+
+func (webServer *WebServer) newReverseProxy(target *url.URL, opts ProxyOptions) *httputil.ReverseProxy {
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(proxyReq *httputil.ProxyRequest) {
+			if opts.StripPrefix != "" {
+				proxyReq.Out.URL.Path = "/" + strings.TrimLeft(strings.TrimPrefix(proxyReq.Out.URL.Path, opts.StripPrefix), "/")
+				proxyReq.Out.URL.RawPath = ""
+			}
+			proxyReq.SetURL(target)
+			if opts.PreserveHost {
+				proxyReq.Out.Host = proxyReq.In.Host
+			}
+			if opts.TrustForwardedHeaders {
+				proxyReq.Out.Header["X-Forwarded-For"] = proxyReq.In.Header["X-Forwarded-For"]
+			}
+			proxyReq.SetXForwarded()
+			for name, values := range opts.RequestHeaders {
+				proxyReq.Out.Header[name] = values
+			}
+		},
+		Transport:     opts.Transport,
+		FlushInterval: opts.FlushInterval,
+		ModifyResponse: func(res *http.Response) error {
+			for name, values := range opts.ResponseHeaders {
+				res.Header[name] = values
+			}
+			if opts.ModifyResponse != nil {
+				return opts.ModifyResponse(res)
+			}
+			return nil
+		},
+		ErrorHandler: opts.ErrorHandler,
+	}
+	if proxy.ErrorHandler == nil {
+		proxy.ErrorHandler = webServer.proxyError
+	}
+	errorHandler := proxy.ErrorHandler
+	proxy.ErrorHandler = func(rw http.ResponseWriter, req *http.Request, err error) {
+		if !errors.Is(err, context.Canceled) {
+			webServer.emit(UpstreamDown{Target: target.String(), Err: err})
+		}
+		errorHandler(rw, req, err)
+	}
+	return proxy
+}
+
+func (webServer *WebServer) proxyError(rw http.ResponseWriter, req *http.Request, err error) {
+	webServer.writeErrorCause(rw, req, http.StatusBadGateway, err)
+	webServer.logger.Println("Proxy: 502: " + req.URL.Path + ": " + err.Error())
+}
@@ -0,0 +1,184 @@
+package webserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+var errCircuitOpen = errors.New("circuit breaker open")
+
+// maxLastGoodResponses bounds the cache of a degrading proxy, an arbitrary entry is dropped when it is full
+const maxLastGoodResponses = 1024
+
+// lastGoodKeyContextKey carries the url of the incoming request to the response of the target
+type lastGoodKeyContextKey struct{}
+
+type lastGoodResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+type degradingProxy struct {
+	webServer *WebServer
+	target    *url.URL
+	proxy     http.Handler
+	opts      ProxyOptions
+
+	mutex     sync.Mutex
+	failures  int
+	openUntil time.Time
+	lastGood  map[string]lastGoodResponse
+}
+
+func (webServer *WebServer) newDegradingProxy(target *url.URL, opts ProxyOptions) *degradingProxy {
+	p := &degradingProxy{webServer: webServer, target: target, opts: opts, lastGood: map[string]lastGoodResponse{}}
+
+	modifyResponse := opts.ModifyResponse
+	opts.ModifyResponse = func(res *http.Response) error {
+		if res.StatusCode >= 500 {
+			p.failure(time.Now())
+		} else {
+			p.success()
+		}
+		if opts.CacheLastGood && res.Request.Method == http.MethodGet && res.StatusCode == http.StatusOK {
+			p.record(res)
+		}
+		if modifyResponse != nil {
+			return modifyResponse(res)
+		}
+		return nil
+	}
+	opts.ErrorHandler = func(rw http.ResponseWriter, req *http.Request, err error) {
+		p.failure(time.Now())
+		p.degrade(rw, req, err)
+	}
+	p.proxy = webServer.newReverseProxy(target, opts)
+	return p
+}
+
+func (p *degradingProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if p.open(time.Now()) {
+		p.degrade(rw, req, errCircuitOpen)
+		return
+	}
+	if p.opts.CacheLastGood {
+		req = req.WithContext(context.WithValue(req.Context(), lastGoodKeyContextKey{}, req.URL.String()))
+	}
+	p.proxy.ServeHTTP(rw, req)
+}
+
+// degrade answers a request the target cannot serve with the last good response, the fallback file or the error handler
+func (p *degradingProxy) degrade(rw http.ResponseWriter, req *http.Request, err error) {
+	if req.Method == http.MethodGet {
+		p.mutex.Lock()
+		response, ok := p.lastGood[req.URL.String()]
+		p.mutex.Unlock()
+		if ok {
+			for name, values := range response.header {
+				rw.Header()[name] = values
+			}
+			rw.Header().Set("X-Proxy-Fallback", "last-good")
+			rw.WriteHeader(response.status)
+			_, _ = rw.Write(response.body)
+			p.webServer.logger.Println("Proxy: serving last good response of " + req.URL.Path + ": " + err.Error())
+			return
+		}
+	}
+
+	if p.opts.FallbackFile != "" {
+		rw.Header().Set("X-Proxy-Fallback", "file")
+		p.webServer.serveFallbackFile(rw, req, p.webServer.Settings(), p.opts.FallbackFile, http.StatusServiceUnavailable)
+		return
+	}
+
+	if p.opts.ErrorHandler != nil {
+		p.opts.ErrorHandler(rw, req, err)
+	} else {
+		p.webServer.proxyError(rw, req, err)
+	}
+}
+
+func (p *degradingProxy) open(now time.Time) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return now.Before(p.openUntil)
+}
+
+func (p *degradingProxy) failure(now time.Time) {
+	if p.opts.BreakerThreshold <= 0 {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.failures++
+	if p.failures >= p.opts.BreakerThreshold {
+		if !now.Before(p.openUntil) {
+			p.webServer.emit(UpstreamDown{Target: p.target.String(), Err: errCircuitOpen})
+		}
+		p.openUntil = now.Add(p.opts.BreakerCooldown)
+	}
+}
+
+func (p *degradingProxy) success() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.failures = 0
+}
+
+// record stores the response once its body has been read completely and did not exceed CacheMaxBodySize
+func (p *degradingProxy) record(res *http.Response) {
+	key, ok := res.Request.Context().Value(lastGoodKeyContextKey{}).(string)
+	if !ok {
+		return
+	}
+	status, header := res.StatusCode, res.Header.Clone()
+	buffer := &bytes.Buffer{}
+	res.Body = &lastGoodReader{
+		ReadCloser: res.Body,
+		buffer:     buffer,
+		max:        p.opts.CacheMaxBodySize,
+		done: func() {
+			p.mutex.Lock()
+			defer p.mutex.Unlock()
+			if _, ok := p.lastGood[key]; !ok && len(p.lastGood) >= maxLastGoodResponses {
+				for existing := range p.lastGood {
+					delete(p.lastGood, existing)
+					break
+				}
+			}
+			p.lastGood[key] = lastGoodResponse{status: status, header: header, body: buffer.Bytes()}
+		},
+	}
+}
+
+type lastGoodReader struct {
+	io.ReadCloser
+	buffer   *bytes.Buffer
+	max      int64
+	overflow bool
+	done     func()
+}
+
+func (reader *lastGoodReader) Read(b []byte) (int, error) {
+	n, err := reader.ReadCloser.Read(b)
+	if !reader.overflow {
+		if int64(reader.buffer.Len()+n) > reader.max {
+			reader.overflow = true
+			reader.buffer.Reset()
+		} else {
+			reader.buffer.Write(b[:n])
+		}
+	}
+	if err == io.EOF && !reader.overflow && reader.done != nil {
+		reader.done()
+		reader.done = nil
+	}
+	return n, err
+}
@@ -0,0 +1,255 @@
+package webserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const proxyProtocolHeaderTimeout = 5 * time.Second
+
+var (
+	proxyProtocolV1Prefix    = []byte("PROXY ")
+	proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	errProxyProtocolHeader = errors.New("invalid proxy protocol header")
+	// errProxyProtocolUntrusted fails listening with UseProxyProtocol but no trusted sources, any client could
+	// otherwise send a header with the address of its choice
+	errProxyProtocolUntrusted = errors.New("proxy protocol needs ProxyProtocolTrustedSources")
+)
+
+// proxyProtocolListener reads HAProxy PROXY protocol (v1 and v2) headers from connections of trusted sources
+// and reports the transported client address as RemoteAddr. Connections of other sources are served as they are.
+type proxyProtocolListener struct {
+	net.Listener
+	trusted []*net.IPNet
+}
+
+func newProxyProtocolListener(listener net.Listener, trustedSources []string) (net.Listener, error) {
+	if len(trustedSources) == 0 {
+		return nil, errProxyProtocolUntrusted
+	}
+	trusted, err := parseCIDRs(trustedSources)
+	if err != nil {
+		return nil, err
+	}
+	return &proxyProtocolListener{Listener: listener, trusted: trusted}, nil
+}
+
+func (listener *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := listener.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyProtocolConn{
+		Conn:    conn,
+		reader:  bufio.NewReader(conn),
+		trusted: containsAddr(listener.trusted, conn.RemoteAddr()),
+	}, nil
+}
+
+type proxyProtocolConn struct {
+	net.Conn
+	reader  *bufio.Reader
+	trusted bool
+
+	once       sync.Once
+	remoteAddr net.Addr
+	localAddr  net.Addr
+	err        error
+}
+
+func (conn *proxyProtocolConn) readHeader() {
+	conn.once.Do(func() {
+		if !conn.trusted {
+			return
+		}
+		_ = conn.Conn.SetReadDeadline(time.Now().Add(proxyProtocolHeaderTimeout))
+		conn.remoteAddr, conn.localAddr, conn.err = readProxyProtocolHeader(conn.reader)
+		_ = conn.Conn.SetReadDeadline(time.Time{})
+	})
+}
+
+func (conn *proxyProtocolConn) Read(b []byte) (int, error) {
+	conn.readHeader()
+	if conn.err != nil {
+		return 0, conn.err
+	}
+	return conn.reader.Read(b)
+}
+
+func (conn *proxyProtocolConn) RemoteAddr() net.Addr {
+	conn.readHeader()
+	if conn.remoteAddr != nil {
+		return conn.remoteAddr
+	}
+	return conn.Conn.RemoteAddr()
+}
+
+func (conn *proxyProtocolConn) LocalAddr() net.Addr {
+	conn.readHeader()
+	if conn.localAddr != nil {
+		return conn.localAddr
+	}
+	return conn.Conn.LocalAddr()
+}
+
+// readProxyProtocolHeader returns nil addresses without error if the stream does not start with a header.
+func readProxyProtocolHeader(reader *bufio.Reader) (net.Addr, net.Addr, error) {
+	peek, err := reader.Peek(len(proxyProtocolV2Signature))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(peek, proxyProtocolV1Prefix):
+		return readProxyProtocolV1(reader)
+	case bytes.Equal(peek, proxyProtocolV2Signature):
+		return readProxyProtocolV2(reader)
+	default:
+		return nil, nil, nil
+	}
+}
+
+func readProxyProtocolV1(reader *bufio.Reader) (net.Addr, net.Addr, error) {
+	line, err := reader.ReadSlice('\n')
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(line) > 107 || !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, errProxyProtocolHeader
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, errProxyProtocolHeader
+	}
+
+	src, err := parseProxyProtocolV1Addr(fields[2], fields[4])
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, err := parseProxyProtocolV1Addr(fields[3], fields[5])
+	if err != nil {
+		return nil, nil, err
+	}
+	return src, dst, nil
+}
+
+func parseProxyProtocolV1Addr(ip string, port string) (net.Addr, error) {
+	parsedIP := net.ParseIP(ip)
+	parsedPort, err := strconv.ParseUint(port, 10, 16)
+	if parsedIP == nil || err != nil {
+		return nil, errProxyProtocolHeader
+	}
+	return &net.TCPAddr{IP: parsedIP, Port: int(parsedPort)}, nil
+}
+
+func readProxyProtocolV2(reader *bufio.Reader) (net.Addr, net.Addr, error) {
+	header := make([]byte, 16)
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	versionCommand := header[12]
+	family := header[13]
+	length := int(binary.BigEndian.Uint16(header[14:16]))
+
+	payload := make([]byte, length)
+	_, err = io.ReadFull(reader, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if versionCommand>>4 != 2 {
+		return nil, nil, errProxyProtocolHeader
+	}
+	// LOCAL command, used by health checks of the proxy itself
+	if versionCommand&0x0F == 0 {
+		return nil, nil, nil
+	}
+	if versionCommand&0x0F != 1 {
+		return nil, nil, errProxyProtocolHeader
+	}
+
+	var ipLength int
+	switch family >> 4 {
+	case 1:
+		ipLength = net.IPv4len
+	case 2:
+		ipLength = net.IPv6len
+	default:
+		// AF_UNSPEC and AF_UNIX carry no usable client address
+		return nil, nil, nil
+	}
+
+	if length < 2*ipLength+4 {
+		return nil, nil, errProxyProtocolHeader
+	}
+	src := &net.TCPAddr{
+		IP:   net.IP(payload[:ipLength]),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLength:])),
+	}
+	dst := &net.TCPAddr{
+		IP:   net.IP(payload[ipLength : 2*ipLength]),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLength+2:])),
+	}
+	return src, dst, nil
+}
+
+// parseCIDRs accepts CIDR ranges as well as plain IP addresses
+func parseCIDRs(values []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, errors.New("invalid ip address: " + value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func containsAddr(nets []*net.IPNet, addr net.Addr) bool {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return false
+	}
+	return containsIP(nets, net.ParseIP(host))
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
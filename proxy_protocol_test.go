@@ -0,0 +1,115 @@
+package webserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func proxyProtocolV2Header(src net.IP, dst net.IP, srcPort uint16, dstPort uint16) []byte {
+	payload := append(append([]byte{}, src.To4()...), dst.To4()...)
+	payload = binary.BigEndian.AppendUint16(payload, srcPort)
+	payload = binary.BigEndian.AppendUint16(payload, dstPort)
+
+	header := append([]byte{}, proxyProtocolV2Signature...)
+	header = append(header, 0x21, 0x11)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	return append(header, payload...)
+}
+
+func TestReadProxyProtocolHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  []byte
+		remote string
+		err    bool
+	}{
+		{"v1 tcp4", []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nGET /"), "192.0.2.1:56324", false},
+		{"v1 tcp6", []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\nGET /"), "[2001:db8::1]:56324", false},
+		{"v1 unknown", []byte("PROXY UNKNOWN\r\nGET /"), "", false},
+		{"v1 malformed", []byte("PROXY TCP4 192.0.2.1\r\nGET /"), "", true},
+		{"v1 bad ip", []byte("PROXY TCP4 999.0.2.1 198.51.100.1 1 2\r\nGET /"), "", true},
+		{"v2 tcp4", append(proxyProtocolV2Header(net.ParseIP("192.0.2.7"), net.ParseIP("198.51.100.1"), 4000, 443), "GET /"...), "192.0.2.7:4000", false},
+		{"no header", []byte("GET / HTTP/1.1\r\n\r\n"), "", false},
+	}
+
+	for _, test := range tests {
+		reader := bufio.NewReader(bytes.NewReader(test.input))
+		src, _, err := readProxyProtocolHeader(reader)
+		if (err != nil) != test.err {
+			t.Errorf("%s: error = %v, want error %v", test.name, err, test.err)
+			continue
+		}
+		if test.err {
+			continue
+		}
+		if test.remote == "" {
+			if src != nil {
+				t.Errorf("%s: remote = %v, want none", test.name, src)
+			}
+			continue
+		}
+		if src == nil || src.String() != test.remote {
+			t.Errorf("%s: remote = %v, want %s", test.name, src, test.remote)
+		}
+		rest, _ := io.ReadAll(reader)
+		if !strings.HasPrefix(string(rest), "GET /") {
+			t.Errorf("%s: remaining stream = %q", test.name, rest)
+		}
+	}
+}
+
+func TestProxyProtocolListenerTrustedSources(t *testing.T) {
+	_, err := newProxyProtocolListener(nil, []string{})
+	if !errors.Is(err, errProxyProtocolUntrusted) {
+		t.Errorf("without trusted sources: err = %v, want %v", err, errProxyProtocolUntrusted)
+	}
+
+	tests := []struct {
+		trusted []string
+		remote  string
+		body    string
+	}{
+		{[]string{"127.0.0.0/8"}, "192.0.2.1:1234", "hello"},
+		{[]string{"10.0.0.1"}, "127.0.0.1", "PROXY TCP4 192.0.2.1 127.0.0.1 1234 80\r\nhello"},
+	}
+
+	for _, test := range tests {
+		tcpListener, err := net.Listen("tcp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listener, err := newProxyProtocolListener(tcpListener, test.trusted)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		go func() {
+			client, err := net.Dial("tcp4", tcpListener.Addr().String())
+			if err != nil {
+				return
+			}
+			_, _ = client.Write([]byte("PROXY TCP4 192.0.2.1 127.0.0.1 1234 80\r\nhello"))
+			_ = client.Close()
+		}()
+
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(conn.RemoteAddr().String(), test.remote) {
+			t.Errorf("trusted %v: remote = %s, want %s", test.trusted, conn.RemoteAddr(), test.remote)
+		}
+		body, _ := io.ReadAll(conn)
+		if string(body) != test.body {
+			t.Errorf("trusted %v: body = %q, want %q", test.trusted, body, test.body)
+		}
+		_ = conn.Close()
+		_ = listener.Close()
+	}
+}
@@ -0,0 +1,117 @@
+package webserver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestProxyHandler(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Backend", "1")
+		_, _ = io.WriteString(rw, req.Method+" "+req.URL.Path+" "+req.Header.Get("X-Forwarded-For")+" "+req.Header.Get("X-Service"))
+	}))
+	defer backend.Close()
+	target, err := url.Parse(backend.URL + "/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webServer := NewWebServer(*NewSettings())
+	opts := NewProxyOptions()
+	opts.StripPrefix = "/api"
+	opts.RequestHeaders.Set("X-Service", "web")
+	opts.ResponseHeaders.Set("X-Proxied", "true")
+	webServer.NewProxyHandler("/api/", target, *opts)
+
+	closed, err := url.Parse("http://127.0.0.1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	webServer.NewProxyHandler("/down/", closed, *NewProxyOptions())
+
+	tests := []struct {
+		method, path string
+		status       int
+		body         string
+	}{
+		{http.MethodGet, "/api/users", http.StatusOK, "GET /v1/users 192.0.2.1 web"},
+		{http.MethodDelete, "/api/users/1", http.StatusOK, "DELETE /v1/users/1 192.0.2.1 web"},
+		{http.MethodGet, "/down/x", http.StatusBadGateway, ""},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+
+		if rec.Code != test.status || rec.Body.String() != test.body {
+			t.Errorf("%s %s: %d %q, want %d %q", test.method, test.path, rec.Code, rec.Body.String(), test.status, test.body)
+		}
+		if test.status == http.StatusOK && (rec.Header().Get("X-Backend") != "1" || rec.Header().Get("X-Proxied") != "true") {
+			t.Errorf("%s %s: headers %v", test.method, test.path, rec.Header())
+		}
+	}
+}
+
+func TestProxyHandlerDegradation(t *testing.T) {
+	failing := false
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if failing {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = io.WriteString(rw, "fresh "+req.URL.Path)
+	}))
+	defer backend.Close()
+	target, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings := NewSettings()
+	settings.Root = "root"
+	webServer := NewWebServer(*settings)
+	opts := NewProxyOptions()
+	opts.BreakerThreshold = 2
+	opts.CacheLastGood = true
+	opts.FallbackFile = "/index.html"
+	webServer.NewProxyHandler("/api/", target, *opts)
+
+	tests := []struct {
+		path     string
+		failing  bool
+		status   int
+		fallback string
+		body     string
+	}{
+		{"/api/page", false, http.StatusOK, "", "fresh /api/page"},
+		{"/api/page", true, http.StatusInternalServerError, "", ""},
+		{"/api/page", true, http.StatusInternalServerError, "", ""},
+		{"/api/page", false, http.StatusOK, "last-good", "fresh /api/page"},
+		{"/api/other", false, http.StatusServiceUnavailable, "file", "TEST"},
+	}
+
+	for i, test := range tests {
+		failing = test.failing
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if rec.Code != test.status || rec.Header().Get("X-Proxy-Fallback") != test.fallback || !strings.Contains(rec.Body.String(), test.body) {
+			t.Errorf("%d: %d %q %q, want %d %q %q", i, rec.Code, rec.Header().Get("X-Proxy-Fallback"), rec.Body.String(), test.status, test.fallback, test.body)
+		}
+	}
+
+	backend.Close()
+	down := NewWebServer(*settings)
+	opts.BreakerThreshold = 0
+	down.NewProxyHandler("/api/", target, *opts)
+	rec := httptest.NewRecorder()
+	down.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/page", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "TEST") {
+		t.Errorf("down: %d %q", rec.Code, rec.Body.String())
+	}
+}
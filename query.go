@@ -0,0 +1,31 @@
+package webserver
+
+import (
+	"net/http"
+	"reflect"
+)
+
+// BindQuery sets the fields of the struct target points to from the url query using `query` field tags.
+// Conversion failures are returned joined, see BindErrors.
+func BindQuery(req *http.Request, target any) error {
+	return bindValues(target, "query", req.URL.Query(), nil)
+}
+
+// NewQueryHandler binds the url query into T, binding errors are passed to the handler to decide on the response
+func NewQueryHandler[T any](
+	webServer *WebServer,
+	method HTTPMethod,
+	pattern string,
+	handler func(http.ResponseWriter, *http.Request, T, error),
+) {
+	if reflect.TypeFor[T]().Kind() != reflect.Struct {
+		panic("T must be a struct")
+	}
+
+	webServer.NewHandleFunc(method, pattern, func(rw http.ResponseWriter, req *http.Request) {
+		var values T
+		err := BindQuery(req, &values)
+		handler(rw, req, values, err)
+	})
+	webServer.documentRequest(method, pattern, "query", reflect.TypeFor[T]())
+}
@@ -0,0 +1,51 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNewQueryHandler(t *testing.T) {
+	type search struct {
+		Term  string    `query:"q"`
+		Page  int       `query:"page"`
+		Exact bool      `query:"exact"`
+		Since time.Time `query:"since"`
+		IDs   []int64   `query:"id"`
+	}
+
+	webServer := NewWebServer(*NewSettings())
+	NewQueryHandler(webServer, HTTPMethodGet, "/search", func(rw http.ResponseWriter, req *http.Request, values search, err error) {
+		if err != nil {
+			fields := ""
+			for _, bindError := range BindErrors(err) {
+				fields += bindError.Field + ";"
+			}
+			webServer.BadRequest(rw, fields)
+			return
+		}
+		_, _ = rw.Write([]byte(values.Term + " " + strconv.Itoa(values.Page) + " " + strconv.FormatBool(values.Exact) + " " +
+			values.Since.Format(time.DateOnly) + " " + strconv.Itoa(len(values.IDs))))
+	})
+
+	tests := []struct {
+		query  string
+		status int
+		body   string
+	}{
+		{"?q=go&page=2&exact=true&since=2024-01-02&id=1&id=2", http.StatusOK, "go 2 true 2024-01-02 2"},
+		{"?q=go", http.StatusOK, "go 0 false 0001-01-01 0"},
+		{"?page=two&id=1&id=x", http.StatusBadRequest, "page;id;"},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search"+test.query, nil))
+		if rec.Code != test.status || rec.Body.String() != test.body {
+			t.Errorf("%s: %d %q, want %d %q", test.query, rec.Code, rec.Body.String(), test.status, test.body)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package webserver
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tier describes what the identities of one plan may do, zero values are unlimited
+type Tier struct {
+	Name              string
+	RequestsPerMinute int
+	MaxBodySize       int64
+	// AllowedRoutes are path prefixes, an empty list allows every route
+	AllowedRoutes []string
+}
+
+type quotaWindow struct {
+	start time.Time
+	count int
+}
+
+type quotas struct {
+	tiers    map[string]Tier
+	identify func(req *http.Request) (identity string, tier string)
+
+	mutex   sync.Mutex
+	windows map[string]*quotaWindow
+}
+
+// EnableQuotas registers a middleware enforcing the tier identify returns for a request.
+// Requests without identity pass unchecked. Routes outside the tier answer 402 Payment Required,
+// exceeding the requests per minute answers 429 Too Many Requests with Retry-After and X-RateLimit headers
+// and bodies above the tier limit answer 413.
+func (webServer *WebServer) EnableQuotas(identify func(req *http.Request) (identity string, tier string), tiers ...Tier) {
+	q := &quotas{
+		tiers:    map[string]Tier{},
+		identify: identify,
+		windows:  map[string]*quotaWindow{},
+	}
+	for _, tier := range tiers {
+		q.tiers[tier.Name] = tier
+	}
+
+	// after the auth middleware so identify can use PrincipalFrom
+	webServer.NewPhaseMiddleware(PhaseAuth+50, func(rw http.ResponseWriter, req *http.Request) bool {
+		return q.check(webServer, rw, req, time.Now())
+	})
+}
+
+func (q *quotas) check(webServer *WebServer, rw http.ResponseWriter, req *http.Request, now time.Time) bool {
+	identity, tierName := q.identify(req)
+	if identity == "" {
+		return true
+	}
+
+	tier, ok := q.tiers[tierName]
+	if !ok || !tier.allows(req.URL.Path) {
+		rw.Header().Set("X-Quota-Tier", tierName)
+		webServer.writeError(rw, req, http.StatusPaymentRequired)
+		webServer.logger.Println("Quota: 402: " + identity + " (" + tierName + ") " + req.URL.Path)
+		return false
+	}
+
+	if tier.MaxBodySize > 0 {
+		if req.ContentLength > tier.MaxBodySize {
+			webServer.writeError(rw, req, http.StatusRequestEntityTooLarge)
+			webServer.logger.Println("Quota: 413: " + identity + " (" + tierName + ")")
+			return false
+		}
+		req.Body = http.MaxBytesReader(rw, req.Body, tier.MaxBodySize)
+	}
+
+	if tier.RequestsPerMinute <= 0 {
+		return true
+	}
+
+	remaining, reset := q.take(identity, tier.RequestsPerMinute, now)
+	rw.Header().Set("X-RateLimit-Limit", strconv.Itoa(tier.RequestsPerMinute))
+	rw.Header().Set("X-RateLimit-Remaining", strconv.Itoa(max(remaining, 0)))
+	rw.Header().Set("X-RateLimit-Reset", strconv.Itoa(reset))
+	if remaining < 0 {
+		rw.Header().Set("Retry-After", strconv.Itoa(reset))
+		webServer.writeError(rw, req, http.StatusTooManyRequests)
+		webServer.logger.Println("Quota: 429: " + identity + " (" + tierName + ")")
+		return false
+	}
+	return true
+}
+
+// take counts a request in the current minute of identity and returns the requests left and the seconds until reset
+func (q *quotas) take(identity string, limit int, now time.Time) (int, int) {
+	windowStart := now.Truncate(time.Minute)
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	window, ok := q.windows[identity]
+	if !ok || !window.start.Equal(windowStart) {
+		if !ok && len(q.windows) > 0 {
+			q.expire(windowStart)
+		}
+		window = &quotaWindow{start: windowStart}
+		q.windows[identity] = window
+	}
+	window.count++
+
+	reset := windowStart.Add(time.Minute).Sub(now)
+	return limit - window.count, int((reset + time.Second - 1) / time.Second)
+}
+
+// expire must be called with the mutex held
+func (q *quotas) expire(windowStart time.Time) {
+	for identity, window := range q.windows {
+		if window.start.Before(windowStart) {
+			delete(q.windows, identity)
+		}
+	}
+}
+
+func (tier Tier) allows(path string) bool {
+	if len(tier.AllowedRoutes) == 0 {
+		return true
+	}
+	for _, route := range tier.AllowedRoutes {
+		if strings.HasPrefix(path, route) {
+			return true
+		}
+	}
+	return false
+}
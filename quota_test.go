@@ -0,0 +1,63 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuotas(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	q := &quotas{
+		tiers: map[string]Tier{
+			"free": {Name: "free", RequestsPerMinute: 2, MaxBodySize: 4, AllowedRoutes: []string{"/api/basic"}},
+			"paid": {Name: "paid"},
+		},
+		identify: func(req *http.Request) (string, string) {
+			return req.Header.Get("X-User"), req.Header.Get("X-Tier")
+		},
+		windows: map[string]*quotaWindow{},
+	}
+
+	now := time.Date(2024, 1, 1, 12, 0, 15, 500, time.UTC)
+	tests := []struct {
+		user, tier, path, body string
+		now                    time.Time
+		status                 int
+		retryAfter             string
+	}{
+		{"anonymous", "", "/api/premium", "", now, http.StatusPaymentRequired, ""},
+		{"", "", "/api/premium", "", now, 0, ""},
+		{"alice", "free", "/api/premium", "", now, http.StatusPaymentRequired, ""},
+		{"alice", "free", "/api/basic", "too large", now, http.StatusRequestEntityTooLarge, ""},
+		{"alice", "free", "/api/basic", "", now, 0, ""},
+		{"alice", "free", "/api/basic", "", now, 0, ""},
+		{"alice", "free", "/api/basic", "", now, http.StatusTooManyRequests, "45"},
+		{"bob", "free", "/api/basic", "", now, 0, ""},
+		{"alice", "free", "/api/basic", "", now.Add(time.Minute), 0, ""},
+		{"carol", "paid", "/api/premium", "large body is fine", now, 0, ""},
+	}
+
+	for i, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(test.body))
+		req.Header.Set("X-User", test.user)
+		req.Header.Set("X-Tier", test.tier)
+		rec := httptest.NewRecorder()
+		passed := q.check(webServer, rec, req, test.now)
+
+		if test.status == 0 {
+			if !passed {
+				t.Errorf("%d: %s %s blocked with %d", i, test.user, test.path, rec.Code)
+			}
+			continue
+		}
+		if passed || rec.Code != test.status {
+			t.Errorf("%d: %s %s passed %v, status %d, want %d", i, test.user, test.path, passed, rec.Code, test.status)
+		}
+		if retryAfter := rec.Header().Get("Retry-After"); retryAfter != test.retryAfter {
+			t.Errorf("%d: Retry-After = %q, want %q", i, retryAfter, test.retryAfter)
+		}
+	}
+}
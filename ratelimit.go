@@ -0,0 +1,192 @@
+package webserver
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitStore keeps the token buckets of the rate limiter, implementations must be safe for concurrent use
+type RateLimitStore interface {
+	// Take removes a token from the bucket of key, which refills with rate tokens per second up to burst.
+	// An empty bucket returns false and the time until the next token.
+	Take(key string, rate float64, burst int, now time.Time) (bool, time.Duration)
+}
+
+type RateLimitOptions struct {
+	RequestsPerSecond float64
+	Burst             int
+	// Key returns the bucket a request counts against, requests with an empty key are not limited
+	Key   func(req *http.Request) string
+	Store RateLimitStore
+	// Geo restricts the limit to clients of its countries or ASNs, see SetGeoIP. Limits with different Geo
+	// apply side by side on the same prefix, e.g. a stricter one for datacenter ASNs.
+	Geo GeoMatch
+}
+
+func NewRateLimitOptions(requestsPerSecond float64, burst int) *RateLimitOptions {
+	return &RateLimitOptions{
+		RequestsPerSecond: requestsPerSecond,
+		Burst:             burst,
+		Key:               RateLimitByIP,
+		Store:             NewMemoryRateLimitStore(),
+	}
+}
+
+// RateLimitByIP keys requests by their ClientIP
+func RateLimitByIP(req *http.Request) string {
+	return ClientIP(req)
+}
+
+// RateLimitByCountry keys requests by the country of their client, see SetGeoIP, unknown clients are not limited
+func RateLimitByCountry(req *http.Request) string {
+	info, _ := Geo(req)
+	return info.Country
+}
+
+// RateLimitByASN keys requests by the autonomous system of their client, see SetGeoIP, unknown clients are not limited
+func RateLimitByASN(req *http.Request) string {
+	info, ok := Geo(req)
+	if !ok || info.ASN == 0 {
+		return ""
+	}
+	return "AS" + strconv.FormatUint(uint64(info.ASN), 10)
+}
+
+// RateLimitByHeader keys requests by the value of the header name
+func RateLimitByHeader(name string) func(req *http.Request) string {
+	return func(req *http.Request) string {
+		return req.Header.Get(name)
+	}
+}
+
+type rateLimitRule struct {
+	prefix  string
+	options RateLimitOptions
+	// id identifies the buckets of the rule, the prefix and the geo match
+	id string
+}
+
+// EnableRateLimit limits every request, route limits set with SetRateLimit apply in addition
+func (webServer *WebServer) EnableRateLimit(options RateLimitOptions) {
+	webServer.SetRateLimit("", options)
+}
+
+// SetRateLimit limits requests below prefix with their own buckets, it replaces the limit of the same prefix and Geo.
+// A request has to pass every matching limit, otherwise it is answered with 429 Too Many Requests and Retry-After.
+func (webServer *WebServer) SetRateLimit(prefix string, options RateLimitOptions) {
+	if options.Key == nil {
+		options.Key = RateLimitByIP
+	}
+	if options.Store == nil {
+		options.Store = NewMemoryRateLimitStore()
+	}
+	if options.Burst < 1 {
+		options.Burst = 1
+	}
+
+	if webServer.rateLimits == nil {
+		webServer.NewPhaseMiddleware(PhaseSecurity, func(rw http.ResponseWriter, req *http.Request) bool {
+			return webServer.checkRateLimits(rw, req, time.Now())
+		})
+	}
+
+	rule := rateLimitRule{prefix: prefix, options: options, id: prefix}
+	if !options.Geo.empty() {
+		rule.id += "\x00" + options.Geo.String()
+	}
+	for i, existing := range webServer.rateLimits {
+		if existing.id == rule.id {
+			webServer.rateLimits[i] = rule
+			return
+		}
+	}
+	webServer.rateLimits = append(webServer.rateLimits, rule)
+	sort.SliceStable(webServer.rateLimits, func(i, j int) bool {
+		return len(webServer.rateLimits[i].prefix) > len(webServer.rateLimits[j].prefix)
+	})
+}
+
+func (webServer *WebServer) checkRateLimits(rw http.ResponseWriter, req *http.Request, now time.Time) bool {
+	for _, rule := range webServer.rateLimits {
+		if !strings.HasPrefix(req.URL.Path, rule.prefix) || !rule.options.Geo.empty() && !rule.options.Geo.Matches(req) {
+			continue
+		}
+		key := rule.options.Key(req)
+		if key == "" {
+			continue
+		}
+
+		ok, wait := rule.options.Store.Take(rule.id+"\x00"+key, rule.options.RequestsPerSecond, rule.options.Burst, now)
+		if !ok {
+			rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			webServer.writeError(rw, req, http.StatusTooManyRequests)
+			webServer.logger.Println("Rate Limit: 429: " + key + " " + req.URL.Path)
+			webServer.emit(RateLimited{IP: ClientIP(req), Key: key, Path: req.URL.Path})
+			return false
+		}
+	}
+	return true
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time
+}
+
+// MemoryRateLimitStore keeps the buckets in process memory, full buckets are dropped periodically
+type MemoryRateLimitStore struct {
+	mutex     sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: map[string]*tokenBucket{}}
+}
+
+func (store *MemoryRateLimitStore) Take(key string, rate float64, burst int, now time.Time) (bool, time.Duration) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if now.Sub(store.lastSweep) > time.Minute {
+		store.sweep(now)
+	}
+
+	bucket, ok := store.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(burst), last: now}
+		store.buckets[key] = bucket
+	}
+	if elapsed := now.Sub(bucket.last); elapsed > 0 {
+		bucket.tokens = math.Min(float64(burst), bucket.tokens+elapsed.Seconds()*rate)
+		bucket.last = now
+	}
+
+	if bucket.tokens < 1 {
+		if rate <= 0 {
+			return false, time.Hour
+		}
+		return false, time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	}
+	bucket.tokens--
+	if rate > 0 {
+		bucket.full = now.Add(time.Duration((float64(burst) - bucket.tokens) / rate * float64(time.Second)))
+	}
+	return true, 0
+}
+
+// sweep must be called with the mutex held
+func (store *MemoryRateLimitStore) sweep(now time.Time) {
+	store.lastSweep = now
+	for key, bucket := range store.buckets {
+		if !bucket.full.IsZero() && now.After(bucket.full) {
+			delete(store.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	webServer.EnableRateLimit(*NewRateLimitOptions(1, 3))
+	api := NewRateLimitOptions(0.5, 1)
+	api.Key = RateLimitByHeader("X-Api-Key")
+	webServer.SetRateLimit("/api/", *api)
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		remote, apiKey, path string
+		after                time.Duration
+		status               int
+		retryAfter           string
+	}{
+		{"10.0.0.1:1000", "", "/", 0, 0, ""},
+		{"10.0.0.1:1001", "", "/", 0, 0, ""},
+		{"10.0.0.1:1002", "", "/", 0, 0, ""},
+		{"10.0.0.1:1003", "", "/", 0, http.StatusTooManyRequests, "1"},
+		{"10.0.0.2:1000", "", "/", 0, 0, ""},
+		{"10.0.0.1:1004", "", "/", time.Second, 0, ""},
+		{"10.0.0.3:1000", "a", "/api/x", 0, 0, ""},
+		{"10.0.0.3:1001", "a", "/api/x", 0, http.StatusTooManyRequests, "2"},
+		{"10.0.0.3:1002", "b", "/api/x", 0, 0, ""},
+		{"10.0.0.3:1003", "a", "/api/x", 2 * time.Second, 0, ""},
+	}
+
+	for i, test := range tests {
+		now = now.Add(test.after)
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		req.RemoteAddr = test.remote
+		req.Header.Set("X-Api-Key", test.apiKey)
+		rec := httptest.NewRecorder()
+		passed := webServer.checkRateLimits(rec, req, now)
+
+		if test.status == 0 {
+			if !passed {
+				t.Errorf("%d: %s %s blocked with %d", i, test.remote, test.path, rec.Code)
+			}
+			continue
+		}
+		if passed || rec.Code != test.status {
+			t.Errorf("%d: %s %s passed %v, status %d, want %d", i, test.remote, test.path, passed, rec.Code, test.status)
+		}
+		if retryAfter := rec.Header().Get("Retry-After"); retryAfter != test.retryAfter {
+			t.Errorf("%d: Retry-After = %q, want %q", i, retryAfter, test.retryAfter)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package webserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// serving is a http.Server running on a listener, done receives the result of Serve
+type serving struct {
+	server   *http.Server
+	listener net.Listener
+	done     chan error
+}
+
+func (webServer *WebServer) newHTTPServer(settings Settings) *http.Server {
+	return &http.Server{
+		Handler:           webServer.serverHandler(),
+		Addr:              settings.BindAddr(),
+		ConnContext:       webServer.connContext,
+		ReadTimeout:       settings.ReadTimeout,
+		ReadHeaderTimeout: settings.ReadHeaderTimeout,
+		WriteTimeout:      settings.WriteTimeout,
+		IdleTimeout:       settings.IdleTimeout,
+		MaxHeaderBytes:    settings.MaxHeaderBytes,
+
+		DisableGeneralOptionsHandler: true,
+	}
+}
+
+func (webServer *WebServer) startServing(server *http.Server, listener net.Listener, settings Settings) (*serving, error) {
+	if settings.UseHttps {
+		err := webServer.loadCertificate(settings)
+		if err != nil {
+			return nil, err
+		}
+		server.TLSConfig, err = newTLSConfig(settings.TLS)
+		if err != nil {
+			return nil, err
+		}
+		if server.TLSConfig.GetCertificate == nil && len(server.TLSConfig.Certificates) == 0 {
+			server.TLSConfig.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				return webServer.certificate.Load(), nil
+			}
+		}
+
+		if settings.UseTLSFingerprint {
+			webServer.configureTLSFingerprint(server)
+			listener = &fingerprintListener{Listener: listener}
+		}
+	}
+
+	err := configureHttp2(server, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	address := settings.Url()
+	if listener.Addr().Network() == "unix" {
+		address = "unix:" + listener.Addr().String()
+	} else if settings.Port() == "0" {
+		// report the port the system chose
+		_, port, _ := net.SplitHostPort(listener.Addr().String())
+		address = settings.Scheme() + net.JoinHostPort(settings.Hostname, port)
+	}
+	if settings.ReusePort {
+		webServer.logger.Println("WebServer " + ReadBuildInfo().String() + " running on " + address + " (pid " + strconv.Itoa(os.Getpid()) + ")")
+	} else {
+		webServer.logger.Println("WebServer " + ReadBuildInfo().String() + " running on " + address)
+	}
+	webServer.emit(ServerStarted{Addr: address, Build: ReadBuildInfo()})
+
+	current := &serving{server: server, listener: listener, done: make(chan error, 1)}
+	webServer.servingMutex.Lock()
+	webServer.serving = current
+	webServer.servingMutex.Unlock()
+
+	go func() {
+		if settings.UseHttps {
+			current.done <- server.ServeTLS(listener, "", "")
+		} else {
+			current.done <- server.Serve(listener)
+		}
+	}()
+	if webServer.readyHook != nil {
+		webServer.readyHook(listener.Addr())
+	}
+	return current, nil
+}
+
+// certExpiryWarning is how long before expiry loading a certificate emits CertExpiring
+const certExpiryWarning = 30 * 24 * time.Hour
+
+func (webServer *WebServer) loadCertificate(settings Settings) error {
+	if settings.UseSelfSignedTLS {
+		return webServer.loadSelfSignedCertificate(settings)
+	}
+	certificate, err := tls.LoadX509KeyPair(settings.CertFile, settings.KeyFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %w", ErrCertNotFound, err)
+	}
+	if err != nil {
+		return err
+	}
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err == nil && time.Until(leaf.NotAfter) < certExpiryWarning {
+		webServer.logger.Println("Certificate: " + leaf.Subject.String() + " expires " + leaf.NotAfter.Format(time.RFC3339))
+		webServer.emit(CertExpiring{Subject: leaf.Subject.String(), NotAfter: leaf.NotAfter})
+	}
+	webServer.certificate.Store(&certificate)
+	return nil
+}
+
+// Reload applies new settings to the running server without dropping connections.
+// Root, filters, fallbacks and body limits apply to the next request and certificates to the next handshake.
+// If the listen address changed, a new listener is bound, if only listener or server options changed, a new server
+// takes over the bound socket. The old server is shut down gracefully once its in-flight requests finished.
+// Logger, metrics and log shipping are kept as created.
+func (webServer *WebServer) Reload(settings Settings) error {
+	old := webServer.Settings()
+	settings.Logger = old.Logger
+	if settings.FileExtensionFilter == nil {
+		settings.FileExtensionFilter = []string{}
+	}
+
+	webServer.servingMutex.Lock()
+	current := webServer.serving
+	webServer.servingMutex.Unlock()
+
+	rebind := current != nil && addressChanged(old, settings)
+	restart := current != nil && (rebind || listenerChanged(old, settings) || serverChanged(old, settings))
+
+	var listener net.Listener
+	if rebind {
+		var err error
+		listener, err = listenMain(settings)
+		if err != nil {
+			return err
+		}
+	} else if restart {
+		// binding the unchanged address again fails while the current server holds it
+		var err error
+		listener, err = reuseListener(current.listener, settings)
+		if err != nil {
+			return err
+		}
+	} else if current != nil && settings.UseHttps {
+		err := webServer.loadCertificate(settings)
+		if err != nil {
+			return err
+		}
+	}
+
+	webServer.settingsMutex.Lock()
+	webServer.settings = settings
+	webServer.settingsMutex.Unlock()
+
+	if !restart {
+		webServer.logger.Println("Reload: settings applied")
+		return nil
+	}
+
+	_, err := webServer.startServing(webServer.newHTTPServer(settings), listener, settings)
+	if err != nil {
+		_ = listener.Close()
+		webServer.settingsMutex.Lock()
+		webServer.settings = old
+		webServer.settingsMutex.Unlock()
+		return err
+	}
+
+	go func() {
+		err := current.server.Shutdown(context.Background())
+		if err != nil {
+			webServer.logger.Println("Reload: shutdown of previous listener: " + err.Error())
+		}
+	}()
+	if rebind {
+		webServer.logger.Println("Reload: settings applied, listener moved from " + current.listener.Addr().String() + " to " + listener.Addr().String())
+	} else {
+		webServer.logger.Println("Reload: settings applied, server restarted on " + listener.Addr().String())
+	}
+	return nil
+}
+
+// ReloadOnSignal calls Reload with the settings returned by load whenever the process receives SIGHUP.
+// The returned function stops listening for the signal.
+func (webServer *WebServer) ReloadOnSignal(load func() (Settings, error)) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for {
+			select {
+			case <-signals:
+				settings, err := load()
+				if err == nil {
+					err = webServer.Reload(settings)
+				}
+				if err != nil {
+					webServer.logger.Println("Reload: " + err.Error())
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// addressChanged reports whether settings listen somewhere else than old, which needs a new listener
+func addressChanged(old Settings, settings Settings) bool {
+	return old.BindAddr() != settings.BindAddr() ||
+		old.Network() != settings.Network() ||
+		old.UnixSocket != settings.UnixSocket
+}
+
+// listenerChanged reports whether settings change how the listener of old accepts connections, which needs a new server
+func listenerChanged(old Settings, settings Settings) bool {
+	return old.UseHttps != settings.UseHttps ||
+		old.ReusePort != settings.ReusePort ||
+		old.UseProxyProtocol != settings.UseProxyProtocol ||
+		!slices.Equal(old.ProxyProtocolTrustedSources, settings.ProxyProtocolTrustedSources) ||
+		old.UseTLSFingerprint != settings.UseTLSFingerprint ||
+		old.UseHttp2 != settings.UseHttp2 ||
+		old.UseH2C != settings.UseH2C ||
+		old.Http2MaxConcurrentStreams != settings.Http2MaxConcurrentStreams ||
+		old.Http2MaxReadFrameSize != settings.Http2MaxReadFrameSize ||
+		old.Http2IdleTimeout != settings.Http2IdleTimeout
+}
+
+// serverChanged reports whether settings change the http.Server options or the tls.Config of old, they are applied by
+// a new server on the same listener as a running http.Server cannot be changed
+func serverChanged(old Settings, settings Settings) bool {
+	return (settings.UseHttps && tlsSettingsChanged(old.TLS, settings.TLS)) ||
+		old.ReadTimeout != settings.ReadTimeout ||
+		old.ReadHeaderTimeout != settings.ReadHeaderTimeout ||
+		old.WriteTimeout != settings.WriteTimeout ||
+		old.IdleTimeout != settings.IdleTimeout ||
+		old.MaxHeaderBytes != settings.MaxHeaderBytes
+}
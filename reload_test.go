@@ -0,0 +1,215 @@
+package webserver
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func waitServing(t *testing.T, webServer *WebServer, previous *serving) *serving {
+	for i := 0; i < 100; i++ {
+		webServer.servingMutex.Lock()
+		current := webServer.serving
+		webServer.servingMutex.Unlock()
+		if current != nil && current != previous {
+			return current
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("server did not start")
+	return nil
+}
+
+func TestReload(t *testing.T) {
+	settings := NewSettings()
+	settings.Bind = "127.0.0.1"
+	settings.HttpPort = "0"
+	settings.Root = "root"
+	webServer := NewWebServer(*settings)
+
+	release := make(chan struct{})
+	webServer.NewHandleFunc(HTTPMethodGet, "/slow", func(rw http.ResponseWriter, req *http.Request) {
+		<-release
+		_, _ = rw.Write([]byte("slow"))
+	})
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- webServer.Run() }()
+	first := waitServing(t, webServer, nil)
+	firstUrl := "http://" + first.listener.Addr().String()
+
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(firstUrl + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		slow <- string(body)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	reloaded := *settings
+	reloaded.Bind = "localhost"
+	reloaded.FileExtensionFilter = []string{"html"}
+	err := webServer.Reload(reloaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := waitServing(t, webServer, first)
+
+	resp, err := http.Get("http://" + second.listener.Addr().String() + "/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("reloaded filter: status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+
+	close(release)
+	if body := <-slow; body != "slow" {
+		t.Errorf("in-flight request = %q, want %q", body, "slow")
+	}
+
+	select {
+	case err := <-runErr:
+		t.Fatalf("Run returned after reload: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	_ = second.server.Close()
+	if err := <-runErr; err != http.ErrServerClosed {
+		t.Errorf("Run returned %v, want %v", err, http.ErrServerClosed)
+	}
+}
+
+func TestReloadFixedPort(t *testing.T) {
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(free.Addr().String())
+	_ = free.Close()
+
+	settings := NewSettings()
+	settings.Bind = "127.0.0.1"
+	settings.HttpPort = port
+	settings.FallbackPorts = nil
+	settings.Root = "root"
+	webServer := NewWebServer(*settings)
+	err = webServer.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer webServer.Shutdown(context.Background())
+	first := waitServing(t, webServer, nil)
+
+	// a listener option on the same port takes over the bound socket instead of binding it again
+	reloaded := *settings
+	reloaded.UseH2C = true
+	err = webServer.Reload(reloaded)
+	if err != nil {
+		t.Fatalf("Reload on a fixed port: %v", err)
+	}
+	second := waitServing(t, webServer, first)
+	if second.listener.Addr().String() != first.listener.Addr().String() {
+		t.Errorf("listener moved from %s to %s", first.listener.Addr(), second.listener.Addr())
+	}
+
+	// the previous server shuts down, the socket stays bound for the new one
+	<-first.done
+	resp, err := http.Get("http://127.0.0.1:" + port + "/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("after reload: status = %d", resp.StatusCode)
+	}
+
+	// timeouts and header limits only need a new http.Server on the same socket
+	timeouts := reloaded
+	timeouts.ReadTimeout = 5 * time.Second
+	timeouts.IdleTimeout = time.Second
+	timeouts.MaxHeaderBytes = 8192
+	err = webServer.Reload(timeouts)
+	if err != nil {
+		t.Fatalf("Reload of timeouts: %v", err)
+	}
+	third := waitServing(t, webServer, second)
+	if third.server.ReadTimeout != 5*time.Second || third.server.IdleTimeout != time.Second || third.server.MaxHeaderBytes != 8192 {
+		t.Errorf("reloaded server: read %s, idle %s, max header bytes %d", third.server.ReadTimeout, third.server.IdleTimeout, third.server.MaxHeaderBytes)
+	}
+	<-second.done
+	resp, err = http.Get("http://127.0.0.1:" + port + "/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestReloadTLSSettings(t *testing.T) {
+	settings := NewSettings()
+	settings.Bind = "127.0.0.1"
+	settings.HttpsPort = "0"
+	settings.UseHttps = true
+	settings.UseSelfSignedTLS = true
+	settings.Root = "root"
+	webServer := NewWebServer(*settings)
+	err := webServer.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer webServer.Shutdown(context.Background())
+	first := waitServing(t, webServer, nil)
+
+	handshake := func() error {
+		conn, err := tls.Dial("tcp", webServer.ListenAddr(), &tls.Config{
+			RootCAs:    webServer.SelfSignedCertPool(),
+			ServerName: "localhost",
+			MaxVersion: tls.VersionTLS12,
+		})
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	err = handshake()
+	if err != nil {
+		t.Fatalf("TLS 1.2 before reload: %v", err)
+	}
+
+	reloaded := *settings
+	reloaded.TLS.MinVersion = "1.3"
+	err = webServer.Reload(reloaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitServing(t, webServer, first)
+	<-first.done
+	err = handshake()
+	if err == nil {
+		t.Error("TLS 1.2 handshake after reloading MinVersion 1.3")
+	}
+}
+
+func TestNewHTTPServerTimeouts(t *testing.T) {
+	settings := NewSettings()
+	settings.WriteTimeout = 0
+	settings.MaxHeaderBytes = 4096
+	server := NewWebServer(*settings).newHTTPServer(*settings)
+
+	if server.ReadTimeout != 30*time.Second || server.ReadHeaderTimeout != 10*time.Second || server.IdleTimeout != 120*time.Second {
+		t.Errorf("timeouts = %s %s %s", server.ReadTimeout, server.ReadHeaderTimeout, server.IdleTimeout)
+	}
+	if server.WriteTimeout != 0 || server.MaxHeaderBytes != 4096 {
+		t.Errorf("write timeout = %s, max header bytes = %d", server.WriteTimeout, server.MaxHeaderBytes)
+	}
+}
@@ -0,0 +1,273 @@
+package webserver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrRememberMeTokenNotFound = errors.New("remember me token not found")
+
+// RememberMeToken is the stored half of a remember me cookie. The cookie carries the selector, to look the token up,
+// and the verifier, of which only the hash is stored so a leaked store cannot be used to sign in.
+type RememberMeToken struct {
+	Selector     string
+	VerifierHash [32]byte
+	Identity     string
+	Expires      time.Time
+	// PreviousHash is the verifier hash replaced by the last rotation at Rotated, it still authenticates requests
+	// sent in parallel to the rotation during RememberMeOptions.RotationGrace
+	PreviousHash [32]byte
+	Rotated      time.Time
+}
+
+// RememberMeStore keeps remember me tokens, implementations must be safe for concurrent use
+type RememberMeStore interface {
+	Save(ctx context.Context, token RememberMeToken) error
+	// Find returns ErrRememberMeTokenNotFound for unknown selectors
+	Find(ctx context.Context, selector string) (RememberMeToken, error)
+	// Rotate saves token only if the stored token of its selector still has the verifier hash previous, false if
+	// another request rotated it meanwhile
+	Rotate(ctx context.Context, token RememberMeToken, previous [32]byte) (bool, error)
+	Delete(ctx context.Context, selector string) error
+	// DeleteAll deletes every token of identity
+	DeleteAll(ctx context.Context, identity string) error
+}
+
+type RememberMeOptions struct {
+	CookieName string
+	MaxAge     time.Duration
+	// Secure sets the Secure flag of the cookie also for requests without TLS, e.g. behind a TLS terminating proxy
+	Secure bool
+	// RotationGrace is how long the verifier replaced by a rotation keeps authenticating, so parallel requests sent
+	// with the same cookie are not mistaken for a stolen one
+	RotationGrace time.Duration
+	Store         RememberMeStore
+}
+
+func NewRememberMeOptions() *RememberMeOptions {
+	return &RememberMeOptions{
+		CookieName:    "remember_me",
+		MaxAge:        30 * 24 * time.Hour,
+		Secure:        false,
+		RotationGrace: time.Minute,
+		Store:         NewMemoryRememberMeStore(),
+	}
+}
+
+// RememberMe issues and verifies persistent login tokens for "keep me signed in", independent of how the
+// client signed in. Every verifier is used once: a verified token gets a new verifier under the same selector, so
+// a known selector with a wrong verifier, the sign of a stolen and already used cookie, revokes every token of its
+// identity. Only the verifier replaced last is still accepted, for RotationGrace after the rotation.
+type RememberMe struct {
+	webServer *WebServer
+	options   RememberMeOptions
+}
+
+func (webServer *WebServer) NewRememberMe(options RememberMeOptions) *RememberMe {
+	if options.Store == nil {
+		options.Store = NewMemoryRememberMeStore()
+	}
+	return &RememberMe{webServer: webServer, options: options}
+}
+
+// Issue sets a remember me cookie for identity, call it after a successful sign in with "keep me signed in"
+func (rememberMe *RememberMe) Issue(rw http.ResponseWriter, req *http.Request, identity string) error {
+	return rememberMe.issue(rw, req, randomToken(12), identity)
+}
+
+// issue saves a token with selector and a new verifier and sets its cookie
+func (rememberMe *RememberMe) issue(rw http.ResponseWriter, req *http.Request, selector string, identity string) error {
+	verifier := randomToken(32)
+	token := RememberMeToken{
+		Selector:     selector,
+		VerifierHash: sha256.Sum256([]byte(verifier)),
+		Identity:     identity,
+		Expires:      time.Now().Add(rememberMe.options.MaxAge),
+	}
+	err := rememberMe.options.Store.Save(req.Context(), token)
+	if err != nil {
+		return err
+	}
+	rememberMe.setCookie(rw, req, selector+":"+verifier, int(rememberMe.options.MaxAge.Seconds()))
+	return nil
+}
+
+// rotate replaces the verifier of token and sets the new cookie. If a parallel request rotated the token first,
+// the client gets the cookie of that request and this one keeps its cookie.
+func (rememberMe *RememberMe) rotate(rw http.ResponseWriter, req *http.Request, token RememberMeToken) error {
+	verifier := randomToken(32)
+	rotated := token
+	rotated.VerifierHash = sha256.Sum256([]byte(verifier))
+	rotated.Expires = time.Now().Add(rememberMe.options.MaxAge)
+	rotated.PreviousHash = token.VerifierHash
+	rotated.Rotated = time.Now()
+	ok, err := rememberMe.options.Store.Rotate(req.Context(), rotated, token.VerifierHash)
+	if err != nil || !ok {
+		return err
+	}
+	rememberMe.setCookie(rw, req, token.Selector+":"+verifier, int(rememberMe.options.MaxAge.Seconds()))
+	return nil
+}
+
+// Forget deletes the token of the request and its cookie, call it on sign out
+func (rememberMe *RememberMe) Forget(rw http.ResponseWriter, req *http.Request) error {
+	rememberMe.setCookie(rw, req, "", -1)
+	selector, _, ok := rememberMe.cookieToken(req)
+	if !ok {
+		return nil
+	}
+	return rememberMe.options.Store.Delete(req.Context(), selector)
+}
+
+// RevokeAll deletes every token of identity, e.g. after a password change
+func (rememberMe *RememberMe) RevokeAll(ctx context.Context, identity string) error {
+	return rememberMe.options.Store.DeleteAll(ctx, identity)
+}
+
+// Middleware returns a middleware authenticating requests without principal by their remember me cookie as
+// Principal{Name: identity, Scheme: "RememberMe"} and replacing the verifier of the cookie. Requests without
+// valid cookie pass unauthenticated. It is meant to be registered with NewPhaseMiddleware(PhaseAuth, ...).
+func (rememberMe *RememberMe) Middleware() func(http.ResponseWriter, *http.Request) bool {
+	return func(rw http.ResponseWriter, req *http.Request) bool {
+		if _, ok := PrincipalFrom(req); ok {
+			return true
+		}
+		selector, verifier, ok := rememberMe.cookieToken(req)
+		if !ok {
+			return true
+		}
+
+		logger := Logger(req)
+		token, err := rememberMe.options.Store.Find(req.Context(), selector)
+		if err != nil {
+			if !errors.Is(err, ErrRememberMeTokenNotFound) {
+				logger.Println("Remember Me: " + err.Error())
+			}
+			rememberMe.setCookie(rw, req, "", -1)
+			return true
+		}
+		hash := sha256.Sum256([]byte(verifier))
+		current := subtle.ConstantTimeCompare(hash[:], token.VerifierHash[:]) == 1
+		previous := time.Since(token.Rotated) < rememberMe.options.RotationGrace &&
+			subtle.ConstantTimeCompare(hash[:], token.PreviousHash[:]) == 1
+		if !current && !previous {
+			logger.Println("Remember Me: verifier mismatch, revoking every token of " + token.Identity)
+			err = rememberMe.options.Store.DeleteAll(req.Context(), token.Identity)
+			if err != nil {
+				logger.Println("Remember Me: " + err.Error())
+			}
+			rememberMe.setCookie(rw, req, "", -1)
+			return true
+		}
+
+		if time.Now().After(token.Expires) {
+			err = rememberMe.options.Store.Delete(req.Context(), selector)
+		} else {
+			// a previous verifier comes from a request sent before the last rotation, its client got the rotated cookie
+			if current {
+				err = rememberMe.rotate(rw, req, token)
+			}
+			if err == nil {
+				setPrincipal(req, Principal{Name: token.Identity, Scheme: "RememberMe"})
+				return true
+			}
+		}
+		if err != nil {
+			logger.Println("Remember Me: " + err.Error())
+		}
+		rememberMe.setCookie(rw, req, "", -1)
+		return true
+	}
+}
+
+func (rememberMe *RememberMe) cookieToken(req *http.Request) (selector string, verifier string, ok bool) {
+	cookie, err := req.Cookie(rememberMe.options.CookieName)
+	if err != nil {
+		return "", "", false
+	}
+	selector, verifier, ok = strings.Cut(cookie.Value, ":")
+	return selector, verifier, ok && selector != "" && verifier != ""
+}
+
+func (rememberMe *RememberMe) setCookie(rw http.ResponseWriter, req *http.Request, value string, maxAge int) {
+	cookie := &http.Cookie{
+		Name:     rememberMe.options.CookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   rememberMe.options.Secure || req.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	rememberMe.webServer.SetCookie(rw, req, cookie)
+}
+
+func randomToken(size int) string {
+	token := make([]byte, size)
+	_, _ = rand.Read(token)
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// MemoryRememberMeStore keeps the tokens in process memory, they are lost on restart
+type MemoryRememberMeStore struct {
+	mutex  sync.Mutex
+	tokens map[string]RememberMeToken
+}
+
+func NewMemoryRememberMeStore() *MemoryRememberMeStore {
+	return &MemoryRememberMeStore{tokens: map[string]RememberMeToken{}}
+}
+
+func (store *MemoryRememberMeStore) Save(ctx context.Context, token RememberMeToken) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.tokens[token.Selector] = token
+	return nil
+}
+
+func (store *MemoryRememberMeStore) Find(ctx context.Context, selector string) (RememberMeToken, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	token, ok := store.tokens[selector]
+	if !ok {
+		return RememberMeToken{}, ErrRememberMeTokenNotFound
+	}
+	return token, nil
+}
+
+func (store *MemoryRememberMeStore) Rotate(ctx context.Context, token RememberMeToken, previous [32]byte) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	stored, ok := store.tokens[token.Selector]
+	if !ok || stored.VerifierHash != previous {
+		return false, nil
+	}
+	store.tokens[token.Selector] = token
+	return true, nil
+}
+
+func (store *MemoryRememberMeStore) Delete(ctx context.Context, selector string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	delete(store.tokens, selector)
+	return nil
+}
+
+func (store *MemoryRememberMeStore) DeleteAll(ctx context.Context, identity string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for selector, token := range store.tokens {
+		if token.Identity == identity {
+			delete(store.tokens, selector)
+		}
+	}
+	return nil
+}
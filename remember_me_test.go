@@ -0,0 +1,178 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRememberMe(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	rememberMe := webServer.NewRememberMe(*NewRememberMeOptions())
+	webServer.NewPhaseMiddleware(PhaseAuth, rememberMe.Middleware())
+	webServer.NewHandleFunc(HTTPMethodPost, "/login", func(rw http.ResponseWriter, req *http.Request) {
+		err := rememberMe.Issue(rw, req, "alice")
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	webServer.NewHandleFunc(HTTPMethodPost, "/logout", func(rw http.ResponseWriter, req *http.Request) {
+		err := rememberMe.Forget(rw, req)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	webServer.NewHandleFunc(HTTPMethodGet, "/whoami", func(rw http.ResponseWriter, req *http.Request) {
+		principal, _ := PrincipalFrom(req)
+		_, _ = rw.Write([]byte(principal.Scheme + " " + principal.Name))
+	})
+
+	// serve returns the body and the last remember me cookie set by the response, nil if none was set
+	serve := func(method string, path string, cookie *http.Cookie) (string, *http.Cookie) {
+		req := httptest.NewRequest(method, path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+		var last *http.Cookie
+		for _, set := range rec.Result().Cookies() {
+			if set.Name == "remember_me" {
+				last = set
+			}
+		}
+		return rec.Body.String(), last
+	}
+
+	_, first := serve(http.MethodPost, "/login", nil)
+	if first == nil || !first.HttpOnly || first.MaxAge <= 0 {
+		t.Fatalf("login cookie = %v", first)
+	}
+	body, second := serve(http.MethodGet, "/whoami", first)
+	if body != "RememberMe alice" {
+		t.Errorf("whoami with cookie = %q, want %q", body, "RememberMe alice")
+	}
+	if second == nil || second.Value == first.Value || second.MaxAge <= 0 {
+		t.Fatalf("cookie was not rotated: %v", second)
+	}
+
+	// the replaced cookie still works during the rotation grace, without rotating again
+	body, unchanged := serve(http.MethodGet, "/whoami", first)
+	if body != "RememberMe alice" || unchanged != nil {
+		t.Errorf("previous cookie: body %q, cookie %v", body, unchanged)
+	}
+
+	// once replaced twice, replaying the used cookie looks like theft and revokes the rotated one as well
+	_, second = serve(http.MethodGet, "/whoami", second)
+	if second == nil {
+		t.Fatal("cookie was not rotated again")
+	}
+	body, cleared := serve(http.MethodGet, "/whoami", first)
+	if body != " " || cleared == nil || cleared.MaxAge >= 0 {
+		t.Errorf("replayed cookie: body %q, cookie %v", body, cleared)
+	}
+	body, _ = serve(http.MethodGet, "/whoami", second)
+	if body != " " {
+		t.Errorf("cookie after theft = %q, want anonymous", body)
+	}
+
+	_, third := serve(http.MethodPost, "/login", nil)
+	_, cleared = serve(http.MethodPost, "/logout", third)
+	if cleared == nil || cleared.MaxAge >= 0 {
+		t.Errorf("logout cookie = %v", cleared)
+	}
+	body, _ = serve(http.MethodGet, "/whoami", third)
+	if body != " " {
+		t.Errorf("cookie after logout = %q, want anonymous", body)
+	}
+
+	_, fourth := serve(http.MethodPost, "/login", nil)
+	err := rememberMe.RevokeAll(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = serve(http.MethodGet, "/whoami", fourth)
+	if body != " " {
+		t.Errorf("cookie after RevokeAll = %q, want anonymous", body)
+	}
+}
+
+func TestRememberMeParallelRequests(t *testing.T) {
+	options := NewRememberMeOptions()
+	webServer := NewWebServer(*NewSettings())
+	rememberMe := webServer.NewRememberMe(*options)
+	webServer.NewPhaseMiddleware(PhaseAuth, rememberMe.Middleware())
+	webServer.NewHandleFunc(HTTPMethodGet, "/whoami", func(rw http.ResponseWriter, req *http.Request) {
+		principal, _ := PrincipalFrom(req)
+		_, _ = rw.Write([]byte(principal.Name))
+	})
+
+	login := httptest.NewRecorder()
+	err := rememberMe.Issue(login, httptest.NewRequest(http.MethodPost, "/login", nil), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := login.Result().Cookies()[0]
+
+	// a page loading several resources at once sends the same cookie with every request
+	var wait sync.WaitGroup
+	bodies := make(chan string, 8)
+	rotated := make(chan *http.Cookie, 8)
+	for i := 0; i < 8; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+			req.AddCookie(cookie)
+			rec := httptest.NewRecorder()
+			webServer.mux.ServeHTTP(rec, req)
+			bodies <- rec.Body.String()
+			for _, set := range rec.Result().Cookies() {
+				rotated <- set
+			}
+		}()
+	}
+	wait.Wait()
+	close(bodies)
+	close(rotated)
+	for body := range bodies {
+		if body != "alice" {
+			t.Errorf("parallel request = %q, want alice", body)
+		}
+	}
+	var next *http.Cookie
+	for set := range rotated {
+		if next != nil || set.MaxAge <= 0 {
+			t.Errorf("parallel requests set %v after %v, want one rotation", set, next)
+		}
+		next = set
+	}
+	if next == nil {
+		t.Fatal("cookie was not rotated")
+	}
+
+	// after the grace the replaced cookie is treated as stolen
+	selector, _, _ := strings.Cut(next.Value, ":")
+	token, err := options.Store.Find(context.Background(), selector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token.Rotated = time.Now().Add(-2 * options.RotationGrace)
+	_ = options.Store.Save(context.Background(), token)
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, req)
+	if rec.Body.String() != "" {
+		t.Errorf("previous cookie after the grace = %q, want anonymous", rec.Body.String())
+	}
+	_, err = options.Store.Find(context.Background(), selector)
+	if !errors.Is(err, ErrRememberMeTokenNotFound) {
+		t.Errorf("token after replay = %v, want revoked", err)
+	}
+}
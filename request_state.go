@@ -0,0 +1,145 @@
+package webserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"sync"
+)
+
+type requestStateKey struct{}
+
+// requestState is attached to every request by the main handler so middleware, which cannot replace
+// the request, can pass values on to the handlers.
+type requestState struct {
+	mutex        sync.Mutex
+	values       map[any]any
+	serverLogger *log.Logger
+	// cleanups are the functions registered with Defer
+	cleanups []func()
+}
+
+func withRequestState(req *http.Request, logger *log.Logger) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), requestStateKey{}, &requestState{values: map[any]any{}, serverLogger: logger}))
+}
+
+// stateOf returns nil for requests that did not pass the main handler
+func stateOf(req *http.Request) *requestState {
+	state, _ := req.Context().Value(requestStateKey{}).(*requestState)
+	return state
+}
+
+// Key identifies a per-request value of type T, keys are compared by identity so two keys with the same name never collide
+type Key[T any] struct {
+	name string
+}
+
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+func (key *Key[T]) String() string {
+	return key.name
+}
+
+// Set stores value for key on the request, so later middleware and the handler can read it with Get.
+// It has no effect on requests which did not pass the main handler.
+func Set[T any](req *http.Request, key *Key[T], value T) {
+	state := stateOf(req)
+	if state == nil {
+		return
+	}
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.values[key] = value
+}
+
+// Get returns the value stored for key on the request
+func Get[T any](req *http.Request, key *Key[T]) (T, bool) {
+	state := stateOf(req)
+	if state == nil {
+		var zero T
+		return zero, false
+	}
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	value, ok := state.values[key].(T)
+	return value, ok
+}
+
+// getOrSet returns the value stored for key, storing the result of create first if there is none
+func getOrSet[T any](state *requestState, key *Key[T], create func() T) T {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	value, ok := state.values[key].(T)
+	if !ok {
+		value = create()
+		state.values[key] = value
+	}
+	return value
+}
+
+var (
+	principalKey = NewKey[Principal]("principal")
+	requestIDKey = NewKey[string]("request id")
+	tenantKey    = NewKey[string]("tenant")
+	loggerKey    = NewKey[*log.Logger]("logger")
+	// serverKey is the server which handles the request, for middleware created without one
+	serverKey = NewKey[*WebServer]("server")
+)
+
+// RequestID returns the X-Request-Id header of the request or, without one, a random id which stays the same for the request
+func RequestID(req *http.Request) string {
+	state := stateOf(req)
+	if state == nil {
+		return req.Header.Get("X-Request-Id")
+	}
+	return getOrSet(state, requestIDKey, func() string {
+		if id := req.Header.Get("X-Request-Id"); id != "" {
+			return id
+		}
+		id := make([]byte, 8)
+		_, _ = rand.Read(id)
+		return hex.EncodeToString(id)
+	})
+}
+
+// ClientIP returns the ip address of the client without port. Behind a proxy of Settings.TrustedProxies it is the
+// address the proxy reports with the Forwarded, X-Forwarded-For or X-Real-IP header, otherwise that of the peer.
+func ClientIP(req *http.Request) string {
+	if ip, ok := Get(req, clientIPKey); ok {
+		return ip
+	}
+	return peerIP(req)
+}
+
+// Identity returns the name of the principal an auth middleware authenticated the request as, empty for anonymous requests
+func Identity(req *http.Request) string {
+	principal, _ := PrincipalFrom(req)
+	return principal.Name
+}
+
+// Tenant returns the tenant a middleware assigned to the request with SetTenant
+func Tenant(req *http.Request) string {
+	tenant, _ := Get(req, tenantKey)
+	return tenant
+}
+
+// SetTenant assigns the request to tenant, it has no effect on requests which did not pass the main handler
+func SetTenant(req *http.Request, tenant string) {
+	Set(req, tenantKey, tenant)
+}
+
+// Logger returns the logger of the server prefixing every line with the request id
+func Logger(req *http.Request) *log.Logger {
+	state := stateOf(req)
+	if state == nil {
+		return log.Default()
+	}
+	id := RequestID(req)
+	return getOrSet(state, loggerKey, func() *log.Logger {
+		return log.New(state.serverLogger.Writer(), state.serverLogger.Prefix()+"["+id+"] ", state.serverLogger.Flags())
+	})
+}
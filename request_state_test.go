@@ -0,0 +1,88 @@
+package webserver
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestAccessors(t *testing.T) {
+	logs := &bytes.Buffer{}
+	settings := NewSettings()
+	settings.Logger = log.New(logs, "", 0)
+	webServer := NewWebServer(*settings)
+	webServer.NewPhaseMiddleware(PhaseAuth, BasicAuth(map[string]string{"alice": "secret"}))
+	webServer.NewMiddleware(func(rw http.ResponseWriter, req *http.Request) bool {
+		SetTenant(req, "acme")
+		return true
+	})
+	webServer.NewHandleFunc(HTTPMethodGet, "/me", func(rw http.ResponseWriter, req *http.Request) {
+		if RequestID(req) != RequestID(req) {
+			t.Error("RequestID changed within the request")
+		}
+		Logger(req).Println("handled")
+		_, _ = rw.Write([]byte(strings.Join([]string{RequestID(req), ClientIP(req), Identity(req), Tenant(req)}, " ")))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.RemoteAddr = "192.0.2.7:51234"
+	req.Header.Set("X-Request-Id", "abc")
+	req.SetBasicAuth("alice", "secret")
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, req)
+	if rec.Body.String() != "abc 192.0.2.7 alice acme" {
+		t.Errorf("accessors %q", rec.Body.String())
+	}
+	if !strings.Contains(logs.String(), "[abc] handled") {
+		t.Errorf("logs %q", logs.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.SetBasicAuth("alice", "secret")
+	rec = httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, req)
+	if id, _, _ := strings.Cut(rec.Body.String(), " "); len(id) != 16 {
+		t.Errorf("generated request id %q", id)
+	}
+
+	outside := httptest.NewRequest(http.MethodGet, "/", nil)
+	if Tenant(outside) != "" || Identity(outside) != "" || Logger(outside) == nil {
+		t.Error("accessors outside of the main handler")
+	}
+}
+
+func TestRequestValues(t *testing.T) {
+	type session struct{ user string }
+	sessionKey := NewKey[*session]("session")
+	otherKey := NewKey[*session]("session")
+	countKey := NewKey[int]("count")
+
+	webServer := NewWebServer(*NewSettings())
+	webServer.NewMiddleware(func(rw http.ResponseWriter, req *http.Request) bool {
+		Set(req, sessionKey, &session{user: "alice"})
+		Set(req, countKey, 1)
+		return true
+	})
+	webServer.NewHandleFunc(HTTPMethodGet, "/values", func(rw http.ResponseWriter, req *http.Request) {
+		s, ok := Get(req, sessionKey)
+		if !ok || s.user != "alice" {
+			t.Errorf("session %v %v", s, ok)
+		}
+		if _, ok := Get(req, otherKey); ok {
+			t.Error("keys with the same name collide")
+		}
+		if count, _ := Get(req, countKey); count != 1 {
+			t.Errorf("count %d", count)
+		}
+	})
+	webServer.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/values", nil))
+
+	outside := httptest.NewRequest(http.MethodGet, "/", nil)
+	Set(outside, countKey, 2)
+	if _, ok := Get(outside, countKey); ok {
+		t.Error("value stored outside of the main handler")
+	}
+}
@@ -0,0 +1,39 @@
+package webserver
+
+import (
+	"net/http"
+	"time"
+)
+
+// SetReadDeadline sets the deadline for reading the rest of the request body, e.g. for a slow upload in a streaming handler.
+// The zero time removes the deadline of Settings.ReadTimeout. It returns http.ErrNotSupported if the connection has no deadlines.
+func SetReadDeadline(rw http.ResponseWriter, deadline time.Time) error {
+	return http.NewResponseController(rw).SetReadDeadline(deadline)
+}
+
+// SetWriteDeadline sets the deadline for writing the response, e.g. for server-sent events outliving Settings.WriteTimeout.
+// The zero time removes the deadline. It returns http.ErrNotSupported if the connection has no deadlines.
+func SetWriteDeadline(rw http.ResponseWriter, deadline time.Time) error {
+	return http.NewResponseController(rw).SetWriteDeadline(deadline)
+}
+
+// ExtendDeadlines moves the read and write deadline d into the future
+func ExtendDeadlines(rw http.ResponseWriter, d time.Duration) error {
+	controller := http.NewResponseController(rw)
+	deadline := time.Now().Add(d)
+	err := controller.SetReadDeadline(deadline)
+	if err != nil {
+		return err
+	}
+	return controller.SetWriteDeadline(deadline)
+}
+
+// EnableFullDuplex allows HTTP/1 handlers to read the request body after they started writing the response
+func EnableFullDuplex(rw http.ResponseWriter) error {
+	return http.NewResponseController(rw).EnableFullDuplex()
+}
+
+// Flush sends the buffered response to the client
+func Flush(rw http.ResponseWriter) error {
+	return http.NewResponseController(rw).Flush()
+}
@@ -0,0 +1,67 @@
+package webserver
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResponseController(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	webServer.EnableUsageReports(*NewUsageOptions(func(req *http.Request) string { return "all" }))
+	webServer.NewHandleFunc(HTTPMethodPost, "/stream", func(rw http.ResponseWriter, req *http.Request) {
+		for _, err := range []error{
+			ExtendDeadlines(rw, time.Minute),
+			SetWriteDeadline(rw, time.Time{}),
+			EnableFullDuplex(rw),
+		} {
+			if err != nil {
+				t.Error(err)
+			}
+		}
+		_, _ = rw.Write([]byte("echo:"))
+		if err := Flush(rw); err != nil {
+			t.Error(err)
+		}
+		_, _ = io.Copy(rw, req.Body)
+	})
+	webServer.NewHandleFunc(HTTPMethodGet, "/stream", func(rw http.ResponseWriter, req *http.Request) {
+		if err := SetReadDeadline(rw, time.Now().Add(time.Second)); err != nil {
+			t.Error(err)
+		}
+	})
+
+	server := httptest.NewServer(webServer.mux)
+	defer server.Close()
+	res, err := http.Post(server.URL+"/stream", "text/plain", strings.NewReader("ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if string(body) != "echo:ping" {
+		t.Errorf("body %q", body)
+	}
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, _ = conn.Write([]byte("HEAD /stream HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	res, err = http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Errorf("HEAD through headWriter: %v %v", res, err)
+	}
+
+	err = SetWriteDeadline(httptest.NewRecorder(), time.Now())
+	if !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("recorder: %v", err)
+	}
+}
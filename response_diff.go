@@ -0,0 +1,133 @@
+package webserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+type DiffOptions struct {
+	// IgnoreHeaders are response headers not compared
+	IgnoreHeaders []string
+	// IgnoreJSONFields are removed at any depth from json bodies before comparing, e.g. generated ids or timestamps
+	IgnoreJSONFields []string
+	// IgnoreBodyPatterns are removed from bodies before comparing
+	IgnoreBodyPatterns []*regexp.Regexp
+	// MaxBodySize limits the compared part of the response bodies
+	MaxBodySize int64
+}
+
+func NewDiffOptions() *DiffOptions {
+	return &DiffOptions{
+		IgnoreHeaders: []string{"Date"},
+		MaxBodySize:   1024 * 1024,
+	}
+}
+
+// ResponseDiff is a recorded request both handler versions answered differently
+type ResponseDiff struct {
+	Method      string
+	URL         string
+	Status      int
+	OtherStatus int
+	// Headers are the names of the headers with different values
+	Headers   []string
+	Body      []byte
+	OtherBody []byte
+}
+
+func (diff ResponseDiff) String() string {
+	var parts []string
+	if diff.Status != diff.OtherStatus {
+		parts = append(parts, "status "+strconv.Itoa(diff.Status)+" != "+strconv.Itoa(diff.OtherStatus))
+	}
+	if len(diff.Headers) > 0 {
+		parts = append(parts, "headers "+strings.Join(diff.Headers, ", "))
+	}
+	if !bytes.Equal(diff.Body, diff.OtherBody) {
+		parts = append(parts, "body "+strconv.Quote(string(diff.Body))+" != "+strconv.Quote(string(diff.OtherBody)))
+	}
+	return diff.Method + " " + diff.URL + ": " + strings.Join(parts, "; ")
+}
+
+// DiffHandlers replays the records, e.g. read with ReadMirrorFile, against handler and other and returns the
+// requests whose responses differ. It is meant for tests guarding a handler refactor against recorded traffic.
+func DiffHandlers(records []MirrorRecord, handler http.Handler, other http.Handler, options DiffOptions) ([]ResponseDiff, error) {
+	var diffs []ResponseDiff
+	for _, record := range records {
+		a, err := replay(record, handler, options.MaxBodySize)
+		if err != nil {
+			return nil, err
+		}
+		b, err := replay(record, other, options.MaxBodySize)
+		if err != nil {
+			return nil, err
+		}
+
+		diff := ResponseDiff{
+			Method:      record.Method,
+			URL:         record.URL,
+			Status:      a.Status(),
+			OtherStatus: b.Status(),
+			Headers:     diffHeaders(a.Header(), b.Header(), options.IgnoreHeaders),
+			Body:        options.normalizeBody(a.body.Bytes()),
+			OtherBody:   options.normalizeBody(b.body.Bytes()),
+		}
+		if diff.Status != diff.OtherStatus || len(diff.Headers) > 0 || !bytes.Equal(diff.Body, diff.OtherBody) {
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs, nil
+}
+
+func replay(record MirrorRecord, handler http.Handler, maxBodySize int64) (*recordingWriter, error) {
+	req, err := record.Request()
+	if err != nil {
+		return nil, err
+	}
+	rw := newRecordingWriter(maxBodySize)
+	handler.ServeHTTP(rw, req)
+	return rw, nil
+}
+
+func (options DiffOptions) normalizeBody(body []byte) []byte {
+	for _, pattern := range options.IgnoreBodyPatterns {
+		body = pattern.ReplaceAll(body, nil)
+	}
+	if len(options.IgnoreJSONFields) == 0 {
+		return body
+	}
+
+	var value any
+	if json.Unmarshal(body, &value) != nil {
+		return body
+	}
+	normalized, err := json.Marshal(removeJSONFields(value, options.IgnoreJSONFields))
+	if err != nil {
+		return body
+	}
+	return normalized
+}
+
+func removeJSONFields(value any, fields []string) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, field := range value {
+			if slices.Contains(fields, key) {
+				delete(value, key)
+			} else {
+				value[key] = removeJSONFields(field, fields)
+			}
+		}
+	case []any:
+		for i, element := range value {
+			value[i] = removeJSONFields(element, fields)
+		}
+	}
+	return value
+}
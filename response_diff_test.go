@@ -0,0 +1,61 @@
+package webserver
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffHandlers(t *testing.T) {
+	records := []MirrorRecord{
+		{Method: http.MethodGet, URL: "/users/1"},
+		{Method: http.MethodGet, URL: "/users/2"},
+		{Method: http.MethodGet, URL: "/missing"},
+		{Method: http.MethodGet, URL: "/time"},
+	}
+
+	version := func(v int) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("Date", time.Now().String())
+			switch {
+			case req.URL.Path == "/time":
+				_, _ = fmt.Fprintf(rw, "now: %d", time.Now().UnixNano()+int64(v))
+			case strings.HasPrefix(req.URL.Path, "/users/"):
+				name := "alice"
+				if v == 2 && req.URL.Path == "/users/2" {
+					name = "bob"
+				}
+				_, _ = fmt.Fprintf(rw, `{"name":%q,"request_id":"%d-%d"}`, name, v, time.Now().UnixNano())
+			default:
+				if v == 2 {
+					rw.Header().Set("X-Version", "2")
+				}
+				rw.WriteHeader(http.StatusNotFound)
+			}
+		})
+	}
+
+	options := NewDiffOptions()
+	options.IgnoreJSONFields = []string{"request_id"}
+	options.IgnoreBodyPatterns = []*regexp.Regexp{regexp.MustCompile(`now: \d+`)}
+	diffs, err := DiffHandlers(records, version(1), version(2), *options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`GET /users/2: body "{\"name\":\"alice\"}" != "{\"name\":\"bob\"}"`,
+		`GET /missing: headers X-Version`,
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("diffs = %v", diffs)
+	}
+	for i, diff := range diffs {
+		if diff.String() != want[i] {
+			t.Errorf("diff %d = %s, want %s", i, diff, want[i])
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package webserver
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// errResponseTooLarge is returned by writes beyond Settings.MaxResponseSize with Settings.AbortLargeResponses
+var errResponseTooLarge = errors.New("response exceeds the maximum response size")
+
+// limitResponse applies Settings.MaxResponseSize and Settings.AbortLargeResponses to rw
+func limitResponse(rw *responseWriter, settings Settings) {
+	rw.limit = settings.MaxResponseSize
+	rw.abort = settings.AbortLargeResponses
+}
+
+// finishResponse logs the size of the response to req and reports oversized ones.
+// An aborted response panics with http.ErrAbortHandler so net/http closes the connection instead of ending the
+// body as if it was complete.
+func (webServer *WebServer) finishResponse(rw *responseWriter, req *http.Request, clientIP string, settings Settings) {
+	if settings.LogResponseSizes {
+		webServer.logger.Println(clientIP, req.Method, req.URL, rw.Status(), rw.written)
+	}
+	if !rw.exceeded {
+		return
+	}
+	aborted := rw.abort
+	webServer.logger.Println("Response Size: " + req.Method + " " + req.URL.Path + " exceeded " +
+		strconv.FormatInt(rw.limit, 10) + " bytes, wrote " + strconv.FormatInt(rw.written, 10))
+	webServer.emit(ResponseTooLarge{Method: req.Method, Path: req.URL.Path, Limit: rw.limit, Written: rw.written, Aborted: aborted})
+	if aborted {
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -0,0 +1,84 @@
+package webserver
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxResponseSize(t *testing.T) {
+	var logs bytes.Buffer
+	settings := NewSettings()
+	settings.Logger = log.New(&logs, "", 0)
+	settings.MaxResponseSize = 10
+	settings.LogResponseSizes = true
+	webServer := NewWebServer(*settings)
+	webServer.NewHandleFunc(HTTPMethodGet, "/stream", func(rw http.ResponseWriter, req *http.Request) {
+		for i := 0; i < 4; i++ {
+			_, err := rw.Write([]byte("chunk"))
+			if err != nil {
+				return
+			}
+			http.NewResponseController(rw).Flush()
+		}
+	})
+	webServer.NewHandleFunc(HTTPMethodGet, "/small", func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("small"))
+	})
+	events := make(chan ResponseTooLarge, 4)
+	On(webServer, func(event ResponseTooLarge) {
+		events <- event
+	})
+
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/small", nil))
+	if rec.Body.String() != "small" || !strings.Contains(logs.String(), "GET /small 200 5") {
+		t.Errorf("small: body %q, logs %q", rec.Body.String(), logs.String())
+	}
+
+	// without AbortLargeResponses the response is only reported
+	rec = httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	if rec.Body.Len() != 20 || !strings.Contains(logs.String(), "Response Size: GET /stream exceeded 10 bytes, wrote 20") {
+		t.Errorf("logged: %d bytes, logs %q", rec.Body.Len(), logs.String())
+	}
+	select {
+	case event := <-events:
+		want := ResponseTooLarge{Method: http.MethodGet, Path: "/stream", Limit: 10, Written: 20}
+		if event != want {
+			t.Errorf("event %#v, want %#v", event, want)
+		}
+	case <-time.After(time.Second):
+		t.Error("no ResponseTooLarge event")
+	}
+
+	settings.AbortLargeResponses = true
+	err := webServer.Reload(*settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(webServer.mux)
+	defer server.Close()
+	response, err := http.Get(server.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(response.Body)
+	_ = response.Body.Close()
+	if err == nil || string(body) != "chunkchunk" {
+		t.Errorf("aborted: body %q, error %v", body, err)
+	}
+	select {
+	case event := <-events:
+		if !event.Aborted || event.Written != 10 {
+			t.Errorf("aborted event %#v", event)
+		}
+	case <-time.After(time.Second):
+		t.Error("no ResponseTooLarge event for the aborted response")
+	}
+}
@@ -0,0 +1,92 @@
+package webserver
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+)
+
+// responseWriter wraps the http.ResponseWriter of a request to observe the status, the amount of
+// bytes written and, if capture is set, the first maxCapture bytes of the body.
+// With a limit, exceeded is set once the body grows larger and with abort nothing beyond the limit is written.
+type responseWriter struct {
+	http.ResponseWriter
+
+	status  int
+	written int64
+
+	limit    int64
+	abort    bool
+	exceeded bool
+
+	capture    *bytes.Buffer
+	maxCapture int64
+	truncated  bool
+}
+
+func newResponseWriter(rw http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: rw}
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+
+	if rw.capture != nil {
+		remaining := rw.maxCapture - int64(rw.capture.Len())
+		if int64(len(b)) > remaining {
+			rw.truncated = true
+			rw.capture.Write(b[:max(remaining, 0)])
+		} else {
+			rw.capture.Write(b)
+		}
+	}
+
+	if rw.limit > 0 && rw.written+int64(len(b)) > rw.limit {
+		rw.exceeded = true
+		if rw.abort {
+			n, err := rw.ResponseWriter.Write(b[:max(rw.limit-rw.written, 0)])
+			rw.written += int64(n)
+			if err == nil {
+				err = errResponseTooLarge
+			}
+			return n, err
+		}
+	}
+
+	n, err := rw.ResponseWriter.Write(b)
+	rw.written += int64(n)
+	return n, err
+}
+
+func (rw *responseWriter) Status() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
+
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack keeps websocket upgrades working through the wrapper
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(rw.ResponseWriter).Hijack()
+}
+
+// Unwrap is used by http.ResponseController to reach the underlying writer
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
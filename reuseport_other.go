@@ -0,0 +1,12 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package webserver
+
+import (
+	"errors"
+	"runtime"
+)
+
+func setReusePort(fd uintptr) error {
+	return errors.New("reuse port is not supported on " + runtime.GOOS)
+}
@@ -0,0 +1,31 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package webserver
+
+import "testing"
+
+func TestListenReusePort(t *testing.T) {
+	settings := NewSettings()
+	settings.ReusePort = true
+	first, err := listen(*settings, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	// a second process of a zero downtime restart binds the same port
+	second, err := listen(*settings, first.Addr().String())
+	if err != nil {
+		t.Fatalf("second listener on %s: %v", first.Addr(), err)
+	}
+	defer second.Close()
+	if second.Addr().String() != first.Addr().String() {
+		t.Errorf("second listener on %s, want %s", second.Addr(), first.Addr())
+	}
+
+	settings.ReusePort = false
+	_, err = listen(*settings, first.Addr().String())
+	if err == nil {
+		t.Error("listener without ReusePort bound a port in use")
+	}
+}
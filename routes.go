@@ -0,0 +1,99 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"runtime"
+	"sort"
+	"text/tabwriter"
+)
+
+// RouteInfo describes one registered route
+type RouteInfo struct {
+	// Host is the host of the virtual host the route belongs to, empty for the routes of the server itself
+	Host    string
+	Method  string
+	Pattern string
+	// Handler is the function or type name of the handler, or a description for routes the server registers
+	// itself like "static files", "proxy <target>" and "mount <root>"
+	Handler string
+}
+
+type route struct {
+	method  string
+	pattern string
+	handler string
+	doc     routeDoc
+}
+
+// handle registers handler on the mux of method and records the route for Routes
+func (webServer *WebServer) handle(method string, pattern string, name string, handler http.Handler) {
+	webServer.methodMux(method).Handle(pattern, handler)
+	webServer.routes = append(webServer.routes, route{method: method, pattern: pattern, handler: name})
+}
+
+func handlerName(handler http.Handler) string {
+	if handlerFunc, ok := handler.(http.HandlerFunc); ok {
+		function := runtime.FuncForPC(reflect.ValueOf(handlerFunc).Pointer())
+		if function != nil {
+			return function.Name()
+		}
+	}
+	return reflect.TypeOf(handler).String()
+}
+
+// Routes returns every route in registration order, followed by the routes of the virtual hosts sorted by host
+func (webServer *WebServer) Routes() []RouteInfo {
+	routes := webServer.hostRoutes("")
+	for _, host := range webServer.sortedHosts() {
+		routes = append(routes, webServer.virtualHosts[host].hostRoutes(host)...)
+	}
+	return routes
+}
+
+func (webServer *WebServer) sortedHosts() []string {
+	hosts := make([]string, 0, len(webServer.virtualHosts))
+	for host := range webServer.virtualHosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+func (webServer *WebServer) hostRoutes(host string) []RouteInfo {
+	routes := make([]RouteInfo, 0, len(webServer.routes))
+	for _, route := range webServer.routes {
+		routes = append(routes, RouteInfo{Host: host, Method: route.method, Pattern: route.pattern, Handler: route.handler})
+	}
+	return routes
+}
+
+// RoutesHandler serves the route table as text or, with format=json, as json. It exposes the internals of the server
+// and should only be registered behind authentication or on a debug listener.
+func (webServer *WebServer) RoutesHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		routes := webServer.Routes()
+		var err error
+		if req.URL.Query().Get("format") == "json" {
+			rw.Header().Set("Content-Type", "application/json")
+			err = json.NewEncoder(rw).Encode(routes)
+		} else {
+			rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			writer := tabwriter.NewWriter(rw, 0, 4, 2, ' ', 0)
+			_, err = writer.Write([]byte("HOST\tMETHOD\tPATTERN\tHANDLER\n"))
+			for _, route := range routes {
+				if err != nil {
+					break
+				}
+				_, err = writer.Write([]byte(route.Host + "\t" + route.Method + "\t" + route.Pattern + "\t" + route.Handler + "\n"))
+			}
+			if err == nil {
+				err = writer.Flush()
+			}
+		}
+		if err != nil {
+			webServer.logger.Println("Routes: " + err.Error())
+		}
+	})
+}
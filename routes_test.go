@@ -0,0 +1,59 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func listUsers(rw http.ResponseWriter, req *http.Request) {}
+
+func TestRoutes(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	webServer.NewHandleFunc(HTTPMethodGet, "/users", listUsers)
+	webServer.NewHandler(HTTPMethodPost, "/usage", webServer.UsageHandler())
+	webServer.MountFS("/assets", fstest.MapFS{}, *NewMountOptions())
+	target, _ := url.Parse("http://backend:8080")
+	webServer.NewProxyHandler("/api/", target, *NewProxyOptions())
+	webServer.VirtualHost("admin.example.com").NewHandler(HTTPMethodGet, "/routes", webServer.RoutesHandler())
+
+	routes := webServer.Routes()
+	want := []RouteInfo{
+		{"", "GET", "/", "static files"},
+		{"", "HEAD", "/", "static files"},
+		{"", "OPTIONS", "/", "options"},
+		{"", "GET", "/users", "github.com/Nikkolix/webserver.listUsers"},
+		{"", "POST", "/usage", "github.com/Nikkolix/webserver.(*WebServer).UsageHandler.func1"},
+		{"", "GET", "/assets/", "mount fs"},
+		{"", "GET", "/api/", "proxy http://backend:8080"},
+	}
+	for i, route := range want {
+		if i >= len(routes) || routes[i] != route {
+			t.Fatalf("route %d: %+v, want %+v", i, routes, route)
+		}
+	}
+	if len(routes) != len(want)+8+4 || routes[len(routes)-1] != (RouteInfo{"admin.example.com", "GET", "/routes", "github.com/Nikkolix/webserver.(*WebServer).RoutesHandler.func1"}) {
+		t.Errorf("routes %+v", routes[len(want):])
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/routes", nil)
+	req.Host = "admin.example.com"
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), "GET      /users    github.com/Nikkolix/webserver.listUsers") {
+		t.Errorf("route table\n%s", rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/routes?format=json", nil)
+	req.Host = "admin.example.com"
+	rec = httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, req)
+	var decoded []RouteInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &decoded); err != nil || len(decoded) != len(routes) {
+		t.Errorf("json routes %v %q", err, rec.Body.String())
+	}
+}
@@ -0,0 +1,135 @@
+package webserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// selfSignedValidity is how long generated certificates are valid
+const selfSignedValidity = 365 * 24 * time.Hour
+
+// GenerateSelfSignedCert writes a self-signed certificate for hosts, names and ip addresses, valid for a year to
+// cert.pem and its key to key.pem in dir and returns their paths, e.g. for Settings.CertFile and Settings.KeyFile.
+// Browsers and clients only accept it after it was trusted explicitly, it is meant for development and tests.
+func GenerateSelfSignedCert(hosts []string, dir string) (certFile string, keyFile string, err error) {
+	certPEM, keyPEM, err := selfSignedCert(hosts)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return "", "", err
+	}
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	err = os.WriteFile(certFile, certPEM, 0644)
+	if err != nil {
+		return "", "", err
+	}
+	err = os.WriteFile(keyFile, keyPEM, 0600)
+	if err != nil {
+		return "", "", err
+	}
+	return certFile, keyFile, nil
+}
+
+// selfSignedCert returns a pem encoded self-signed certificate for hosts and its key
+func selfSignedCert(hosts []string) (certPEM []byte, keyPEM []byte, err error) {
+	if len(hosts) == 0 {
+		return nil, nil, errors.New("self-signed certificate needs a host")
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hosts[0], Organization: []string{"webserver self-signed"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}), nil
+}
+
+// selfSignedHosts are the names of the certificate of Settings.UseSelfSignedTLS
+func selfSignedHosts(settings Settings) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	for _, host := range []string{settings.Hostname, settings.Bind} {
+		if host != "" && !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// loadSelfSignedCertificate generates the certificate of Settings.UseSelfSignedTLS once, reloads keep it so
+// clients trusting SelfSignedCertPool keep working
+func (webServer *WebServer) loadSelfSignedCertificate(settings Settings) error {
+	if webServer.selfSignedPool.Load() != nil {
+		return nil
+	}
+
+	hosts := selfSignedHosts(settings)
+	certPEM, keyPEM, err := selfSignedCert(hosts)
+	if err != nil {
+		return err
+	}
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPEM)
+
+	webServer.certificate.Store(&certificate)
+	webServer.selfSignedPool.Store(pool)
+	webServer.logger.Println("Certificate: generated self-signed certificate for " + strings.Join(hosts, ", ") + ", for development only")
+	return nil
+}
+
+// SelfSignedCertPool returns a pool trusting the certificate of Settings.UseSelfSignedTLS, e.g. for the
+// tls.Config RootCAs of a test client, nil before the server started or without UseSelfSignedTLS
+func (webServer *WebServer) SelfSignedCertPool() *x509.CertPool {
+	return webServer.selfSignedPool.Load()
+}
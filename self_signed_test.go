@@ -0,0 +1,72 @@
+package webserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateSelfSignedCert(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "ssl")
+	certFile, keyFile, err := GenerateSelfSignedCert([]string{"dev.test", "10.0.0.1"}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.VerifyHostname("dev.test") != nil || leaf.VerifyHostname("10.0.0.1") != nil {
+		t.Errorf("names %v %v", leaf.DNSNames, leaf.IPAddresses)
+	}
+	info, err := os.Stat(keyFile)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("key file mode %v, %v", info, err)
+	}
+
+	_, _, err = GenerateSelfSignedCert(nil, dir)
+	if err == nil {
+		t.Error("certificate without hosts generated")
+	}
+}
+
+func TestUseSelfSignedTLS(t *testing.T) {
+	settings := NewSettings()
+	settings.UseHttps = true
+	settings.UseSelfSignedTLS = true
+	settings.Bind = "127.0.0.1"
+	settings.HttpsPort = "0"
+	webServer := NewWebServer(*settings)
+	webServer.NewHandleFunc(HTTPMethodGet, "/hello", func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("hello"))
+	})
+	if webServer.SelfSignedCertPool() != nil {
+		t.Error("pool before start")
+	}
+
+	err := webServer.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = webServer.Shutdown(context.Background()) }()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: webServer.SelfSignedCertPool()}}}
+	resp, err := client.Get("https://" + webServer.ListenAddr() + "/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "hello" {
+		t.Errorf("body = %q", body)
+	}
+}
@@ -0,0 +1,164 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrSelfTestFailed = errors.New("self test failed")
+
+// selfTestTimeout is how long SelfTest waits for the response to one request
+const selfTestTimeout = 10 * time.Second
+
+// SelfTestSample is a request SelfTest sends in addition to the generated ones, e.g. a POST with a valid body
+type SelfTestSample struct {
+	// Host selects a virtual host, empty for the server itself
+	Host   string
+	Method string
+	Path   string
+	Header http.Header
+	Body   string
+	// Status is the expected status code, 0 accepts every status below 500
+	Status int
+}
+
+// SelfTestResult is the outcome of one request of SelfTest
+type SelfTestResult struct {
+	Host   string
+	Method string
+	Path   string
+	Status int
+	// Err is set if the request failed or was answered with an unexpected status
+	Err error
+}
+
+func (result SelfTestResult) String() string {
+	target := result.Method + " " + result.Host + result.Path
+	if result.Err != nil {
+		return target + ": " + result.Err.Error()
+	}
+	return target + ": " + strconv.Itoa(result.Status)
+}
+
+// AddSelfTestSample adds a request to SelfTest
+func (webServer *WebServer) AddSelfTestSample(sample SelfTestSample) {
+	webServer.selfTestSamples = append(webServer.selfTestSamples, sample)
+}
+
+// SelfTest serves the server on an ephemeral loopback port and requests every registered route: GET routes with HEAD,
+// routes of other methods with OPTIONS, followed by the samples added with AddSelfTestSample.
+// Wildcards of patterns are filled with "selftest". A route fails if it is answered with 5xx or the request fails,
+// a sample if its status differs from the expected one. Every result is returned, the error wraps ErrSelfTestFailed
+// if a request failed, so SelfTest can gate traffic cutover, e.g. in a container health check.
+func (webServer *WebServer) SelfTest(ctx context.Context) ([]SelfTestResult, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	server := &http.Server{Handler: webServer.serverHandler(), DisableGeneralOptionsHandler: true}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Close()
+
+	client := &http.Client{
+		Timeout: selfTestTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	baseUrl := "http://" + listener.Addr().String()
+
+	var samples []SelfTestSample
+	for _, route := range webServer.Routes() {
+		method := http.MethodOptions
+		if route.Method == http.MethodGet || route.Method == http.MethodHead || route.Method == http.MethodOptions {
+			method = route.Method
+		}
+		if method == http.MethodGet {
+			method = http.MethodHead
+		}
+		host, path := selfTestPath(route.Pattern)
+		if route.Host != "" {
+			host = strings.Replace(route.Host, "*", "selftest", 1)
+		}
+		samples = append(samples, SelfTestSample{Host: host, Method: method, Path: path})
+	}
+	samples = append(samples, webServer.selfTestSamples...)
+
+	results := make([]SelfTestResult, 0, len(samples))
+	failed := 0
+	for _, sample := range samples {
+		result := runSelfTestSample(ctx, client, baseUrl, sample)
+		if result.Err != nil {
+			failed++
+			webServer.logger.Println("Self Test: " + result.String())
+		}
+		results = append(results, result)
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%w: %d of %d requests", ErrSelfTestFailed, failed, len(results))
+	}
+	return results, nil
+}
+
+func runSelfTestSample(ctx context.Context, client *http.Client, baseUrl string, sample SelfTestSample) SelfTestResult {
+	result := SelfTestResult{Host: sample.Host, Method: sample.Method, Path: sample.Path}
+	req, err := http.NewRequestWithContext(ctx, sample.Method, baseUrl+sample.Path, strings.NewReader(sample.Body))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	for name, values := range sample.Header {
+		req.Header[name] = values
+	}
+	if sample.Host != "" {
+		req.Host = sample.Host
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	_ = res.Body.Close()
+
+	result.Status = res.StatusCode
+	if sample.Status != 0 && res.StatusCode != sample.Status {
+		result.Err = fmt.Errorf("status %d, want %d", res.StatusCode, sample.Status)
+	} else if sample.Status == 0 && res.StatusCode >= 500 {
+		result.Err = fmt.Errorf("status %d", res.StatusCode)
+	}
+	return result
+}
+
+// selfTestPath turns a mux pattern into a host and a path matching it
+func selfTestPath(pattern string) (host string, path string) {
+	if _, rest, ok := strings.Cut(pattern, " "); ok {
+		pattern = strings.TrimSpace(rest)
+	}
+	index := strings.Index(pattern, "/")
+	if index == -1 {
+		return pattern, "/"
+	}
+	host, pattern = pattern[:index], pattern[index:]
+
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		switch {
+		case segment == "{$}":
+			segments[i] = ""
+		case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"):
+			segments[i] = "selftest"
+		}
+	}
+	return host, strings.Join(segments, "/")
+}
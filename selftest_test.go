@@ -0,0 +1,75 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	settings := NewSettings()
+	settings.Root = "root"
+	webServer := NewWebServer(*settings)
+	webServer.NewHandleFunc(HTTPMethodGet, "/users/{id}", func(rw http.ResponseWriter, req *http.Request) {
+		if req.PathValue("id") == "" {
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	webServer.NewHandleFunc(HTTPMethodPost, "/orders", func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusCreated)
+	})
+	webServer.VirtualHost("*.example.com").NewHandleFunc(HTTPMethodGet, "/broken", func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusBadGateway)
+	})
+	webServer.AddSelfTestSample(SelfTestSample{Method: http.MethodPost, Path: "/orders", Status: http.StatusCreated})
+	webServer.AddSelfTestSample(SelfTestSample{Method: http.MethodPost, Path: "/orders", Status: http.StatusOK})
+
+	results, err := webServer.SelfTest(context.Background())
+	if !errors.Is(err, ErrSelfTestFailed) {
+		t.Fatalf("SelfTest() = %v, want ErrSelfTestFailed", err)
+	}
+
+	failed := map[string]bool{}
+	statuses := map[string]int{}
+	for _, result := range results {
+		key := result.Method + " " + result.Host + result.Path
+		statuses[key] = result.Status
+		if result.Err != nil {
+			failed[key] = true
+		}
+	}
+	want := map[string]int{
+		"HEAD /":                           http.StatusOK,
+		"OPTIONS /":                        http.StatusNoContent,
+		"HEAD /users/selftest":             http.StatusOK,
+		"OPTIONS /orders":                  http.StatusNoContent,
+		"POST /orders":                     http.StatusCreated,
+		"HEAD selftest.example.com/broken": http.StatusBadGateway,
+	}
+	for key, status := range want {
+		if statuses[key] != status {
+			t.Errorf("%s: %d, want %d (%v)", key, statuses[key], status, results)
+		}
+	}
+	if len(failed) != 2 || !failed["HEAD selftest.example.com/broken"] || !failed["POST /orders"] {
+		t.Errorf("failed %v, want the broken route and the sample expecting 200", failed)
+	}
+}
+
+func TestSelfTestPath(t *testing.T) {
+	tests := map[string][2]string{
+		"/":                      {"", "/"},
+		"/files/{path...}":       {"", "/files/selftest"},
+		"/users/{id}/orders/{$}": {"", "/users/selftest/orders/"},
+		"example.com/admin/":     {"example.com", "/admin/"},
+		"GET /items/{id}":        {"", "/items/selftest"},
+	}
+
+	for pattern, want := range tests {
+		host, path := selfTestPath(pattern)
+		if host != want[0] || path != want[1] {
+			t.Errorf("selfTestPath(%q) = %q, %q, want %q, %q", pattern, host, path, want[0], want[1])
+		}
+	}
+}
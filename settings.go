@@ -3,34 +3,187 @@ package webserver
 import (
 	"encoding/json"
 	"log"
+	"net"
 	"os"
+	"time"
+)
+
+type IPMode string
+
+const (
+	IPModeDualStack IPMode = "dual"
+	IPModeIPv4      IPMode = "ipv4"
+	IPModeIPv6      IPMode = "ipv6"
 )
 
 type Settings struct {
-	UseHttps         bool
-	UseHttpRedirect  bool
-	Hostname         string
-	HttpPort         string
-	HttpsPort        string
-	Root             string
-	FallbackRedirect string
-	Logger           *log.Logger
-	CertFile         string
-	KeyFile          string
+	UseHttps bool
+	// UseHttpRedirect binds HttpPort too and redirects its requests to HttpsPort, keeping path and query
+	UseHttpRedirect bool
+	Hostname        string
+	Bind            string
+	IPMode          IPMode
+	UnixSocket      string
+	// HttpPort and HttpsPort "0" bind a port chosen by the system, see WebServer.ListenAddr
+	HttpPort  string
+	HttpsPort string
+	// FallbackPorts are tried in order by Run if the port is in use, see SetPortFallbackHook
+	FallbackPorts []string
+	// Root is the directory static files are served from, a relative Root and "/" are relative to the working directory
+	Root                string
+	FallbackRedirect    string
+	FallbackMode        FallbackMode
+	FallbackFile        string
+	Logger              *log.Logger
+	CertFile            string
+	KeyFile             string
+	ReusePort           bool
+	BlockSymlinkEscape  bool
+	BlockDotfiles       bool
+	DotfileExceptions   []string
+	DirectoryListing    bool
+	FileExtensionFilter []string
+	AllowedExtensions   []string
+	DenyPaths           []string
+	DownloadExtensions  []string
+	DownloadPrefixes    []string
+	CachePolicies       []CachePolicy
+	MaxBodySize         int64
+	MaxMultipartMemory  int64
+	StripImageMetadata  bool
+	OriginUrl           string
+	OriginTimeout       time.Duration
+	OriginHedgeDelay    time.Duration
+
+	// ServePrecompressed serves file.br or file.gz next to a static file with Content-Encoding if the client accepts
+	// it, CompressFiles gzips compressible static files without such a sidecar on the fly
+	ServePrecompressed bool
+	CompressFiles      bool
+
+	// MaxResponseSize logs responses with a body larger than this many bytes, 0 disables the check. With
+	// AbortLargeResponses the body is cut off at the limit and the connection closed so clients see the failure.
+	// LogResponseSizes logs the status and body size of every response when it is finished.
+	MaxResponseSize     int64
+	AbortLargeResponses bool
+	LogResponseSizes    bool
+
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+
+	UseHttp2                  bool
+	UseH2C                    bool
+	Http2MaxConcurrentStreams uint32
+	Http2MaxReadFrameSize     uint32
+	Http2IdleTimeout          time.Duration
+
+	// UseProxyProtocol reads the client address from PROXY protocol headers sent by ProxyProtocolTrustedSources,
+	// the ranges of the load balancers in front, listening fails without them
+	UseProxyProtocol            bool
+	ProxyProtocolTrustedSources []string
+	// TrustedProxies are the ranges of proxies whose forwarded headers ClientIP reads the client address from
+	TrustedProxies []string
+
+	UseTLSFingerprint bool
+	// UseSelfSignedTLS serves UseHttps with a certificate generated in memory at startup for localhost, the loopback
+	// addresses, Hostname and Bind instead of CertFile and KeyFile. It is meant for development, clients have to trust
+	// it, see WebServer.SelfSignedCertPool.
+	UseSelfSignedTLS bool
+
+	MetricsBackend MetricsBackend
+	MetricsAddr    string
+	MetricsPrefix  string
+	MetricsTags    map[string]string
+	// MetricsInstance is added as instance tag to every metric, so the processes sharing a port with ReusePort
+	// report apart. Empty uses the process id with ReusePort and adds no tag otherwise.
+	MetricsInstance string
+	// MetricsBuckets are histogram boundaries in seconds, MetricsLabels any of the MetricsLabel constants and
+	// MetricsMaxRoutes limits the distinct values of the route label, further routes are recorded as "other"
+	MetricsBuckets   []float64
+	MetricsLabels    []string
+	MetricsMaxRoutes int
+
+	LogShipping LogShipperOptions
+
+	TLS TLSSettings
 }
 
 func NewSettings() *Settings {
 	return &Settings{
-		UseHttps:         false,
-		UseHttpRedirect:  false,
-		Hostname:         "localhost",
-		HttpPort:         "80",
-		HttpsPort:        "443",
-		Root:             "/",
-		FallbackRedirect: "/404",
-		Logger:           log.New(os.Stdout, "", log.LstdFlags),
-		CertFile:         "",
-		KeyFile:          "",
+		UseHttps:            false,
+		UseHttpRedirect:     false,
+		Hostname:            "localhost",
+		Bind:                "",
+		IPMode:              IPModeDualStack,
+		UnixSocket:          "",
+		HttpPort:            "80",
+		HttpsPort:           "443",
+		FallbackPorts:       []string{},
+		Root:                ".",
+		FallbackRedirect:    "/404",
+		FallbackMode:        FallbackModeRedirect,
+		FallbackFile:        "",
+		Logger:              log.New(os.Stdout, "", log.LstdFlags),
+		CertFile:            "",
+		KeyFile:             "",
+		ReusePort:           false,
+		BlockSymlinkEscape:  false,
+		BlockDotfiles:       false,
+		DotfileExceptions:   []string{".well-known"},
+		DirectoryListing:    false,
+		FileExtensionFilter: []string{},
+		AllowedExtensions:   []string{},
+		DenyPaths:           []string{},
+		DownloadExtensions:  []string{},
+		DownloadPrefixes:    []string{},
+		CachePolicies:       []CachePolicy{},
+		MaxBodySize:         32 << 20,
+		MaxMultipartMemory:  8 << 20,
+		StripImageMetadata:  true,
+		OriginUrl:           "",
+		OriginTimeout:       10 * time.Second,
+		OriginHedgeDelay:    0,
+
+		ServePrecompressed: true,
+		CompressFiles:      false,
+
+		MaxResponseSize:     0,
+		AbortLargeResponses: false,
+		LogResponseSizes:    false,
+
+		ReadTimeout:       30 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		MaxHeaderBytes:    1 << 20,
+
+		UseHttp2:                  true,
+		UseH2C:                    false,
+		Http2MaxConcurrentStreams: 250,
+		Http2MaxReadFrameSize:     0,
+		Http2IdleTimeout:          0,
+
+		UseProxyProtocol:            false,
+		ProxyProtocolTrustedSources: []string{},
+		TrustedProxies:              []string{},
+
+		UseTLSFingerprint: false,
+		UseSelfSignedTLS:  false,
+
+		MetricsBackend:   MetricsBackendNone,
+		MetricsAddr:      "127.0.0.1:8125",
+		MetricsPrefix:    "webserver",
+		MetricsTags:      map[string]string{},
+		MetricsInstance:  "",
+		MetricsBuckets:   append([]float64{}, DefaultMetricsBuckets...),
+		MetricsLabels:    []string{MetricsLabelMethod, MetricsLabelRoute, MetricsLabelStatusClass},
+		MetricsMaxRoutes: 100,
+
+		LogShipping: *NewLogShipperOptions(LogShipperNone, ""),
+
+		TLS: *NewTLSSettings(),
 	}
 }
 
@@ -73,7 +226,26 @@ func (s *Settings) Port() string {
 }
 
 func (s *Settings) Addr() string {
-	return s.Hostname + ":" + s.Port()
+	return net.JoinHostPort(s.Hostname, s.Port())
+}
+
+// BindAddr is the address the listener binds to, Bind falls back to Hostname if empty
+func (s *Settings) BindAddr() string {
+	if s.Bind == "" {
+		return s.Addr()
+	}
+	return net.JoinHostPort(s.Bind, s.Port())
+}
+
+func (s *Settings) Network() string {
+	switch s.IPMode {
+	case IPModeIPv4:
+		return "tcp4"
+	case IPModeIPv6:
+		return "tcp6"
+	default:
+		return "tcp"
+	}
 }
 
 func (s *Settings) Url() string {
@@ -81,9 +253,9 @@ func (s *Settings) Url() string {
 }
 
 func (s *Settings) UrlHttps() string {
-	return "https://" + s.Hostname + ":" + s.HttpsPort
+	return "https://" + net.JoinHostPort(s.Hostname, s.HttpsPort)
 }
 
 func (s *Settings) UrlHttp() string {
-	return "http://" + s.Hostname + ":" + s.HttpPort
+	return "http://" + net.JoinHostPort(s.Hostname, s.HttpPort)
 }
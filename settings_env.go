@@ -0,0 +1,191 @@
+package webserver
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// settingField is a field of Settings configurable by environment variables and flags
+type settingField struct {
+	// words is the field path split at word boundaries, e.g. TLS, Min, Version for TLS.MinVersion
+	words []string
+	path  string
+	value reflect.Value
+}
+
+// LoadEnv sets every field for which an environment variable named prefix_FIELD_NAME exists,
+// e.g. WEBSERVER_HTTP_PORT for HttpPort or WEBSERVER_TLS_MIN_VERSION for TLS.MinVersion with the prefix WEBSERVER.
+// Lists are comma separated and maps are comma separated key=value pairs. Invalid values are returned as BindErrors.
+//
+// Settings are meant to be loaded in the order defaults, file, environment and flags, every step overriding the previous one:
+//
+//	settings := NewSettings()
+//	err := settings.LoadJson("settings.json")
+//	err = settings.LoadEnv("WEBSERVER")
+//	settings.BindFlags(flag.CommandLine)
+//	flag.Parse()
+func (s *Settings) LoadEnv(prefix string) error {
+	var errs []error
+	for _, field := range s.settingFields() {
+		name := strings.ToUpper(strings.Join(field.words, "_"))
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		err := setSetting(field.value, value)
+		if err != nil {
+			var bindError *BindError
+			if errors.As(err, &bindError) {
+				bindError.Field = name
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// BindFlags defines a flag for every field of s on fs, named like -http-port or -tls-min-version.
+// The flags default to the current values of s and set them when fs is parsed, so only flags given on the
+// command line override values loaded before.
+func (s *Settings) BindFlags(fs *flag.FlagSet) {
+	for _, field := range s.settingFields() {
+		fs.Var(&settingFlag{value: field.value}, strings.ToLower(strings.Join(field.words, "-")), "sets Settings."+field.path)
+	}
+}
+
+func (s *Settings) settingFields() []settingField {
+	return appendSettingFields(nil, reflect.ValueOf(s).Elem(), nil, "")
+}
+
+func appendSettingFields(fields []settingField, v reflect.Value, words []string, path string) []settingField {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("json") == "-" {
+			continue
+		}
+		fieldWords := append(append([]string{}, words...), splitWords(field.Name)...)
+		fieldPath := path + field.Name
+
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			fields = appendSettingFields(fields, v.Field(i), fieldWords, fieldPath+".")
+		case reflect.Pointer, reflect.Interface, reflect.Func, reflect.Chan:
+			// the logger and other values only settable in code
+		case reflect.Map:
+			if field.Type.Key().Kind() == reflect.String && field.Type.Elem().Kind() == reflect.String {
+				fields = append(fields, settingField{words: fieldWords, path: fieldPath, value: v.Field(i)})
+			}
+		default:
+			fields = append(fields, settingField{words: fieldWords, path: fieldPath, value: v.Field(i)})
+		}
+	}
+	return fields
+}
+
+// splitWords splits a CamelCase name into its words, keeping acronyms and trailing digits together,
+// e.g. Use, TLS, Fingerprint for UseTLSFingerprint and Http2, Max, Read, Frame, Size for Http2MaxReadFrameSize
+func splitWords(name string) []string {
+	runes := []rune(name)
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		if !unicode.IsUpper(runes[i]) {
+			continue
+		}
+		nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if unicode.IsLower(runes[i-1]) || (unicode.IsUpper(runes[i-1]) || unicode.IsDigit(runes[i-1])) && nextLower {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(words, string(runes[start:]))
+}
+
+func setSetting(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.Slice:
+		values := []string{}
+		if value != "" {
+			values = strings.Split(value, ",")
+		}
+		for i := range values {
+			values[i] = strings.TrimSpace(values[i])
+		}
+		if len(values) == 0 {
+			field.Set(reflect.MakeSlice(field.Type(), 0, 0))
+			return nil
+		}
+		return setField(field, values)
+	case reflect.Map:
+		entries := reflect.MakeMap(field.Type())
+		if value != "" {
+			for _, pair := range strings.Split(value, ",") {
+				key, entry, ok := strings.Cut(pair, "=")
+				if !ok {
+					return &BindError{Value: value, Err: errors.New("expected key=value pairs")}
+				}
+				entries.SetMapIndex(reflect.ValueOf(strings.TrimSpace(key)).Convert(field.Type().Key()),
+					reflect.ValueOf(strings.TrimSpace(entry)).Convert(field.Type().Elem()))
+			}
+		}
+		field.Set(entries)
+		return nil
+	default:
+		return setValue(field, value)
+	}
+}
+
+func formatSetting(field reflect.Value) string {
+	switch {
+	case field.Type() == reflect.TypeFor[time.Duration]():
+		return time.Duration(field.Int()).String()
+	case field.Kind() == reflect.Slice:
+		values := make([]string, field.Len())
+		for i := range values {
+			values[i] = fmt.Sprint(field.Index(i).Interface())
+		}
+		return strings.Join(values, ",")
+	case field.Kind() == reflect.Map:
+		pairs := make([]string, 0, field.Len())
+		iter := field.MapRange()
+		for iter.Next() {
+			pairs = append(pairs, fmt.Sprint(iter.Key().Interface())+"="+fmt.Sprint(iter.Value().Interface()))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	default:
+		return fmt.Sprint(field.Interface())
+	}
+}
+
+// settingFlag is a flag.Value writing into a field of Settings
+type settingFlag struct {
+	value reflect.Value
+}
+
+func (f *settingFlag) String() string {
+	// the flag package calls String on a zero settingFlag to detect zero defaults
+	if f == nil || !f.value.IsValid() {
+		return ""
+	}
+	return formatSetting(f.value)
+}
+
+func (f *settingFlag) Set(value string) error {
+	return setSetting(f.value, value)
+}
+
+func (f *settingFlag) IsBoolFlag() bool {
+	return f.value.Kind() == reflect.Bool
+}
@@ -0,0 +1,361 @@
+package webserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrSettingsFormat = errors.New("unknown settings format")
+
+// SaveFile saves s as json, yaml or toml depending on the extension of fileName: .json, .yaml, .yml or .toml
+func (s *Settings) SaveFile(fileName string) error {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json":
+		return s.SaveJson(fileName)
+	case ".yaml", ".yml":
+		return s.SaveYaml(fileName)
+	case ".toml":
+		return s.SaveToml(fileName)
+	default:
+		return fmt.Errorf("%w: %s", ErrSettingsFormat, fileName)
+	}
+}
+
+// LoadFile loads s from a json, yaml or toml file depending on the extension of fileName: .json, .yaml, .yml or .toml
+func (s *Settings) LoadFile(fileName string) error {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json":
+		return s.LoadJson(fileName)
+	case ".yaml", ".yml":
+		return s.LoadYaml(fileName)
+	case ".toml":
+		return s.LoadToml(fileName)
+	default:
+		return fmt.Errorf("%w: %s", ErrSettingsFormat, fileName)
+	}
+}
+
+// SaveYaml saves s with the field names of SaveJson, durations are written like "30s"
+func (s *Settings) SaveYaml(fileName string) error {
+	node, err := yamlNode(settingsTree(reflect.ValueOf(s).Elem()))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	err = encoder.Encode(node)
+	if err == nil {
+		err = encoder.Close()
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, buf.Bytes(), 0666)
+}
+
+// LoadYaml loads s from yaml with the field names of SaveJson, matched case-insensitively.
+// Durations are written like "30s" or in nanoseconds, syntax errors are reported with their line.
+func (s *Settings) LoadYaml(fileName string) error {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	tree, err := parseYaml(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fileName, err)
+	}
+	return assignSetting(reflect.ValueOf(s).Elem(), tree, "")
+}
+
+// SaveToml saves s with the field names of SaveJson, nested settings like TLS are written as tables
+func (s *Settings) SaveToml(fileName string) error {
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).Encode(tomlValue(settingsTree(reflect.ValueOf(s).Elem())))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, buf.Bytes(), 0666)
+}
+
+// LoadToml loads s from toml with the field names of SaveJson, matched case-insensitively.
+// Durations are written like "30s" or in nanoseconds, syntax errors are reported with their line.
+func (s *Settings) LoadToml(fileName string) error {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	tree, err := parseToml(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fileName, err)
+	}
+	return assignSetting(reflect.ValueOf(s).Elem(), tree, "")
+}
+
+// configTable is a struct, its entries are written in field order
+type configTable []configEntry
+
+type configEntry struct {
+	key   string
+	value any
+}
+
+// settingsTree returns v as configTables for structs, map[string]any for maps, []any for slices and as strings, bools
+// and numbers, durations are written like "30s"
+func settingsTree(v reflect.Value) any {
+	switch {
+	case v.Type() == reflect.TypeFor[time.Duration]():
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Struct:
+		table := configTable{}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() || field.Tag.Get("json") == "-" {
+				continue
+			}
+			switch field.Type.Kind() {
+			case reflect.Pointer, reflect.Interface, reflect.Func, reflect.Chan:
+				continue
+			}
+			table = append(table, configEntry{key: field.Name, value: settingsTree(v.Field(i))})
+		}
+		return table
+	case v.Kind() == reflect.Map:
+		entries := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			entries[fmt.Sprint(iter.Key().Interface())] = settingsTree(iter.Value())
+		}
+		return entries
+	case v.Kind() == reflect.Slice:
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = settingsTree(v.Index(i))
+		}
+		return list
+	case v.Kind() == reflect.String:
+		return v.String()
+	case v.Kind() == reflect.Bool:
+		return v.Bool()
+	case v.CanInt():
+		return v.Int()
+	case v.CanUint():
+		return v.Uint()
+	case v.CanFloat():
+		return v.Float()
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// yamlNode returns the yaml of a settingsTree value, configTables are written in field order
+func yamlNode(value any) (*yaml.Node, error) {
+	switch value := value.(type) {
+	case configTable:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, entry := range value {
+			child, err := yamlNode(entry.value)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: entry.key}, child)
+		}
+		return node, nil
+	case []any:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range value {
+			child, err := yamlNode(item)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+		return node, nil
+	default:
+		node := &yaml.Node{}
+		err := node.Encode(value)
+		return node, err
+	}
+}
+
+// tomlValue returns a settingsTree value for the toml encoder, configTables become structs so they are written in
+// field order
+func tomlValue(value any) any {
+	switch value := value.(type) {
+	case configTable:
+		fields := make([]reflect.StructField, len(value))
+		for i, entry := range value {
+			fields[i] = reflect.StructField{Name: entry.key, Type: reflect.TypeFor[any](), Tag: reflect.StructTag(`toml:"` + entry.key + `"`)}
+		}
+		table := reflect.New(reflect.StructOf(fields)).Elem()
+		for i, entry := range value {
+			table.Field(i).Set(reflect.ValueOf(tomlValue(entry.value)))
+		}
+		return table.Interface()
+	case []any:
+		list := make([]any, len(value))
+		for i, item := range value {
+			list[i] = tomlValue(item)
+		}
+		return list
+	default:
+		return value
+	}
+}
+
+// assignSetting sets dst from a value parsed from yaml or toml, scalars are converted like environment variables
+func assignSetting(dst reflect.Value, src any, path string) error {
+	if src == nil {
+		return nil
+	}
+	switch dst.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Func, reflect.Chan:
+		return nil
+	case reflect.Struct:
+		table, ok := src.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected a table", path)
+		}
+		keys := make([]string, 0, len(table))
+		for key := range table {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var errs []error
+		t := dst.Type()
+		for _, key := range keys {
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				if field.IsExported() && field.Tag.Get("json") != "-" && strings.EqualFold(field.Name, key) {
+					errs = append(errs, assignSetting(dst.Field(i), table[key], strings.TrimPrefix(path+"."+field.Name, ".")))
+					break
+				}
+			}
+		}
+		return errors.Join(errs...)
+	case reflect.Map:
+		table, ok := src.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected a table", path)
+		}
+		// entries are added to the map like LoadJson does
+		entries := dst
+		if entries.IsNil() {
+			entries = reflect.MakeMap(dst.Type())
+		}
+		for key, value := range table {
+			entry := reflect.New(dst.Type().Elem()).Elem()
+			err := assignSetting(entry, value, path+"."+key)
+			if err != nil {
+				return err
+			}
+			entries.SetMapIndex(reflect.ValueOf(key).Convert(dst.Type().Key()), entry)
+		}
+		dst.Set(entries)
+		return nil
+	case reflect.Slice:
+		list, ok := src.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected a list", path)
+		}
+		slice := reflect.MakeSlice(dst.Type(), len(list), len(list))
+		for i, value := range list {
+			err := assignSetting(slice.Index(i), value, path+"["+strconv.Itoa(i)+"]")
+			if err != nil {
+				return err
+			}
+		}
+		dst.Set(slice)
+		return nil
+	default:
+		value, ok := src.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected a value", path)
+		}
+		if dst.Type() == reflect.TypeFor[time.Duration]() {
+			// nanoseconds as written by SaveJson
+			nanoseconds, err := strconv.ParseInt(value, 10, 64)
+			if err == nil {
+				dst.SetInt(nanoseconds)
+				return nil
+			}
+		}
+		err := setValue(dst, value)
+		var bindError *BindError
+		if errors.As(err, &bindError) {
+			bindError.Field = path
+		}
+		return err
+	}
+}
+
+// parseYaml decodes yaml into the tables, lists and string scalars assignSetting expects
+func parseYaml(data []byte) (any, error) {
+	var tree any
+	err := yaml.Unmarshal(data, &tree)
+	if err != nil {
+		return nil, err
+	}
+	return configValue(reflect.ValueOf(tree)), nil
+}
+
+// parseToml decodes toml into the tables, lists and string scalars assignSetting expects
+func parseToml(data []byte) (any, error) {
+	tree := map[string]any{}
+	err := toml.Unmarshal(data, &tree)
+	if err != nil {
+		return nil, err
+	}
+	return configValue(reflect.ValueOf(tree)), nil
+}
+
+// configValue converts decoded maps to map[string]any, slices to []any and scalars to their text
+func configValue(v reflect.Value) any {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	switch value := v.Interface().(type) {
+	case string:
+		return value
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
+	case float32, float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		table := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			table[fmt.Sprint(iter.Key().Interface())] = configValue(iter.Value())
+		}
+		return table
+	case reflect.Slice, reflect.Array:
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = configValue(v.Index(i))
+		}
+		return list
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
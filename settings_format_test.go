@@ -0,0 +1,237 @@
+package webserver
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSettingsFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	settings := NewSettings()
+	settings.HttpPort = "8080"
+	settings.UseHttps = true
+	settings.FallbackFile = "say \"hi\"\n# not a comment"
+	settings.FileExtensionFilter = []string{"exe", "bat"}
+	settings.MetricsTags = map[string]string{"env": "prod", "zone name": "eu-1"}
+	settings.ReadTimeout = 5 * time.Second
+	settings.Http2MaxConcurrentStreams = 100
+	settings.LogShipping.Labels = map[string]string{"job": "web"}
+	settings.TLS.CipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}
+	settings.CachePolicies = []CachePolicy{{Pattern: "/assets/**", Value: CacheImmutable}, {Pattern: "*.html", Value: CacheRevalidate}}
+
+	for _, name := range []string{"settings.json", "settings.yaml", "settings.yml", "settings.toml"} {
+		fileName := filepath.Join(dir, name)
+		err := settings.SaveFile(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		loaded := NewSettings()
+		err = loaded.LoadFile(fileName)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		loaded.Logger = settings.Logger
+		if !reflect.DeepEqual(loaded, settings) {
+			t.Errorf("%s: loaded %+v, want %+v", name, loaded, settings)
+		}
+	}
+
+	err := settings.SaveFile(filepath.Join(dir, "settings.ini"))
+	if !errors.Is(err, ErrSettingsFormat) {
+		t.Errorf("SaveFile(settings.ini) = %v, want ErrSettingsFormat", err)
+	}
+}
+
+func TestSettingsLoadYaml(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "settings.yaml")
+	err := os.WriteFile(fileName, []byte(`# production
+hostname: example.com
+HttpPort: 8080   # plain numbers are fine for strings
+UseHttps: true
+ReadTimeout: 5s
+WriteTimeout: 60000000000
+FileExtensionFilter:
+- exe
+- 'it''s'
+DownloadExtensions: [zip, "tar.gz"]
+MetricsTags: {env: prod}
+TLS:
+  MinVersion: "1.3"
+  CurvePreferences:
+    - X25519
+LogShipping:
+  Labels:
+    job: web
+FallbackFile: ~
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings := NewSettings()
+	err = settings.LoadYaml(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := NewSettings()
+	want.Logger = settings.Logger
+	want.Hostname = "example.com"
+	want.HttpPort = "8080"
+	want.UseHttps = true
+	want.ReadTimeout = 5 * time.Second
+	want.FileExtensionFilter = []string{"exe", "it's"}
+	want.DownloadExtensions = []string{"zip", "tar.gz"}
+	want.MetricsTags = map[string]string{"env": "prod"}
+	want.TLS.MinVersion = "1.3"
+	want.TLS.CurvePreferences = []string{"X25519"}
+	want.LogShipping.Labels = map[string]string{"job": "web"}
+	if !reflect.DeepEqual(settings, want) {
+		t.Errorf("LoadYaml() = %+v, want %+v", settings, want)
+	}
+}
+
+func TestSettingsLoadToml(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "settings.toml")
+	err := os.WriteFile(fileName, []byte(`# production
+Hostname = "example.com"
+HttpPort = 8080
+ReadTimeout = "5s"
+FileExtensionFilter = [
+  "exe", # windows
+  'bat',
+]
+MetricsTags = { env = "prod", "zone name" = "eu-1" }
+TLS.ClientAuth = "request"
+
+[TLS]
+MinVersion = "1.3"
+
+[LogShipping.Labels]
+job = "web"
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings := NewSettings()
+	err = settings.LoadToml(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := NewSettings()
+	want.Logger = settings.Logger
+	want.Hostname = "example.com"
+	want.HttpPort = "8080"
+	want.ReadTimeout = 5 * time.Second
+	want.FileExtensionFilter = []string{"exe", "bat"}
+	want.MetricsTags = map[string]string{"env": "prod", "zone name": "eu-1"}
+	want.TLS.ClientAuth = TLSClientAuthRequest
+	want.TLS.MinVersion = "1.3"
+	want.LogShipping.Labels = map[string]string{"job": "web"}
+	if !reflect.DeepEqual(settings, want) {
+		t.Errorf("LoadToml() = %+v, want %+v", settings, want)
+	}
+}
+
+func TestSettingsLoadInvalid(t *testing.T) {
+	tests := map[string]string{
+		"indent.yaml":   "TLS:\n  MinVersion: \"1.3\"\n    CipherSuites: []\n",
+		"value.yaml":    "MaxBodySize: big\n",
+		"list.yaml":     "FileExtensionFilter: exe\n",
+		"key.toml":      "HttpPort\n",
+		"dup.toml":      "HttpPort = 1\nHttpPort = 2\n",
+		"table.toml":    "[TLS\n",
+		"duration.toml": "ReadTimeout = \"soon\"\n",
+	}
+
+	dir := t.TempDir()
+	for name, content := range tests {
+		fileName := filepath.Join(dir, name)
+		err := os.WriteFile(fileName, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = NewSettings().LoadFile(fileName)
+		if err == nil {
+			t.Errorf("LoadFile(%s) succeeded", name)
+		}
+	}
+
+	// syntax errors name the line
+	for name, content := range map[string]string{
+		"line.yaml": "Hostname: example.com\n\tHttpPort: 8080\n",
+		"line.toml": "Hostname = \"example.com\"\nHttpPort = = 8080\n",
+	} {
+		fileName := filepath.Join(dir, name)
+		err := os.WriteFile(fileName, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = NewSettings().LoadFile(fileName)
+		if err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("LoadFile(%s) = %v, want an error in line 2", name, err)
+		}
+	}
+}
+
+func TestSettingsLoadFullSyntax(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"settings.yaml": `defaults: &defaults
+  job: web
+LogShipping:
+  Labels:
+    <<: *defaults
+    zone: eu-1
+FallbackFile: |
+  line one
+  line two
+CachePolicies:
+  - Pattern: "/assets/**"
+    Value: !!str public, max-age=31536000, immutable
+`,
+		"settings.toml": `FallbackFile = """
+line one
+line two
+"""
+
+[LogShipping.Labels]
+job = 'web'
+zone = "eu-1"
+
+[[CachePolicies]]
+Pattern = "/assets/**"
+Value = 'public, max-age=31536000, immutable'
+`,
+	}
+
+	for name, content := range files {
+		fileName := filepath.Join(dir, name)
+		err := os.WriteFile(fileName, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		settings := NewSettings()
+		err = settings.LoadFile(fileName)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		want := NewSettings()
+		want.Logger = settings.Logger
+		want.FallbackFile = "line one\nline two\n"
+		want.LogShipping.Labels = map[string]string{"job": "web", "zone": "eu-1"}
+		want.CachePolicies = []CachePolicy{{Pattern: "/assets/**", Value: CacheImmutable}}
+		if !reflect.DeepEqual(settings, want) {
+			t.Errorf("%s: loaded %+v, want %+v", name, settings, want)
+		}
+	}
+}
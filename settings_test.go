@@ -0,0 +1,149 @@
+package webserver
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestSettingsBindAddr(t *testing.T) {
+	tests := []struct {
+		hostname string
+		bind     string
+		addr     string
+		url      string
+	}{
+		{"localhost", "", "localhost:80", "http://localhost:80"},
+		{"localhost", "0.0.0.0", "0.0.0.0:80", "http://localhost:80"},
+		{"::1", "", "[::1]:80", "http://[::1]:80"},
+		{"example.com", "::", "[::]:80", "http://example.com:80"},
+		{"example.com", "fe80::1%eth0", "[fe80::1%eth0]:80", "http://example.com:80"},
+	}
+
+	for _, test := range tests {
+		settings := NewSettings()
+		settings.Hostname = test.hostname
+		settings.Bind = test.bind
+		if got := settings.BindAddr(); got != test.addr {
+			t.Errorf("BindAddr() with hostname %q and bind %q = %q, want %q", test.hostname, test.bind, got, test.addr)
+		}
+		if got := settings.Url(); got != test.url {
+			t.Errorf("Url() with hostname %q = %q, want %q", test.hostname, got, test.url)
+		}
+	}
+}
+
+func TestSettingsNetwork(t *testing.T) {
+	tests := map[IPMode]string{
+		IPModeDualStack: "tcp",
+		IPModeIPv4:      "tcp4",
+		IPModeIPv6:      "tcp6",
+		"":              "tcp",
+	}
+
+	for mode, want := range tests {
+		settings := Settings{IPMode: mode}
+		if got := settings.Network(); got != want {
+			t.Errorf("Network() with mode %q = %q, want %q", mode, got, want)
+		}
+	}
+}
+
+func TestSettingsLoadEnv(t *testing.T) {
+	t.Setenv("WEBSERVER_HTTP_PORT", "8080")
+	t.Setenv("WEBSERVER_USE_HTTPS", "true")
+	t.Setenv("WEBSERVER_IP_MODE", "ipv4")
+	t.Setenv("WEBSERVER_READ_TIMEOUT", "5s")
+	t.Setenv("WEBSERVER_HTTP2_MAX_CONCURRENT_STREAMS", "100")
+	t.Setenv("WEBSERVER_FILE_EXTENSION_FILTER", ".html, .css")
+	t.Setenv("WEBSERVER_METRICS_TAGS", "env=prod,region=eu")
+	t.Setenv("WEBSERVER_TLS_MIN_VERSION", "1.3")
+
+	settings := NewSettings()
+	err := settings.LoadEnv("WEBSERVER")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if settings.HttpPort != "8080" || !settings.UseHttps || settings.IPMode != IPModeIPv4 {
+		t.Errorf("HttpPort, UseHttps, IPMode = %q, %v, %q", settings.HttpPort, settings.UseHttps, settings.IPMode)
+	}
+	if settings.ReadTimeout != 5*time.Second || settings.Http2MaxConcurrentStreams != 100 {
+		t.Errorf("ReadTimeout, Http2MaxConcurrentStreams = %v, %d", settings.ReadTimeout, settings.Http2MaxConcurrentStreams)
+	}
+	if !slices.Equal(settings.FileExtensionFilter, []string{".html", ".css"}) {
+		t.Errorf("FileExtensionFilter = %q", settings.FileExtensionFilter)
+	}
+	if settings.MetricsTags["env"] != "prod" || settings.MetricsTags["region"] != "eu" {
+		t.Errorf("MetricsTags = %v", settings.MetricsTags)
+	}
+	if settings.TLS.MinVersion != "1.3" {
+		t.Errorf("TLS.MinVersion = %q", settings.TLS.MinVersion)
+	}
+	if settings.HttpsPort != "443" {
+		t.Errorf("HttpsPort = %q, want the default", settings.HttpsPort)
+	}
+
+	t.Setenv("WEBSERVER_MAX_BODY_SIZE", "big")
+	err = settings.LoadEnv("WEBSERVER")
+	bindErrors := BindErrors(err)
+	if len(bindErrors) != 1 || bindErrors[0].Field != "WEBSERVER_MAX_BODY_SIZE" {
+		t.Errorf("LoadEnv() with invalid value = %v", err)
+	}
+}
+
+func TestSettingsBindFlags(t *testing.T) {
+	t.Setenv("WEBSERVER_HTTP_PORT", "8080")
+	t.Setenv("WEBSERVER_HOSTNAME", "example.com")
+
+	settings := NewSettings()
+	err := settings.LoadEnv("WEBSERVER")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("webserver", flag.ContinueOnError)
+	settings.BindFlags(fs)
+	err = fs.Parse([]string{"-http-port", "9090", "-use-http2=false", "-directory-listing", "-idle-timeout", "1m", "-log-shipping-batch-size", "10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if settings.HttpPort != "9090" {
+		t.Errorf("HttpPort = %q, want the flag to override the environment", settings.HttpPort)
+	}
+	if settings.Hostname != "example.com" {
+		t.Errorf("Hostname = %q, want the environment to be kept", settings.Hostname)
+	}
+	if settings.UseHttp2 || !settings.DirectoryListing || settings.IdleTimeout != time.Minute || settings.LogShipping.BatchSize != 10 {
+		t.Errorf("UseHttp2, DirectoryListing, IdleTimeout, LogShipping.BatchSize = %v, %v, %v, %d",
+			settings.UseHttp2, settings.DirectoryListing, settings.IdleTimeout, settings.LogShipping.BatchSize)
+	}
+	if got := fs.Lookup("read-timeout").DefValue; got != "30s" {
+		t.Errorf("read-timeout default = %q", got)
+	}
+	if fs.Lookup("logger") != nil {
+		t.Error("logger must not be bound to a flag")
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	tests := map[string]string{
+		"HttpPort":                  "Http Port",
+		"UseTLSFingerprint":         "Use TLS Fingerprint",
+		"IPMode":                    "IP Mode",
+		"UseH2C":                    "Use H2C",
+		"Http2MaxConcurrentStreams": "Http2 Max Concurrent Streams",
+		"ClientCAFile":              "Client CA File",
+		"Root":                      "Root",
+	}
+
+	for name, want := range tests {
+		if got := strings.Join(splitWords(name), " "); got != want {
+			t.Errorf("splitWords(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
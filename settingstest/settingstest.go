@@ -0,0 +1,96 @@
+// Package settingstest creates webserver.Settings for tests from a declarative Fixture: a temporary root with files,
+// a self-signed certificate and ports chosen by the system, so tests need neither real certificates nor
+// privileged ports.
+package settingstest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Nikkolix/webserver"
+)
+
+type Fixture struct {
+	// Files are written below the temporary root, the keys are slash separated paths
+	Files map[string]string
+	// TLS creates a self-signed certificate for Hosts and enables UseHttps
+	TLS bool
+	// Hosts are the names and addresses of the certificate, "localhost" and "127.0.0.1" if empty
+	Hosts []string
+}
+
+// New returns settings serving fixture on 127.0.0.1 with HttpPort and HttpsPort "0", the bound address is
+// WebServer.ListenAddr. Files are removed when t ends.
+func New(t testing.TB, fixture Fixture) *webserver.Settings {
+	t.Helper()
+	settings := webserver.NewSettings()
+	settings.Hostname = "localhost"
+	settings.Bind = "127.0.0.1"
+	settings.HttpPort = "0"
+	settings.HttpsPort = "0"
+	settings.Root = TempRoot(t, fixture.Files)
+	if fixture.TLS {
+		settings.UseHttps = true
+		settings.CertFile, settings.KeyFile = SelfSignedCert(t, fixture.Hosts...)
+	}
+	return settings
+}
+
+// TempRoot returns a temporary directory containing files, the keys are slash separated paths.
+// It is removed when t ends.
+func TempRoot(t testing.TB, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// SelfSignedCert writes a certificate for hosts and its key to a temporary directory, see
+// webserver.GenerateSelfSignedCert, hosts default to "localhost" and "127.0.0.1"
+func SelfSignedCert(t testing.TB, hosts ...string) (certFile string, keyFile string) {
+	t.Helper()
+	if len(hosts) == 0 {
+		hosts = []string{"localhost", "127.0.0.1"}
+	}
+	certFile, keyFile, err := webserver.GenerateSelfSignedCert(hosts, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// Client returns a client trusting the certificate of settings, for settings without UseHttps it is a plain client
+func Client(t testing.TB, settings *webserver.Settings) *http.Client {
+	t.Helper()
+	if !settings.UseHttps {
+		return &http.Client{Timeout: 10 * time.Second}
+	}
+
+	certPEM, err := os.ReadFile(settings.CertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(certPEM) {
+		t.Fatal("settingstest: no certificate in " + settings.CertFile)
+	}
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+	}
+}
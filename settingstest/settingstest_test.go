@@ -0,0 +1,33 @@
+package settingstest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	settings := New(t, Fixture{Files: map[string]string{"a/b.txt": "b"}, TLS: true, Hosts: []string{"example.test", "::1"}})
+	if settings.HttpPort != "0" || settings.HttpsPort != "0" || !settings.UseHttps {
+		t.Errorf("ports %s %s, UseHttps %t", settings.HttpPort, settings.HttpsPort, settings.UseHttps)
+	}
+
+	content, err := os.ReadFile(filepath.Join(settings.Root, "a", "b.txt"))
+	if err != nil || string(content) != "b" {
+		t.Errorf("a/b.txt = %q, %v", content, err)
+	}
+
+	pair, err := tls.LoadX509KeyPair(settings.CertFile, settings.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.VerifyHostname("example.test") != nil || cert.VerifyHostname("::1") != nil {
+		t.Errorf("certificate names %v %v", cert.DNSNames, cert.IPAddresses)
+	}
+}
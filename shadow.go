@@ -0,0 +1,212 @@
+package webserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+type ShadowOptions struct {
+	// Methods are sent to both handlers, other methods only reach the primary handler
+	Methods []string
+	// MaxBodySize limits the request and response bodies kept for comparison, larger requests are not shadowed
+	MaxBodySize int64
+	// IgnoreHeaders are response headers not compared, e.g. Date or X-Request-Id
+	IgnoreHeaders []string
+	// OnDivergence is called for every request whose responses differ, the default logs the divergence
+	OnDivergence func(req *http.Request, divergence ShadowDivergence)
+}
+
+func NewShadowOptions() *ShadowOptions {
+	return &ShadowOptions{
+		Methods: []string{
+			http.MethodPost,
+			http.MethodPut,
+			http.MethodPatch,
+			http.MethodDelete,
+		},
+		MaxBodySize:   1024 * 1024,
+		IgnoreHeaders: []string{"Date"},
+	}
+}
+
+// ShadowDivergence describes how the response of the shadow handler differs from the served one
+type ShadowDivergence struct {
+	Status       int
+	ShadowStatus int
+	// Headers are the names of the headers with different values
+	Headers     []string
+	BodyDiffers bool
+	// Panic is the value the shadow handler panicked with
+	Panic any
+}
+
+func (divergence ShadowDivergence) String() string {
+	var parts []string
+	if divergence.Panic != nil {
+		parts = append(parts, fmt.Sprintf("panic %v", divergence.Panic))
+	}
+	if divergence.Status != divergence.ShadowStatus {
+		parts = append(parts, "status "+strconv.Itoa(divergence.Status)+" != "+strconv.Itoa(divergence.ShadowStatus))
+	}
+	if len(divergence.Headers) > 0 {
+		parts = append(parts, "headers "+strings.Join(divergence.Headers, ", "))
+	}
+	if divergence.BodyDiffers {
+		parts = append(parts, "body")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// NewShadowHandler serves requests with primary and replays the shadowed methods against shadow afterwards.
+// The client always receives the response of primary, differences of the shadow response are reported to OnDivergence.
+// This allows migrating a route to a new backend while the old one stays authoritative.
+func (webServer *WebServer) NewShadowHandler(primary http.Handler, shadow http.Handler, options ShadowOptions) http.Handler {
+	if options.OnDivergence == nil {
+		options.OnDivergence = func(req *http.Request, divergence ShadowDivergence) {
+			webServer.logger.Println("Shadow: " + req.Method + " " + req.URL.Path + ": " + divergence.String())
+		}
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if !slices.Contains(options.Methods, req.Method) || req.ContentLength > options.MaxBodySize {
+			primary.ServeHTTP(rw, req)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(req.Body, options.MaxBodySize+1))
+		if err != nil {
+			webServer.bodyError(rw, req, err)
+			return
+		}
+		if int64(len(body)) > options.MaxBodySize {
+			req.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), req.Body), Closer: req.Body}
+			primary.ServeHTTP(rw, req)
+			return
+		}
+
+		shadowReq := req.Clone(context.WithoutCancel(req.Context()))
+		shadowReq.Body = io.NopCloser(bytes.NewReader(body))
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		served := &shadowedWriter{responseWriter: newResponseWriter(rw)}
+		served.capture = &bytes.Buffer{}
+		served.maxCapture = options.MaxBodySize
+		primary.ServeHTTP(served, req)
+		if served.header == nil {
+			served.header = served.Header().Clone()
+		}
+
+		go func() {
+			shadowed := newRecordingWriter(options.MaxBodySize)
+			divergence := ShadowDivergence{Status: served.Status()}
+			func() {
+				defer func() {
+					divergence.Panic = recover()
+				}()
+				shadow.ServeHTTP(shadowed, shadowReq)
+			}()
+
+			divergence.ShadowStatus = shadowed.Status()
+			divergence.Headers = diffHeaders(served.header, shadowed.Header(), options.IgnoreHeaders)
+			divergence.BodyDiffers = served.truncated != shadowed.truncated || !bytes.Equal(served.capture.Bytes(), shadowed.body.Bytes())
+			if divergence.Panic != nil || divergence.Status != divergence.ShadowStatus || len(divergence.Headers) > 0 || divergence.BodyDiffers {
+				options.OnDivergence(shadowReq, divergence)
+			}
+		}()
+	})
+}
+
+// shadowedWriter keeps the headers as set by the handler, before the server adds its own
+type shadowedWriter struct {
+	*responseWriter
+	header http.Header
+}
+
+func (rw *shadowedWriter) WriteHeader(status int) {
+	if rw.header == nil {
+		rw.header = rw.Header().Clone()
+	}
+	rw.responseWriter.WriteHeader(status)
+}
+
+func (rw *shadowedWriter) Write(b []byte) (int, error) {
+	if rw.header == nil {
+		rw.header = rw.Header().Clone()
+	}
+	return rw.responseWriter.Write(b)
+}
+
+// recordingWriter keeps a response in memory instead of sending it
+type recordingWriter struct {
+	header    http.Header
+	status    int
+	body      bytes.Buffer
+	maxBody   int64
+	truncated bool
+}
+
+func newRecordingWriter(maxBody int64) *recordingWriter {
+	return &recordingWriter{header: http.Header{}, maxBody: maxBody}
+}
+
+func (rw *recordingWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	remaining := rw.maxBody - int64(rw.body.Len())
+	if int64(len(b)) > remaining {
+		rw.truncated = true
+		rw.body.Write(b[:max(remaining, 0)])
+	} else {
+		rw.body.Write(b)
+	}
+	return len(b), nil
+}
+
+func (rw *recordingWriter) Status() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
+
+// diffHeaders returns the sorted canonical names of the headers whose values differ between a and b
+func diffHeaders(a http.Header, b http.Header, ignore []string) []string {
+	var names []string
+	check := func(name string) {
+		name = http.CanonicalHeaderKey(name)
+		if slices.Contains(names, name) || slices.ContainsFunc(ignore, func(ignored string) bool {
+			return strings.EqualFold(ignored, name)
+		}) {
+			return
+		}
+		if !slices.Equal(a.Values(name), b.Values(name)) {
+			names = append(names, name)
+		}
+	}
+	for name := range a {
+		check(name)
+	}
+	for name := range b {
+		check(name)
+	}
+	slices.Sort(names)
+	return names
+}
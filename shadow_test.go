@@ -0,0 +1,74 @@
+package webserver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShadowHandler(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	primary := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		rw.Header().Set("Date", time.Now().String())
+		rw.Header().Set("X-Version", "1")
+		_, _ = rw.Write(body)
+	})
+	shadow := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		rw.Header().Set("Date", "later")
+		switch string(body) {
+		case "same":
+			rw.Header().Set("X-Version", "1")
+			_, _ = rw.Write(body)
+		case "status":
+			rw.Header().Set("X-Version", "1")
+			rw.WriteHeader(http.StatusConflict)
+			_, _ = rw.Write(body)
+		case "panic":
+			panic("not implemented")
+		default:
+			rw.Header().Set("X-Version", "2")
+			_, _ = rw.Write([]byte("changed"))
+		}
+	})
+
+	divergences := make(chan ShadowDivergence, 1)
+	options := NewShadowOptions()
+	options.OnDivergence = func(req *http.Request, divergence ShadowDivergence) {
+		divergences <- divergence
+	}
+	handler := webServer.NewShadowHandler(primary, shadow, *options)
+
+	tests := []struct {
+		method, body string
+		divergence   string
+	}{
+		{http.MethodPost, "same", ""},
+		{http.MethodGet, "other", ""},
+		{http.MethodPut, "status", "status 200 != 409"},
+		{http.MethodPost, "other", "headers X-Version; body"},
+		{http.MethodDelete, "panic", "panic not implemented; headers X-Version; body"},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(test.method, "/", strings.NewReader(test.body)))
+		if rec.Body.String() != test.body {
+			t.Errorf("%s %s: served %q", test.method, test.body, rec.Body.String())
+		}
+
+		var divergence string
+		select {
+		case d := <-divergences:
+			divergence = d.String()
+		case <-time.After(50 * time.Millisecond):
+		}
+		if divergence != test.divergence {
+			t.Errorf("%s %s: divergence %q, want %q", test.method, test.body, divergence, test.divergence)
+		}
+	}
+}
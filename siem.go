@@ -0,0 +1,297 @@
+package webserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+type SIEMFormat string
+
+const (
+	// SIEMFormatJSON writes one json object per line (JSON Lines)
+	SIEMFormatJSON SIEMFormat = "json"
+	// SIEMFormatCEF writes ArcSight Common Event Format lines
+	SIEMFormatCEF SIEMFormat = "cef"
+)
+
+type SIEMOptions struct {
+	Format SIEMFormat
+	// Sink receives one Write per event, see NewSIEMFileSink, NewSIEMHTTPSink and DialSyslogSink
+	Sink io.Writer
+	// Vendor, Product and Version fill the CEF header
+	Vendor  string
+	Product string
+	Version string
+	// Events are the names of the streamed events, empty streams every security event
+	Events []string
+}
+
+func NewSIEMOptions(format SIEMFormat, sink io.Writer) *SIEMOptions {
+	return &SIEMOptions{
+		Format:  format,
+		Sink:    sink,
+		Vendor:  "Nikkolix",
+		Product: "webserver",
+		Version: "1.0",
+	}
+}
+
+// siemStream is a running StreamSecurityEvents, Shutdown stops it and closes its sink
+type siemStream struct {
+	unsubscribe func()
+	sink        io.Writer
+}
+
+// StreamSecurityEvents writes the security events, failed and suspicious logins, lockouts, two factor failures,
+// rate limit trips, blocked addresses, rejected tokens and honeypot hits, to options.Sink until stop is called or
+// the server shuts down, which also closes the sink if it is an io.Closer. Failed writes are logged, the event is lost.
+func (webServer *WebServer) StreamSecurityEvents(options SIEMOptions) (stop func(), err error) {
+	if options.Sink == nil {
+		return nil, errors.New("siem sink is nil")
+	}
+	if options.Format != SIEMFormatJSON && options.Format != SIEMFormatCEF {
+		return nil, errors.New("unknown siem format: " + string(options.Format))
+	}
+
+	stream := &siemStream{sink: options.Sink}
+	stream.unsubscribe = webServer.Subscribe(func(event Event) {
+		if len(options.Events) > 0 && !slices.Contains(options.Events, event.EventName()) {
+			return
+		}
+		line, ok := formatSecurityEvent(options, event, time.Now())
+		if !ok {
+			return
+		}
+		_, err := options.Sink.Write(line)
+		if err != nil {
+			webServer.logger.Println("SIEM: " + event.EventName() + ": " + err.Error())
+		}
+	})
+	webServer.siemMutex.Lock()
+	webServer.siemStreams = append(webServer.siemStreams, stream)
+	webServer.siemMutex.Unlock()
+
+	return func() {
+		webServer.siemMutex.Lock()
+		webServer.siemStreams = slices.DeleteFunc(webServer.siemStreams, func(running *siemStream) bool { return running == stream })
+		webServer.siemMutex.Unlock()
+		stream.unsubscribe()
+	}, nil
+}
+
+// siemField is a field of a security event with its CEF extension key and json name
+type siemField struct {
+	cef   string
+	json  string
+	value string
+}
+
+// securityEvent returns the CEF severity (0-10) and the fields of event, ok is false for other events
+func securityEvent(event Event) (severity int, fields []siemField, ok bool) {
+	switch e := event.(type) {
+	case LoginFailed:
+		return 5, []siemField{{"suser", "identity", e.Identity}, {"src", "ip", e.IP},
+			{"cnt", "failures", strconv.Itoa(e.Failures)}}, true
+	case AccountLocked:
+		return 8, []siemField{{"suser", "identity", e.Identity}, {"src", "ip", e.IP},
+			{"cnt", "failures", strconv.Itoa(e.Failures)}, {"end", "until", e.Until.UTC().Format(time.RFC3339)}}, true
+	case SuspiciousLogin:
+		var reasons []string
+		if e.NewIP {
+			reasons = append(reasons, "new ip")
+		}
+		if e.NewDevice {
+			reasons = append(reasons, "new device")
+		}
+		return 6, []siemField{{"suser", "identity", e.Identity}, {"src", "ip", e.IP},
+			{"requestClientApplication", "user_agent", e.UserAgent}, {"msg", "reason", strings.Join(reasons, ", ")}}, true
+	case TwoFactorFailed:
+		return 6, []siemField{{"suser", "identity", e.Identity}, {"src", "ip", e.IP}}, true
+	case RecoveryCodeUsed:
+		return 4, []siemField{{"suser", "identity", e.Identity}, {"src", "ip", e.IP},
+			{"cnt", "remaining", strconv.Itoa(e.Remaining)}}, true
+	case RateLimited:
+		return 4, []siemField{{"src", "ip", e.IP}, {"request", "path", e.Path}, {"msg", "key", e.Key}}, true
+	case IPBlocked:
+		return 7, []siemField{{"src", "ip", e.IP}, {"request", "path", e.Path}}, true
+	case AuthFailed:
+		return 5, []siemField{{"src", "ip", e.IP}, {"request", "path", e.Path}, {"app", "scheme", e.Scheme},
+			{"msg", "reason", e.Reason}}, true
+	case HoneypotHit:
+		return 7, []siemField{{"src", "ip", e.IP}, {"requestMethod", "method", e.Method}, {"request", "path", e.Path},
+			{"requestClientApplication", "user_agent", e.UserAgent}}, true
+	}
+	return 0, nil, false
+}
+
+// formatSecurityEvent returns event as line in options.Format, ok is false if event is no security event
+func formatSecurityEvent(options SIEMOptions, event Event, now time.Time) (line []byte, ok bool) {
+	severity, fields, ok := securityEvent(event)
+	if !ok {
+		return nil, false
+	}
+
+	if options.Format == SIEMFormatJSON {
+		document := map[string]any{
+			"time":     now.UTC().Format(time.RFC3339Nano),
+			"event":    event.EventName(),
+			"severity": severity,
+		}
+		for _, field := range fields {
+			if field.value != "" {
+				document[field.json] = field.value
+			}
+		}
+		line, err := json.Marshal(document)
+		if err != nil {
+			return nil, false
+		}
+		return append(line, '\n'), true
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString("CEF:0")
+	for _, value := range []string{options.Vendor, options.Product, options.Version, event.EventName(), event.EventName()} {
+		buffer.WriteByte('|')
+		buffer.WriteString(cefHeaderEscaper.Replace(value))
+	}
+	buffer.WriteString("|" + strconv.Itoa(severity) + "|rt=" + strconv.FormatInt(now.UnixMilli(), 10))
+	for _, field := range fields {
+		if field.value != "" {
+			buffer.WriteString(" " + field.cef + "=" + cefValueEscaper.Replace(field.value))
+		}
+	}
+	buffer.WriteByte('\n')
+	return buffer.Bytes(), true
+}
+
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefValueEscaper  = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// NewSIEMFileSink opens path for appending security events, Shutdown closes the file of a running stream
+func NewSIEMFileSink(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+}
+
+// SIEMHTTPSink posts every event to Url, e.g. a Splunk HTTP Event Collector or a Logstash http input
+type SIEMHTTPSink struct {
+	Url         string
+	ContentType string
+	// Header is added to every request, e.g. an Authorization header
+	Header http.Header
+	Client *http.Client
+}
+
+func NewSIEMHTTPSink(url string) *SIEMHTTPSink {
+	return &SIEMHTTPSink{
+		Url:         url,
+		ContentType: "application/json",
+		Header:      http.Header{},
+		Client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (sink *SIEMHTTPSink) Write(p []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, sink.Url, bytes.NewReader(p))
+	if err != nil {
+		return 0, err
+	}
+	for name, values := range sink.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", sink.ContentType)
+
+	resp, err := sink.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, errors.New("unexpected status " + resp.Status + " from " + sink.Url)
+	}
+	return len(p), nil
+}
+
+// SyslogSink sends every event as RFC 5424 syslog message with facility authpriv and severity notice
+type SyslogSink struct {
+	network string
+	address string
+	tag     string
+
+	mutex sync.Mutex
+	conn  net.Conn
+}
+
+// DialSyslogSink connects to the syslog server at address over network ("udp" or "tcp"),
+// tag is the app name of the messages
+func DialSyslogSink(network string, address string, tag string) (*SyslogSink, error) {
+	sink := &SyslogSink{network: network, address: address, tag: tag}
+	err := sink.dial()
+	if err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+func (sink *SyslogSink) dial() error {
+	conn, err := net.DialTimeout(sink.network, sink.address, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	sink.conn = conn
+	return nil
+}
+
+// syslogPriority is facility authpriv (10) and severity notice (5)
+const syslogPriority = 10*8 + 5
+
+func (sink *SyslogSink) Write(p []byte) (int, error) {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	message := "<" + strconv.Itoa(syslogPriority) + ">1 " + time.Now().UTC().Format(time.RFC3339Nano) + " " +
+		hostname + " " + sink.tag + " " + strconv.Itoa(os.Getpid()) + " - - " + strings.TrimRight(string(p), "\n")
+	if sink.network == "tcp" {
+		// octet counting framing, RFC 6587
+		message = strconv.Itoa(len(message)) + " " + message
+	}
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	_, err = sink.conn.Write([]byte(message))
+	if err != nil {
+		// the server may have closed the connection, try once on a new one
+		_ = sink.conn.Close()
+		err = sink.dial()
+		if err != nil {
+			return 0, err
+		}
+		_, err = sink.conn.Write([]byte(message))
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (sink *SyslogSink) Close() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	return sink.conn.Close()
+}
@@ -0,0 +1,109 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lineWriter passes every write to a channel
+type lineWriter chan string
+
+func (writer lineWriter) Write(p []byte) (int, error) {
+	writer <- string(p)
+	return len(p), nil
+}
+
+func TestStreamSecurityEvents(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	webServer.EnableRateLimit(*NewRateLimitOptions(0.001, 1))
+	webServer.SetHoneypot("/.env")
+
+	lines := make(lineWriter, 10)
+	options := NewSIEMOptions(SIEMFormatJSON, lines)
+	options.Events = []string{"honeypot_hit", "rate_limited"}
+	stop, err := webServer.StreamSecurityEvents(*options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	webServer.emit(RouteNotFound{Method: http.MethodGet, Path: "/"})
+
+	for _, path := range []string{"/.env", "/"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		req.Header.Set("User-Agent", "scanner")
+		webServer.mux.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	want := []map[string]any{
+		{"event": "honeypot_hit", "severity": 7.0, "ip": "10.0.0.1", "method": "GET", "path": "/.env", "user_agent": "scanner"},
+		{"event": "rate_limited", "severity": 4.0, "ip": "10.0.0.1", "path": "/", "key": "10.0.0.1"},
+	}
+	for _, fields := range want {
+		var line string
+		select {
+		case line = <-lines:
+		case <-time.After(time.Second):
+			t.Fatalf("%s not streamed", fields["event"])
+		}
+		var document map[string]any
+		err := json.Unmarshal([]byte(line), &document)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key, value := range fields {
+			if document[key] != value {
+				t.Errorf("%s: %s = %v, want %v", fields["event"], key, document[key], value)
+			}
+		}
+	}
+}
+
+func TestFormatSecurityEventCEF(t *testing.T) {
+	options := NewSIEMOptions(SIEMFormatCEF, nil)
+	now := time.UnixMilli(1700000000000)
+	line, ok := formatSecurityEvent(*options, AuthFailed{Scheme: "JWT", IP: "10.0.0.1", Path: "/api", Reason: "claim a=b"}, now)
+	want := `CEF:0|Nikkolix|webserver|1.0|auth_failed|auth_failed|5|rt=1700000000000 src=10.0.0.1 request=/api app=JWT msg=claim a\=b` + "\n"
+	if !ok || string(line) != want {
+		t.Errorf("line = %q, want %q", line, want)
+	}
+
+	_, ok = formatSecurityEvent(*options, RouteNotFound{}, now)
+	if ok {
+		t.Error("RouteNotFound formatted as security event")
+	}
+}
+
+func TestSyslogSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sink, err := DialSyslogSink("udp", conn.LocalAddr().String(), "webserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	_, err = sink.Write([]byte("CEF:0|a|b|c|d|e|5|\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := make([]byte, 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := string(buffer[:n])
+	if !strings.HasPrefix(message, "<85>1 ") || !strings.Contains(message, " webserver ") || !strings.HasSuffix(message, " - - CEF:0|a|b|c|d|e|5|") {
+		t.Errorf("message = %q", message)
+	}
+}
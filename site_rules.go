@@ -0,0 +1,181 @@
+package webserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// siteRules are the _redirects and _headers files of a root, in the syntax Netlify uses
+type siteRules struct {
+	root      string
+	redirects []redirectRule
+	headers   []headerRule
+}
+
+type redirectRule struct {
+	from   string
+	to     string
+	status int
+	// force applies the rule even if a file exists at the path
+	force bool
+}
+
+type headerRule struct {
+	pattern string
+	header  http.Header
+}
+
+type siteRewriteKey struct{}
+
+// siteRules returns the rules of settings.Root, parsing them again after the root changed
+func (webServer *WebServer) siteRules(settings Settings) *siteRules {
+	rules := webServer.rules.Load()
+	if rules != nil && rules.root == settings.Root {
+		return rules
+	}
+	rules = &siteRules{root: settings.Root}
+	rootDir := rootDir(settings.Root)
+	if data, err := os.ReadFile(filepath.Join(rootDir, "_redirects")); err == nil {
+		rules.redirects = webServer.parseRedirects(data)
+	}
+	if data, err := os.ReadFile(filepath.Join(rootDir, "_headers")); err == nil {
+		rules.headers = parseHeaderRules(data)
+	}
+	webServer.rules.Store(rules)
+	return rules
+}
+
+// parseRedirects reads lines of "from to [status][!]", the status defaults to 301 and 200 rewrites without redirect
+func (webServer *WebServer) parseRedirects(data []byte) []redirectRule {
+	rules := []redirectRule{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 {
+			webServer.logger.Println("Site Rules: _redirects line " + strconv.Itoa(line) + ": missing target")
+			continue
+		}
+		rule := redirectRule{from: fields[0], to: fields[1], status: http.StatusMovedPermanently}
+		if len(fields) > 2 {
+			status, force := strings.CutSuffix(fields[2], "!")
+			code, err := strconv.Atoi(status)
+			if err != nil {
+				webServer.logger.Println("Site Rules: _redirects line " + strconv.Itoa(line) + ": invalid status " + fields[2])
+				continue
+			}
+			rule.status, rule.force = code, force
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// parseHeaderRules reads path lines followed by indented "Name: value" lines
+func parseHeaderRules(data []byte) []headerRule {
+	rules := []headerRule{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			rules = append(rules, headerRule{pattern: trimmed, header: http.Header{}})
+			continue
+		}
+		name, value, ok := strings.Cut(trimmed, ":")
+		if ok && len(rules) > 0 {
+			rules[len(rules)-1].header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+	}
+	return rules
+}
+
+// matchSitePattern matches path against a pattern with ":name" segments and a trailing "*" and returns the values
+func matchSitePattern(pattern string, path string) (map[string]string, bool) {
+	values := map[string]string{}
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range patternSegments {
+		if segment == "*" && i == len(patternSegments)-1 {
+			if i < len(pathSegments) {
+				values["splat"] = strings.Join(pathSegments[i:], "/")
+			}
+			return values, true
+		}
+		if i >= len(pathSegments) {
+			return nil, false
+		}
+		if strings.HasPrefix(segment, ":") {
+			values[segment[1:]] = pathSegments[i]
+		} else if segment != pathSegments[i] {
+			return nil, false
+		}
+	}
+	return values, len(patternSegments) == len(pathSegments)
+}
+
+// expandTarget replaces ":name" and ":splat" in target with the values of the match
+func expandTarget(target string, values map[string]string) string {
+	for name, value := range values {
+		target = strings.ReplaceAll(target, ":"+name, value)
+	}
+	return target
+}
+
+func (rules *siteRules) setHeaders(header http.Header, path string) {
+	for _, rule := range rules.headers {
+		if _, ok := matchSitePattern(rule.pattern, path); ok {
+			for name, values := range rule.header {
+				header[name] = append(header[name], values...)
+			}
+		}
+	}
+}
+
+// applyRedirects answers req with the first matching rule. Before the file at the path was looked up only forced rules apply,
+// the others only apply if there is no file.
+func (webServer *WebServer) applyRedirects(rw http.ResponseWriter, req *http.Request, settings Settings, rules *siteRules, forcedOnly bool) bool {
+	if req.Context().Value(siteRewriteKey{}) != nil {
+		return false
+	}
+	for _, rule := range rules.redirects {
+		if forcedOnly && !rule.force {
+			continue
+		}
+		values, ok := matchSitePattern(rule.from, req.URL.Path)
+		if !ok {
+			continue
+		}
+		target := expandTarget(rule.to, values)
+
+		switch {
+		case rule.status >= 300 && rule.status < 400:
+			http.Redirect(rw, req, target, rule.status)
+			webServer.logger.Println("Site Rules: " + strconv.Itoa(rule.status) + ": " + req.URL.Path + " to " + target)
+		case rule.status == http.StatusOK:
+			rewritten := req.Clone(context.WithValue(req.Context(), siteRewriteKey{}, true))
+			targetPath, query, _ := strings.Cut(target, "?")
+			rewritten.URL.Path, rewritten.URL.RawPath = targetPath, ""
+			if query != "" {
+				rewritten.URL.RawQuery = query
+			}
+			webServer.logger.Println("Site Rules: rewrite " + req.URL.Path + " to " + target)
+			webServer.fileHandler(rw, rewritten)
+		default:
+			webServer.serveFallbackFile(rw, req, settings, target, rule.status)
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSiteRules(t *testing.T) {
+	root, err := os.MkdirTemp(".", "site-rules-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(root) }()
+	files := map[string]string{
+		"index.html":      "home",
+		"404.html":        "custom not found",
+		"app/index.html":  "app shell",
+		"old.html":        "old page",
+		"blog/hello.html": "hello",
+		"_redirects": `# comment
+/old.html          /new.html                 301!
+/news/:year/:slug  /blog/:slug.html          302
+/docs/*            https://docs.example.com/:splat
+/app/*             /app/index.html           200
+/*                 /404.html                 404
+`,
+		"_headers": `/*
+  X-Frame-Options: DENY
+/blog/*
+  Cache-Control: public, max-age=600
+`,
+	}
+	for name, content := range files {
+		_ = os.MkdirAll(filepath.Join(root, filepath.Dir(name)), 0o755)
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	settings := NewSettings()
+	settings.Root = root
+	webServer := NewWebServer(*settings)
+
+	tests := []struct {
+		path, body, location, cacheControl string
+		status                             int
+	}{
+		{"/index.html", "home", "", "", http.StatusOK},
+		{"/old.html", "", "/new.html", "", http.StatusMovedPermanently},
+		{"/news/2024/hello", "", "/blog/hello.html", "", http.StatusFound},
+		{"/blog/hello.html", "hello", "", "public, max-age=600", http.StatusOK},
+		{"/docs/api/v1", "", "https://docs.example.com/api/v1", "", http.StatusMovedPermanently},
+		{"/app/settings/profile", "app shell", "", "", http.StatusOK},
+		{"/missing.png", "custom not found", "", "", http.StatusNotFound},
+		{"/_redirects", "", "", "", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if rec.Code != test.status || rec.Header().Get("Location") != test.location || rec.Header().Get("Cache-Control") != test.cacheControl {
+			t.Errorf("%s: %d %q %q, want %d %q %q", test.path, rec.Code, rec.Header().Get("Location"), rec.Header().Get("Cache-Control"), test.status, test.location, test.cacheControl)
+		}
+		if test.body != "" && rec.Body.String() != test.body {
+			t.Errorf("%s: body %q, want %q", test.path, rec.Body.String(), test.body)
+		}
+		if rec.Header().Get("X-Frame-Options") != "DENY" {
+			t.Errorf("%s: X-Frame-Options %q", test.path, rec.Header().Get("X-Frame-Options"))
+		}
+	}
+}
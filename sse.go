@@ -0,0 +1,117 @@
+package webserver
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sseHeartbeat is the interval of the comment lines keeping idle streams open through proxies
+const sseHeartbeat = 30 * time.Second
+
+// SSEEvent is one server-sent event
+type SSEEvent struct {
+	// ID is sent back by the browser as Last-Event-ID when it reconnects, see SSELastEventID
+	ID string
+	// Event is the event type, empty for the default type "message"
+	Event string
+	Data  string
+	// Retry tells the browser how long to wait before reconnecting
+	Retry time.Duration
+}
+
+type sseLastEventIDKey struct{}
+
+// SSELastEventID returns the id of the last event a reconnecting client received, empty for new clients
+func SSELastEventID(ctx context.Context) string {
+	id, _ := ctx.Value(sseLastEventIDKey{}).(string)
+	return id
+}
+
+// NewSSEHandler streams the events source sends as text/event-stream on GET requests to pattern.
+// The context passed to source is cancelled once the client disconnected or a write failed, source should return then.
+// The write deadline of Settings.WriteTimeout is lifted for the stream.
+func (webServer *WebServer) NewSSEHandler(pattern string, source func(ctx context.Context, send func(SSEEvent))) {
+	webServer.NewHandleFunc(HTTPMethodGet, pattern, func(rw http.ResponseWriter, req *http.Request) {
+		controller := http.NewResponseController(rw)
+		_ = controller.SetWriteDeadline(time.Time{})
+
+		header := rw.Header()
+		header.Set("Content-Type", "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+		header.Set("X-Accel-Buffering", "no")
+		rw.WriteHeader(http.StatusOK)
+		err := controller.Flush()
+		if err != nil {
+			webServer.logger.Println("SSE: " + err.Error())
+			return
+		}
+
+		ctx, cancel := context.WithCancel(context.WithValue(req.Context(), sseLastEventIDKey{}, req.Header.Get("Last-Event-ID")))
+		defer cancel()
+
+		var mutex sync.Mutex
+		write := func(data string) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if ctx.Err() != nil {
+				return
+			}
+			_, err := rw.Write([]byte(data))
+			if err == nil {
+				err = controller.Flush()
+			}
+			if err != nil {
+				cancel()
+			}
+		}
+
+		go func() {
+			ticker := time.NewTicker(sseHeartbeat)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					write(": heartbeat\n\n")
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		source(ctx, func(event SSEEvent) {
+			write(formatSSEEvent(event))
+		})
+
+		// the heartbeat must not write after the handler returned
+		mutex.Lock()
+		cancel()
+		mutex.Unlock()
+	})
+}
+
+func formatSSEEvent(event SSEEvent) string {
+	var builder strings.Builder
+	if event.ID != "" {
+		builder.WriteString("id: " + stripNewlines(event.ID) + "\n")
+	}
+	if event.Event != "" {
+		builder.WriteString("event: " + stripNewlines(event.Event) + "\n")
+	}
+	if event.Retry > 0 {
+		builder.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+	data := strings.ReplaceAll(event.Data, "\r\n", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		builder.WriteString("data: " + line + "\n")
+	}
+	builder.WriteString("\n")
+	return builder.String()
+}
+
+func stripNewlines(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
@@ -0,0 +1,54 @@
+package webserver
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSEHandler(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	stopped := make(chan struct{})
+	webServer.NewSSEHandler("/events", func(ctx context.Context, send func(SSEEvent)) {
+		defer close(stopped)
+		send(SSEEvent{ID: "1", Event: "greeting", Data: "hello\nworld", Retry: 3 * time.Second})
+		send(SSEEvent{ID: "2", Data: "after " + SSELastEventID(ctx)})
+		<-ctx.Done()
+	})
+
+	server := httptest.NewServer(webServer.mux)
+	defer server.Close()
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/events", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Header.Get("Content-Type") != "text/event-stream" || res.Header.Get("Cache-Control") != "no-cache" {
+		t.Errorf("headers %v", res.Header)
+	}
+
+	want := "id: 1\nevent: greeting\nretry: 3000\ndata: hello\ndata: world\n\nid: 2\ndata: after 0\n\n"
+	reader := bufio.NewReader(res.Body)
+	got := make([]byte, len(want))
+	_, err = io.ReadFull(reader, got)
+	if err != nil || string(got) != want {
+		t.Errorf("stream %q %v, want %q", got, err, want)
+	}
+
+	_ = res.Body.Close()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("source not cancelled after disconnect")
+	}
+
+	if s := formatSSEEvent(SSEEvent{Event: "a\nb", Data: ""}); !strings.HasPrefix(s, "event: ab\ndata: \n") {
+		t.Errorf("format %q", s)
+	}
+}
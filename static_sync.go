@@ -0,0 +1,400 @@
+package webserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+var ErrSyncHashMismatch = errors.New("sync: file does not match the manifest hash")
+
+// SyncEntry is a file of a SyncManifest, Path is slash separated and relative to the synced directory
+type SyncEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	SHA256  string    `json:"sha256"`
+}
+
+// SyncManifest lists every file of a directory served by NewSyncHandler, sorted by path
+type SyncManifest struct {
+	Files []SyncEntry `json:"files"`
+}
+
+type SyncOptions struct {
+	// Client fetches the manifest and the files
+	Client *http.Client
+	// Delete removes local files missing in the manifest
+	Delete bool
+	// Interval is the time between two syncs of MirrorDir
+	Interval time.Duration
+}
+
+func NewSyncOptions() *SyncOptions {
+	return &SyncOptions{
+		Client:   &http.Client{Timeout: 5 * time.Minute},
+		Delete:   true,
+		Interval: time.Minute,
+	}
+}
+
+// SyncResult counts the files a sync fetched, deleted and found unchanged
+type SyncResult struct {
+	Fetched   int
+	Deleted   int
+	Unchanged int
+}
+
+// syncHandler serves the manifest and files of root, hashes are cached until size or modification time of a file change.
+// root is resolved like Settings.Root so the manifest lists the files resolvePath finds.
+type syncHandler struct {
+	webServer *WebServer
+	prefix    string
+	root      string
+	mutex     sync.Mutex
+	hashes    map[string]SyncEntry
+}
+
+// NewSyncHandler serves the directory root for mirroring by SyncDir on other instances:
+// prefix answers with the json SyncManifest and prefix followed by a manifest path with the file.
+// Files of unfinished uploads and files the file handler refuses, i.e. of the FileExtensionFilter, DenyPaths and with
+// BlockDotfiles hidden ones, are neither listed nor served. The endpoint should be protected, e.g. with a PhaseAuth middleware.
+func (webServer *WebServer) NewSyncHandler(prefix string, root string) {
+	prefix = "/" + strings.Trim(prefix, "/") + "/"
+	webServer.handle(http.MethodGet, prefix, "sync "+root, &syncHandler{
+		webServer: webServer,
+		prefix:    prefix,
+		root:      rootDir(root),
+		hashes:    map[string]SyncEntry{},
+	})
+}
+
+func (handler *syncHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	webServer := handler.webServer
+	name := strings.TrimPrefix(req.URL.Path, handler.prefix)
+	if name == "" {
+		manifest, err := handler.manifest()
+		if err != nil {
+			webServer.writeErrorCause(rw, req, http.StatusInternalServerError, err)
+			webServer.logger.Println("Sync: 500: " + err.Error())
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Cache-Control", "no-store")
+		err = json.NewEncoder(rw).Encode(manifest)
+		if err != nil {
+			webServer.logger.Println("Sync: " + err.Error())
+		}
+		return
+	}
+
+	settings := webServer.Settings()
+	if reason, blocked := syncBlocked(settings, name); blocked {
+		webServer.writeError(rw, req, http.StatusNotFound)
+		webServer.logger.Println("Sync: 404: " + reason + " (" + req.URL.Path + ")")
+		return
+	}
+	filePath, err := resolvePath(handler.root, "/"+name, settings.BlockSymlinkEscape)
+	if errors.Is(err, errPathTraversal) {
+		webServer.writeErrorCause(rw, req, http.StatusForbidden, err)
+		webServer.logger.Println("Sync: 403: " + err.Error() + " (" + req.URL.Path + ")")
+		return
+	}
+	var file *os.File
+	var info os.FileInfo
+	if err == nil {
+		file, err = os.Open(filePath)
+	}
+	if err == nil {
+		defer file.Close()
+		info, err = file.Stat()
+		if err == nil && info.IsDir() {
+			err = fs.ErrNotExist
+		}
+	}
+	if errors.Is(err, fs.ErrNotExist) || isPartFile(name) {
+		webServer.writeError(rw, req, http.StatusNotFound)
+		webServer.logger.Println("Sync: 404: " + req.URL.Path)
+		return
+	}
+	if err != nil {
+		webServer.writeErrorCause(rw, req, http.StatusInternalServerError, err)
+		webServer.logger.Println("Sync: 500: " + err.Error())
+		return
+	}
+	rw.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(rw, req, name, info.ModTime(), file)
+}
+
+func (handler *syncHandler) manifest() (SyncManifest, error) {
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+
+	settings := handler.webServer.Settings()
+	manifest := SyncManifest{Files: []SyncEntry{}}
+	seen := map[string]bool{}
+	err := filepath.WalkDir(handler.root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(handler.root, filePath)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if entry.IsDir() {
+			if _, hidden := hiddenPath(name, settings.DotfileExceptions); settings.BlockDotfiles && hidden {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || isPartFile(filePath) {
+			return nil
+		}
+		if _, blocked := syncBlocked(settings, name); blocked {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		seen[name] = true
+
+		cached, ok := handler.hashes[name]
+		if !ok || cached.Size != info.Size() || !cached.ModTime.Equal(info.ModTime()) {
+			hash, err := hashFile(filePath)
+			if err != nil {
+				return err
+			}
+			cached = SyncEntry{Path: name, Size: info.Size(), ModTime: info.ModTime(), SHA256: hash}
+			handler.hashes[name] = cached
+		}
+		manifest.Files = append(manifest.Files, cached)
+		return nil
+	})
+	if err != nil {
+		return SyncManifest{}, err
+	}
+	for name := range handler.hashes {
+		if !seen[name] {
+			delete(handler.hashes, name)
+		}
+	}
+	return manifest, nil
+}
+
+// syncBlocked returns why the file handler refuses the file name: its FileExtensionFilter extension, the DenyPaths
+// pattern or, with BlockDotfiles, the hidden segment
+func syncBlocked(settings Settings, name string) (string, bool) {
+	parts := strings.Split(name, ".")
+	if extension := parts[len(parts)-1]; len(parts) > 1 && slices.Contains(settings.FileExtensionFilter, extension) {
+		return extension, true
+	}
+	if pattern, denied := deniedPath(settings.DenyPaths, name); denied {
+		return pattern, true
+	}
+	if hidden, ok := hiddenPath(name, settings.DotfileExceptions); settings.BlockDotfiles && ok {
+		return "hidden " + hidden, true
+	}
+	return "", false
+}
+
+func hashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// SyncDir mirrors the directory served by NewSyncHandler at syncUrl into root.
+// Files whose hash differs from the manifest are fetched, verified and replaced atomically, with options.Delete
+// local files missing in the manifest are removed.
+func SyncDir(ctx context.Context, syncUrl string, root string, options SyncOptions) (SyncResult, error) {
+	var result SyncResult
+	manifest, err := fetchManifest(ctx, syncUrl, options.Client)
+	if err != nil {
+		return result, err
+	}
+	err = os.MkdirAll(root, 0755)
+	if err != nil {
+		return result, err
+	}
+
+	listed := map[string]bool{}
+	for _, entry := range manifest.Files {
+		err := checkTraversal("/" + entry.Path)
+		if err != nil {
+			return result, fmt.Errorf("sync: %s: %w", entry.Path, err)
+		}
+		listed[entry.Path] = true
+
+		filePath := filepath.Join(root, filepath.FromSlash(entry.Path))
+		info, err := os.Stat(filePath)
+		if err == nil && info.Size() == entry.Size {
+			hash, err := hashFile(filePath)
+			if err == nil && hash == entry.SHA256 {
+				result.Unchanged++
+				continue
+			}
+		}
+
+		err = fetchSyncFile(ctx, syncUrl, entry, filePath, options.Client)
+		if err != nil {
+			return result, err
+		}
+		result.Fetched++
+	}
+
+	if !options.Delete {
+		return result, nil
+	}
+	var stale []string
+	err = filepath.WalkDir(root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		if !listed[filepath.ToSlash(rel)] {
+			stale = append(stale, filePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	sort.Strings(stale)
+	for _, filePath := range stale {
+		err := os.Remove(filePath)
+		if err != nil {
+			return result, err
+		}
+		result.Deleted++
+	}
+	return result, nil
+}
+
+// MirrorDir runs SyncDir every options.Interval until the returned function is called, logging failed syncs
+func (webServer *WebServer) MirrorDir(syncUrl string, root string, options SyncOptions) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(options.Interval)
+		defer ticker.Stop()
+		for {
+			result, err := SyncDir(ctx, syncUrl, root, options)
+			if err != nil && ctx.Err() == nil {
+				webServer.logger.Println("Sync: " + syncUrl + ": " + err.Error())
+			} else if result.Fetched > 0 || result.Deleted > 0 {
+				webServer.logger.Println("Sync: " + syncUrl + ": fetched " + strconv.Itoa(result.Fetched) +
+					", deleted " + strconv.Itoa(result.Deleted))
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func fetchManifest(ctx context.Context, syncUrl string, client *http.Client) (SyncManifest, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(syncUrl, "/")+"/", nil)
+	if err != nil {
+		return SyncManifest{}, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return SyncManifest{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return SyncManifest{}, fmt.Errorf("sync: manifest: %s", res.Status)
+	}
+	var manifest SyncManifest
+	err = json.NewDecoder(res.Body).Decode(&manifest)
+	if err != nil {
+		return SyncManifest{}, fmt.Errorf("sync: manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+func fetchSyncFile(ctx context.Context, syncUrl string, entry SyncEntry, filePath string, client *http.Client) error {
+	fileUrl, err := url.JoinPath(syncUrl, strings.Split(entry.Path, "/")...)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileUrl, nil)
+	if err != nil {
+		return err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("sync: %s: %s", entry.Path, res.Status)
+	}
+
+	err = os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(filePath), ".sync-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(temp, hash), res.Body)
+	if err == nil {
+		err = temp.Chmod(0644)
+	}
+	closeErr := temp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != entry.SHA256 {
+		return fmt.Errorf("%w: %s", ErrSyncHashMismatch, entry.Path)
+	}
+	err = os.Chtimes(temp.Name(), entry.ModTime, entry.ModTime)
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), filePath)
+}
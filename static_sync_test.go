@@ -0,0 +1,117 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSyncDir(t *testing.T) {
+	// absolute roots, the manifest and the file requests have to resolve them the same way
+	primary := t.TempDir()
+	secondary := t.TempDir()
+
+	write := func(root string, name string, content string) {
+		filePath := filepath.Join(root, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(filePath), 0755)
+		if err == nil {
+			err = os.WriteFile(filePath, []byte(content), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(primary, "index.html", "index")
+	write(primary, "css/style.css", "style")
+	write(primary, "same.txt", "same")
+	write(primary, "upload.bin.part", "incomplete")
+	// refused by the file handler, so neither listed nor served
+	write(primary, ".env", "SECRET=1")
+	write(primary, ".git/config", "[core]")
+	write(primary, "private/key.pem", "key")
+	write(primary, ".well-known/security.txt", "contact")
+	write(secondary, "same.txt", "same")
+	write(secondary, "css/style.css", "old")
+	write(secondary, "stale.txt", "stale")
+
+	settings := NewSettings()
+	settings.Root = "root"
+	settings.BlockDotfiles = true
+	settings.DenyPaths = []string{"/private/**"}
+	webServer := NewWebServer(*settings)
+	webServer.NewSyncHandler("/sync", primary)
+	server := httptest.NewServer(webServer.mux)
+	defer server.Close()
+
+	result, err := SyncDir(context.Background(), server.URL+"/sync", secondary, *NewSyncOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (SyncResult{Fetched: 3, Deleted: 1, Unchanged: 1}) {
+		t.Errorf("SyncDir() = %+v", result)
+	}
+	for name, want := range map[string]string{"index.html": "index", "css/style.css": "style", "same.txt": "same", ".well-known/security.txt": "contact"} {
+		data, err := os.ReadFile(filepath.Join(secondary, filepath.FromSlash(name)))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v, want %q", name, data, err, want)
+		}
+	}
+	for _, name := range []string{"stale.txt", "upload.bin.part", ".env", ".git", "private"} {
+		_, err := os.Stat(filepath.Join(secondary, name))
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s exists after sync: %v", name, err)
+		}
+	}
+
+	result, err = SyncDir(context.Background(), server.URL+"/sync", secondary, *NewSyncOptions())
+	if err != nil || result != (SyncResult{Unchanged: 4}) {
+		t.Errorf("second SyncDir() = %+v, %v", result, err)
+	}
+
+	for path, status := range map[string]int{
+		"/sync/css/style.css":     http.StatusOK,
+		"/sync/missing.txt":       http.StatusNotFound,
+		"/sync/upload.bin.part":   http.StatusNotFound,
+		"/sync/.env":              http.StatusNotFound,
+		"/sync/.git/config":       http.StatusNotFound,
+		"/sync/private/key.pem":   http.StatusNotFound,
+		"/sync/..%2fwebserver.go": http.StatusForbidden,
+		"/sync/css":               http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != status {
+			t.Errorf("GET %s: %d, want %d", path, rec.Code, status)
+		}
+	}
+}
+
+func TestSyncDirHashMismatch(t *testing.T) {
+	secondary, err := os.MkdirTemp(".", "sync-secondary-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(secondary)
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/sync/" {
+			_, _ = rw.Write([]byte(`{"files":[{"path":"a.txt","size":1,"sha256":"00"}]}`))
+			return
+		}
+		_, _ = rw.Write([]byte("a"))
+	}))
+	defer server.Close()
+
+	_, err = SyncDir(context.Background(), server.URL+"/sync", secondary, *NewSyncOptions())
+	if !errors.Is(err, ErrSyncHashMismatch) {
+		t.Errorf("SyncDir() = %v, want ErrSyncHashMismatch", err)
+	}
+	entries, _ := os.ReadDir(secondary)
+	if len(entries) != 0 {
+		t.Errorf("files left after a failed fetch: %v", entries)
+	}
+}
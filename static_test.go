@@ -0,0 +1,108 @@
+package webserver
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRangeRequests(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	webServer.SetRootFS(fstest.MapFS{"video.mp4": {Data: []byte("0123456789")}})
+
+	tests := []struct {
+		rangeHeader  string
+		status       int
+		body         string
+		contentRange string
+	}{
+		{"", http.StatusOK, "0123456789", ""},
+		{"bytes=1-2", http.StatusPartialContent, "12", "bytes 1-2/10"},
+		{"bytes=-3", http.StatusPartialContent, "789", "bytes 7-9/10"},
+		{"bytes=8-", http.StatusPartialContent, "89", "bytes 8-9/10"},
+		{"bytes=10-20", http.StatusRequestedRangeNotSatisfiable, "", "bytes */10"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/video.mp4", nil)
+		if test.rangeHeader != "" {
+			req.Header.Set("Range", test.rangeHeader)
+		}
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+
+		if rec.Code != test.status || rec.Header().Get("Content-Range") != test.contentRange {
+			t.Errorf("%q: %d %q, want %d %q", test.rangeHeader, rec.Code, rec.Header().Get("Content-Range"), test.status, test.contentRange)
+		}
+		if test.status != http.StatusRequestedRangeNotSatisfiable && rec.Body.String() != test.body {
+			t.Errorf("%q: body %q, want %q", test.rangeHeader, rec.Body.String(), test.body)
+		}
+		if rec.Header().Get("Accept-Ranges") != "bytes" && test.status == http.StatusOK {
+			t.Errorf("%q: Accept-Ranges %q", test.rangeHeader, rec.Header().Get("Accept-Ranges"))
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/video.mp4", nil)
+	req.Header.Set("Range", "bytes=0-1,5-5")
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, req)
+	mediaType, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if rec.Code != http.StatusPartialContent || err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("multi range: %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	reader := multipart.NewReader(rec.Body, params["boundary"])
+	var parts []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(part)
+		parts = append(parts, string(data)+" "+part.Header.Get("Content-Range"))
+	}
+	if strings.Join(parts, ", ") != "01 bytes 0-1/10, 5 bytes 5-5/10" {
+		t.Errorf("multi range parts %v", parts)
+	}
+}
+
+func TestHeadStaticFiles(t *testing.T) {
+	settings := NewSettings()
+	settings.Root = "root"
+	webServer := NewWebServer(*settings)
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/style.css", http.StatusOK},
+		{"/test.json", http.StatusOK},
+		{"/missing.css", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		get := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(get, httptest.NewRequest(http.MethodGet, test.path, nil))
+		head := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(head, httptest.NewRequest(http.MethodHead, test.path, nil))
+
+		if head.Code != test.status || head.Code != get.Code {
+			t.Errorf("%s: HEAD %d, GET %d, want %d", test.path, head.Code, get.Code, test.status)
+		}
+		if head.Body.Len() != 0 {
+			t.Errorf("%s: HEAD wrote body %q", test.path, head.Body.String())
+		}
+		for _, name := range []string{"Content-Type", "Content-Length", "Last-Modified"} {
+			if head.Header().Get(name) != get.Header().Get(name) {
+				t.Errorf("%s: HEAD %s %q, GET %q", test.path, name, head.Header().Get(name), get.Header().Get(name))
+			}
+		}
+	}
+}
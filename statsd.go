@@ -0,0 +1,137 @@
+package webserver
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxStatsdPacketSize keeps packets below the common ethernet MTU
+const maxStatsdPacketSize = 1432
+
+// StatsdExporter sends metrics over UDP in the StatsD line format, with DogStatsD tags if enabled.
+// Plain StatsD has no tags, so tag values are appended to the metric name in order of their keys.
+type StatsdExporter struct {
+	conn      net.Conn
+	prefix    string
+	dogStatsd bool
+	tags      map[string]string
+
+	mutex  sync.Mutex
+	buffer []byte
+	done   chan struct{}
+}
+
+func NewStatsdExporter(addr string, prefix string, dogStatsd bool, tags map[string]string) (*StatsdExporter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter := &StatsdExporter{
+		conn:      conn,
+		prefix:    prefix,
+		dogStatsd: dogStatsd,
+		tags:      tags,
+		done:      make(chan struct{}),
+	}
+	go exporter.flushLoop(time.Second)
+	return exporter, nil
+}
+
+func (exporter *StatsdExporter) Count(name string, value int64, tags map[string]string) {
+	exporter.send(name, strconv.FormatInt(value, 10), "c", tags)
+}
+
+func (exporter *StatsdExporter) Gauge(name string, value float64, tags map[string]string) {
+	exporter.send(name, strconv.FormatFloat(value, 'f', -1, 64), "g", tags)
+}
+
+func (exporter *StatsdExporter) Timing(name string, value time.Duration, tags map[string]string) {
+	exporter.send(name, strconv.FormatFloat(float64(value)/float64(time.Millisecond), 'f', -1, 64), "ms", tags)
+}
+
+// Close flushes buffered metrics and closes the connection
+func (exporter *StatsdExporter) Close() error {
+	close(exporter.done)
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	exporter.flush()
+	return exporter.conn.Close()
+}
+
+func (exporter *StatsdExporter) send(name string, value string, metricType string, tags map[string]string) {
+	allTags := make(map[string]string, len(exporter.tags)+len(tags))
+	for key, tagValue := range exporter.tags {
+		allTags[key] = tagValue
+	}
+	for key, tagValue := range tags {
+		allTags[key] = tagValue
+	}
+	keys := make([]string, 0, len(allTags))
+	for key := range allTags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	line := sanitizeStatsd(name)
+	if exporter.prefix != "" {
+		line = sanitizeStatsd(exporter.prefix) + "." + line
+	}
+	if !exporter.dogStatsd {
+		for _, key := range keys {
+			line += "." + sanitizeStatsd(allTags[key])
+		}
+	}
+	line += ":" + value + "|" + metricType
+	if exporter.dogStatsd && len(keys) > 0 {
+		pairs := make([]string, 0, len(keys))
+		for _, key := range keys {
+			pairs = append(pairs, sanitizeStatsd(key)+":"+sanitizeStatsd(allTags[key]))
+		}
+		line += "|#" + strings.Join(pairs, ",")
+	}
+
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	if len(exporter.buffer) > 0 && len(exporter.buffer)+1+len(line) > maxStatsdPacketSize {
+		exporter.flush()
+	}
+	if len(exporter.buffer) > 0 {
+		exporter.buffer = append(exporter.buffer, '\n')
+	}
+	exporter.buffer = append(exporter.buffer, line...)
+}
+
+func (exporter *StatsdExporter) flushLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			exporter.mutex.Lock()
+			exporter.flush()
+			exporter.mutex.Unlock()
+		case <-exporter.done:
+			return
+		}
+	}
+}
+
+// flush must be called with the mutex held, send errors are dropped like any lost udp packet
+func (exporter *StatsdExporter) flush() {
+	if len(exporter.buffer) == 0 {
+		return
+	}
+	_, _ = exporter.conn.Write(exporter.buffer)
+	exporter.buffer = exporter.buffer[:0]
+}
+
+var statsdReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", "\n", "_", " ", "_")
+
+func sanitizeStatsd(value string) string {
+	return statsdReplacer.Replace(value)
+}
@@ -0,0 +1,75 @@
+package webserver
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readStatsdPacket(t *testing.T, conn net.PacketConn) string {
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, maxStatsdPacketSize)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestStatsdExporter(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	tests := []struct {
+		dogStatsd bool
+		want      string
+	}{
+		{false, "app.requests.1.2xx:1|c\napp.latency.1.2xx:1.5|ms\napp.queue.1:3|g"},
+		{true, "app.requests:1|c|#instance:1,status_class:2xx\napp.latency:1.5|ms|#instance:1,status_class:2xx\napp.queue:3|g|#instance:1"},
+	}
+
+	for _, test := range tests {
+		exporter, err := NewStatsdExporter(conn.LocalAddr().String(), "app", test.dogStatsd, map[string]string{"instance": "1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		exporter.Count("requests", 1, map[string]string{"status_class": "2xx"})
+		exporter.Timing("latency", 1500*time.Microsecond, map[string]string{"status_class": "2xx"})
+		exporter.Gauge("queue", 3, nil)
+		_ = exporter.Close()
+
+		if got := readStatsdPacket(t, conn); got != test.want {
+			t.Errorf("dogStatsd %v: packet = %q, want %q", test.dogStatsd, got, test.want)
+		}
+	}
+}
+
+func TestStatsdExporterRequestMetrics(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	settings := NewSettings()
+	settings.MetricsBackend = MetricsBackendDogStatsd
+	settings.MetricsAddr = conn.LocalAddr().String()
+	webServer := NewWebServer(*settings)
+	webServer.NewHandleFunc(HTTPMethodPost, "/items/{id}", func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusAccepted)
+	})
+
+	webServer.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/items/42", nil))
+	_ = webServer.metrics.(*StatsdExporter).Close()
+
+	packet := readStatsdPacket(t, conn)
+	if !strings.Contains(packet, "webserver.requests:1|c|#method:POST,route:/items/{id},status_class:2xx") {
+		t.Errorf("packet = %q", packet)
+	}
+}
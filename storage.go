@@ -0,0 +1,112 @@
+package webserver
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// StaticStorage is a backend the file handler reads static files from instead of Settings.Root
+type StaticStorage interface {
+	// ReadFile returns the file at the slash separated name without leading slash.
+	// Missing files are reported as *fs.PathError wrapping fs.ErrNotExist.
+	ReadFile(ctx context.Context, name string) ([]byte, error)
+}
+
+// modTimeStorage is implemented by storages which know when a file was modified, it is sent as Last-Modified
+type modTimeStorage interface {
+	ModTime(ctx context.Context, name string) (time.Time, error)
+}
+
+// storageModTime returns the modification time of the file name of storage, zero if it is unknown
+func storageModTime(ctx context.Context, storage StaticStorage, name string) time.Time {
+	modTimes, ok := storage.(modTimeStorage)
+	if !ok {
+		return time.Time{}
+	}
+	modTime, err := modTimes.ModTime(ctx, name)
+	if err != nil {
+		return time.Time{}
+	}
+	return modTime
+}
+
+// SetStaticStorage makes the file handler read from storage, nil switches back to Settings.Root.
+// Directory listings are not available for storages, directories serve their index.html.
+func (webServer *WebServer) SetStaticStorage(storage StaticStorage) {
+	webServer.storage = storage
+}
+
+// storageName maps a url path onto a storage name, directories map to their index.html
+func storageName(urlPath string) (string, bool) {
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	if name == "" {
+		return "index.html", true
+	}
+	if strings.HasSuffix(urlPath, "/") {
+		return name + "/index.html", true
+	}
+	return name, false
+}
+
+type cachedStorage struct {
+	storage StaticStorage
+	dir     string
+	ttl     time.Duration
+}
+
+// NewCachedStorage keeps the files read from storage in dir and serves them from there for ttl
+func NewCachedStorage(storage StaticStorage, dir string, ttl time.Duration) StaticStorage {
+	return &cachedStorage{storage: storage, dir: dir, ttl: ttl}
+}
+
+func (cache *cachedStorage) ReadFile(ctx context.Context, name string) ([]byte, error) {
+	filePath := filepath.Join(cache.dir, filepath.FromSlash(path.Clean("/"+name)))
+	if info, err := os.Stat(filePath); err == nil && time.Since(info.ModTime()) < cache.ttl {
+		file, err := os.ReadFile(filePath)
+		if err == nil {
+			return file, nil
+		}
+	}
+
+	file, err := cache.storage.ReadFile(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	// a failing cache only costs the next request another read from storage
+	_ = writeFileAtomic(filePath, file)
+	return file, nil
+}
+
+type fsStorage struct {
+	fsys fs.FS
+}
+
+// SetRootFS makes the file handler serve from fsys, e.g. an embed.FS, instead of Settings.Root.
+// Use fs.Sub to serve a subdirectory of an embed.FS, names are looked up without leading slash.
+func (webServer *WebServer) SetRootFS(fsys fs.FS) {
+	webServer.SetStaticStorage(fsStorage{fsys: fsys})
+}
+
+func (storage fsStorage) ReadFile(ctx context.Context, name string) ([]byte, error) {
+	info, err := fs.Stat(storage.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return fs.ReadFile(storage.fsys, name)
+}
+
+func (storage fsStorage) ModTime(ctx context.Context, name string) (time.Time, error) {
+	info, err := fs.Stat(storage.fsys, name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
@@ -0,0 +1,150 @@
+package webserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+type TLSClientAuth string
+
+const (
+	TLSClientAuthNone             TLSClientAuth = "none"
+	TLSClientAuthRequest          TLSClientAuth = "request"
+	TLSClientAuthRequire          TLSClientAuth = "require"
+	TLSClientAuthVerifyIfGiven    TLSClientAuth = "verify-if-given"
+	TLSClientAuthRequireAndVerify TLSClientAuth = "require-and-verify"
+)
+
+// TLSSettings configure the https server, they apply when the server starts and to new connections after a Reload
+// changing them
+type TLSSettings struct {
+	// MinVersion is one of 1.0, 1.1, 1.2 or 1.3, empty uses the default of crypto/tls
+	MinVersion string
+	// CipherSuites are names as listed by tls.CipherSuites, e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256. They do not apply to TLS 1.3.
+	CipherSuites []string
+	// CurvePreferences are X25519, P256, P384 or P521
+	CurvePreferences []string
+	ClientAuth       TLSClientAuth
+	// ClientCAFile is a pem bundle of the CAs client certificates are verified against
+	ClientCAFile string
+	// Config is used as base if set, the fields above override it and the certificate is only set if it has none
+	Config *tls.Config `json:"-"`
+}
+
+func NewTLSSettings() *TLSSettings {
+	return &TLSSettings{
+		MinVersion:       "1.2",
+		CipherSuites:     []string{},
+		CurvePreferences: []string{},
+		ClientAuth:       TLSClientAuthNone,
+		ClientCAFile:     "",
+	}
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCurves = map[string]tls.CurveID{
+	"X25519": tls.X25519,
+	"P256":   tls.CurveP256,
+	"P384":   tls.CurveP384,
+	"P521":   tls.CurveP521,
+}
+
+var tlsClientAuths = map[TLSClientAuth]tls.ClientAuthType{
+	"":                            tls.NoClientCert,
+	TLSClientAuthNone:             tls.NoClientCert,
+	TLSClientAuthRequest:          tls.RequestClientCert,
+	TLSClientAuthRequire:          tls.RequireAnyClientCert,
+	TLSClientAuthVerifyIfGiven:    tls.VerifyClientCertIfGiven,
+	TLSClientAuthRequireAndVerify: tls.RequireAndVerifyClientCert,
+}
+
+func newTLSConfig(settings TLSSettings) (*tls.Config, error) {
+	config := &tls.Config{}
+	if settings.Config != nil {
+		config = settings.Config.Clone()
+	}
+
+	if settings.MinVersion != "" {
+		version, ok := tlsVersions[settings.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("tls: unknown min version %q", settings.MinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	if len(settings.CipherSuites) > 0 {
+		config.CipherSuites = nil
+		for _, name := range settings.CipherSuites {
+			id, ok := cipherSuiteID(name)
+			if !ok {
+				return nil, fmt.Errorf("tls: unknown cipher suite %q", name)
+			}
+			config.CipherSuites = append(config.CipherSuites, id)
+		}
+	}
+
+	if len(settings.CurvePreferences) > 0 {
+		config.CurvePreferences = nil
+		for _, name := range settings.CurvePreferences {
+			curve, ok := tlsCurves[strings.TrimPrefix(name, "Curve")]
+			if !ok {
+				return nil, fmt.Errorf("tls: unknown curve %q", name)
+			}
+			config.CurvePreferences = append(config.CurvePreferences, curve)
+		}
+	}
+
+	clientAuth, ok := tlsClientAuths[settings.ClientAuth]
+	if !ok {
+		return nil, fmt.Errorf("tls: unknown client auth %q", settings.ClientAuth)
+	}
+	if settings.ClientAuth != "" || settings.Config == nil {
+		config.ClientAuth = clientAuth
+	}
+
+	if settings.ClientCAFile != "" {
+		pem, err := os.ReadFile(settings.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("tls: no certificates in " + settings.ClientCAFile)
+		}
+	}
+	if (config.ClientAuth == tls.VerifyClientCertIfGiven || config.ClientAuth == tls.RequireAndVerifyClientCert) && config.ClientCAs == nil {
+		return nil, errors.New("tls: client certificate verification needs ClientCAFile")
+	}
+	return config, nil
+}
+
+// tlsSettingsChanged reports whether settings build a different tls.Config than old
+func tlsSettingsChanged(old TLSSettings, settings TLSSettings) bool {
+	return old.MinVersion != settings.MinVersion ||
+		!slices.Equal(old.CipherSuites, settings.CipherSuites) ||
+		!slices.Equal(old.CurvePreferences, settings.CurvePreferences) ||
+		old.ClientAuth != settings.ClientAuth ||
+		old.ClientCAFile != settings.ClientCAFile ||
+		old.Config != settings.Config
+}
+
+func cipherSuiteID(name string) (uint16, bool) {
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if suite.Name == name {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,83 @@
+package webserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewTLSConfig(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "client ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	err = os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		settings TLSSettings
+		check    func(config *tls.Config) bool
+		err      bool
+	}{
+		{"default", *NewTLSSettings(), func(config *tls.Config) bool {
+			return config.MinVersion == tls.VersionTLS12 && config.ClientAuth == tls.NoClientCert
+		}, false},
+		{"hardened", TLSSettings{
+			MinVersion:       "1.3",
+			CipherSuites:     []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+			CurvePreferences: []string{"X25519", "CurveP256"},
+		}, func(config *tls.Config) bool {
+			return config.MinVersion == tls.VersionTLS13 &&
+				len(config.CipherSuites) == 1 && config.CipherSuites[0] == tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 &&
+				len(config.CurvePreferences) == 2 && config.CurvePreferences[1] == tls.CurveP256
+		}, false},
+		{"mtls", TLSSettings{ClientAuth: TLSClientAuthRequireAndVerify, ClientCAFile: caFile}, func(config *tls.Config) bool {
+			return config.ClientAuth == tls.RequireAndVerifyClientCert && config.ClientCAs != nil
+		}, false},
+		{"override", TLSSettings{Config: &tls.Config{ServerName: "example.com", ClientAuth: tls.RequestClientCert}}, func(config *tls.Config) bool {
+			return config.ServerName == "example.com" && config.ClientAuth == tls.RequestClientCert
+		}, false},
+		{"verify without ca", TLSSettings{ClientAuth: TLSClientAuthRequireAndVerify}, nil, true},
+		{"unknown version", TLSSettings{MinVersion: "2.0"}, nil, true},
+		{"unknown cipher", TLSSettings{CipherSuites: []string{"RC5"}}, nil, true},
+		{"unknown curve", TLSSettings{CurvePreferences: []string{"P999"}}, nil, true},
+		{"unknown client auth", TLSSettings{ClientAuth: "always"}, nil, true},
+		{"missing ca file", TLSSettings{ClientCAFile: "missing.pem"}, nil, true},
+	}
+
+	for _, test := range tests {
+		config, err := newTLSConfig(test.settings)
+		if (err != nil) != test.err {
+			t.Errorf("%s: err = %v", test.name, err)
+			continue
+		}
+		if err == nil && !test.check(config) {
+			t.Errorf("%s: unexpected config %+v", test.name, config)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package webserver
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/slices"
+)
+
+const maxClientHelloSize = 64 * 1024
+
+var errClientHello = errors.New("invalid client hello")
+
+type ClientFingerprint struct {
+	JA3     string
+	JA3Hash string
+	JA4     string
+}
+
+type connContextKey struct{}
+
+// SetTLSFingerprintHook registers a hook called once per TLS handshake with the computed fingerprint,
+// returning an error aborts the handshake. Requires UseTLSFingerprint.
+func (webServer *WebServer) SetTLSFingerprintHook(hook func(hello *tls.ClientHelloInfo, fingerprint ClientFingerprint) error) {
+	webServer.tlsFingerprintHook = hook
+}
+
+// TLSFingerprint returns the fingerprint of the TLS client hello of the connection the request arrived on.
+func TLSFingerprint(req *http.Request) (ClientFingerprint, bool) {
+	conn, ok := req.Context().Value(connContextKey{}).(*fingerprintConn)
+	if !ok {
+		return ClientFingerprint{}, false
+	}
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	return conn.fingerprint, conn.computed
+}
+
+func (webServer *WebServer) connContext(ctx context.Context, conn net.Conn) context.Context {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	if fpConn, ok := conn.(*fingerprintConn); ok {
+		ctx = context.WithValue(ctx, connContextKey{}, fpConn)
+	}
+	return ctx
+}
+
+func (webServer *WebServer) configureTLSFingerprint(server *http.Server) {
+	if server.TLSConfig == nil {
+		server.TLSConfig = &tls.Config{}
+	}
+	server.TLSConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		conn, ok := hello.Conn.(*fingerprintConn)
+		if !ok {
+			return nil, nil
+		}
+		fingerprint, err := conn.finish()
+		if err != nil {
+			webServer.logger.Println("TLS Fingerprint: " + err.Error())
+			return nil, nil
+		}
+		if webServer.tlsFingerprintHook != nil {
+			return nil, webServer.tlsFingerprintHook(hello, fingerprint)
+		}
+		return nil, nil
+	}
+}
+
+type fingerprintListener struct {
+	net.Listener
+}
+
+func (listener *fingerprintListener) Accept() (net.Conn, error) {
+	conn, err := listener.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &fingerprintConn{Conn: conn}, nil
+}
+
+// fingerprintConn records the bytes read until the client hello has been consumed by the TLS handshake.
+type fingerprintConn struct {
+	net.Conn
+
+	mutex       sync.Mutex
+	hello       []byte
+	computed    bool
+	fingerprint ClientFingerprint
+}
+
+func (conn *fingerprintConn) Read(b []byte) (int, error) {
+	n, err := conn.Conn.Read(b)
+	conn.mutex.Lock()
+	if !conn.computed && len(conn.hello) < maxClientHelloSize {
+		conn.hello = append(conn.hello, b[:n]...)
+	}
+	conn.mutex.Unlock()
+	return n, err
+}
+
+func (conn *fingerprintConn) finish() (ClientFingerprint, error) {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	hello := conn.hello
+	conn.hello = nil
+	conn.computed = true
+
+	fingerprint, err := computeClientFingerprint(hello)
+	if err != nil {
+		return ClientFingerprint{}, err
+	}
+	conn.fingerprint = fingerprint
+	return fingerprint, nil
+}
+
+type clientHello struct {
+	version             uint16
+	cipherSuites        []uint16
+	extensions          []uint16
+	curves              []uint16
+	pointFormats        []uint8
+	signatureAlgorithms []uint16
+	supportedVersions   []uint16
+	alpn                []string
+	serverName          bool
+}
+
+// computeClientFingerprint parses the raw TLS records containing a client hello.
+func computeClientFingerprint(records []byte) (ClientFingerprint, error) {
+	var message []byte
+	for len(records) >= 5 && records[0] == 22 {
+		length := int(binary.BigEndian.Uint16(records[3:5]))
+		if len(records) < 5+length {
+			break
+		}
+		message = append(message, records[5:5+length]...)
+		records = records[5+length:]
+	}
+
+	hello, err := parseClientHello(message)
+	if err != nil {
+		return ClientFingerprint{}, err
+	}
+
+	ja3 := ja3String(hello)
+	sum := md5.Sum([]byte(ja3))
+	return ClientFingerprint{
+		JA3:     ja3,
+		JA3Hash: hex.EncodeToString(sum[:]),
+		JA4:     ja4String(hello),
+	}, nil
+}
+
+func parseClientHello(message []byte) (*clientHello, error) {
+	if len(message) < 4 || message[0] != 1 {
+		return nil, errClientHello
+	}
+	length := int(message[1])<<16 | int(message[2])<<8 | int(message[3])
+	if len(message) < 4+length {
+		return nil, errClientHello
+	}
+	reader := byteReader(message[4 : 4+length])
+
+	hello := &clientHello{}
+	var ok bool
+	if hello.version, ok = reader.uint16(); !ok {
+		return nil, errClientHello
+	}
+	if _, ok = reader.bytes(32); !ok {
+		return nil, errClientHello
+	}
+	if _, ok = reader.vector8(); !ok {
+		return nil, errClientHello
+	}
+	cipherSuites, ok := reader.vector16()
+	if !ok {
+		return nil, errClientHello
+	}
+	hello.cipherSuites = uint16s(cipherSuites)
+	if _, ok = reader.vector8(); !ok {
+		return nil, errClientHello
+	}
+
+	extensions, ok := reader.vector16()
+	if !ok {
+		// hellos without extensions are valid
+		return hello, nil
+	}
+	for len(extensions) > 0 {
+		extensionType, ok := extensions.uint16()
+		if !ok {
+			return nil, errClientHello
+		}
+		data, ok := extensions.vector16()
+		if !ok {
+			return nil, errClientHello
+		}
+		hello.extensions = append(hello.extensions, extensionType)
+
+		switch extensionType {
+		case 0x0000:
+			hello.serverName = true
+		case 0x000a:
+			curves, _ := data.vector16()
+			hello.curves = uint16s(curves)
+		case 0x000b:
+			pointFormats, _ := data.vector8()
+			hello.pointFormats = pointFormats
+		case 0x000d:
+			algorithms, _ := data.vector16()
+			hello.signatureAlgorithms = uint16s(algorithms)
+		case 0x0010:
+			protocols, _ := data.vector16()
+			for len(protocols) > 0 {
+				protocol, ok := protocols.vector8()
+				if !ok {
+					break
+				}
+				hello.alpn = append(hello.alpn, string(protocol))
+			}
+		case 0x002b:
+			versions, _ := data.vector8()
+			hello.supportedVersions = uint16s(versions)
+		}
+	}
+	return hello, nil
+}
+
+func ja3String(hello *clientHello) string {
+	pointFormats := make([]string, 0, len(hello.pointFormats))
+	for _, format := range hello.pointFormats {
+		pointFormats = append(pointFormats, strconv.Itoa(int(format)))
+	}
+	return strings.Join([]string{
+		strconv.Itoa(int(hello.version)),
+		joinUint16s(hello.cipherSuites, "-", strconv.Itoa),
+		joinUint16s(hello.extensions, "-", strconv.Itoa),
+		joinUint16s(hello.curves, "-", strconv.Itoa),
+		strings.Join(pointFormats, "-"),
+	}, ",")
+}
+
+func ja4String(hello *clientHello) string {
+	version := hello.version
+	if supportedVersions := withoutGrease(hello.supportedVersions); len(supportedVersions) > 0 {
+		version = slices.Max(supportedVersions)
+	}
+	versions := map[uint16]string{0x0304: "13", 0x0303: "12", 0x0302: "11", 0x0301: "10", 0x0300: "s3"}
+	versionString, ok := versions[version]
+	if !ok {
+		versionString = "00"
+	}
+
+	sni := "i"
+	if hello.serverName {
+		sni = "d"
+	}
+
+	alpn := "00"
+	if len(hello.alpn) > 0 && hello.alpn[0] != "" {
+		first, last := hello.alpn[0][0], hello.alpn[0][len(hello.alpn[0])-1]
+		if isAlphanumeric(first) && isAlphanumeric(last) {
+			alpn = string([]byte{first, last})
+		} else {
+			encoded := hex.EncodeToString([]byte(hello.alpn[0]))
+			alpn = string([]byte{encoded[0], encoded[len(encoded)-1]})
+		}
+	}
+
+	cipherSuites := withoutGrease(hello.cipherSuites)
+	extensions := withoutGrease(hello.extensions)
+
+	a := "t" + versionString + sni + twoDigits(len(cipherSuites)) + twoDigits(len(extensions)) + alpn
+
+	sortedCiphers := slices.Clone(cipherSuites)
+	slices.Sort(sortedCiphers)
+	b := truncatedHash(joinUint16s(sortedCiphers, ",", hex4), len(sortedCiphers) == 0)
+
+	var sortedExtensions []uint16
+	for _, extension := range extensions {
+		if extension != 0x0000 && extension != 0x0010 {
+			sortedExtensions = append(sortedExtensions, extension)
+		}
+	}
+	slices.Sort(sortedExtensions)
+	c := joinUint16s(sortedExtensions, ",", hex4)
+	if len(hello.signatureAlgorithms) > 0 {
+		c += "_" + joinUint16s(hello.signatureAlgorithms, ",", hex4)
+	}
+
+	return a + "_" + b + "_" + truncatedHash(c, len(sortedExtensions) == 0)
+}
+
+func truncatedHash(value string, empty bool) string {
+	if empty {
+		return "000000000000"
+	}
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+func twoDigits(n int) string {
+	n = min(n, 99)
+	if n < 10 {
+		return "0" + strconv.Itoa(n)
+	}
+	return strconv.Itoa(n)
+}
+
+func hex4(n int) string {
+	return hex.EncodeToString([]byte{byte(n >> 8), byte(n)})
+}
+
+func isAlphanumeric(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// isGrease reports the reserved values of RFC 8701 which clients add randomly
+func isGrease(value uint16) bool {
+	return value&0x0f0f == 0x0a0a && value>>8 == value&0xff
+}
+
+func withoutGrease(values []uint16) []uint16 {
+	var out []uint16
+	for _, value := range values {
+		if !isGrease(value) {
+			out = append(out, value)
+		}
+	}
+	return out
+}
+
+func joinUint16s(values []uint16, separator string, format func(int) string) string {
+	parts := make([]string, 0, len(values))
+	for _, value := range withoutGrease(values) {
+		parts = append(parts, format(int(value)))
+	}
+	return strings.Join(parts, separator)
+}
+
+func uint16s(data byteReader) []uint16 {
+	var values []uint16
+	for len(data) >= 2 {
+		value, _ := data.uint16()
+		values = append(values, value)
+	}
+	return values
+}
+
+type byteReader []byte
+
+func (reader *byteReader) bytes(n int) ([]byte, bool) {
+	if len(*reader) < n {
+		return nil, false
+	}
+	out := (*reader)[:n]
+	*reader = (*reader)[n:]
+	return out, true
+}
+
+func (reader *byteReader) uint16() (uint16, bool) {
+	data, ok := reader.bytes(2)
+	if !ok {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(data), true
+}
+
+func (reader *byteReader) vector8() (byteReader, bool) {
+	length, ok := reader.bytes(1)
+	if !ok {
+		return nil, false
+	}
+	return reader.bytes(int(length[0]))
+}
+
+func (reader *byteReader) vector16() (byteReader, bool) {
+	length, ok := reader.uint16()
+	if !ok {
+		return nil, false
+	}
+	return reader.bytes(int(length))
+}
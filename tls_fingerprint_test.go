@@ -0,0 +1,79 @@
+package webserver
+
+import (
+	"crypto/tls"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func captureClientHello(t *testing.T, config *tls.Config) []byte {
+	client, server := net.Pipe()
+	defer func() { _ = server.Close() }()
+
+	go func() {
+		_ = tls.Client(client, config).Handshake()
+		_ = client.Close()
+	}()
+
+	_ = server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, maxClientHelloSize)
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf[:n]
+}
+
+func TestComputeClientFingerprint(t *testing.T) {
+	hello := captureClientHello(t, &tls.Config{
+		ServerName: "example.com",
+		NextProtos: []string{"h2", "http/1.1"},
+		MinVersion: tls.VersionTLS12,
+	})
+
+	fingerprint, err := computeClientFingerprint(hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !regexp.MustCompile(`^771,[0-9-]+,[0-9-]+,[0-9-]+,[0-9-]*$`).MatchString(fingerprint.JA3) {
+		t.Errorf("JA3 = %q", fingerprint.JA3)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(fingerprint.JA3Hash) {
+		t.Errorf("JA3Hash = %q", fingerprint.JA3Hash)
+	}
+	if !regexp.MustCompile(`^t13d[0-9]{4}h2_[0-9a-f]{12}_[0-9a-f]{12}$`).MatchString(fingerprint.JA4) {
+		t.Errorf("JA4 = %q", fingerprint.JA4)
+	}
+
+	withoutSNI := captureClientHello(t, &tls.Config{InsecureSkipVerify: true})
+	fingerprint, err = computeClientFingerprint(withoutSNI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^t13i[0-9]{4}00_`).MatchString(fingerprint.JA4) {
+		t.Errorf("JA4 without SNI and ALPN = %q", fingerprint.JA4)
+	}
+}
+
+func TestComputeClientFingerprintInvalid(t *testing.T) {
+	_, err := computeClientFingerprint([]byte("GET / HTTP/1.1\r\n\r\n"))
+	if err == nil {
+		t.Error("expected error for non TLS input")
+	}
+}
+
+func TestIsGrease(t *testing.T) {
+	for _, value := range []uint16{0x0a0a, 0x1a1a, 0xfafa} {
+		if !isGrease(value) {
+			t.Errorf("isGrease(%#04x) = false", value)
+		}
+	}
+	for _, value := range []uint16{0x0a1a, 0x1301, 0x0000} {
+		if isGrease(value) {
+			t.Errorf("isGrease(%#04x) = true", value)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package webserver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTOTPNotEnrolled     = errors.New("totp not enrolled")
+	ErrTOTPAlreadyEnrolled = errors.New("totp already enrolled")
+	ErrTOTPInvalidCode     = errors.New("invalid totp code")
+)
+
+// totpPeriod is the time step of the codes, authenticator apps only support 30 seconds reliably
+const totpPeriod = 30
+
+// TOTPEnrollment is the second factor of an identity
+type TOTPEnrollment struct {
+	Identity string
+	// Secret is the base32 encoded shared secret
+	Secret string
+	// Confirmed is set once a code of the authenticator app was verified, unconfirmed enrollments are not enforced
+	Confirmed bool
+	// LastStep is the time step of the last accepted code, codes of it and earlier steps are rejected as replays
+	LastStep int64
+	// RecoveryCodeHashes are the sha256 hashes of the unused recovery codes
+	RecoveryCodeHashes [][32]byte
+}
+
+// TOTPStore keeps TOTP enrollments, implementations must be safe for concurrent use
+type TOTPStore interface {
+	// FindTOTP returns ErrTOTPNotEnrolled for identities without enrollment
+	FindTOTP(ctx context.Context, identity string) (TOTPEnrollment, error)
+	SaveTOTP(ctx context.Context, enrollment TOTPEnrollment) error
+	DeleteTOTP(ctx context.Context, identity string) error
+}
+
+type TOTPOptions struct {
+	// Issuer is shown by authenticator apps next to the account name
+	Issuer string
+	// Skew is the number of time steps a code may be early or late
+	Skew          int
+	RecoveryCodes int
+	// Required rejects identities without confirmed enrollment in Middleware instead of letting them pass
+	Required bool
+	// VerifiedFor is how long the cookie set by VerifyRequest satisfies Middleware
+	VerifiedFor time.Duration
+	CookieName  string
+	// Key signs the cookie, empty uses a random key, so verifications do not survive a restart
+	Key []byte
+	// Secure sets the Secure flag of the cookie also for requests without TLS, e.g. behind a TLS terminating proxy
+	Secure bool
+	// Guard locks identities after repeated wrong codes, e.g. the LoginGuard of the password login to count both
+	// together, nil uses a guard of its own with NewLoginGuardOptions
+	Guard *LoginGuard
+	Store TOTPStore
+}
+
+func NewTOTPOptions() *TOTPOptions {
+	return &TOTPOptions{
+		Issuer:        "WebServer",
+		Skew:          1,
+		RecoveryCodes: 10,
+		Required:      false,
+		VerifiedFor:   12 * time.Hour,
+		CookieName:    "totp_verified",
+		Key:           []byte{},
+		Secure:        false,
+		Guard:         nil,
+		Store:         NewMemoryTOTPStore(),
+	}
+}
+
+// TOTP is a second factor with time based one time passwords (RFC 6238) of authenticator apps and single use
+// recovery codes. A successful VerifyRequest sets a signed cookie bound to the identity and its enrollment, which
+// Middleware requires on sensitive routes, so enrolling again invalidates it. Failed verifications emit TwoFactorFailed
+// and used recovery codes RecoveryCodeUsed.
+type TOTP struct {
+	webServer *WebServer
+	options   TOTPOptions
+	// mutex serializes verifications, so a code cannot be used twice by concurrent requests
+	mutex sync.Mutex
+}
+
+func (webServer *WebServer) NewTOTP(options TOTPOptions) *TOTP {
+	if options.Store == nil {
+		options.Store = NewMemoryTOTPStore()
+	}
+	if len(options.Key) == 0 {
+		options.Key = make([]byte, 32)
+		_, _ = rand.Read(options.Key)
+	}
+	if options.Guard == nil {
+		options.Guard = webServer.NewLoginGuard(*NewLoginGuardOptions())
+	}
+	return &TOTP{webServer: webServer, options: options}
+}
+
+// Enroll creates a new secret for identity and returns it with the otpauth uri to show as QR code.
+// The enrollment is enforced after Confirm, enrolling again before replaces the secret.
+func (totp *TOTP) Enroll(ctx context.Context, identity string) (secret string, uri string, err error) {
+	existing, err := totp.options.Store.FindTOTP(ctx, identity)
+	if err == nil && existing.Confirmed {
+		return "", "", fmt.Errorf("%w: %s", ErrTOTPAlreadyEnrolled, identity)
+	}
+	if err != nil && !errors.Is(err, ErrTOTPNotEnrolled) {
+		return "", "", err
+	}
+
+	key := make([]byte, 20)
+	_, err = rand.Read(key)
+	if err != nil {
+		return "", "", err
+	}
+	secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(key)
+	err = totp.options.Store.SaveTOTP(ctx, TOTPEnrollment{Identity: identity, Secret: secret})
+	if err != nil {
+		return "", "", err
+	}
+	return secret, totpURI(totp.options.Issuer, identity, secret), nil
+}
+
+// totpURI returns the Key Uri Format understood by authenticator apps
+func totpURI(issuer string, account string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", "6")
+	query.Set("period", strconv.Itoa(totpPeriod))
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+account) + "?" + query.Encode()
+}
+
+// Confirm verifies the first code of the authenticator app, enforces the enrollment and returns the recovery codes
+// to show once
+func (totp *TOTP) Confirm(ctx context.Context, identity string, code string) (recoveryCodes []string, err error) {
+	totp.mutex.Lock()
+	defer totp.mutex.Unlock()
+	enrollment, err := totp.options.Store.FindTOTP(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment.Confirmed {
+		return nil, fmt.Errorf("%w: %s", ErrTOTPAlreadyEnrolled, identity)
+	}
+	step, ok := totp.matchCode(enrollment, code, time.Now())
+	if !ok {
+		return nil, ErrTOTPInvalidCode
+	}
+
+	enrollment.Confirmed = true
+	enrollment.LastStep = step
+	enrollment.RecoveryCodeHashes = nil
+	for i := 0; i < totp.options.RecoveryCodes; i++ {
+		recoveryCode := newRecoveryCode()
+		recoveryCodes = append(recoveryCodes, recoveryCode)
+		enrollment.RecoveryCodeHashes = append(enrollment.RecoveryCodeHashes, sha256.Sum256([]byte(normalizeRecoveryCode(recoveryCode))))
+	}
+	return recoveryCodes, totp.options.Store.SaveTOTP(ctx, enrollment)
+}
+
+// Disable removes the enrollment of identity, e.g. after it verified once more or an admin reset it.
+// Cookies of earlier verifications no longer satisfy Middleware once the identity enrolls again.
+func (totp *TOTP) Disable(ctx context.Context, identity string) error {
+	return totp.options.Store.DeleteTOTP(ctx, identity)
+}
+
+// VerifyRequest checks code, a TOTP code or an unused recovery code, for the identity of the request and sets the
+// cookie Middleware requires. It returns ErrTOTPInvalidCode for wrong and reused codes and an error wrapping
+// ErrAccountLocked once the Guard locked the identity after too many of them.
+func (totp *TOTP) VerifyRequest(rw http.ResponseWriter, req *http.Request, code string) error {
+	identity := Identity(req)
+	if identity == "" {
+		return fmt.Errorf("%w: request without identity", ErrTOTPInvalidCode)
+	}
+	err := totp.options.Guard.Check(identity)
+	if err != nil {
+		totp.webServer.logger.Println("TOTP: " + err.Error())
+		return err
+	}
+
+	totp.mutex.Lock()
+	enrollment, err := totp.options.Store.FindTOTP(req.Context(), identity)
+	if err == nil && !enrollment.Confirmed {
+		err = fmt.Errorf("%w: %s did not confirm", ErrTOTPNotEnrolled, identity)
+	}
+	if err != nil {
+		totp.mutex.Unlock()
+		return err
+	}
+	step, ok := totp.matchCode(enrollment, code, time.Now())
+	recovery := false
+	if ok {
+		enrollment.LastStep = step
+	} else {
+		recovery = totp.useRecoveryCode(&enrollment, code)
+	}
+	if ok || recovery {
+		err = totp.options.Store.SaveTOTP(req.Context(), enrollment)
+	}
+	totp.mutex.Unlock()
+
+	ip := ClientIP(req)
+	if !ok && !recovery {
+		totp.webServer.logger.Println("TOTP: invalid code for " + identity + " from " + ip)
+		totp.webServer.emit(TwoFactorFailed{Identity: identity, IP: ip})
+		totp.options.Guard.Failed(req, identity)
+		return ErrTOTPInvalidCode
+	}
+	if err != nil {
+		return err
+	}
+	totp.options.Guard.Unlock(identity)
+	if recovery {
+		totp.webServer.logger.Println("TOTP: recovery code used by " + identity + " from " + ip + ", " + strconv.Itoa(len(enrollment.RecoveryCodeHashes)) + " left")
+		totp.webServer.emit(RecoveryCodeUsed{Identity: identity, IP: ip, Remaining: len(enrollment.RecoveryCodeHashes)})
+	}
+	totp.setCookie(rw, req, enrollment)
+	return nil
+}
+
+// Middleware returns a middleware for sensitive routes answering requests without principal and requests of
+// identities with confirmed enrollment but without valid cookie of VerifyRequest with 401 Unauthorized.
+// It is meant to be registered after the login middleware, e.g. with NewPhaseMiddleware(PhaseAuth, ...) and a condition.
+func (totp *TOTP) Middleware() func(http.ResponseWriter, *http.Request) bool {
+	return func(rw http.ResponseWriter, req *http.Request) bool {
+		identity := Identity(req)
+		if identity != "" {
+			enrollment, err := totp.options.Store.FindTOTP(req.Context(), identity)
+			if err != nil && !errors.Is(err, ErrTOTPNotEnrolled) {
+				Logger(req).Println("TOTP: " + err.Error())
+				totp.webServer.writeError(rw, req, http.StatusInternalServerError)
+				return false
+			}
+			confirmed := err == nil && enrollment.Confirmed
+			if confirmed && totp.verified(req, enrollment) {
+				return true
+			}
+			if !confirmed && !totp.options.Required {
+				return true
+			}
+		}
+		totp.webServer.writeError(rw, req, http.StatusUnauthorized)
+		return false
+	}
+}
+
+// matchCode returns the time step code is valid for within the skew, steps up to LastStep are rejected
+func (totp *TOTP) matchCode(enrollment TOTPEnrollment, code string, now time.Time) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != 6 {
+		return 0, false
+	}
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(enrollment.Secret))
+	if err != nil {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - int64(totp.options.Skew); step <= current+int64(totp.options.Skew); step++ {
+		if step > enrollment.LastStep && subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// totpCode is the 6 digit HOTP (RFC 4226) value of step
+func totpCode(key []byte, step int64) string {
+	mac := hmac.New(sha1.New, key)
+	_ = binary.Write(mac, binary.BigEndian, step)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+// useRecoveryCode removes the hash of code from enrollment and reports whether it was unused
+func (totp *TOTP) useRecoveryCode(enrollment *TOTPEnrollment, code string) bool {
+	hash := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	for i, existing := range enrollment.RecoveryCodeHashes {
+		if subtle.ConstantTimeCompare(existing[:], hash[:]) == 1 {
+			enrollment.RecoveryCodeHashes = append(enrollment.RecoveryCodeHashes[:i:i], enrollment.RecoveryCodeHashes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// newRecoveryCode returns a code like "k3j9x-qp2mf"
+func newRecoveryCode() string {
+	random := make([]byte, 7)
+	_, _ = rand.Read(random)
+	code := strings.ToLower(base32.StdEncoding.EncodeToString(random))[:10]
+	return code[:5] + "-" + code[5:]
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// setCookie sets the verification cookie "identity.expires.signature" with base64url encoded identity and signature,
+// the signature also covers the secret of enrollment
+func (totp *TOTP) setCookie(rw http.ResponseWriter, req *http.Request, enrollment TOTPEnrollment) {
+	expires := strconv.FormatInt(time.Now().Add(totp.options.VerifiedFor).Unix(), 10)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(enrollment.Identity)) + "." + expires
+	cookie := &http.Cookie{
+		Name:     totp.options.CookieName,
+		Value:    payload + "." + base64.RawURLEncoding.EncodeToString(totp.sign(payload, enrollment.Secret)),
+		Path:     "/",
+		MaxAge:   int(totp.options.VerifiedFor.Seconds()),
+		Secure:   totp.options.Secure || req.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	totp.webServer.SetCookie(rw, req, cookie)
+}
+
+// verified reports whether the request carries an unexpired verification cookie of enrollment
+func (totp *TOTP) verified(req *http.Request, enrollment TOTPEnrollment) bool {
+	cookie, err := req.Cookie(totp.options.CookieName)
+	if err != nil {
+		return false
+	}
+	index := strings.LastIndex(cookie.Value, ".")
+	if index < 0 {
+		return false
+	}
+	payload := cookie.Value[:index]
+	signature, err := base64.RawURLEncoding.DecodeString(cookie.Value[index+1:])
+	if err != nil || !hmac.Equal(signature, totp.sign(payload, enrollment.Secret)) {
+		return false
+	}
+	encodedIdentity, expires, _ := strings.Cut(payload, ".")
+	cookieIdentity, err := base64.RawURLEncoding.DecodeString(encodedIdentity)
+	if err != nil || string(cookieIdentity) != enrollment.Identity {
+		return false
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	return err == nil && time.Now().Unix() < unix
+}
+
+func (totp *TOTP) sign(payload string, secret string) []byte {
+	mac := hmac.New(sha256.New, totp.options.Key)
+	mac.Write([]byte(payload))
+	mac.Write([]byte{0})
+	mac.Write([]byte(secret))
+	return mac.Sum(nil)
+}
+
+// MemoryTOTPStore keeps the enrollments in process memory, they are lost on restart
+type MemoryTOTPStore struct {
+	mutex       sync.Mutex
+	enrollments map[string]TOTPEnrollment
+}
+
+func NewMemoryTOTPStore() *MemoryTOTPStore {
+	return &MemoryTOTPStore{enrollments: map[string]TOTPEnrollment{}}
+}
+
+func (store *MemoryTOTPStore) FindTOTP(ctx context.Context, identity string) (TOTPEnrollment, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	enrollment, ok := store.enrollments[identity]
+	if !ok {
+		return TOTPEnrollment{}, ErrTOTPNotEnrolled
+	}
+	enrollment.RecoveryCodeHashes = append([][32]byte{}, enrollment.RecoveryCodeHashes...)
+	return enrollment, nil
+}
+
+func (store *MemoryTOTPStore) SaveTOTP(ctx context.Context, enrollment TOTPEnrollment) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.enrollments[enrollment.Identity] = enrollment
+	return nil
+}
+
+func (store *MemoryTOTPStore) DeleteTOTP(ctx context.Context, identity string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	delete(store.enrollments, identity)
+	return nil
+}
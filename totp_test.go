@@ -0,0 +1,217 @@
+package webserver
+
+import (
+	"context"
+	"encoding/base32"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, SHA1, the last 6 of the 8 digits
+	key := []byte("12345678901234567890")
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, vector := range vectors {
+		if code := totpCode(key, vector.unix/totpPeriod); code != vector.code {
+			t.Errorf("totpCode at %d = %s, want %s", vector.unix, code, vector.code)
+		}
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	webServer.NewPhaseMiddleware(PhaseAuth, func(rw http.ResponseWriter, req *http.Request) bool {
+		if user := req.Header.Get("X-Test-User"); user != "" {
+			setPrincipal(req, Principal{Name: user, Scheme: "Test"})
+		}
+		return true
+	})
+	totp := webServer.NewTOTP(*NewTOTPOptions())
+	webServer.NewPhaseMiddleware(PhaseAuth, totp.Middleware(), When(func(req *http.Request) bool {
+		return strings.HasPrefix(req.URL.Path, "/admin")
+	}))
+	webServer.NewHandleFunc(HTTPMethodGet, "/admin", func(rw http.ResponseWriter, req *http.Request) {})
+	webServer.NewHandleFunc(HTTPMethodPost, "/verify", func(rw http.ResponseWriter, req *http.Request) {
+		err := totp.VerifyRequest(rw, req, req.URL.Query().Get("code"))
+		if err != nil {
+			rw.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	recovered := make(chan RecoveryCodeUsed, 1)
+	On(webServer, func(event RecoveryCodeUsed) {
+		recovered <- event
+	})
+
+	serve := func(method string, path string, user string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-Test-User", user)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve(http.MethodGet, "/admin", "alice", nil); rec.Code != http.StatusOK {
+		t.Errorf("admin without enrollment = %d, want 200", rec.Code)
+	}
+
+	ctx := context.Background()
+	secret, uri, err := totp.Enroll(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(uri, "otpauth://totp/WebServer:alice?") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("uri = %s", uri)
+	}
+	key, _ := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	step := time.Now().Unix() / totpPeriod
+	recoveryCodes, err := totp.Confirm(ctx, "alice", totpCode(key, step))
+	if err != nil || len(recoveryCodes) != 10 {
+		t.Fatalf("Confirm = %v, %d recovery codes", err, len(recoveryCodes))
+	}
+
+	if rec := serve(http.MethodGet, "/admin", "alice", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("admin without verification = %d, want 401", rec.Code)
+	}
+	if rec := serve(http.MethodPost, "/verify?code="+totpCode(key, step), "alice", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("reused code = %d, want 401", rec.Code)
+	}
+	rec := serve(http.MethodPost, "/verify?code="+totpCode(key, step+1), "alice", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("verify = %d", rec.Code)
+	}
+	cookie := rec.Result().Cookies()[0]
+	if rec := serve(http.MethodGet, "/admin", "alice", cookie); rec.Code != http.StatusOK {
+		t.Errorf("admin with verification = %d, want 200", rec.Code)
+	}
+	if rec := serve(http.MethodGet, "/admin", "mallory", cookie); rec.Code != http.StatusOK {
+		t.Errorf("cookie of alice for unenrolled mallory = %d, want 200", rec.Code)
+	}
+
+	recoveryCode := strings.ToUpper(recoveryCodes[3])
+	if rec := serve(http.MethodPost, "/verify?code="+recoveryCode, "alice", nil); rec.Code != http.StatusOK {
+		t.Errorf("recovery code = %d", rec.Code)
+	}
+	select {
+	case event := <-recovered:
+		if event.Identity != "alice" || event.Remaining != 9 {
+			t.Errorf("RecoveryCodeUsed = %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Error("RecoveryCodeUsed was not emitted")
+	}
+	if rec := serve(http.MethodPost, "/verify?code="+recoveryCode, "alice", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("reused recovery code = %d, want 401", rec.Code)
+	}
+
+	_, _, err = totp.Enroll(ctx, "alice")
+	if !errors.Is(err, ErrTOTPAlreadyEnrolled) {
+		t.Errorf("second Enroll = %v, want ErrTOTPAlreadyEnrolled", err)
+	}
+}
+
+func TestTOTPAttemptsAndReenroll(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	webServer.NewPhaseMiddleware(PhaseAuth, func(rw http.ResponseWriter, req *http.Request) bool {
+		setPrincipal(req, Principal{Name: "alice", Scheme: "Test"})
+		return true
+	})
+	options := NewTOTPOptions()
+	guardOptions := NewLoginGuardOptions()
+	guardOptions.MaxFailures = 3
+	options.Guard = webServer.NewLoginGuard(*guardOptions)
+	totp := webServer.NewTOTP(*options)
+	webServer.NewPhaseMiddleware(PhaseAuth, totp.Middleware(), When(func(req *http.Request) bool {
+		return req.URL.Path == "/admin"
+	}))
+	webServer.NewHandleFunc(HTTPMethodGet, "/admin", func(rw http.ResponseWriter, req *http.Request) {})
+	var verifyErr error
+	webServer.NewHandleFunc(HTTPMethodPost, "/verify", func(rw http.ResponseWriter, req *http.Request) {
+		verifyErr = totp.VerifyRequest(rw, req, req.URL.Query().Get("code"))
+	})
+	ctx := context.Background()
+
+	enroll := func() []byte {
+		secret, _, err := totp.Enroll(ctx, "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, _ := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+		_, err = totp.Confirm(ctx, "alice", totpCode(key, time.Now().Unix()/totpPeriod-1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	verify := func(code string) (*http.Cookie, error) {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/verify?code="+code, nil))
+		if verifyErr != nil {
+			return nil, verifyErr
+		}
+		return rec.Result().Cookies()[0], nil
+	}
+	admin := func(cookie *http.Cookie) int {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	key := enroll()
+	step := time.Now().Unix() / totpPeriod
+	cookie, err := verify(totpCode(key, step))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := admin(cookie); code != http.StatusOK {
+		t.Fatalf("admin with verification = %d", code)
+	}
+
+	// a re-enrolled identity needs to verify again
+	err = totp.Disable(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key = enroll()
+	if code := admin(cookie); code != http.StatusUnauthorized {
+		t.Errorf("cookie of the previous enrollment = %d, want 401", code)
+	}
+
+	// wrong codes lock the identity, also for the right code
+	for i := 0; i < 3; i++ {
+		_, err = verify("000000")
+		if !errors.Is(err, ErrTOTPInvalidCode) {
+			t.Fatalf("wrong code %d = %v", i, err)
+		}
+	}
+	_, err = verify(totpCode(key, step+1))
+	if !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("right code after 3 wrong ones = %v, want ErrAccountLocked", err)
+	}
+	options.Guard.Unlock("alice")
+	cookie, err = verify(totpCode(key, step+1))
+	if err != nil {
+		t.Fatalf("verify after Unlock = %v", err)
+	}
+	if code := admin(cookie); code != http.StatusOK {
+		t.Errorf("admin after Unlock = %d", code)
+	}
+}
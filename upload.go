@@ -0,0 +1,391 @@
+package webserver
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/slices"
+)
+
+// partSuffix is appended to the name of files whose ranged upload is not complete yet,
+// partTotalSuffix to the name of the file keeping the total declared by the first part
+const (
+	partSuffix      = ".part"
+	partTotalSuffix = partSuffix + ".total"
+)
+
+// isPartFile reports whether name is the state of an incomplete upload, which is neither served nor uploaded directly
+func isPartFile(name string) bool {
+	return strings.HasSuffix(name, partSuffix) || strings.HasSuffix(name, partTotalSuffix)
+}
+
+var errInvalidContentRange = errors.New("invalid Content-Range")
+
+// contentRange is a parsed Content-Range request header, start and end are -1 for "bytes */total"
+type contentRange struct {
+	start int64
+	end   int64
+	total int64
+}
+
+// parseContentRange parses "bytes start-end/total" and "bytes */total"
+func parseContentRange(header string) (contentRange, error) {
+	unit, spec, ok := strings.Cut(header, " ")
+	if !ok || unit != "bytes" {
+		return contentRange{}, errInvalidContentRange
+	}
+	bounds, totalValue, ok := strings.Cut(spec, "/")
+	if !ok {
+		return contentRange{}, errInvalidContentRange
+	}
+	total, err := strconv.ParseInt(totalValue, 10, 64)
+	if err != nil || total < 0 {
+		return contentRange{}, errInvalidContentRange
+	}
+	if bounds == "*" {
+		return contentRange{start: -1, end: -1, total: total}, nil
+	}
+
+	startValue, endValue, ok := strings.Cut(bounds, "-")
+	if !ok {
+		return contentRange{}, errInvalidContentRange
+	}
+	start, err := strconv.ParseInt(startValue, 10, 64)
+	if err != nil || start < 0 {
+		return contentRange{}, errInvalidContentRange
+	}
+	end, err := strconv.ParseInt(endValue, 10, 64)
+	if err != nil || end < start || end >= total {
+		return contentRange{}, errInvalidContentRange
+	}
+	return contentRange{start: start, end: end, total: total}, nil
+}
+
+// uploadHandler accepts PUT requests writing files below root.
+// A PUT without Content-Range replaces the file with the body. Large files are uploaded in parts with
+// "Content-Range: bytes start-end/total", every part has to start where the previous one ended. Incomplete uploads
+// are answered with 308 and a Range header of the bytes received so far, "Content-Range: bytes */total" with an
+// empty body only asks for that state, e.g. to resume after a lost connection. The last part completes the upload.
+// Every part has to declare the total of the first one, other totals are answered with 416.
+// Settings.MaxBodySize limits the whole file, also the total of an upload in parts.
+type uploadHandler struct {
+	webServer *WebServer
+	prefix    string
+	root      string
+	options   MountOptions
+
+	// locks serializes requests to the same file, an entry lives while requests to its file hold or wait for it
+	locksMutex sync.Mutex
+	locks      map[string]*uploadLock
+}
+
+type uploadLock struct {
+	sync.Mutex
+	users int
+}
+
+func (handler *uploadHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	webServer := handler.webServer
+	urlPath := "/" + strings.TrimPrefix(req.URL.Path, handler.prefix)
+	extension := path.Ext(urlPath)
+
+	if strings.HasSuffix(urlPath, "/") || isPartFile(urlPath) {
+		webServer.writeError(rw, req, http.StatusMethodNotAllowed)
+		webServer.logger.Println("Upload: 405: " + req.URL.Path)
+		return
+	}
+	if slices.Contains(handler.options.FileExtensionFilter, strings.TrimPrefix(extension, ".")) {
+		webServer.writeError(rw, req, http.StatusForbidden)
+		webServer.logger.Println("Upload: 403: " + extension + " (" + req.URL.Path + ")")
+		return
+	}
+	if !allowedExtension(handler.options.AllowedExtensions, strings.TrimPrefix(extension, ".")) {
+		webServer.writeError(rw, req, http.StatusForbidden)
+		webServer.logger.Println("Upload: 403: " + extension + " not allowed (" + req.URL.Path + ")")
+		return
+	}
+	err := checkTraversal(urlPath)
+	if err != nil {
+		webServer.writeErrorCause(rw, req, http.StatusForbidden, err)
+		webServer.logger.Println("Upload: 403: " + err.Error() + " (" + req.URL.Path + ")")
+		return
+	}
+	if pattern, denied := deniedPath(handler.options.DenyPaths, urlPath); denied {
+		webServer.writeError(rw, req, http.StatusForbidden)
+		webServer.logger.Println("Upload: 403: " + pattern + " (" + req.URL.Path + ")")
+		return
+	}
+	dir, err := resolvePath(handler.root, path.Dir(urlPath), webServer.Settings().BlockSymlinkEscape)
+	if err == nil {
+		var info os.FileInfo
+		info, err = os.Stat(dir)
+		if err == nil && !info.IsDir() {
+			err = fs.ErrNotExist
+		}
+	}
+	if err != nil {
+		// like WebDAV, parent directories are not created implicitly
+		webServer.writeError(rw, req, http.StatusConflict)
+		webServer.logger.Println("Upload: 409: " + req.URL.Path)
+		return
+	}
+	filePath := filepath.Join(dir, path.Base(urlPath))
+
+	unlock := handler.lock(filePath)
+	defer unlock()
+
+	webServer.limitBody(rw, req)
+	header := req.Header.Get("Content-Range")
+	if header == "" {
+		handler.putFile(rw, req, filePath)
+		return
+	}
+	contentRange, err := parseContentRange(header)
+	if err != nil {
+		rw.Header().Set("Content-Range", "bytes */*")
+		webServer.writeError(rw, req, http.StatusRequestedRangeNotSatisfiable)
+		webServer.logger.Println("Upload: 416: " + header + " (" + req.URL.Path + ")")
+		return
+	}
+	maxBodySize := webServer.Settings().MaxBodySize
+	if maxBodySize > 0 && contentRange.total > maxBodySize {
+		webServer.bodyError(rw, req, &http.MaxBytesError{Limit: maxBodySize})
+		return
+	}
+	handler.putPart(rw, req, filePath, contentRange)
+}
+
+// lock locks filePath for the request and returns the unlock function, which drops the lock once unused
+func (handler *uploadHandler) lock(filePath string) (unlock func()) {
+	handler.locksMutex.Lock()
+	if handler.locks == nil {
+		handler.locks = map[string]*uploadLock{}
+	}
+	lock := handler.locks[filePath]
+	if lock == nil {
+		lock = &uploadLock{}
+		handler.locks[filePath] = lock
+	}
+	lock.users++
+	handler.locksMutex.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		handler.locksMutex.Lock()
+		lock.users--
+		if lock.users == 0 {
+			delete(handler.locks, filePath)
+		}
+		handler.locksMutex.Unlock()
+	}
+}
+
+func (handler *uploadHandler) putFile(rw http.ResponseWriter, req *http.Request, filePath string) {
+	// a name of its own so the part file of a ranged upload of the same file is left alone
+	file, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*"+partSuffix)
+	if err != nil {
+		handler.fail(rw, req, err)
+		return
+	}
+	partPath := file.Name()
+	// removes the part file unless complete renamed it, also if the request fails or panics
+	Defer(req, func() { _ = os.Remove(partPath) })
+	_, err = io.Copy(file, req.Body)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		handler.bodyError(rw, req, err)
+		return
+	}
+	handler.complete(rw, req, partPath, filePath)
+}
+
+func (handler *uploadHandler) putPart(rw http.ResponseWriter, req *http.Request, filePath string, contentRange contentRange) {
+	partPath := filePath + partSuffix
+	var received int64
+	info, err := os.Stat(partPath)
+	if err == nil {
+		received = info.Size()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		handler.fail(rw, req, err)
+		return
+	}
+
+	totalPath := filePath + partTotalSuffix
+	total, err := readPartTotal(totalPath)
+	if err != nil {
+		handler.fail(rw, req, err)
+		return
+	}
+	if total != -1 && total != contentRange.total {
+		// the total of a running upload cannot change, a shorter total would complete it with the wrong size
+		rw.Header().Set("Content-Range", "bytes */"+strconv.FormatInt(total, 10))
+		setReceivedRange(rw, received)
+		handler.webServer.writeError(rw, req, http.StatusRequestedRangeNotSatisfiable)
+		handler.webServer.logger.Println("Upload: 416: total " + strconv.FormatInt(contentRange.total, 10) + ", expected " +
+			strconv.FormatInt(total, 10) + " (" + req.URL.Path + ")")
+		return
+	}
+
+	if contentRange.start == -1 {
+		handler.incomplete(rw, received)
+		return
+	}
+	if contentRange.start != received {
+		// parts out of order, the client resumes at the end of the Range header
+		setReceivedRange(rw, received)
+		handler.webServer.writeError(rw, req, http.StatusConflict)
+		handler.webServer.logger.Println("Upload: 409: part at " + strconv.FormatInt(contentRange.start, 10) + ", expected " +
+			strconv.FormatInt(received, 10) + " (" + req.URL.Path + ")")
+		return
+	}
+
+	if total == -1 {
+		err = os.WriteFile(totalPath, []byte(strconv.FormatInt(contentRange.total, 10)), 0666)
+		if err != nil {
+			handler.fail(rw, req, err)
+			return
+		}
+	}
+	file, err := os.OpenFile(partPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		handler.fail(rw, req, err)
+		return
+	}
+	length := contentRange.end - contentRange.start + 1
+	written, err := io.Copy(file, io.LimitReader(req.Body, length))
+	if err == nil && written < length {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		// keep the bytes before a failed part out of the upload
+		_ = file.Truncate(received)
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		handler.bodyError(rw, req, err)
+		return
+	}
+
+	if contentRange.end+1 < contentRange.total {
+		handler.incomplete(rw, contentRange.end+1)
+		return
+	}
+	handler.complete(rw, req, partPath, filePath)
+	if _, err := os.Stat(partPath); errors.Is(err, fs.ErrNotExist) {
+		_ = os.Remove(totalPath)
+	}
+}
+
+// readPartTotal returns the total declared by the first part of an upload, -1 before the first part
+func readPartTotal(totalPath string) (int64, error) {
+	data, err := os.ReadFile(totalPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return -1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(data), 10, 64)
+}
+
+// complete scans the finished upload at partPath, encrypts it with the Keyring of the mount and moves it to its final name
+func (handler *uploadHandler) complete(rw http.ResponseWriter, req *http.Request, partPath string, filePath string) {
+	webServer := handler.webServer
+	if webServer.uploadScanner != nil {
+		file, err := os.Open(partPath)
+		if err != nil {
+			handler.fail(rw, req, err)
+			return
+		}
+		signature, err := webServer.uploadScanner.Scan(req.Context(), path.Base(req.URL.Path), file)
+		_ = file.Close()
+		if err != nil {
+			webServer.writeErrorCause(rw, req, http.StatusServiceUnavailable, err)
+			webServer.logger.Println("Upload Scanner: 503: " + err.Error())
+			return
+		}
+		if signature != "" {
+			_ = os.Remove(partPath)
+			infected := &infectedError{name: req.URL.Path, signature: signature}
+			webServer.writeErrorCause(rw, req, http.StatusUnprocessableEntity, infected)
+			webServer.logger.Println("Upload Scanner: 422: " + infected.Error())
+			return
+		}
+	}
+
+	if handler.options.Keyring != nil {
+		err := handler.encrypt(req, partPath)
+		if err != nil {
+			handler.fail(rw, req, err)
+			return
+		}
+	}
+
+	_, err := os.Stat(filePath)
+	replaced := err == nil
+	err = os.Rename(partPath, filePath)
+	if err != nil {
+		handler.fail(rw, req, err)
+		return
+	}
+	if replaced {
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+	rw.Header().Set("Location", req.URL.Path)
+	rw.WriteHeader(http.StatusCreated)
+}
+
+// encrypt replaces the upload at partPath with its EncryptFile encryption under the name the mount reads it with
+func (handler *uploadHandler) encrypt(req *http.Request, partPath string) error {
+	file, err := os.ReadFile(partPath)
+	if err != nil {
+		return err
+	}
+	name, _ := storageName(strings.TrimPrefix(req.URL.Path, handler.prefix))
+	encrypted, err := EncryptFile(*handler.options.Keyring, name, file)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(partPath, encrypted, 0666)
+}
+
+func (handler *uploadHandler) incomplete(rw http.ResponseWriter, received int64) {
+	setReceivedRange(rw, received)
+	rw.WriteHeader(http.StatusPermanentRedirect)
+}
+
+func setReceivedRange(rw http.ResponseWriter, received int64) {
+	if received > 0 {
+		rw.Header().Set("Range", "bytes=0-"+strconv.FormatInt(received-1, 10))
+	}
+}
+
+func (handler *uploadHandler) bodyError(rw http.ResponseWriter, req *http.Request, err error) {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) || errors.Is(err, io.ErrUnexpectedEOF) {
+		handler.webServer.bodyError(rw, req, err)
+		return
+	}
+	handler.fail(rw, req, err)
+}
+
+func (handler *uploadHandler) fail(rw http.ResponseWriter, req *http.Request, err error) {
+	handler.webServer.writeErrorCause(rw, req, http.StatusInternalServerError, err)
+	handler.webServer.logger.Println("Upload: 500: " + err.Error())
+}
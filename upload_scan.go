@@ -0,0 +1,169 @@
+package webserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// UploadScanner checks uploaded files before multipart handlers receive them
+type UploadScanner interface {
+	// Scan returns the signature found in content, an empty signature means the file is clean
+	Scan(ctx context.Context, name string, content io.Reader) (signature string, err error)
+}
+
+// SetUploadScanner scans every file part of multipart handlers before binding. Requests with an infected file are answered
+// with 422 Unprocessable Entity and logged, if the scanner fails requests are answered with 503 Service Unavailable.
+func (webServer *WebServer) SetUploadScanner(scanner UploadScanner) {
+	webServer.uploadScanner = scanner
+}
+
+// infectedError is returned by scanUploads for a file the scanner found a signature in
+type infectedError struct {
+	name      string
+	signature string
+}
+
+func (err *infectedError) Error() string {
+	return "infected upload " + err.name + ": " + err.signature
+}
+
+func (webServer *WebServer) scanUploads(ctx context.Context, files map[string][]*multipart.FileHeader) error {
+	if webServer.uploadScanner == nil {
+		return nil
+	}
+	for _, headers := range files {
+		for _, header := range headers {
+			file, err := header.Open()
+			if err != nil {
+				return err
+			}
+			signature, err := webServer.uploadScanner.Scan(ctx, header.Filename, file)
+			_ = file.Close()
+			if err != nil {
+				return err
+			}
+			if signature != "" {
+				return &infectedError{name: header.Filename, signature: signature}
+			}
+		}
+	}
+	return nil
+}
+
+type commandScanner struct {
+	name string
+	args []string
+}
+
+// NewCommandScanner runs the command for every file with the content on stdin, e.g. NewCommandScanner("clamscan", "--no-summary", "-").
+// Exit code 0 means clean and 1 infected with the signature taken from the output, as clamscan and clamdscan report it.
+func NewCommandScanner(name string, args ...string) UploadScanner {
+	return &commandScanner{name: name, args: args}
+}
+
+func (scanner *commandScanner) Scan(ctx context.Context, name string, content io.Reader) (string, error) {
+	cmd := exec.CommandContext(ctx, scanner.name, scanner.args...)
+	cmd.Stdin = content
+	output, err := cmd.Output()
+
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) && exitError.ExitCode() == 1 {
+		return clamavSignature(string(output)), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return "", nil
+}
+
+type clamdScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamdScanner streams files to a clamd daemon with the INSTREAM command, e.g. NewClamdScanner("unix", "/run/clamav/clamd.ctl")
+func NewClamdScanner(network string, address string) UploadScanner {
+	return &clamdScanner{network: network, address: address, timeout: time.Minute}
+}
+
+func (scanner *clamdScanner) Scan(ctx context.Context, name string, content io.Reader) (string, error) {
+	dialer := net.Dialer{Timeout: scanner.timeout}
+	conn, err := dialer.DialContext(ctx, scanner.network, scanner.address)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = conn.Close() }()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(scanner.timeout)
+	}
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = conn.Write([]byte("zINSTREAM\x00"))
+	if err != nil {
+		return "", err
+	}
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := content.Read(chunk)
+		if n > 0 {
+			size := binary.BigEndian.AppendUint32(nil, uint32(n))
+			_, writeErr := conn.Write(append(size, chunk[:n]...))
+			if writeErr != nil {
+				return "", writeErr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	_, err = conn.Write([]byte{0, 0, 0, 0})
+	if err != nil {
+		return "", err
+	}
+
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		return "", err
+	}
+	result := string(bytes.TrimRight(reply, "\x00\n"))
+	switch {
+	case strings.HasSuffix(result, " OK"):
+		return "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		return clamavSignature(result), nil
+	default:
+		return "", errors.New("clamd: " + result)
+	}
+}
+
+// clamavSignature extracts the signature of a "<name>: <signature> FOUND" line
+func clamavSignature(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasSuffix(line, " FOUND") {
+			continue
+		}
+		line = strings.TrimSuffix(line, " FOUND")
+		if index := strings.LastIndex(line, ": "); index >= 0 {
+			line = line[index+2:]
+		}
+		return line
+	}
+	return "unknown"
+}
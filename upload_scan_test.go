@@ -0,0 +1,99 @@
+package webserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd answers INSTREAM commands like clamd, reporting content containing EICAR
+func fakeClamd(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			command := make([]byte, len("zINSTREAM\x00"))
+			_, _ = io.ReadFull(conn, command)
+			var content []byte
+			for {
+				var size uint32
+				if binary.Read(conn, binary.BigEndian, &size) != nil || size == 0 {
+					break
+				}
+				chunk := make([]byte, size)
+				_, _ = io.ReadFull(conn, chunk)
+				content = append(content, chunk...)
+			}
+			if bytes.Contains(content, []byte("EICAR")) {
+				_, _ = conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+			} else {
+				_, _ = conn.Write([]byte("stream: OK\x00"))
+			}
+			_ = conn.Close()
+		}
+	}()
+	return listener
+}
+
+func TestUploadScanner(t *testing.T) {
+	clamd := fakeClamd(t)
+	defer func() { _ = clamd.Close() }()
+
+	type upload struct {
+		File *multipart.FileHeader `form:"file"`
+	}
+	scanners := map[string]UploadScanner{
+		"command": NewCommandScanner("sh", "-c", `if grep -q EICAR; then echo "stdin: Eicar-Test-Signature FOUND"; exit 1; fi`),
+		"clamd":   NewClamdScanner("tcp", clamd.Addr().String()),
+		"down":    NewClamdScanner("tcp", "127.0.0.1:1"),
+	}
+
+	tests := []struct {
+		scanner, content string
+		status           int
+	}{
+		{"command", "hello", http.StatusOK},
+		{"command", eicar, http.StatusUnprocessableEntity},
+		{"clamd", strings.Repeat("hello", 10000), http.StatusOK},
+		{"clamd", eicar, http.StatusUnprocessableEntity},
+		{"down", "hello", http.StatusServiceUnavailable},
+	}
+
+	for _, test := range tests {
+		webServer := NewWebServer(*NewSettings())
+		webServer.SetUploadScanner(scanners[test.scanner])
+		NewMultipartHandler(webServer, HTTPMethodPost, "/upload", func(rw http.ResponseWriter, req *http.Request, values upload) {})
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "file.txt")
+		_, _ = part.Write([]byte(test.content))
+		_ = writer.Close()
+		req := httptest.NewRequest(http.MethodPost, "/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("%s %.10q: status %d, want %d", test.scanner, test.content, rec.Code, test.status)
+		}
+	}
+
+	if signature := clamavSignature("/tmp/upload: Win.Test.EICAR_HDB-1 FOUND\n"); signature != "Win.Test.EICAR_HDB-1" {
+		t.Errorf("signature = %q", signature)
+	}
+}
@@ -0,0 +1,187 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRangedUpload(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "upload-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	settings := NewSettings()
+	settings.Root = "root"
+	webServer := NewWebServer(*settings)
+	options := NewMountOptions()
+	options.Uploads = true
+	options.FileExtensionFilter = []string{"exe"}
+	webServer.Mount("/uploads", dir, *options)
+
+	tests := []struct {
+		path         string
+		contentRange string
+		body         string
+		status       int
+		rangeHeader  string
+	}{
+		{"/uploads/small.txt", "", "small", http.StatusCreated, ""},
+		{"/uploads/small.txt", "", "replaced", http.StatusNoContent, ""},
+		{"/uploads/big.bin", "bytes 0-3/10", "0123", http.StatusPermanentRedirect, "bytes=0-3"},
+		{"/uploads/big.bin", "bytes 6-9/10", "6789", http.StatusConflict, "bytes=0-3"},
+		{"/uploads/big.bin", "bytes */10", "", http.StatusPermanentRedirect, "bytes=0-3"},
+		{"/uploads/big.bin", "bytes 4-7/10", "45", http.StatusBadRequest, ""},
+		{"/uploads/big.bin", "bytes 4-7/10", "4567", http.StatusPermanentRedirect, "bytes=0-7"},
+		{"/uploads/big.bin", "bytes 8-9/10", "89", http.StatusCreated, ""},
+		{"/uploads/other.bin", "bytes 5-2/10", "", http.StatusRequestedRangeNotSatisfiable, ""},
+		{"/uploads/sized.bin", "bytes 0-3/10", "0123", http.StatusPermanentRedirect, "bytes=0-3"},
+		{"/uploads/sized.bin", "bytes 4-5/6", "45", http.StatusRequestedRangeNotSatisfiable, "bytes=0-3"},
+		{"/uploads/sized.bin", "bytes */6", "", http.StatusRequestedRangeNotSatisfiable, "bytes=0-3"},
+		{"/uploads/sized.bin", "bytes 4-9/10", "456789", http.StatusCreated, ""},
+		{"/uploads/sized.bin.part.total", "", "6", http.StatusMethodNotAllowed, ""},
+		{"/uploads/tool.exe", "", "MZ", http.StatusForbidden, ""},
+		{"/uploads/missing/file.txt", "", "x", http.StatusConflict, ""},
+		{"/uploads/..%2fescape.txt", "", "x", http.StatusForbidden, ""},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPut, test.path, strings.NewReader(test.body))
+		if test.contentRange != "" {
+			req.Header.Set("Content-Range", test.contentRange)
+		}
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("PUT %s %q: %d, want %d", test.path, test.contentRange, rec.Code, test.status)
+		}
+		if rangeHeader := rec.Header().Get("Range"); rangeHeader != test.rangeHeader {
+			t.Errorf("PUT %s %q: Range = %q, want %q", test.path, test.contentRange, rangeHeader, test.rangeHeader)
+		}
+	}
+
+	for name, want := range map[string]string{"small.txt": "replaced", "big.bin": "0123456789", "sized.bin": "0123456789"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v, want %q", name, data, err, want)
+		}
+		if _, err := os.Stat(filepath.Join(dir, name+partTotalSuffix)); err == nil {
+			t.Errorf("%s: total of the completed upload kept", name)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/uploads/big.bin", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "0123456789" {
+		t.Errorf("GET uploaded file: %d %q", rec.Code, rec.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/uploads/pending.bin", strings.NewReader("01"))
+	req.Header.Set("Content-Range", "bytes 0-1/4")
+	webServer.mux.ServeHTTP(httptest.NewRecorder(), req)
+	rec = httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/uploads/pending.bin.part", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET incomplete upload: %d, want 404", rec.Code)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		header string
+		want   contentRange
+		valid  bool
+	}{
+		{"bytes 0-99/200", contentRange{0, 99, 200}, true},
+		{"bytes */200", contentRange{-1, -1, 200}, true},
+		{"bytes 100-199/200", contentRange{100, 199, 200}, true},
+		{"bytes 100-200/200", contentRange{}, false},
+		{"bytes 10-5/200", contentRange{}, false},
+		{"bytes 0-99/*", contentRange{}, false},
+		{"items 0-99/200", contentRange{}, false},
+		{"bytes 0-99", contentRange{}, false},
+	}
+
+	for _, test := range tests {
+		got, err := parseContentRange(test.header)
+		if (err == nil) != test.valid || got != test.want {
+			t.Errorf("parseContentRange(%q) = %v, %v", test.header, got, err)
+		}
+	}
+}
+
+func TestUploadLimitsAndEncryption(t *testing.T) {
+	dir := t.TempDir()
+	settings := NewSettings()
+	settings.MaxBodySize = 16
+	webServer := NewWebServer(*settings)
+	keyring := Keyring{Current: "2024", Keys: map[string][]byte{"2024": make([]byte, 32)}}
+	options := NewMountOptions()
+	options.Uploads = true
+	options.Keyring = &keyring
+	webServer.Mount("/uploads", dir, *options)
+
+	put := func(path string, contentRange string, body string) int {
+		req := httptest.NewRequest(http.MethodPut, path, strings.NewReader(body))
+		if contentRange != "" {
+			req.Header.Set("Content-Range", contentRange)
+		}
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// small parts of a file larger than MaxBodySize are rejected up front
+	if code := put("/uploads/big.bin", "bytes 0-3/100", "0123"); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("part of a too large file = %d, want 413", code)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "big.bin"+partSuffix)); err == nil {
+		t.Error("part of a too large file was stored")
+	}
+
+	if code := put("/uploads/notes.txt", "bytes 0-5/12", "secret"); code != http.StatusPermanentRedirect {
+		t.Fatalf("first part = %d", code)
+	}
+	if code := put("/uploads/notes.txt", "bytes 6-11/12", " notes"); code != http.StatusCreated {
+		t.Fatalf("last part = %d", code)
+	}
+	stored, err := os.ReadFile(filepath.Join(dir, "notes.txt"))
+	if err != nil || strings.Contains(string(stored), "secret") {
+		t.Errorf("stored upload = %q, %v, want encrypted", stored, err)
+	}
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/uploads/notes.txt", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "secret notes" {
+		t.Errorf("GET encrypted upload = %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestUploadLocksAreReleased(t *testing.T) {
+	handler := &uploadHandler{}
+	unlock := handler.lock("a.txt")
+	done := make(chan struct{})
+	go func() {
+		handler.lock("a.txt")()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("second request did not wait for the lock")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	<-done
+
+	handler.locksMutex.Lock()
+	defer handler.locksMutex.Unlock()
+	if len(handler.locks) != 0 {
+		t.Errorf("locks after the requests = %v", handler.locks)
+	}
+}
@@ -1,8 +1,9 @@
 package webserver
 
 import (
-	"context"
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"github.com/a-h/templ"
 	"net/http"
 	"net/url"
@@ -22,7 +23,8 @@ func NewURLBodyHandler[T any](
 	webServer.NewHandlerBody(method, pattern, func(rw http.ResponseWriter, req *http.Request, body []byte) {
 		query, err := url.ParseQuery(string(body))
 		if err != nil {
-			webServer.settings.Logger.Fatalln(err)
+			webServer.bodyError(rw, req, err)
+			return
 		}
 
 		values := new(T)
@@ -46,7 +48,8 @@ func NewURLBodyHandler[T any](
 
 			err := json.Unmarshal([]byte(value), dst.Interface())
 			if err != nil {
-				panic(err)
+				webServer.bodyError(rw, req, fmt.Errorf("%s: %w", field.Name, err))
+				return
 			}
 
 			v.Field(i).Set(dst.Elem())
@@ -54,6 +57,7 @@ func NewURLBodyHandler[T any](
 
 		handler(rw, req, *values)
 	})
+	webServer.documentRequest(method, pattern, "form", reflect.TypeFor[T]())
 }
 
 // htmx templ addon
@@ -66,9 +70,14 @@ func NewHTMXTemplURLBodyHandler[D, C any](
 	handler func(http.ResponseWriter, *http.Request, D) C,
 ) {
 	NewURLBodyHandler(webServer, method, pattern, func(rw http.ResponseWriter, req *http.Request, data D) {
-		err := component(handler(rw, req, data)).Render(context.Background(), rw)
+		// rendered into a buffer, so a failing component is answered with 500 instead of a truncated fragment
+		var body bytes.Buffer
+		err := component(handler(rw, req, data)).Render(req.Context(), &body)
 		if err != nil {
-			webServer.settings.Logger.Fatalln(err)
+			Logger(req).Println("HTMX Templ: 500: " + err.Error())
+			webServer.writeErrorCause(rw, req, http.StatusInternalServerError, err)
+			return
 		}
+		_, _ = body.WriteTo(rw)
 	})
 }
@@ -0,0 +1,197 @@
+package webserver
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type UsageOptions struct {
+	// Key returns the principal a request is accounted to, requests with an empty key are not counted
+	Key       func(req *http.Request) string
+	Window    time.Duration
+	Retention time.Duration
+}
+
+func NewUsageOptions(key func(req *http.Request) string) *UsageOptions {
+	return &UsageOptions{
+		Key:       key,
+		Window:    time.Hour,
+		Retention: 31 * 24 * time.Hour,
+	}
+}
+
+// UsageRecord is the usage of one principal within one window
+type UsageRecord struct {
+	Key          string
+	WindowStart  time.Time
+	Requests     int64
+	BytesIn      int64
+	BytesOut     int64
+	ClientErrors int64
+	ServerErrors int64
+	ErrorRate    float64
+}
+
+type usageTracker struct {
+	options UsageOptions
+
+	mutex   sync.Mutex
+	windows map[time.Time]map[string]*UsageRecord
+}
+
+// EnableUsageReports aggregates requests, bytes and errors per principal over fixed windows
+func (webServer *WebServer) EnableUsageReports(options UsageOptions) {
+	if options.Window <= 0 {
+		options.Window = time.Hour
+	}
+	webServer.usage = &usageTracker{options: options, windows: map[time.Time]map[string]*UsageRecord{}}
+}
+
+// UsageReport returns the records of windows starting within [from, to), sorted by window and key
+func (webServer *WebServer) UsageReport(from time.Time, to time.Time) []UsageRecord {
+	if webServer.usage == nil {
+		return nil
+	}
+	return webServer.usage.report(from, to, "")
+}
+
+// UsageHandler serves the usage report as json or, with format=csv, as csv.
+// The optional query parameters from and to (RFC 3339) limit the windows and key selects one principal.
+func (webServer *WebServer) UsageHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if webServer.usage == nil {
+			webServer.writeError(rw, req, http.StatusNotFound)
+			return
+		}
+
+		var query struct {
+			From   time.Time `query:"from"`
+			To     time.Time `query:"to"`
+			Key    string    `query:"key"`
+			Format string    `query:"format"`
+		}
+		err := BindQuery(req, &query)
+		if err != nil {
+			webServer.bodyError(rw, req, err)
+			return
+		}
+		if query.To.IsZero() {
+			query.To = time.Now()
+		}
+
+		records := webServer.usage.report(query.From, query.To, query.Key)
+		if query.Format == "csv" {
+			rw.Header().Set("Content-Type", "text/csv")
+			err = writeUsageCSV(rw, records)
+		} else {
+			rw.Header().Set("Content-Type", "application/json")
+			err = json.NewEncoder(rw).Encode(records)
+		}
+		if err != nil {
+			webServer.logger.Println("Usage Report: " + err.Error())
+		}
+	})
+}
+
+func (tracker *usageTracker) record(req *http.Request, status int, bytesOut int64, now time.Time) {
+	key := tracker.options.Key(req)
+	if key == "" {
+		return
+	}
+	windowStart := now.Truncate(tracker.options.Window)
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	window, ok := tracker.windows[windowStart]
+	if !ok {
+		window = map[string]*UsageRecord{}
+		tracker.windows[windowStart] = window
+		tracker.expire(now)
+	}
+	record, ok := window[key]
+	if !ok {
+		record = &UsageRecord{Key: key, WindowStart: windowStart}
+		window[key] = record
+	}
+
+	record.Requests++
+	record.BytesIn += max(req.ContentLength, 0)
+	record.BytesOut += bytesOut
+	switch {
+	case status >= 500:
+		record.ServerErrors++
+	case status >= 400:
+		record.ClientErrors++
+	}
+}
+
+// expire must be called with the mutex held
+func (tracker *usageTracker) expire(now time.Time) {
+	if tracker.options.Retention <= 0 {
+		return
+	}
+	for windowStart := range tracker.windows {
+		if now.Sub(windowStart) > tracker.options.Retention {
+			delete(tracker.windows, windowStart)
+		}
+	}
+}
+
+func (tracker *usageTracker) report(from time.Time, to time.Time, key string) []UsageRecord {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	records := []UsageRecord{}
+	for windowStart, window := range tracker.windows {
+		if windowStart.Before(from) || !windowStart.Before(to) {
+			continue
+		}
+		for _, record := range window {
+			if key != "" && record.Key != key {
+				continue
+			}
+			copied := *record
+			copied.ErrorRate = float64(copied.ClientErrors+copied.ServerErrors) / float64(copied.Requests)
+			records = append(records, copied)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].WindowStart.Equal(records[j].WindowStart) {
+			return records[i].WindowStart.Before(records[j].WindowStart)
+		}
+		return records[i].Key < records[j].Key
+	})
+	return records
+}
+
+func writeUsageCSV(rw http.ResponseWriter, records []UsageRecord) error {
+	writer := csv.NewWriter(rw)
+	err := writer.Write([]string{"key", "window_start", "requests", "bytes_in", "bytes_out", "client_errors", "server_errors", "error_rate"})
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		err := writer.Write([]string{
+			record.Key,
+			record.WindowStart.UTC().Format(time.RFC3339),
+			strconv.FormatInt(record.Requests, 10),
+			strconv.FormatInt(record.BytesIn, 10),
+			strconv.FormatInt(record.BytesOut, 10),
+			strconv.FormatInt(record.ClientErrors, 10),
+			strconv.FormatInt(record.ServerErrors, 10),
+			strconv.FormatFloat(record.ErrorRate, 'f', 4, 64),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
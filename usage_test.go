@@ -0,0 +1,60 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUsageReports(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	webServer.EnableUsageReports(*NewUsageOptions(func(req *http.Request) string {
+		return req.Header.Get("X-Api-Key")
+	}))
+	webServer.NewHandleFunc(HTTPMethodPost, "/orders", func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Has("fail") {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = rw.Write([]byte("created"))
+	})
+	webServer.NewHandler(HTTPMethodGet, "/admin/usage", webServer.UsageHandler())
+
+	for _, request := range []struct{ key, query string }{
+		{"tenant-a", ""},
+		{"tenant-a", "?fail"},
+		{"tenant-b", ""},
+		{"", ""},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/orders"+request.query, strings.NewReader("body"))
+		req.Header.Set("X-Api-Key", request.key)
+		webServer.mux.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	records := webServer.UsageReport(time.Time{}, time.Now().Add(time.Hour))
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2: %+v", len(records), records)
+	}
+	a := records[0]
+	if a.Key != "tenant-a" || a.Requests != 2 || a.BytesIn != 8 || a.BytesOut != 7 || a.ServerErrors != 1 || a.ErrorRate != 0.5 {
+		t.Errorf("tenant-a = %+v", a)
+	}
+
+	rec := httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/usage?key=tenant-b", nil))
+	var fromHandler []UsageRecord
+	err := json.Unmarshal(rec.Body.Bytes(), &fromHandler)
+	if err != nil || len(fromHandler) != 1 || fromHandler[0].Key != "tenant-b" {
+		t.Errorf("json report = %s (%v)", rec.Body.String(), err)
+	}
+
+	rec = httptest.NewRecorder()
+	webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/usage?format=csv", nil))
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "key,window_start") || !strings.HasPrefix(lines[1], "tenant-a,") {
+		t.Errorf("csv report = %q", lines)
+	}
+}
@@ -0,0 +1,260 @@
+package webserver
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/slices"
+)
+
+// Validatable is implemented by request bodies with rules beyond the `validate` field tags, see Validate.
+// Validate may return ValidationErrors to report single fields.
+type Validatable interface {
+	Validate() error
+}
+
+// FieldError is a rule a field broke, Field is the dotted json path of the field, e.g. "address.city"
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors are the field errors of a value, see Validate
+type ValidationErrors []FieldError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		if err.Field == "" {
+			messages = append(messages, err.Message)
+			continue
+		}
+		messages = append(messages, err.Field+": "+err.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validate checks the `validate` tags of the fields of the struct value and its nested structs and calls Validate
+// if value implements Validatable. The comma separated rules are
+//
+//	required          the field is not its zero value, for pointers not nil
+//	min=n, max=n      bounds of numbers and of the length of strings, slices and maps
+//	enum=a|b|c        the string form of the field is one of the values
+//	regexp=pattern    strings match pattern, it has to be the last rule and may contain commas
+//
+// Rules other than required are skipped for zero values and nil pointers, so they only apply to set fields.
+// The errors of the tags are returned as ValidationErrors before Validate is called.
+func Validate(value any) error {
+	v := reflect.ValueOf(value)
+	var errs ValidationErrors
+	validateValue(v, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if validatable, ok := value.(Validatable); ok {
+		return validatable.Validate()
+	}
+	if v.Kind() != reflect.Pointer {
+		pointer := reflect.New(v.Type())
+		pointer.Elem().Set(v)
+		if validatable, ok := pointer.Interface().(Validatable); ok {
+			return validatable.Validate()
+		}
+	}
+	return nil
+}
+
+// validationRule is a parsed `validate` tag
+type validationRule struct {
+	required bool
+	min, max *float64
+	enum     []string
+	pattern  *regexp.Regexp
+}
+
+var validationRules sync.Map // tag -> validationRule
+
+func parseValidationTag(tag string) (validationRule, error) {
+	if rule, ok := validationRules.Load(tag); ok {
+		return rule.(validationRule), nil
+	}
+
+	var rule validationRule
+	rest := tag
+	for rest != "" {
+		var part string
+		if strings.HasPrefix(rest, "regexp=") {
+			part, rest = rest, ""
+		} else {
+			part, rest, _ = strings.Cut(rest, ",")
+		}
+
+		name, argument, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "required":
+			rule.required = true
+		case "min", "max":
+			bound, err := strconv.ParseFloat(argument, 64)
+			if err != nil {
+				return rule, fmt.Errorf("validate tag %q: %s: %w", tag, name, err)
+			}
+			if name == "min" {
+				rule.min = &bound
+			} else {
+				rule.max = &bound
+			}
+		case "enum":
+			rule.enum = strings.Split(argument, "|")
+		case "regexp":
+			pattern, err := regexp.Compile(argument)
+			if err != nil {
+				return rule, fmt.Errorf("validate tag %q: %w", tag, err)
+			}
+			rule.pattern = pattern
+		case "":
+		default:
+			return rule, errors.New("validate tag " + strconv.Quote(tag) + ": unknown rule " + name)
+		}
+	}
+	validationRules.Store(tag, rule)
+	return rule, nil
+}
+
+// checkValidationTags panics on invalid `validate` tags of t, so they fail on registration instead of on requests
+func checkValidationTags(t reflect.Type, seen map[reflect.Type]bool) {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if tag, ok := field.Tag.Lookup("validate"); ok {
+			_, err := parseValidationTag(tag)
+			if err != nil {
+				panic(t.Name() + "." + field.Name + ": " + err.Error())
+			}
+		}
+		checkValidationTags(field.Type, seen)
+	}
+}
+
+func validateValue(v reflect.Value, path string, errs *ValidationErrors) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := fieldPath(path, field)
+			if tag, ok := field.Tag.Lookup("validate"); ok {
+				rule, err := parseValidationTag(tag)
+				if err != nil {
+					*errs = append(*errs, FieldError{Field: name, Message: err.Error()})
+					continue
+				}
+				if !validateField(v.Field(i), name, rule, errs) {
+					continue
+				}
+			}
+			validateValue(v.Field(i), name, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), path+"["+strconv.Itoa(i)+"]", errs)
+		}
+	}
+}
+
+// validateField applies rule to v and reports whether it passed
+func validateField(v reflect.Value, name string, rule validationRule, errs *ValidationErrors) bool {
+	fail := func(message string) bool {
+		*errs = append(*errs, FieldError{Field: name, Message: message})
+		return false
+	}
+
+	if v.IsZero() {
+		if rule.required {
+			return fail("is required")
+		}
+		return true
+	}
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return true
+		}
+		v = v.Elem()
+	}
+
+	var number float64
+	isNumber := true
+	unit := ""
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		number = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		number = v.Float()
+	case reflect.String:
+		number, unit = float64(len([]rune(v.String()))), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		number, unit = float64(v.Len()), " items"
+	default:
+		isNumber = false
+	}
+	verb := "must be"
+	if unit != "" {
+		verb = "must have"
+	}
+	if isNumber && rule.min != nil && number < *rule.min {
+		return fail(verb + " at least " + formatBound(*rule.min) + unit)
+	}
+	if isNumber && rule.max != nil && number > *rule.max {
+		return fail(verb + " at most " + formatBound(*rule.max) + unit)
+	}
+
+	if rule.enum != nil && !slices.Contains(rule.enum, fmt.Sprint(v.Interface())) {
+		return fail("must be one of " + strings.Join(rule.enum, ", "))
+	}
+	if rule.pattern != nil && v.Kind() == reflect.String && !rule.pattern.MatchString(v.String()) {
+		return fail("must match " + rule.pattern.String())
+	}
+	return true
+}
+
+func formatBound(bound float64) string {
+	return strconv.FormatFloat(bound, 'f', -1, 64)
+}
+
+// fieldPath returns the json name of field below path
+func fieldPath(path string, field reflect.StructField) string {
+	name := field.Name
+	if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag != "" && tag != "-" {
+		name = tag
+	}
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,63 @@
+package webserver
+
+import (
+	"net/http"
+
+	"golang.org/x/exp/slices"
+)
+
+// RouteVariant serves the requests its predicate When matches instead of the default handler of a route
+type RouteVariant struct {
+	When    func(req *http.Request) bool
+	Handler http.Handler
+	// Vary is added to the Vary header of every response of the route so caches keep the variants apart
+	Vary string
+}
+
+// WhenHeader matches requests whose header name equals value, an empty value matches any non empty header
+func WhenHeader(name string, value string, handler http.Handler) RouteVariant {
+	return RouteVariant{
+		When: func(req *http.Request) bool {
+			header := req.Header.Get(name)
+			return header != "" && (value == "" || header == value)
+		},
+		Handler: handler,
+		Vary:    http.CanonicalHeaderKey(name),
+	}
+}
+
+// WhenCookie matches requests whose cookie name equals value, an empty value matches any non empty cookie
+func WhenCookie(name string, value string, handler http.Handler) RouteVariant {
+	return RouteVariant{
+		When: func(req *http.Request) bool {
+			cookie, err := req.Cookie(name)
+			return err == nil && cookie.Value != "" && (value == "" || cookie.Value == value)
+		},
+		Handler: handler,
+		Vary:    "Cookie",
+	}
+}
+
+// NewVariantHandler registers handler for method and pattern, requests matching a variant are served by the first matching one.
+// This allows moving a route to a new implementation behind a feature gate, e.g. WhenHeader("X-Beta", "1", newHandler).
+func (webServer *WebServer) NewVariantHandler(method HTTPMethod, pattern string, handler http.Handler, variants ...RouteVariant) {
+	var vary []string
+	for _, variant := range variants {
+		if variant.Vary != "" && !slices.Contains(vary, variant.Vary) {
+			vary = append(vary, variant.Vary)
+		}
+	}
+
+	webServer.NewHandleFunc(method, pattern, func(rw http.ResponseWriter, req *http.Request) {
+		for _, name := range vary {
+			rw.Header().Add("Vary", name)
+		}
+		for _, variant := range variants {
+			if variant.When(req) {
+				variant.Handler.ServeHTTP(rw, req)
+				return
+			}
+		}
+		handler.ServeHTTP(rw, req)
+	})
+}
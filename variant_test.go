@@ -0,0 +1,51 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVariantHandler(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	serve := func(body string) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			_, _ = rw.Write([]byte(body))
+		})
+	}
+	webServer.NewVariantHandler(HTTPMethodGet, "/checkout", serve("old"),
+		WhenHeader("X-Beta", "1", serve("beta")),
+		WhenCookie("checkout", "v2", serve("v2")),
+	)
+
+	tests := []struct {
+		header, cookie string
+		body           string
+	}{
+		{"", "", "old"},
+		{"1", "", "beta"},
+		{"0", "", "old"},
+		{"", "v2", "v2"},
+		{"", "v1", "old"},
+		{"1", "v2", "beta"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/checkout", nil)
+		if test.header != "" {
+			req.Header.Set("X-Beta", test.header)
+		}
+		if test.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "checkout", Value: test.cookie})
+		}
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+
+		if rec.Body.String() != test.body {
+			t.Errorf("header %q cookie %q: body = %q, want %q", test.header, test.cookie, rec.Body.String(), test.body)
+		}
+		if vary := rec.Header().Values("Vary"); len(vary) != 2 || vary[0] != "X-Beta" || vary[1] != "Cookie" {
+			t.Errorf("Vary = %v", vary)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package webserver
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// VirtualHost returns the routes of requests for host, e.g. "api.example.com" or "*.example.com" for every subdomain.
+// The virtual host has its own handlers, middleware and settings, starting as a copy of the current settings, so
+// SetRoot or SetFallback on it only affect its host. Requests for unknown hosts are served by webServer itself.
+// Middleware of webServer runs for every host before the middleware of the virtual host.
+func (webServer *WebServer) VirtualHost(host string) *WebServer {
+	host = normalizeHost(host)
+	if vhost, ok := webServer.virtualHosts[host]; ok {
+		return vhost
+	}
+
+	vhost := &WebServer{
+		logger:   webServer.logger,
+		settings: webServer.Settings(),
+		events:   webServer.events,
+	}
+	vhost.initMuxes()
+	if webServer.virtualHosts == nil {
+		webServer.virtualHosts = map[string]*WebServer{}
+	}
+	webServer.virtualHosts[host] = vhost
+	return vhost
+}
+
+// virtualHost returns the virtual host of the request, an exact host wins over a wildcard
+func (webServer *WebServer) virtualHost(req *http.Request) *WebServer {
+	if len(webServer.virtualHosts) == 0 {
+		return webServer
+	}
+	host := normalizeHost(req.Host)
+	if vhost, ok := webServer.virtualHosts[host]; ok {
+		return vhost
+	}
+	for index := strings.IndexByte(host, '.'); index >= 0; index = strings.IndexByte(host, '.') {
+		host = host[index+1:]
+		if vhost, ok := webServer.virtualHosts["*."+host]; ok {
+			return vhost
+		}
+	}
+	return webServer
+}
+
+// normalizeHost strips the port and trailing dot and lowercases host
+func normalizeHost(host string) string {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	return strings.ToLower(strings.TrimSuffix(strings.Trim(host, "[]"), "."))
+}
@@ -0,0 +1,60 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestVirtualHost(t *testing.T) {
+	settings := NewSettings()
+	settings.Root = "root"
+	webServer := NewWebServer(*settings)
+	webServer.NewMiddleware(func(rw http.ResponseWriter, req *http.Request) bool {
+		rw.Header().Set("X-Global", "1")
+		return true
+	})
+
+	api := webServer.VirtualHost("API.example.com")
+	api.NewHandleFunc(HTTPMethodGet, "/users", func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("users"))
+	})
+	api.NewMiddleware(func(rw http.ResponseWriter, req *http.Request) bool {
+		rw.Header().Set("X-Api", "1")
+		return true
+	})
+	if webServer.VirtualHost("api.example.com") != api {
+		t.Error("VirtualHost created a second api host")
+	}
+	webServer.VirtualHost("*.example.com").SetRootFS(fstest.MapFS{"index.html": {Data: []byte("tenant")}})
+
+	tests := []struct {
+		host, path string
+		status     int
+		body       string
+		api        string
+	}{
+		{"api.example.com", "/users", http.StatusOK, "users", "1"},
+		{"api.example.com:8080", "/users", http.StatusOK, "users", "1"},
+		{"example.com", "/users", http.StatusTemporaryRedirect, "", ""},
+		{"shop.example.com", "/", http.StatusOK, "tenant", ""},
+		{"a.b.example.com", "/", http.StatusOK, "tenant", ""},
+		{"localhost", "/index.html", http.StatusOK, "TEST", ""},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		req.Host = test.host
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+
+		if rec.Code != test.status || !strings.Contains(rec.Body.String(), test.body) {
+			t.Errorf("%s%s: %d %q, want %d %q", test.host, test.path, rec.Code, rec.Body.String(), test.status, test.body)
+		}
+		if rec.Header().Get("X-Global") != "1" || rec.Header().Get("X-Api") != test.api {
+			t.Errorf("%s%s: middleware headers %v", test.host, test.path, rec.Header())
+		}
+	}
+}
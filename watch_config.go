@@ -0,0 +1,147 @@
+package webserver
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// configPollInterval is how often WatchConfig checks the settings file for changes
+const configPollInterval = time.Second
+
+// reloadableSettings are the fields WatchConfig applies at runtime
+var reloadableSettings = []string{
+	"Root",
+	"FallbackRedirect",
+	"FallbackMode",
+	"FallbackFile",
+	"DirectoryListing",
+	"BlockSymlinkEscape",
+	"BlockDotfiles",
+	"DotfileExceptions",
+	"FileExtensionFilter",
+	"AllowedExtensions",
+	"DenyPaths",
+	"DownloadExtensions",
+	"DownloadPrefixes",
+	"CachePolicies",
+	"MaxBodySize",
+	"MaxMultipartMemory",
+	"MaxResponseSize",
+	"AbortLargeResponses",
+	"LogResponseSizes",
+	"CertFile",
+	"KeyFile",
+	"TrustedProxies",
+}
+
+// WatchConfig checks the settings file fileName every second and applies changes of the reloadable fields Root,
+// the fallback, filter, download, cache policy, body and response size settings, CertFile, KeyFile and TrustedProxies
+// when it was modified. A changed certificate is loaded for the next handshake. Every applied change is logged,
+// changes of other fields are logged as requiring Reload or a restart. The file is loaded with LoadFile on top of
+// NewSettings. The returned function stops watching.
+func (webServer *WebServer) WatchConfig(fileName string) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	modified := configModified(fileName)
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(configPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+			current := configModified(fileName)
+			if current == modified {
+				continue
+			}
+			modified = current
+			err := webServer.applyConfig(fileName)
+			if err != nil {
+				webServer.logger.Println("Config: " + fileName + ": " + err.Error())
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+// configModified identifies a version of fileName by its modification time and size
+func configModified(fileName string) string {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return ""
+	}
+	return info.ModTime().String() + " " + fmt.Sprint(info.Size())
+}
+
+// applyConfig loads fileName and applies its reloadable fields
+func (webServer *WebServer) applyConfig(fileName string) error {
+	loaded := NewSettings()
+	err := loaded.LoadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	settings := webServer.Settings()
+	current := reflect.ValueOf(&settings).Elem()
+	updated := reflect.ValueOf(loaded).Elem()
+	var changes []string
+	certChanged := false
+	for _, name := range reloadableSettings {
+		from, to := current.FieldByName(name), updated.FieldByName(name)
+		if !reflect.DeepEqual(from.Interface(), to.Interface()) {
+			changes = append(changes, fmt.Sprintf("%s %q -> %q", name, formatSetting(from), formatSetting(to)))
+			from.Set(to)
+			certChanged = certChanged || name == "CertFile" || name == "KeyFile"
+		}
+	}
+
+	var restart []string
+	t := current.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Name == "Logger" || slices.Contains(reloadableSettings, field.Name) {
+			continue
+		}
+		if !reflect.DeepEqual(current.Field(i).Interface(), updated.Field(i).Interface()) {
+			restart = append(restart, field.Name)
+		}
+	}
+	if len(restart) > 0 {
+		webServer.logger.Println("Config: " + fileName + ": " + strings.Join(restart, ", ") + " changed, Reload or restart to apply")
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	webServer.servingMutex.Lock()
+	serving := webServer.serving
+	webServer.servingMutex.Unlock()
+	if serving != nil && settings.UseHttps && certChanged {
+		err := webServer.loadCertificate(settings)
+		if err != nil {
+			return err
+		}
+	}
+
+	webServer.settingsMutex.Lock()
+	webServer.settings = settings
+	webServer.settingsMutex.Unlock()
+	webServer.logger.Println("Config: " + fileName + ": " + strings.Join(changes, ", "))
+	return nil
+}
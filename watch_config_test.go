@@ -0,0 +1,57 @@
+package webserver
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchConfig(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "settings.yaml")
+	err := os.WriteFile(fileName, []byte("Root: root\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	settings := NewSettings()
+	settings.Root = "root"
+	settings.Logger = log.New(&logs, "", 0)
+	webServer := NewWebServer(*settings)
+	stop := webServer.WatchConfig(fileName)
+	defer stop()
+
+	err = os.WriteFile(fileName, []byte("Root: public\nFileExtensionFilter: [exe]\nHttpPort: 8080\n"), 0644)
+	if err == nil {
+		// modification times may have a coarse resolution
+		err = os.Chtimes(fileName, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for webServer.Settings().Root != "public" && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	stop()
+
+	applied := webServer.Settings()
+	if applied.Root != "public" || len(applied.FileExtensionFilter) != 1 || applied.FileExtensionFilter[0] != "exe" {
+		t.Errorf("Root, FileExtensionFilter = %q, %q", applied.Root, applied.FileExtensionFilter)
+	}
+	if applied.HttpPort != "80" {
+		t.Errorf("HttpPort = %q, want it to require a reload", applied.HttpPort)
+	}
+	output := logs.String()
+	if !strings.Contains(output, `Root "root" -> "public"`) || !strings.Contains(output, `FileExtensionFilter "" -> "exe"`) {
+		t.Errorf("log does not describe the changes: %s", output)
+	}
+	if !strings.Contains(output, "HttpPort changed, Reload or restart to apply") {
+		t.Errorf("log does not report the port change: %s", output)
+	}
+}
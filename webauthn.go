@@ -0,0 +1,653 @@
+package webserver
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+var (
+	ErrWebAuthnCredentialNotFound = errors.New("webauthn credential not found")
+	errWebAuthn                   = errors.New("webauthn verification failed")
+)
+
+// webAuthnChallengeCookie holds the id of the pending ceremony of the client
+const webAuthnChallengeCookie = "webauthn_challenge"
+
+// WebAuthnCredential is a registered passkey or security key of an identity
+type WebAuthnCredential struct {
+	ID       []byte
+	Identity string
+	// PublicKey is the COSE encoded public key of the authenticator
+	PublicKey []byte
+	SignCount uint32
+	Created   time.Time
+}
+
+// WebAuthnCredentialStore keeps WebAuthn credentials, implementations must be safe for concurrent use
+type WebAuthnCredentialStore interface {
+	SaveWebAuthnCredential(ctx context.Context, credential WebAuthnCredential) error
+	// FindWebAuthnCredential returns ErrWebAuthnCredentialNotFound for unknown ids
+	FindWebAuthnCredential(ctx context.Context, id []byte) (WebAuthnCredential, error)
+	ListWebAuthnCredentials(ctx context.Context, identity string) ([]WebAuthnCredential, error)
+}
+
+type WebAuthnOptions struct {
+	// RPID is the domain the credentials are bound to, e.g. "example.com" also for pages on its subdomains
+	RPID   string
+	RPName string
+	// Origins are the accepted origins of the pages calling the WebAuthn api, empty accepts "https://" + RPID
+	Origins []string
+	// UserVerification is "required", "preferred" or "discouraged", required rejects authenticators without PIN or biometrics
+	UserVerification string
+	// Timeout is how long a ceremony may take from begin to finish
+	Timeout time.Duration
+	Store   WebAuthnCredentialStore
+	// Login is called after a successful login, e.g. to issue a session or a RememberMe cookie
+	Login func(rw http.ResponseWriter, req *http.Request, identity string) error
+}
+
+func NewWebAuthnOptions() *WebAuthnOptions {
+	return &WebAuthnOptions{
+		RPID:             "localhost",
+		RPName:           "WebServer",
+		Origins:          []string{},
+		UserVerification: "preferred",
+		Timeout:          5 * time.Minute,
+		Store:            NewMemoryWebAuthnCredentialStore(),
+	}
+}
+
+type webAuthn struct {
+	webServer *WebServer
+	options   WebAuthnOptions
+	prefix    string
+
+	mutex      sync.Mutex
+	challenges map[string]webAuthnChallenge
+}
+
+type webAuthnChallenge struct {
+	challenge []byte
+	identity  string
+	expires   time.Time
+}
+
+// EnableWebAuthn serves passkey registration and login below prefix, answering the json the WebAuthn api of the
+// browser takes and returns, with binary fields base64url encoded:
+//
+//   - POST prefix/register/begin returns the options for navigator.credentials.create, it requires a principal
+//     authenticated by another middleware, e.g. a password login
+//   - POST prefix/register/finish takes the created credential and stores it for the principal
+//   - POST prefix/login/begin takes an optional {"identity": ...} and returns the options for navigator.credentials.get,
+//     without identity any discoverable credential is accepted
+//   - POST prefix/login/finish takes the assertion, verifies it and calls WebAuthnOptions.Login
+//
+// Signatures of ES256, EdDSA and RS256 keys are verified. The attestation "none" is requested and attestation
+// statements are not verified, so the authenticator model is not checked.
+func (webServer *WebServer) EnableWebAuthn(prefix string, options WebAuthnOptions) {
+	if options.Store == nil {
+		options.Store = NewMemoryWebAuthnCredentialStore()
+	}
+	if len(options.Origins) == 0 {
+		options.Origins = []string{"https://" + options.RPID}
+	}
+	prefix = "/" + strings.Trim(prefix, "/") + "/"
+	webAuthn := &webAuthn{
+		webServer:  webServer,
+		options:    options,
+		prefix:     prefix,
+		challenges: map[string]webAuthnChallenge{},
+	}
+	webServer.handle(http.MethodPost, prefix+"register/begin", "webauthn register begin", http.HandlerFunc(webAuthn.registerBegin))
+	webServer.handle(http.MethodPost, prefix+"register/finish", "webauthn register finish", http.HandlerFunc(webAuthn.registerFinish))
+	webServer.handle(http.MethodPost, prefix+"login/begin", "webauthn login begin", http.HandlerFunc(webAuthn.loginBegin))
+	webServer.handle(http.MethodPost, prefix+"login/finish", "webauthn login finish", http.HandlerFunc(webAuthn.loginFinish))
+}
+
+func (webAuthn *webAuthn) registerBegin(rw http.ResponseWriter, req *http.Request) {
+	identity := Identity(req)
+	if identity == "" {
+		webAuthn.webServer.writeError(rw, req, http.StatusUnauthorized)
+		return
+	}
+	credentials, err := webAuthn.options.Store.ListWebAuthnCredentials(req.Context(), identity)
+	if err != nil {
+		webAuthn.fail(rw, req, http.StatusInternalServerError, err)
+		return
+	}
+
+	challenge := webAuthn.begin(rw, req, identity)
+	webAuthn.writeJSON(rw, http.StatusOK, map[string]any{"publicKey": map[string]any{
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"rp":        map[string]string{"id": webAuthn.options.RPID, "name": webAuthn.options.RPName},
+		"user": map[string]string{
+			"id":          base64.RawURLEncoding.EncodeToString([]byte(identity)),
+			"name":        identity,
+			"displayName": identity,
+		},
+		"pubKeyCredParams": []map[string]any{
+			{"type": "public-key", "alg": coseAlgES256},
+			{"type": "public-key", "alg": coseAlgEdDSA},
+			{"type": "public-key", "alg": coseAlgRS256},
+		},
+		"timeout":            webAuthn.options.Timeout.Milliseconds(),
+		"attestation":        "none",
+		"excludeCredentials": credentialDescriptors(credentials),
+		"authenticatorSelection": map[string]string{
+			"residentKey":      "preferred",
+			"userVerification": webAuthn.options.UserVerification,
+		},
+	}})
+}
+
+func (webAuthn *webAuthn) registerFinish(rw http.ResponseWriter, req *http.Request) {
+	ceremony, ok := webAuthn.finish(rw, req)
+	if !ok || ceremony.identity == "" || ceremony.identity != Identity(req) {
+		webAuthn.fail(rw, req, http.StatusBadRequest, fmt.Errorf("%w: no pending registration", errWebAuthn))
+		return
+	}
+	response, err := readWebAuthnResponse(req)
+	if err != nil {
+		webAuthn.fail(rw, req, http.StatusBadRequest, err)
+		return
+	}
+	err = webAuthn.verifyClientData(response.clientData, "webauthn.create", ceremony.challenge)
+	if err != nil {
+		webAuthn.fail(rw, req, http.StatusBadRequest, err)
+		return
+	}
+
+	attestation, _, err := decodeCBOR(response.attestationObject)
+	attestationMap, ok := attestation.(map[any]any)
+	if err != nil || !ok {
+		webAuthn.fail(rw, req, http.StatusBadRequest, fmt.Errorf("%w: attestation object", errWebAuthn))
+		return
+	}
+	rawAuthData, _ := attestationMap["authData"].([]byte)
+	authData, err := parseWebAuthnAuthData(rawAuthData)
+	if err != nil {
+		webAuthn.fail(rw, req, http.StatusBadRequest, err)
+		return
+	}
+	err = webAuthn.verifyAuthData(authData)
+	if err == nil && authData.credentialID == nil {
+		err = fmt.Errorf("%w: no attested credential", errWebAuthn)
+	}
+	if err == nil {
+		_, err = parseCOSEKey(authData.publicKey)
+	}
+	if err != nil {
+		webAuthn.fail(rw, req, http.StatusBadRequest, err)
+		return
+	}
+
+	_, err = webAuthn.options.Store.FindWebAuthnCredential(req.Context(), authData.credentialID)
+	if err == nil {
+		webAuthn.fail(rw, req, http.StatusConflict, fmt.Errorf("%w: credential already registered", errWebAuthn))
+		return
+	}
+	if !errors.Is(err, ErrWebAuthnCredentialNotFound) {
+		webAuthn.fail(rw, req, http.StatusInternalServerError, err)
+		return
+	}
+	err = webAuthn.options.Store.SaveWebAuthnCredential(req.Context(), WebAuthnCredential{
+		ID:        authData.credentialID,
+		Identity:  ceremony.identity,
+		PublicKey: authData.publicKey,
+		SignCount: authData.signCount,
+		Created:   time.Now(),
+	})
+	if err != nil {
+		webAuthn.fail(rw, req, http.StatusInternalServerError, err)
+		return
+	}
+	webAuthn.webServer.logger.Println("WebAuthn: registered credential for " + ceremony.identity)
+	webAuthn.writeJSON(rw, http.StatusCreated, map[string]string{"id": base64.RawURLEncoding.EncodeToString(authData.credentialID)})
+}
+
+func (webAuthn *webAuthn) loginBegin(rw http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Identity string `json:"identity"`
+	}
+	err := json.NewDecoder(io.LimitReader(req.Body, 4096)).Decode(&body)
+	if err != nil && !errors.Is(err, io.EOF) {
+		webAuthn.fail(rw, req, http.StatusBadRequest, err)
+		return
+	}
+	credentials := []WebAuthnCredential{}
+	if body.Identity != "" {
+		credentials, err = webAuthn.options.Store.ListWebAuthnCredentials(req.Context(), body.Identity)
+		if err != nil {
+			webAuthn.fail(rw, req, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	challenge := webAuthn.begin(rw, req, body.Identity)
+	webAuthn.writeJSON(rw, http.StatusOK, map[string]any{"publicKey": map[string]any{
+		"challenge":        base64.RawURLEncoding.EncodeToString(challenge),
+		"rpId":             webAuthn.options.RPID,
+		"timeout":          webAuthn.options.Timeout.Milliseconds(),
+		"userVerification": webAuthn.options.UserVerification,
+		"allowCredentials": credentialDescriptors(credentials),
+	}})
+}
+
+func (webAuthn *webAuthn) loginFinish(rw http.ResponseWriter, req *http.Request) {
+	ceremony, ok := webAuthn.finish(rw, req)
+	if !ok {
+		webAuthn.fail(rw, req, http.StatusBadRequest, fmt.Errorf("%w: no pending login", errWebAuthn))
+		return
+	}
+	response, err := readWebAuthnResponse(req)
+	if err != nil {
+		webAuthn.fail(rw, req, http.StatusBadRequest, err)
+		return
+	}
+	credential, err := webAuthn.options.Store.FindWebAuthnCredential(req.Context(), response.id)
+	if errors.Is(err, ErrWebAuthnCredentialNotFound) {
+		webAuthn.fail(rw, req, http.StatusUnauthorized, err)
+		return
+	}
+	if err != nil {
+		webAuthn.fail(rw, req, http.StatusInternalServerError, err)
+		return
+	}
+	if ceremony.identity != "" && ceremony.identity != credential.Identity ||
+		len(response.userHandle) > 0 && string(response.userHandle) != credential.Identity {
+		webAuthn.fail(rw, req, http.StatusUnauthorized, fmt.Errorf("%w: credential of another identity", errWebAuthn))
+		return
+	}
+
+	err = webAuthn.verifyClientData(response.clientData, "webauthn.get", ceremony.challenge)
+	if err != nil {
+		webAuthn.fail(rw, req, http.StatusBadRequest, err)
+		return
+	}
+	authData, err := parseWebAuthnAuthData(response.authenticatorData)
+	if err == nil {
+		err = webAuthn.verifyAuthData(authData)
+	}
+	if err != nil {
+		webAuthn.fail(rw, req, http.StatusBadRequest, err)
+		return
+	}
+	verify, err := parseCOSEKey(credential.PublicKey)
+	if err != nil {
+		webAuthn.fail(rw, req, http.StatusInternalServerError, err)
+		return
+	}
+	clientDataHash := sha256.Sum256(response.clientData)
+	err = verify(append(slices.Clone(response.authenticatorData), clientDataHash[:]...), response.signature)
+	if err != nil {
+		webAuthn.fail(rw, req, http.StatusUnauthorized, err)
+		return
+	}
+	// authenticators without counter always send 0, a counter not increasing hints at a cloned authenticator
+	if (authData.signCount != 0 || credential.SignCount != 0) && authData.signCount <= credential.SignCount {
+		webAuthn.fail(rw, req, http.StatusUnauthorized, fmt.Errorf("%w: sign count of %s did not increase", errWebAuthn, credential.Identity))
+		return
+	}
+	credential.SignCount = authData.signCount
+	err = webAuthn.options.Store.SaveWebAuthnCredential(req.Context(), credential)
+	if err != nil {
+		webAuthn.fail(rw, req, http.StatusInternalServerError, err)
+		return
+	}
+
+	setPrincipal(req, Principal{Name: credential.Identity, Scheme: "WebAuthn"})
+	if webAuthn.options.Login != nil {
+		err = webAuthn.options.Login(rw, req, credential.Identity)
+		if err != nil {
+			webAuthn.fail(rw, req, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	webAuthn.writeJSON(rw, http.StatusOK, map[string]string{"identity": credential.Identity})
+}
+
+// begin stores a new challenge for identity and sets the cookie referencing it
+func (webAuthn *webAuthn) begin(rw http.ResponseWriter, req *http.Request, identity string) []byte {
+	challenge := make([]byte, 32)
+	_, _ = rand.Read(challenge)
+	id := randomToken(16)
+	now := time.Now()
+
+	webAuthn.mutex.Lock()
+	for key, pending := range webAuthn.challenges {
+		if now.After(pending.expires) {
+			delete(webAuthn.challenges, key)
+		}
+	}
+	webAuthn.challenges[id] = webAuthnChallenge{challenge: challenge, identity: identity, expires: now.Add(webAuthn.options.Timeout)}
+	webAuthn.mutex.Unlock()
+
+	webAuthn.setCookie(rw, req, id, int(webAuthn.options.Timeout.Seconds()))
+	return challenge
+}
+
+// finish removes and returns the pending challenge of the client, every challenge can be used once
+func (webAuthn *webAuthn) finish(rw http.ResponseWriter, req *http.Request) (webAuthnChallenge, bool) {
+	cookie, err := req.Cookie(webAuthnChallengeCookie)
+	if err != nil {
+		return webAuthnChallenge{}, false
+	}
+	webAuthn.setCookie(rw, req, "", -1)
+
+	webAuthn.mutex.Lock()
+	defer webAuthn.mutex.Unlock()
+	ceremony, ok := webAuthn.challenges[cookie.Value]
+	delete(webAuthn.challenges, cookie.Value)
+	return ceremony, ok && time.Now().Before(ceremony.expires)
+}
+
+func (webAuthn *webAuthn) setCookie(rw http.ResponseWriter, req *http.Request, value string, maxAge int) {
+	http.SetCookie(rw, &http.Cookie{
+		Name:     webAuthnChallengeCookie,
+		Value:    value,
+		Path:     webAuthn.prefix,
+		MaxAge:   maxAge,
+		Secure:   req.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func (webAuthn *webAuthn) verifyClientData(raw []byte, ceremonyType string, challenge []byte) error {
+	var clientData struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Origin    string `json:"origin"`
+	}
+	err := json.Unmarshal(raw, &clientData)
+	if err != nil {
+		return fmt.Errorf("%w: client data: %w", errWebAuthn, err)
+	}
+	if clientData.Type != ceremonyType {
+		return fmt.Errorf("%w: client data type %q", errWebAuthn, clientData.Type)
+	}
+	received, err := decodeBase64URL(clientData.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(received, challenge) != 1 {
+		return fmt.Errorf("%w: challenge mismatch", errWebAuthn)
+	}
+	if !slices.Contains(webAuthn.options.Origins, clientData.Origin) {
+		return fmt.Errorf("%w: origin %s not accepted", errWebAuthn, clientData.Origin)
+	}
+	return nil
+}
+
+func (webAuthn *webAuthn) verifyAuthData(authData webAuthnAuthData) error {
+	rpIDHash := sha256.Sum256([]byte(webAuthn.options.RPID))
+	if !bytes.Equal(authData.rpIDHash, rpIDHash[:]) {
+		return fmt.Errorf("%w: credential of another relying party", errWebAuthn)
+	}
+	if authData.flags&webAuthnFlagUserPresent == 0 {
+		return fmt.Errorf("%w: user not present", errWebAuthn)
+	}
+	if webAuthn.options.UserVerification == "required" && authData.flags&webAuthnFlagUserVerified == 0 {
+		return fmt.Errorf("%w: user not verified", errWebAuthn)
+	}
+	return nil
+}
+
+func (webAuthn *webAuthn) fail(rw http.ResponseWriter, req *http.Request, status int, err error) {
+	webAuthn.webServer.writeErrorCause(rw, req, status, err)
+	webAuthn.webServer.logger.Println("WebAuthn: " + fmt.Sprint(status) + ": " + err.Error())
+}
+
+func (webAuthn *webAuthn) writeJSON(rw http.ResponseWriter, status int, value any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.WriteHeader(status)
+	err := json.NewEncoder(rw).Encode(value)
+	if err != nil {
+		webAuthn.webServer.logger.Println("WebAuthn: " + err.Error())
+	}
+}
+
+func credentialDescriptors(credentials []WebAuthnCredential) []map[string]string {
+	descriptors := []map[string]string{}
+	for _, credential := range credentials {
+		descriptors = append(descriptors, map[string]string{"type": "public-key", "id": base64.RawURLEncoding.EncodeToString(credential.ID)})
+	}
+	return descriptors
+}
+
+// webAuthnResponse is the decoded json of a PublicKeyCredential, attestationObject is set for registrations,
+// authenticatorData, signature and userHandle for logins
+type webAuthnResponse struct {
+	id                []byte
+	clientData        []byte
+	attestationObject []byte
+	authenticatorData []byte
+	signature         []byte
+	userHandle        []byte
+}
+
+func readWebAuthnResponse(req *http.Request) (webAuthnResponse, error) {
+	var body struct {
+		ID       string `json:"id"`
+		RawID    string `json:"rawId"`
+		Type     string `json:"type"`
+		Response struct {
+			ClientDataJSON    string `json:"clientDataJSON"`
+			AttestationObject string `json:"attestationObject"`
+			AuthenticatorData string `json:"authenticatorData"`
+			Signature         string `json:"signature"`
+			UserHandle        string `json:"userHandle"`
+		} `json:"response"`
+	}
+	err := json.NewDecoder(io.LimitReader(req.Body, 64*1024)).Decode(&body)
+	if err != nil {
+		return webAuthnResponse{}, fmt.Errorf("%w: %w", errWebAuthn, err)
+	}
+	if body.Type != "public-key" {
+		return webAuthnResponse{}, fmt.Errorf("%w: credential type %q", errWebAuthn, body.Type)
+	}
+	if body.RawID == "" {
+		body.RawID = body.ID
+	}
+
+	var response webAuthnResponse
+	for _, field := range []struct {
+		value  string
+		target *[]byte
+	}{
+		{body.RawID, &response.id},
+		{body.Response.ClientDataJSON, &response.clientData},
+		{body.Response.AttestationObject, &response.attestationObject},
+		{body.Response.AuthenticatorData, &response.authenticatorData},
+		{body.Response.Signature, &response.signature},
+		{body.Response.UserHandle, &response.userHandle},
+	} {
+		*field.target, err = decodeBase64URL(field.value)
+		if err != nil {
+			return webAuthnResponse{}, fmt.Errorf("%w: %w", errWebAuthn, err)
+		}
+	}
+	if len(response.id) == 0 || len(response.clientData) == 0 {
+		return webAuthnResponse{}, fmt.Errorf("%w: incomplete credential", errWebAuthn)
+	}
+	return response, nil
+}
+
+// decodeBase64URL decodes base64url with or without padding
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+const (
+	webAuthnFlagUserPresent  = 0x01
+	webAuthnFlagUserVerified = 0x04
+	webAuthnFlagAttested     = 0x40
+)
+
+// webAuthnAuthData is the parsed authenticator data, credentialID and publicKey are set if the attested flag is
+type webAuthnAuthData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+}
+
+func parseWebAuthnAuthData(data []byte) (webAuthnAuthData, error) {
+	if len(data) < 37 {
+		return webAuthnAuthData{}, fmt.Errorf("%w: authenticator data too short", errWebAuthn)
+	}
+	authData := webAuthnAuthData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if authData.flags&webAuthnFlagAttested == 0 {
+		return authData, nil
+	}
+
+	// aaguid, length of the credential id, credential id, COSE key
+	rest := data[37:]
+	if len(rest) < 18 {
+		return webAuthnAuthData{}, fmt.Errorf("%w: attested credential data too short", errWebAuthn)
+	}
+	idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLength == 0 || idLength > 1023 || len(rest) < idLength {
+		return webAuthnAuthData{}, fmt.Errorf("%w: credential id length %d", errWebAuthn, idLength)
+	}
+	authData.credentialID = rest[:idLength]
+	rest = rest[idLength:]
+	_, extensions, err := decodeCBOR(rest)
+	if err != nil {
+		return webAuthnAuthData{}, fmt.Errorf("%w: credential public key: %w", errWebAuthn, err)
+	}
+	authData.publicKey = rest[:len(rest)-len(extensions)]
+	return authData, nil
+}
+
+// COSE algorithm identifiers
+const (
+	coseAlgES256 = -7
+	coseAlgEdDSA = -8
+	coseAlgRS256 = -257
+)
+
+// parseCOSEKey returns a function verifying signatures with the COSE encoded public key
+func parseCOSEKey(data []byte) (func(message []byte, signature []byte) error, error) {
+	decoded, _, err := decodeCBOR(data)
+	key, ok := decoded.(map[any]any)
+	if err != nil || !ok {
+		return nil, fmt.Errorf("%w: public key is no COSE key", errWebAuthn)
+	}
+	kty, _ := key[int64(1)].(int64)
+	alg, _ := key[int64(3)].(int64)
+	crv, _ := key[int64(-1)].(int64)
+	x, _ := key[int64(-2)].([]byte)
+	y, _ := key[int64(-3)].([]byte)
+	errSignature := fmt.Errorf("%w: signature", errWebAuthn)
+
+	switch {
+	case alg == coseAlgES256 && kty == 2 && crv == 1:
+		// ecdh rejects points not on the curve
+		_, err = ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...))
+		if err != nil || len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("%w: invalid P-256 key", errWebAuthn)
+		}
+		publicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return func(message []byte, signature []byte) error {
+			hash := sha256.Sum256(message)
+			if !ecdsa.VerifyASN1(publicKey, hash[:], signature) {
+				return errSignature
+			}
+			return nil
+		}, nil
+	case alg == coseAlgEdDSA && kty == 1 && crv == 6:
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: invalid Ed25519 key", errWebAuthn)
+		}
+		return func(message []byte, signature []byte) error {
+			if !ed25519.Verify(x, message, signature) {
+				return errSignature
+			}
+			return nil
+		}, nil
+	case alg == coseAlgRS256 && kty == 3:
+		n, _ := key[int64(-1)].([]byte)
+		e := new(big.Int).SetBytes(x)
+		publicKey := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(e.Int64())}
+		if publicKey.N.BitLen() < 2048 || !e.IsInt64() || publicKey.E < 3 || publicKey.E > 1<<31-1 {
+			return nil, fmt.Errorf("%w: invalid RSA key", errWebAuthn)
+		}
+		return func(message []byte, signature []byte) error {
+			hash := sha256.Sum256(message)
+			if rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], signature) != nil {
+				return errSignature
+			}
+			return nil
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: unsupported COSE key type %d algorithm %d", errWebAuthn, kty, alg)
+}
+
+// MemoryWebAuthnCredentialStore keeps the credentials in process memory, they are lost on restart
+type MemoryWebAuthnCredentialStore struct {
+	mutex       sync.Mutex
+	credentials map[string]WebAuthnCredential
+}
+
+func NewMemoryWebAuthnCredentialStore() *MemoryWebAuthnCredentialStore {
+	return &MemoryWebAuthnCredentialStore{credentials: map[string]WebAuthnCredential{}}
+}
+
+func (store *MemoryWebAuthnCredentialStore) SaveWebAuthnCredential(ctx context.Context, credential WebAuthnCredential) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.credentials[string(credential.ID)] = credential
+	return nil
+}
+
+func (store *MemoryWebAuthnCredentialStore) FindWebAuthnCredential(ctx context.Context, id []byte) (WebAuthnCredential, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	credential, ok := store.credentials[string(id)]
+	if !ok {
+		return WebAuthnCredential{}, ErrWebAuthnCredentialNotFound
+	}
+	return credential, nil
+}
+
+func (store *MemoryWebAuthnCredentialStore) ListWebAuthnCredentials(ctx context.Context, identity string) ([]WebAuthnCredential, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	credentials := []WebAuthnCredential{}
+	for _, credential := range store.credentials {
+		if credential.Identity == identity {
+			credentials = append(credentials, credential)
+		}
+	}
+	slices.SortFunc(credentials, func(a, b WebAuthnCredential) int {
+		return a.Created.Compare(b.Created)
+	})
+	return credentials, nil
+}
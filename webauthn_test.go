@@ -0,0 +1,194 @@
+package webserver
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+)
+
+// encodeCBOR encodes the values a test authenticator sends, map keys are sorted for a stable encoding
+func encodeCBOR(value any) []byte {
+	head := func(major byte, argument int) []byte {
+		switch {
+		case argument < 24:
+			return []byte{major<<5 | byte(argument)}
+		case argument < 256:
+			return []byte{major<<5 | 24, byte(argument)}
+		default:
+			return []byte{major<<5 | 25, byte(argument >> 8), byte(argument)}
+		}
+	}
+	switch value := value.(type) {
+	case int:
+		if value < 0 {
+			return head(1, -1-value)
+		}
+		return head(0, value)
+	case []byte:
+		return append(head(2, len(value)), value...)
+	case string:
+		return append(head(3, len(value)), value...)
+	case map[any]any:
+		keys := make([]string, 0, len(value))
+		encoded := map[string][]byte{}
+		for key, item := range value {
+			encodedKey := string(encodeCBOR(key))
+			keys = append(keys, encodedKey)
+			encoded[encodedKey] = encodeCBOR(item)
+		}
+		sort.Strings(keys)
+		data := head(5, len(value))
+		for _, key := range keys {
+			data = append(append(data, key...), encoded[key]...)
+		}
+		return data
+	}
+	panic("unsupported value")
+}
+
+type testAuthenticator struct {
+	id        []byte
+	key       *ecdsa.PrivateKey
+	signCount uint32
+}
+
+func (authenticator *testAuthenticator) authData(rpID string, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	flags := byte(webAuthnFlagUserPresent | webAuthnFlagUserVerified)
+	if attested {
+		flags |= webAuthnFlagAttested
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, authenticator.signCount)
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(authenticator.id)))
+		data = append(data, authenticator.id...)
+		x, y := make([]byte, 32), make([]byte, 32)
+		authenticator.key.X.FillBytes(x)
+		authenticator.key.Y.FillBytes(y)
+		data = append(data, encodeCBOR(map[any]any{1: 2, 3: -7, -1: 1, -2: x, -3: y})...)
+	}
+	return data
+}
+
+func TestWebAuthn(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	webServer.NewPhaseMiddleware(PhaseAuth, func(rw http.ResponseWriter, req *http.Request) bool {
+		if user := req.Header.Get("X-Test-User"); user != "" {
+			setPrincipal(req, Principal{Name: user, Scheme: "Test"})
+		}
+		return true
+	})
+	options := NewWebAuthnOptions()
+	options.RPID = "example.com"
+	loggedIn := ""
+	options.Login = func(rw http.ResponseWriter, req *http.Request, identity string) error {
+		loggedIn = identity
+		return nil
+	}
+	webServer.EnableWebAuthn("/webauthn", *options)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator := &testAuthenticator{id: []byte("credential-1"), key: key}
+	encode := base64.RawURLEncoding.EncodeToString
+
+	// post sends body with the challenge cookie and the test user and returns the response
+	post := func(path string, user string, cookie *http.Cookie, body any) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+		if user != "" {
+			req.Header.Set("X-Test-User", user)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, req)
+		return rec
+	}
+	// begin returns the challenge and the cookie of a begin endpoint
+	begin := func(path string, user string, body any) ([]byte, *http.Cookie) {
+		rec := post(path, user, nil, body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s = %d", path, rec.Code)
+		}
+		var options struct {
+			PublicKey struct {
+				Challenge string `json:"challenge"`
+			} `json:"publicKey"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &options)
+		challenge, _ := decodeBase64URL(options.PublicKey.Challenge)
+		return challenge, rec.Result().Cookies()[0]
+	}
+	clientData := func(ceremonyType string, challenge []byte, origin string) []byte {
+		data, _ := json.Marshal(map[string]string{"type": ceremonyType, "challenge": encode(challenge), "origin": origin})
+		return data
+	}
+	assertion := func(challenge []byte, origin string) map[string]any {
+		authData := authenticator.authData("example.com", false)
+		client := clientData("webauthn.get", challenge, origin)
+		clientHash := sha256.Sum256(client)
+		digest := sha256.Sum256(append(append([]byte{}, authData...), clientHash[:]...))
+		signature, _ := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		return map[string]any{"id": encode(authenticator.id), "rawId": encode(authenticator.id), "type": "public-key",
+			"response": map[string]string{
+				"clientDataJSON":    encode(client),
+				"authenticatorData": encode(authData),
+				"signature":         encode(signature),
+				"userHandle":        encode([]byte("alice")),
+			}}
+	}
+
+	if rec := post("/webauthn/register/begin", "", nil, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("register without principal = %d, want 401", rec.Code)
+	}
+
+	challenge, cookie := begin("/webauthn/register/begin", "alice", nil)
+	attestation := encodeCBOR(map[any]any{"fmt": "none", "attStmt": map[any]any{}, "authData": authenticator.authData("example.com", true)})
+	rec := post("/webauthn/register/finish", "alice", cookie, map[string]any{"id": encode(authenticator.id), "type": "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    encode(clientData("webauthn.create", challenge, "https://example.com")),
+			"attestationObject": encode(attestation),
+		}})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("register finish = %d", rec.Code)
+	}
+
+	authenticator.signCount = 1
+	challenge, cookie = begin("/webauthn/login/begin", "", map[string]string{"identity": "alice"})
+	rec = post("/webauthn/login/finish", "", cookie, assertion(challenge, "https://example.com"))
+	if rec.Code != http.StatusOK || loggedIn != "alice" {
+		t.Fatalf("login finish = %d, logged in %q", rec.Code, loggedIn)
+	}
+	if rec := post("/webauthn/login/finish", "", cookie, assertion(challenge, "https://example.com")); rec.Code != http.StatusBadRequest {
+		t.Errorf("replayed challenge = %d, want 400", rec.Code)
+	}
+
+	authenticator.signCount = 2
+	challenge, cookie = begin("/webauthn/login/begin", "", nil)
+	if rec := post("/webauthn/login/finish", "", cookie, assertion(challenge, "https://evil.example")); rec.Code != http.StatusBadRequest {
+		t.Errorf("foreign origin = %d, want 400", rec.Code)
+	}
+
+	// a counter not increasing hints at a cloned authenticator
+	authenticator.signCount = 1
+	challenge, cookie = begin("/webauthn/login/begin", "", nil)
+	if rec := post("/webauthn/login/finish", "", cookie, assertion(challenge, "https://example.com")); rec.Code != http.StatusUnauthorized {
+		t.Errorf("stale sign count = %d, want 401", rec.Code)
+	}
+}
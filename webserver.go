@@ -1,15 +1,24 @@
 package webserver
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"golang.org/x/exp/slices"
 	"io"
 	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type HTTPMethod string
@@ -177,19 +186,6 @@ func getMimeType(fileExtension string) string {
 	}
 }
 
-func urlJoin(parts ...string) string {
-	out := "./"
-	for _, part := range parts {
-		segments := strings.Split(part, "/")
-		for _, segment := range segments {
-			if segment != "" {
-				out += segment + "/"
-			}
-		}
-	}
-	return out[:len(out)-1]
-}
-
 //helper end
 
 //public
@@ -210,245 +206,673 @@ type WebServer struct {
 
 	customMux *http.ServeMux
 
-	settings Settings
+	settingsMutex sync.RWMutex
+	settings      Settings
+
+	logger *log.Logger
+
+	middleware []middleware
+
+	routes []route
+
+	tlsFingerprintHook func(hello *tls.ClientHelloInfo, fingerprint ClientFingerprint) error
+	portFallbackHook   func(port string)
+	readyHook          func(addr net.Addr)
+
+	fallbackRules []fallbackRule
+	errorPages    map[int]http.Handler
+	errorHandler  func(rw http.ResponseWriter, req *http.Request, err error, status int)
+	apiPrefixes   []string
+	rateLimits    []rateLimitRule
+	ipFilters     []ipFilterRule
+	honeypots     map[string]bool
+	geoIP         GeoIPLookup
+	jwtRules      []jwtRule
+	workerPools   map[string]*workerPool
+
+	// cookieWarnings are the cookie problems SetCookie already logged
+	cookieWarnings sync.Map
+
+	cors      *cors
+	hardening *HardeningOptions
+
+	injectBuildInfo bool
+
+	storage StaticStorage
+
+	uploadScanner UploadScanner
+
+	virtualHosts map[string]*WebServer
+
+	originMutex sync.Mutex
+	originCalls map[string]*originCall
+
+	mirror *mirror
+
+	metrics   MetricsExporter
+	usage     *usageTracker
+	anomalies *anomalyDetector
+
+	metricRoutes labelLimiter
+
+	logShipper *LogShipper
+
+	siemMutex   sync.Mutex
+	siemStreams []*siemStream
+	// exportersOnce closes log shipper, metrics exporter and siem sinks on the first Shutdown
+	exportersOnce sync.Once
+
+	readinessMutex  sync.Mutex
+	readinessChecks []readinessCheck
+
+	selfTestSamples []SelfTestSample
+
+	events *eventBus
+
+	servingMutex sync.Mutex
+	serving      *serving
+	httpRedirect *serving
+	certificate  atomic.Pointer[tls.Certificate]
+	trusted      atomic.Pointer[trustedProxies]
 
-	fileExtensionFilter []string
+	// selfSignedPool trusts the certificate of Settings.UseSelfSignedTLS
+	selfSignedPool atomic.Pointer[x509.CertPool]
 
-	middleware []func(http.ResponseWriter, *http.Request) bool
+	rules  atomic.Pointer[siteRules]
+	assets atomic.Pointer[assetIndex]
 }
 
 func NewWebServer(settings Settings) *WebServer {
 	mux := http.NewServeMux()
 
+	if settings.Logger == nil {
+		settings.Logger = log.New(os.Stdout, "", log.LstdFlags)
+	}
+
 	webServer := &WebServer{
-		server: &http.Server{
-			Handler: mux,
-			Addr:    settings.Addr(),
-		},
 		mux: mux,
 
-		getMux:     http.NewServeMux(),
-		headMux:    http.NewServeMux(),
-		postMux:    http.NewServeMux(),
-		putMux:     http.NewServeMux(),
-		patchMux:   http.NewServeMux(),
-		deleteMux:  http.NewServeMux(),
-		connectMux: http.NewServeMux(),
-		optionsMux: http.NewServeMux(),
-		traceMux:   http.NewServeMux(),
+		logger: settings.Logger,
 
-		customMux: http.NewServeMux(),
+		events: newEventBus(),
+	}
+	webServer.initMuxes()
 
-		settings: settings,
+	if settings.LogShipping.Kind != LogShipperNone {
+		shipper, err := NewLogShipper(settings.LogShipping)
+		if err != nil {
+			webServer.logger.Println("Log Shipper: " + err.Error())
+		} else {
+			webServer.logger = log.New(io.MultiWriter(settings.Logger.Writer(), shipper), settings.Logger.Prefix(), settings.Logger.Flags())
+			webServer.logShipper = shipper
+			settings.Logger = webServer.logger
+		}
+	}
 
-		fileExtensionFilter: []string{},
+	if settings.FileExtensionFilter == nil {
+		settings.FileExtensionFilter = []string{}
 	}
+	webServer.settings = settings
+	webServer.server = webServer.newHTTPServer(settings)
 
-	if webServer.settings.Logger == nil {
-		webServer.settings.Logger = log.New(os.Stdout, "", log.LstdFlags)
+	metrics, err := newMetricsExporter(settings)
+	if err != nil {
+		webServer.logger.Println("Metrics: " + err.Error())
+	} else if metrics != nil {
+		webServer.metrics = metrics
 	}
 
+	webServer.siteRules(settings)
 	webServer.mux.HandleFunc("/", webServer.mainHandler)
-	webServer.getMux.HandleFunc("/", webServer.fileHandler)
 
 	return webServer
 }
 
+// initMuxes creates the method muxes with the file handler serving GET and HEAD requests without route
+// and the OPTIONS auto-responder
+func (webServer *WebServer) initMuxes() {
+	webServer.getMux = http.NewServeMux()
+	webServer.headMux = http.NewServeMux()
+	webServer.postMux = http.NewServeMux()
+	webServer.putMux = http.NewServeMux()
+	webServer.patchMux = http.NewServeMux()
+	webServer.deleteMux = http.NewServeMux()
+	webServer.connectMux = http.NewServeMux()
+	webServer.optionsMux = http.NewServeMux()
+	webServer.traceMux = http.NewServeMux()
+
+	webServer.customMux = http.NewServeMux()
+
+	webServer.handle(http.MethodGet, "/", "static files", http.HandlerFunc(webServer.fileHandler))
+	webServer.handle(http.MethodHead, "/", "static files", http.HandlerFunc(webServer.fileHandler))
+	webServer.handle(http.MethodOptions, "/", "options", http.HandlerFunc(webServer.optionsHandler))
+}
+
+// Settings returns a copy of the settings currently in use
+func (webServer *WebServer) Settings() Settings {
+	webServer.settingsMutex.RLock()
+	defer webServer.settingsMutex.RUnlock()
+	return webServer.settings
+}
+
 func (webServer *WebServer) NewHandleFunc(method HTTPMethod, pattern string, handler func(http.ResponseWriter, *http.Request)) {
-	switch method {
-	case http.MethodGet:
-		webServer.getMux.HandleFunc(pattern, handler)
-	case http.MethodHead:
-		webServer.headMux.HandleFunc(pattern, handler)
-	case http.MethodPost:
-		webServer.postMux.HandleFunc(pattern, handler)
-	case http.MethodPut:
-		webServer.putMux.HandleFunc(pattern, handler)
-	case http.MethodPatch:
-		webServer.patchMux.HandleFunc(pattern, handler)
-	case http.MethodDelete:
-		webServer.deleteMux.HandleFunc(pattern, handler)
-	case http.MethodConnect:
-		webServer.connectMux.HandleFunc(pattern, handler)
-	case http.MethodOptions:
-		webServer.optionsMux.HandleFunc(pattern, handler)
-	case http.MethodTrace:
-		webServer.traceMux.HandleFunc(pattern, handler)
-	default:
-		webServer.customMux.HandleFunc(pattern, handler)
-	}
+	webServer.NewHandler(method, pattern, http.HandlerFunc(handler))
 }
 
 func (webServer *WebServer) NewHandlerBody(method HTTPMethod, pattern string, handler func(http.ResponseWriter, *http.Request, []byte)) {
 	webServer.NewHandleFunc(method, pattern, func(rw http.ResponseWriter, req *http.Request) {
 		bodyData, err := io.ReadAll(req.Body)
-		if err != nil {
-			panic(err)
+		if err == nil {
+			err = req.Body.Close()
 		}
-
-		err = req.Body.Close()
 		if err != nil {
-			panic(err)
+			webServer.bodyError(rw, req, err)
+			return
 		}
 		handler(rw, req, bodyData)
 	})
 }
 
 func (webServer *WebServer) NewHandler(method HTTPMethod, pattern string, handler http.Handler) {
-	switch method {
-	case http.MethodGet:
-		webServer.getMux.Handle(pattern, handler)
-	case http.MethodHead:
-		webServer.headMux.Handle(pattern, handler)
-	case http.MethodPost:
-		webServer.postMux.Handle(pattern, handler)
-	case http.MethodPut:
-		webServer.putMux.Handle(pattern, handler)
-	case http.MethodPatch:
-		webServer.patchMux.Handle(pattern, handler)
-	case http.MethodDelete:
-		webServer.deleteMux.Handle(pattern, handler)
-	case http.MethodConnect:
-		webServer.connectMux.Handle(pattern, handler)
-	case http.MethodOptions:
-		webServer.optionsMux.Handle(pattern, handler)
-	case http.MethodTrace:
-		webServer.traceMux.Handle(pattern, handler)
-	default:
-		webServer.customMux.Handle(pattern, handler)
-	}
+	webServer.handle(string(method), pattern, handlerName(handler), handler)
 }
 
-// NewMiddleware return value is for deciding to run next middleware/handler
-func (webServer *WebServer) NewMiddleware(m func(http.ResponseWriter, *http.Request) bool) {
-	webServer.middleware = append(webServer.middleware, m)
+// NewMiddleware return value is for deciding to run next middleware/handler.
+// It runs in PhaseBusiness, after the security, auth and transform middleware, for requests all conditions hold for.
+func (webServer *WebServer) NewMiddleware(m func(http.ResponseWriter, *http.Request) bool, conditions ...MiddlewareCondition) {
+	webServer.NewPhaseMiddleware(PhaseBusiness, m, conditions...)
 }
 
 func (webServer *WebServer) SetRoot(root string) {
+	webServer.settingsMutex.Lock()
+	defer webServer.settingsMutex.Unlock()
 	webServer.settings.Root = root
 }
 
 func (webServer *WebServer) SetFileExtensionsFilter(fileExtensions ...string) {
+	webServer.settingsMutex.Lock()
+	defer webServer.settingsMutex.Unlock()
+
+	// copied so snapshots returned by Settings are not modified
+	filter := slices.Clone(webServer.settings.FileExtensionFilter)
 	for _, extension := range fileExtensions {
-		if !slices.Contains(webServer.fileExtensionFilter, extension) {
-			webServer.fileExtensionFilter = append(webServer.fileExtensionFilter, extension)
+		if !slices.Contains(filter, extension) {
+			filter = append(filter, extension)
 		}
-
 	}
+	webServer.settings.FileExtensionFilter = filter
 }
 
+// Run binds the listener and serves until the server is shut down.
+// Common misconfigurations are reported as ErrPortInUse, ErrPrivilegedPort and ErrCertNotFound.
 func (webServer *WebServer) Run() error {
-	if webServer.settings.UseHttps {
-		if webServer.settings.UseHttpRedirect {
-			m := http.NewServeMux()
-			m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-				url := "https://" + webServer.settings.Hostname + ":" + webServer.settings.HttpsPort + r.URL.Path
-				http.Redirect(w, r, url, http.StatusMovedPermanently)
-				webServer.settings.Logger.Println("Redirect: http to https 301 to " + url)
-			})
-			s := http.Server{
-				Addr:    ":" + "80",
-				Handler: m,
-			}
-			go func() {
-				err := s.ListenAndServe()
+	listener, err := webServer.bind()
+	if err != nil {
+		return err
+	}
+
+	err = webServer.RunListener(listener)
+	_ = webServer.stopHttpRedirect(context.Background())
+	return err
+}
+
+// Start binds the listener like Run and returns once the server accepts connections, it serves in the background
+// until Shutdown
+func (webServer *WebServer) Start() error {
+	listener, err := webServer.bind()
+	if err != nil {
+		return err
+	}
+
+	_, err = webServer.startServing(webServer.server, listener, webServer.Settings())
+	if err != nil {
+		_ = listener.Close()
+		_ = webServer.stopHttpRedirect(context.Background())
+	}
+	return err
+}
+
+// RunContext serves like Run until ctx is cancelled, then shuts the server down gracefully and returns nil
+func (webServer *WebServer) RunContext(ctx context.Context) error {
+	err := webServer.Start()
+	if err != nil {
+		return err
+	}
+
+	webServer.servingMutex.Lock()
+	current := webServer.serving
+	webServer.servingMutex.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- webServer.wait(current)
+	}()
+	select {
+	case err = <-done:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		err = webServer.Shutdown(context.Background())
+		<-done
+		return err
+	}
+}
+
+// Shutdown stops accepting connections, also on the http redirect, and waits for in-flight requests until ctx is done,
+// Run then returns http.ErrServerClosed. Afterwards it flushes and closes the log shipper, the metrics exporter and
+// the sinks of StreamSecurityEvents.
+func (webServer *WebServer) Shutdown(ctx context.Context) error {
+	redirectErr := webServer.stopHttpRedirect(ctx)
+	webServer.servingMutex.Lock()
+	current := webServer.serving
+	webServer.servingMutex.Unlock()
+	if current == nil {
+		webServer.closeExporters()
+		return redirectErr
+	}
+	err := current.server.Shutdown(ctx)
+	if err == nil {
+		err = redirectErr
+	}
+	webServer.closeExporters()
+	return err
+}
+
+// closeExporters stops the security event streams and closes their sinks, the metrics exporter and, last so it
+// ships the lines logged before, the log shipper. Errors are logged.
+func (webServer *WebServer) closeExporters() {
+	webServer.exportersOnce.Do(func() {
+		webServer.siemMutex.Lock()
+		streams := webServer.siemStreams
+		webServer.siemStreams = nil
+		webServer.siemMutex.Unlock()
+		for _, stream := range streams {
+			stream.unsubscribe()
+			if closer, ok := stream.sink.(io.Closer); ok {
+				err := closer.Close()
 				if err != nil {
-					panic(err)
+					webServer.logger.Println("SIEM: " + err.Error())
 				}
-			}()
+			}
 		}
-		webServer.settings.Logger.Println("WebServer running on " + webServer.settings.Url())
-		return webServer.server.ListenAndServeTLS(webServer.settings.CertFile, webServer.settings.KeyFile)
-	} else {
-		webServer.settings.Logger.Println("WebServer running on " + webServer.settings.Url())
-		return webServer.server.ListenAndServe()
+
+		if closer, ok := webServer.metrics.(io.Closer); ok {
+			err := closer.Close()
+			if err != nil {
+				webServer.logger.Println("Metrics: " + err.Error())
+			}
+		}
+
+		if webServer.logShipper != nil {
+			_ = webServer.logShipper.Close()
+		}
+	})
+}
+
+// Addr returns the address the server is bound to, e.g. the port chosen for HttpPort "0", nil if it is not serving
+func (webServer *WebServer) Addr() net.Addr {
+	webServer.servingMutex.Lock()
+	defer webServer.servingMutex.Unlock()
+	if webServer.serving == nil {
+		return nil
 	}
+	return webServer.serving.listener.Addr()
 }
 
-//private
+// ListenAddr returns the address the server is bound to as string, e.g. "127.0.0.1:43817" for HttpPort "0",
+// empty if it is not serving
+func (webServer *WebServer) ListenAddr() string {
+	addr := webServer.Addr()
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+// SetReadyHook registers a hook called with the bound address once the server accepts connections,
+// also when a reload bound a new listener
+func (webServer *WebServer) SetReadyHook(hook func(addr net.Addr)) {
+	webServer.readyHook = hook
+}
+
+// bind checks the settings, binds the main listener and starts the http redirect
+func (webServer *WebServer) bind() (net.Listener, error) {
+	settings := webServer.Settings()
+	err := preflight(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := webServer.listenMainFallback(settings)
+	if err != nil {
+		return nil, err
+	}
+	if settings.UseHttps && settings.UseHttpRedirect {
+		err = webServer.startHttpRedirect(webServer.Settings())
+		if err != nil {
+			_ = listener.Close()
+			return nil, err
+		}
+	}
+	return listener, nil
+}
+
+// Handler returns the handler Run serves with, the middleware and routing pipeline, e.g. to embed the server in
+// another http.Server or to test it with httptest, see package webservertest
+func (webServer *WebServer) Handler() http.Handler {
+	return webServer.serverHandler()
+}
+
+// RunListener serves on an already bound listener, e.g. one passed in by systemd socket activation
+func (webServer *WebServer) RunListener(listener net.Listener) error {
+	current, err := webServer.startServing(webServer.server, listener, webServer.Settings())
+	if err != nil {
+		return err
+	}
+
+	return webServer.wait(current)
+}
+
+// wait returns the result of serving, a reload replaces the server, in that case it waits for the new one
+func (webServer *WebServer) wait(current *serving) error {
+	for {
+		err := <-current.done
+		webServer.servingMutex.Lock()
+		next := webServer.serving
+		webServer.servingMutex.Unlock()
+		if next == current {
+			return err
+		}
+		current = next
+	}
+}
 
-func (webServer *WebServer) fallbackRedirect(rw http.ResponseWriter, req *http.Request) {
-	url := "http://" + webServer.settings.Hostname + ":" + webServer.settings.HttpPort + webServer.settings.FallbackRedirect
-	if webServer.settings.UseHttps {
-		url = "https://" + webServer.settings.Hostname + ":" + webServer.settings.HttpsPort + webServer.settings.FallbackRedirect
+//private
 
+func (webServer *WebServer) fallbackRedirect(rw http.ResponseWriter, req *http.Request, target string) {
+	settings := webServer.Settings()
+	url := settings.UrlHttp() + target
+	if settings.UseHttps {
+		url = settings.UrlHttps() + target
 	}
 	http.Redirect(rw, req, url, http.StatusTemporaryRedirect)
-	webServer.settings.Logger.Println("Fallback Redirect to " + url)
+	webServer.logger.Println("Fallback Redirect to " + url)
 }
 
+// fileHandler serves GET and HEAD requests, HEAD answers with the same headers and no body
 func (webServer *WebServer) fileHandler(rw http.ResponseWriter, req *http.Request) {
+	settings := webServer.Settings()
 	path := req.URL.Path
+	original, fingerprinted := webServer.fingerprintedAsset(path)
+	if fingerprinted {
+		path = original
+	}
 	parts := strings.Split(path, ".")
 	fileExtension := parts[len(parts)-1]
 
-	if slices.Contains(webServer.fileExtensionFilter, fileExtension) {
-		rw.WriteHeader(http.StatusForbidden)
-		webServer.settings.Logger.Println("File Handler: 403: " + fileExtension + " (" + path + ")")
+	if slices.Contains(settings.FileExtensionFilter, fileExtension) {
+		webServer.writeError(rw, req, http.StatusForbidden)
+		webServer.logger.Println("File Handler: 403: " + fileExtension + " (" + path + ")")
+		return
+	}
+	if pattern, denied := deniedPath(settings.DenyPaths, path); denied {
+		webServer.writeError(rw, req, http.StatusForbidden)
+		webServer.logger.Println("File Handler: 403: " + pattern + " (" + path + ")")
+		return
+	}
+	if name, hidden := hiddenPath(path, settings.DotfileExceptions); settings.BlockDotfiles && hidden {
+		webServer.writeError(rw, req, http.StatusNotFound)
+		webServer.logger.Println("File Handler: 404: hidden " + name + " (" + path + ")")
 		return
 	}
 
-	file, err := os.ReadFile(urlJoin(webServer.settings.Root, path))
+	filePath, err := resolvePath(settings.Root, path, settings.BlockSymlinkEscape)
+	if errors.Is(err, errPathTraversal) {
+		webServer.writeError(rw, req, http.StatusForbidden)
+		webServer.logger.Println("File Handler: 403: " + err.Error() + " (" + path + ")")
+		return
+	}
+
+	rules := webServer.siteRules(settings)
+	rules.setHeaders(rw.Header(), path)
+	if path == "/_redirects" || path == "/_headers" {
+		webServer.writeError(rw, req, http.StatusNotFound)
+		webServer.logger.Println("File Handler: 404: " + path)
+		return
+	}
+	if webServer.applyRedirects(rw, req, settings, rules, true) {
+		return
+	}
+
+	var file []byte
+	var modTime time.Time
+	var sidecar func(suffix string) ([]byte, error)
+	if webServer.storage != nil {
+		name, index := storageName(path)
+		if index {
+			fileExtension = "html"
+		}
+		file, err = webServer.storage.ReadFile(req.Context(), name)
+		sidecar = func(suffix string) ([]byte, error) {
+			return webServer.storage.ReadFile(req.Context(), name+suffix)
+		}
+		if err == nil {
+			modTime = storageModTime(req.Context(), webServer.storage, name)
+		}
+	} else {
+		if err == nil {
+			if info, statErr := os.Stat(filePath); statErr == nil && info.IsDir() {
+				if !strings.HasSuffix(path, "/") {
+					http.Redirect(rw, req, path+"/", http.StatusMovedPermanently)
+					return
+				}
+				index := filepath.Join(filePath, "index.html")
+				if _, statErr := os.Stat(index); statErr != nil && settings.DirectoryListing {
+					webServer.serveDirectoryListing(rw, req, settings, filePath)
+					return
+				}
+				filePath, fileExtension = index, "html"
+			}
+		}
+		if err == nil {
+			file, err = os.ReadFile(filePath)
+			sidecar = func(suffix string) ([]byte, error) {
+				return os.ReadFile(filePath + suffix)
+			}
+		}
+		if err == nil {
+			if info, statErr := os.Stat(filePath); statErr == nil {
+				modTime = info.ModTime()
+			}
+		}
+	}
+	if errors.Is(err, fs.ErrNotExist) && settings.OriginUrl != "" {
+		file, err = webServer.pullFromOrigin(settings, path)
+		sidecar = nil
+		if errors.Is(err, errOrigin) {
+			webServer.writeErrorCause(rw, req, http.StatusBadGateway, err)
+			webServer.logger.Println("File Handler: 502: " + err.Error())
+			return
+		}
+	}
 	if err != nil {
 		var pathError *fs.PathError
 		if errors.As(err, &pathError) {
-			webServer.settings.Logger.Println("File Handler: 404: " + pathError.Error())
+			if webServer.applyRedirects(rw, req, settings, rules, false) {
+				return
+			}
+			webServer.logger.Println("File Handler: 404: " + pathError.Error())
+			webServer.emit(RouteNotFound{Method: req.Method, Path: path})
 			if fileExtension == "html" || fileExtension == "" || len(parts) == 1 {
-				webServer.fallbackRedirect(rw, req)
+				webServer.fallback(rw, req)
 			} else {
-				rw.WriteHeader(http.StatusNotFound)
-				write, err := rw.Write([]byte{})
-				if err != nil {
-					webServer.settings.Logger.Println("File Handler: Write Error: " + err.Error() + " (" + strconv.Itoa(write) + " bytes send)")
-				}
+				webServer.writeError(rw, req, http.StatusNotFound)
 			}
 			return
 		} else {
-			rw.WriteHeader(http.StatusInternalServerError)
-			webServer.settings.Logger.Println("File Handler: 500: " + err.Error())
+			webServer.writeErrorCause(rw, req, http.StatusInternalServerError, err)
+			webServer.logger.Println("File Handler: 500: " + err.Error())
 			return
 		}
 	}
 
+	if !allowedExtension(settings.AllowedExtensions, fileExtension) {
+		webServer.writeError(rw, req, http.StatusForbidden)
+		webServer.logger.Println("File Handler: 403: " + fileExtension + " not allowed (" + path + ")")
+		return
+	}
+
+	if webServer.injectBuildInfo && fileExtension == "html" {
+		file = injectBuildInfoMeta(file)
+		// the sidecars lack the injected meta tag
+		sidecar = nil
+	}
+
+	// ServeContent answers Range requests with 206 Partial Content, also multipart/byteranges for multiple ranges
 	rw.Header().Set("Content-Type", getMimeType(fileExtension))
-	rw.WriteHeader(http.StatusOK)
-	bytes, err := rw.Write(file)
-	if err != nil {
-		webServer.settings.Logger.Println("File Handler: Write Error: " + err.Error() + " (" + strconv.Itoa(bytes) + "/" + strconv.Itoa(len(file)) + ")")
-	} else {
-		webServer.settings.Logger.Println("File Handler: 200: " + path)
+	if isDownload(settings, path, fileExtension) {
+		Attachment(rw, downloadName(path))
 	}
+	if fingerprinted {
+		rw.Header().Set("Cache-Control", string(CacheImmutable))
+	} else if preset, ok := cachePolicy(settings.CachePolicies, path); ok && rw.Header().Get("Cache-Control") == "" {
+		rw.Header().Set("Cache-Control", string(preset))
+	}
+	status := webServer.serveContent(rw, req, settings, path, modTime, file, sidecar)
+	webServer.logger.Println("File Handler: " + strconv.Itoa(status) + ": " + path)
 }
 
-func (webServer *WebServer) mainHandler(rw http.ResponseWriter, req *http.Request) {
-	webServer.settings.Logger.Println(req.Method, req.URL, req.ContentLength)
+// serveContent answers req with a static file whose Content-Type is set, shared by the file handler and mounts.
+// The body is replaced by a precompressed sidecar or gzipped if the client accepts it, http.ServeContent answers
+// Range and conditional requests and sends Last-Modified for a non-zero modTime. It returns the status sent.
+func (webServer *WebServer) serveContent(
+	rw http.ResponseWriter,
+	req *http.Request,
+	settings Settings,
+	name string,
+	modTime time.Time,
+	file []byte,
+	sidecar func(suffix string) ([]byte, error),
+) int {
+	file = webServer.encodeFile(rw, req, settings, file, sidecar)
+	observed := newResponseWriter(rw)
+	http.ServeContent(observed, req, name, modTime, bytes.NewReader(file))
+	return observed.Status()
+}
 
-	for _, m := range webServer.middleware {
-		if !m(rw, req) {
-			return
-		}
+// observeRequest runs after a request has been served
+func (webServer *WebServer) observeRequest(rw *responseWriter, req *http.Request, start time.Time) {
+	if webServer.metrics != nil {
+		webServer.recordRequest(rw, req, start)
+	}
+	if webServer.usage != nil {
+		webServer.usage.record(req, rw.Status(), rw.written, start)
 	}
+	if webServer.anomalies != nil {
+		webServer.detectAnomaly(req, rw.Status(), start)
+	}
+}
 
-	switch strings.ToUpper(req.Method) {
+func (webServer *WebServer) methodMux(method string) *http.ServeMux {
+	switch method {
 	case http.MethodGet:
-		webServer.getMux.ServeHTTP(rw, req)
+		return webServer.getMux
 	case http.MethodHead:
-		webServer.headMux.ServeHTTP(rw, req)
+		return webServer.headMux
 	case http.MethodPost:
-		webServer.postMux.ServeHTTP(rw, req)
+		return webServer.postMux
 	case http.MethodPut:
-		webServer.putMux.ServeHTTP(rw, req)
+		return webServer.putMux
 	case http.MethodPatch:
-		webServer.patchMux.ServeHTTP(rw, req)
+		return webServer.patchMux
 	case http.MethodDelete:
-		webServer.deleteMux.ServeHTTP(rw, req)
+		return webServer.deleteMux
 	case http.MethodConnect:
-		webServer.connectMux.ServeHTTP(rw, req)
+		return webServer.connectMux
 	case http.MethodOptions:
-		webServer.optionsMux.ServeHTTP(rw, req)
+		return webServer.optionsMux
 	case http.MethodTrace:
-		webServer.traceMux.ServeHTTP(rw, req)
+		return webServer.traceMux
 	default:
-		webServer.customMux.ServeHTTP(rw, req)
+		return webServer.customMux
+	}
+}
+
+func (webServer *WebServer) mainHandler(rw http.ResponseWriter, req *http.Request) {
+	settings := webServer.Settings()
+	clientIP := resolveClientIP(req, webServer.trustedProxies(settings))
+	webServer.logger.Println(clientIP, req.Method, req.URL, req.ContentLength)
+	defer webServer.recoverPanics(rw, req)
+
+	sized := settings.MaxResponseSize > 0 || settings.LogResponseSizes
+	if webServer.metrics != nil || webServer.usage != nil || webServer.anomalies != nil || sized {
+		observed := newResponseWriter(rw)
+		limitResponse(observed, settings)
+		rw = observed
+		start := time.Now()
+		defer func() {
+			webServer.observeRequest(observed, req, start)
+			webServer.finishResponse(observed, req, clientIP, settings)
+		}()
+	}
+
+	if webServer.mirror != nil && webServer.mirror.sample() {
+		var finish func() error
+		rw, req, finish = webServer.mirror.capture(rw, req)
+		defer func() {
+			err := finish()
+			if err != nil {
+				webServer.logger.Println("Mirror: " + err.Error())
+			}
+		}()
+	}
+
+	req = withRequestState(req, webServer.logger)
+	defer webServer.runCleanups(req)
+	Set(req, serverKey, webServer)
+	Set(req, clientIPKey, clientIP)
+	if webServer.geoIP != nil {
+		webServer.lookupGeo(req, clientIP)
+	}
+	if webServer.metrics != nil {
+		Set(req, metricsServerKey, webServer)
+	}
+	if webServer.hardening != nil {
+		var ok bool
+		if rw, ok = webServer.harden(rw, req); !ok {
+			return
+		}
+	}
+	if !webServer.runMiddleware(rw, req) {
+		return
+	}
+
+	target := webServer.virtualHost(req)
+	if target != webServer && !target.runMiddleware(rw, req) {
+		return
+	}
+	if allow, ok := target.methodNotAllowed(req); ok {
+		rw.Header().Set("Allow", strings.Join(allow, ", "))
+		target.writeError(rw, req, http.StatusMethodNotAllowed)
+		webServer.logger.Println("Method Not Allowed: 405: " + req.Method + " " + req.URL.Path)
+		return
+	}
+	method := strings.ToUpper(req.Method)
+	if method != http.MethodGet && method != http.MethodHead && method != http.MethodOptions && !target.hasRoute(method, req) {
+		webServer.emit(RouteNotFound{Method: req.Method, Path: req.URL.Path})
+		if _, ok := target.errorPages[http.StatusNotFound]; ok {
+			target.writeError(rw, req, http.StatusNotFound)
+			webServer.logger.Println("Not Found: 404: " + req.Method + " " + req.URL.Path)
+			return
+		}
+	}
+	if method == http.MethodHead && target.headFromGet(req) {
+		head := newHeadWriter(rw)
+		target.getMux.ServeHTTP(head, req)
+		head.finish()
+		return
 	}
+	target.methodMux(method).ServeHTTP(rw, req)
 }
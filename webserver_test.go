@@ -1,31 +1,57 @@
-package webserver
+package webserver_test
 
 import (
+	"context"
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"testing"
+
+	"github.com/Nikkolix/webserver"
+	"github.com/Nikkolix/webserver/settingstest"
 )
 
 func TestNewWebServer(t *testing.T) {
-	settings := Settings{
-		UseHttps:         true,
-		UseHttpRedirect:  true,
-		Hostname:         "localhost",
-		HttpPort:         "80",
-		HttpsPort:        "443",
-		Root:             "root",
-		Logger:           nil,
-		CertFile:         "./ssl/certificate.crt",
-		KeyFile:          "./ssl/privatekey.key",
-		FallbackRedirect: "/index",
+	settings := settingstest.New(t, settingstest.Fixture{
+		Files: map[string]string{"index.html": "index"},
+		TLS:   true,
+	})
+	settings.FallbackRedirect = "/index.html"
+	webServer := webserver.NewWebServer(*settings)
+
+	type greeting struct {
+		Name string
 	}
-	webServer := NewWebServer(settings)
+	webserver.NewURLBodyHandler(webServer, webserver.HTTPMethodPost, "/hello", func(rw http.ResponseWriter, req *http.Request, body greeting) {
+		_, _ = rw.Write([]byte("hello " + body.Name))
+	})
 
-	webServer.NewHandlerURLBody(http.MethodGet, "/index", func(rw http.ResponseWriter, req *http.Request, values map[string]any) {
+	err := webServer.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = webServer.Shutdown(context.Background()) }()
 
-	})
+	client := settingstest.Client(t, settings)
+	base := "https://" + webServer.ListenAddr()
+	resp, err := client.Get(base + "/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "index" {
+		t.Errorf("GET /index.html = %d %q, want 200 %q", resp.StatusCode, body, "index")
+	}
 
-	err := webServer.Run()
+	resp, err = client.Post(base+"/hello", "application/x-www-form-urlencoded", strings.NewReader(url.Values{"Name": {"alice"}}.Encode()))
 	if err != nil {
-		panic(err)
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "hello alice" {
+		t.Errorf("POST /hello = %d %q, want 200 %q", resp.StatusCode, body, "hello alice")
 	}
 }
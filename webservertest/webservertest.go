@@ -0,0 +1,85 @@
+package webservertest
+
+import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/Nikkolix/webserver"
+)
+
+// TestServer serves a WebServer with httptest on a loopback listener
+type TestServer struct {
+	*httptest.Server
+	WebServer *webserver.WebServer
+}
+
+// NewTestServer starts serving ws on a loopback listener with a random port, the url is in URL.
+// Close it at the end of the test.
+func NewTestServer(ws *webserver.WebServer) *TestServer {
+	return &TestServer{Server: httptest.NewServer(ws.Handler()), WebServer: ws}
+}
+
+// Do serves req in memory with the middleware and routing pipeline of ws, without binding a socket.
+// req is a server request, e.g. from httptest.NewRequest.
+func Do(ws *webserver.WebServer, req *http.Request) *http.Response {
+	rec := httptest.NewRecorder()
+	ws.Handler().ServeHTTP(rec, req)
+	return rec.Result()
+}
+
+func Get(ws *webserver.WebServer, target string) *http.Response {
+	return Do(ws, httptest.NewRequest(http.MethodGet, target, nil))
+}
+
+func Post(ws *webserver.WebServer, target string, contentType string, body io.Reader) *http.Response {
+	req := httptest.NewRequest(http.MethodPost, target, body)
+	req.Header.Set("Content-Type", contentType)
+	return Do(ws, req)
+}
+
+// Body reads and closes the body of resp
+func Body(resp *http.Response) string {
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+// Transport is a http.RoundTripper serving client requests in memory with WebServer
+type Transport struct {
+	WebServer *webserver.WebServer
+	// RemoteAddr is the peer address the server sees, empty uses "192.0.2.1:1234" like httptest.NewRequest
+	RemoteAddr string
+}
+
+func (transport *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	serverReq := req.Clone(req.Context())
+	serverReq.RequestURI = req.URL.RequestURI()
+	serverReq.RemoteAddr = transport.RemoteAddr
+	if serverReq.RemoteAddr == "" {
+		serverReq.RemoteAddr = "192.0.2.1:1234"
+	}
+	if serverReq.Host == "" {
+		serverReq.Host = req.URL.Host
+	}
+	if serverReq.Body == nil {
+		serverReq.Body = http.NoBody
+	}
+	serverReq.TLS = nil
+	if strings.EqualFold(req.URL.Scheme, "https") {
+		serverReq.TLS = httptest.NewRequest(http.MethodGet, "https://localhost/", nil).TLS
+	}
+
+	resp := Do(transport.WebServer, serverReq)
+	resp.Request = req
+	return resp, nil
+}
+
+// NewClient returns a client serving its requests in memory with ws, with a cookie jar and following redirects,
+// for requests to any host like "http://example.com/login"
+func NewClient(ws *webserver.WebServer) *http.Client {
+	jar, _ := cookiejar.New(nil)
+	return &http.Client{Transport: &Transport{WebServer: ws}, Jar: jar}
+}
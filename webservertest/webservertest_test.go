@@ -0,0 +1,67 @@
+package webservertest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Nikkolix/webserver"
+)
+
+func newWebServer() *webserver.WebServer {
+	ws := webserver.NewWebServer(*webserver.NewSettings())
+	ws.NewMiddleware(func(rw http.ResponseWriter, req *http.Request) bool {
+		rw.Header().Set("X-Middleware", "ran")
+		return true
+	})
+	ws.NewHandleFunc(webserver.HTTPMethodGet, "/hello", func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("hello"))
+	})
+	ws.NewHandleFunc(webserver.HTTPMethodPost, "/login", func(rw http.ResponseWriter, req *http.Request) {
+		http.SetCookie(rw, &http.Cookie{Name: "user", Value: "alice", Path: "/"})
+		http.Redirect(rw, req, "/me", http.StatusSeeOther)
+	})
+	ws.NewHandleFunc(webserver.HTTPMethodGet, "/me", func(rw http.ResponseWriter, req *http.Request) {
+		cookie, err := req.Cookie("user")
+		if err != nil {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = rw.Write([]byte(cookie.Value))
+	})
+	return ws
+}
+
+func TestDo(t *testing.T) {
+	resp := Get(newWebServer(), "/hello")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Middleware") != "ran" {
+		t.Errorf("Get = %d, middleware %q", resp.StatusCode, resp.Header.Get("X-Middleware"))
+	}
+	if body := Body(resp); body != "hello" {
+		t.Errorf("body = %q, want %q", body, "hello")
+	}
+}
+
+func TestTestServer(t *testing.T) {
+	server := NewTestServer(newWebServer())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := Body(resp); body != "hello" || resp.Header.Get("X-Middleware") != "ran" {
+		t.Errorf("body = %q, middleware %q", body, resp.Header.Get("X-Middleware"))
+	}
+}
+
+func TestClient(t *testing.T) {
+	client := NewClient(newWebServer())
+	resp, err := client.Post("http://example.com/login", "text/plain", strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := Body(resp); resp.StatusCode != http.StatusOK || body != "alice" {
+		t.Errorf("login redirect to /me = %d %q, want 200 %q", resp.StatusCode, body, "alice")
+	}
+}
@@ -0,0 +1,131 @@
+package webserver
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+type WorkerPoolOptions struct {
+	// Workers is the number of requests of the pool handled at the same time
+	Workers int
+	// QueueSize is the number of requests waiting for a worker, further requests are answered with 503 Service Unavailable
+	QueueSize int
+	// QueueTimeout is how long a request waits for a worker before it is answered with 503, 0 waits as long as the client
+	QueueTimeout time.Duration
+}
+
+func NewWorkerPoolOptions(workers int) *WorkerPoolOptions {
+	return &WorkerPoolOptions{
+		Workers:      workers,
+		QueueSize:    workers * 4,
+		QueueTimeout: 10 * time.Second,
+	}
+}
+
+// WorkerPoolStats is a snapshot of a worker pool, Rejected counts the requests answered with 503 since it was created
+type WorkerPoolStats struct {
+	Active   int
+	Queued   int
+	Rejected int64
+}
+
+type workerPool struct {
+	name    string
+	options WorkerPoolOptions
+	workers chan struct{}
+
+	queued   atomic.Int64
+	rejected atomic.Int64
+}
+
+// NewWorkerPool creates the pool name for WithWorkerPool, e.g. a small pool for cpu heavy image transforms so they
+// cannot take the capacity latency sensitive api routes need. It panics if Workers is below 1 or name already exists.
+func (webServer *WebServer) NewWorkerPool(name string, options WorkerPoolOptions) {
+	if options.Workers < 1 {
+		panic("worker pool " + name + " needs at least one worker")
+	}
+	if _, ok := webServer.workerPools[name]; ok {
+		panic("worker pool " + name + " already exists")
+	}
+	if webServer.workerPools == nil {
+		webServer.workerPools = map[string]*workerPool{}
+	}
+	webServer.workerPools[name] = &workerPool{name: name, options: options, workers: make(chan struct{}, options.Workers)}
+}
+
+// WithWorkerPool runs handler once a worker of the pool name is free, e.g.
+// webServer.NewHandler(HTTPMethodPost, "/images/resize", webServer.WithWorkerPool("images", handler)).
+// Requests finding the queue full or waiting longer than QueueTimeout are answered with 503 Service Unavailable and
+// Retry-After. It panics if there is no pool name.
+func (webServer *WebServer) WithWorkerPool(name string, handler http.Handler) http.Handler {
+	pool, ok := webServer.workerPools[name]
+	if !ok {
+		panic("worker pool " + name + " does not exist")
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if !pool.acquire(webServer, rw, req) {
+			return
+		}
+		defer func() { <-pool.workers }()
+		handler.ServeHTTP(rw, req)
+	})
+}
+
+// WithWorkerPoolFunc is WithWorkerPool for handler functions
+func (webServer *WebServer) WithWorkerPoolFunc(name string, handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return webServer.WithWorkerPool(name, http.HandlerFunc(handler)).ServeHTTP
+}
+
+// WorkerPoolStats returns the current load of the pool name, false if there is none
+func (webServer *WebServer) WorkerPoolStats(name string) (WorkerPoolStats, bool) {
+	pool, ok := webServer.workerPools[name]
+	if !ok {
+		return WorkerPoolStats{}, false
+	}
+	return WorkerPoolStats{
+		Active:   len(pool.workers),
+		Queued:   int(pool.queued.Load()),
+		Rejected: pool.rejected.Load(),
+	}, true
+}
+
+// acquire takes a worker for req, false if the request was answered because none became free or the client is gone
+func (pool *workerPool) acquire(webServer *WebServer, rw http.ResponseWriter, req *http.Request) bool {
+	select {
+	case pool.workers <- struct{}{}:
+		return true
+	default:
+	}
+
+	if pool.queued.Add(1) > int64(pool.options.QueueSize) {
+		pool.queued.Add(-1)
+		pool.reject(webServer, rw, req, "queue full")
+		return false
+	}
+	defer pool.queued.Add(-1)
+
+	var timeout <-chan time.Time
+	if pool.options.QueueTimeout > 0 {
+		timer := time.NewTimer(pool.options.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case pool.workers <- struct{}{}:
+		return true
+	case <-timeout:
+		pool.reject(webServer, rw, req, "queue timeout")
+		return false
+	case <-req.Context().Done():
+		webServer.logger.Println("Worker Pool: " + pool.name + ": client gone while queued (" + req.URL.Path + ")")
+		return false
+	}
+}
+
+func (pool *workerPool) reject(webServer *WebServer, rw http.ResponseWriter, req *http.Request, reason string) {
+	pool.rejected.Add(1)
+	rw.Header().Set("Retry-After", "1")
+	webServer.writeError(rw, req, http.StatusServiceUnavailable)
+	webServer.logger.Println("Worker Pool: 503: " + pool.name + " " + reason + " (" + req.URL.Path + ")")
+}
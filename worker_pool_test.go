@@ -0,0 +1,87 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool(t *testing.T) {
+	webServer := NewWebServer(*NewSettings())
+	images := NewWorkerPoolOptions(1)
+	images.QueueSize = 1
+	images.QueueTimeout = 0
+	webServer.NewWorkerPool("images", *images)
+	slow := NewWorkerPoolOptions(1)
+	slow.QueueTimeout = 20 * time.Millisecond
+	webServer.NewWorkerPool("slow", *slow)
+
+	release := make(chan struct{})
+	blocking := func(rw http.ResponseWriter, req *http.Request) {
+		<-release
+		_, _ = rw.Write([]byte("done"))
+	}
+	webServer.NewHandleFunc(HTTPMethodGet, "/images/resize", webServer.WithWorkerPoolFunc("images", blocking))
+	webServer.NewHandleFunc(HTTPMethodGet, "/slow", webServer.WithWorkerPoolFunc("slow", blocking))
+	webServer.NewHandleFunc(HTTPMethodGet, "/api/status", func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("ok"))
+	})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		webServer.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	waitFor := func(name string, check func(WorkerPoolStats) bool) {
+		deadline := time.Now().Add(time.Second)
+		for {
+			stats, _ := webServer.WorkerPoolStats(name)
+			if check(stats) {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s stats = %+v", name, stats)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	results := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			results <- serve("/images/resize").Code
+		}()
+	}
+	waitFor("images", func(stats WorkerPoolStats) bool { return stats.Active == 1 && stats.Queued == 1 })
+
+	rec := serve("/images/resize")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("full pool = %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := serve("/api/status"); rec.Code != http.StatusOK {
+		t.Errorf("route outside the pool = %d", rec.Code)
+	}
+
+	go func() {
+		results <- serve("/slow").Code
+	}()
+	waitFor("slow", func(stats WorkerPoolStats) bool { return stats.Active == 1 })
+	if rec := serve("/slow"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("queue timeout = %d", rec.Code)
+	}
+
+	close(release)
+	for i := 0; i < 3; i++ {
+		if code := <-results; code != http.StatusOK {
+			t.Errorf("pooled request = %d", code)
+		}
+	}
+	stats, ok := webServer.WorkerPoolStats("images")
+	if !ok || stats != (WorkerPoolStats{Rejected: 1}) {
+		t.Errorf("images stats = %+v", stats)
+	}
+	if _, ok := webServer.WorkerPoolStats("missing"); ok {
+		t.Error("stats of a missing pool")
+	}
+}